@@ -1,24 +1,12 @@
 package main
 
+// Backends register themselves with the driver package when linked in.
+// Out-of-tree backends are added to the binary the same way.
 import (
-	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
-	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/consul"
-	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/etcd"
-	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
-	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/redis"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/consul"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/etcd"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/file"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/postgres"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/redis"
 )
-
-func buildBackendFromConfig(cfg *registry.Config) (registry.Backend, error) {
-	switch cfg.Backend.Type {
-	case registry.RedisRegistryBackend:
-		return redis.New(cfg.Backend.Redis)
-	case registry.ConsulRegistryBackend:
-		return consul.New(cfg.Backend.Consul)
-	case registry.EtcdRegistryBackend:
-		return etcd.New(cfg.Backend.Etcd)
-	case registry.MemoryRegistryBackend:
-		return memory.New(cfg.Backend.Memory)
-	default:
-		return nil, ErrUnhandledBackend
-	}
-}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+	"github.com/urfave/cli/v3"
+)
+
+// backendFlagSet holds the command line flags of a built-in backend and
+// copies them into its section of the backend config, allocating the
+// section if it is nil. Backends registered from outside this module bring
+// their own through Driver.Flags and Driver.Configure.
+type backendFlagSet struct {
+	flags     []cli.Flag
+	configure func(flags driver.FlagValues, cfg *registry.BackendConfig)
+}
+
+var backendFlagSets = map[registry.RegistryBackend]backendFlagSet{
+	registry.ConsulRegistryBackend:   {flags: consulFlags, configure: configureConsul},
+	registry.EtcdRegistryBackend:     {flags: etcdFlags, configure: configureEtcd},
+	registry.FileRegistryBackend:     {flags: fileFlags, configure: configureFile},
+	registry.MemoryRegistryBackend:   {flags: memoryFlags, configure: configureMemory},
+	registry.PostgresRegistryBackend: {flags: postgresFlags, configure: configurePostgres},
+	registry.RedisRegistryBackend:    {flags: redisFlags, configure: configureRedis},
+}
+
+// backendFlags returns the flags of every registered backend, ordered by
+// backend name. They are added to the registry command whether or not the
+// backend is selected.
+func backendFlags() []cli.Flag {
+	var flags []cli.Flag
+	for _, name := range driver.Names() {
+		if set, ok := backendFlagSets[name]; ok {
+			flags = append(flags, set.flags...)
+			continue
+		}
+		if d, _ := driver.Lookup(name); d.Flags != nil {
+			flags = append(flags, d.Flags...)
+		}
+	}
+
+	return flags
+}
+
+// backendConfigure returns the function copying d's flags into the backend
+// config. It does nothing for a driver without flags.
+func backendConfigure(d driver.Driver) func(flags driver.FlagValues, cfg *registry.BackendConfig) error {
+	if set, ok := backendFlagSets[d.Name]; ok {
+		return func(flags driver.FlagValues, cfg *registry.BackendConfig) error {
+			set.configure(flags, cfg)
+			return nil
+		}
+	}
+	if d.Configure != nil {
+		return d.Configure
+	}
+
+	return func(driver.FlagValues, *registry.BackendConfig) error { return nil }
+}
+
+var consulFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  ConsulAddrFlag,
+		Usage: "consul agent http api host:port",
+		Value: "localhost:8500",
+	},
+	&cli.StringFlag{
+		Name:  ConsulTokenFlag,
+		Usage: "consul acl token (empty uses the agent's default token)",
+	},
+	&cli.StringFlag{
+		Name:  ConsulDatacenterFlag,
+		Usage: "consul datacenter holding the registry (defaults to the agent's datacenter)",
+	},
+	&cli.StringFlag{
+		Name:  ConsulPrefixFlag,
+		Usage: "prefix for every consul kv key",
+		Value: "aero-arc-registry/",
+	},
+	&cli.BoolFlag{
+		Name:  ConsulTLSFlag,
+		Usage: "connect to consul over https",
+	},
+	&cli.StringFlag{
+		Name:  ConsulTLSCAFlag,
+		Usage: "path to a ca bundle used to verify consul (defaults to system roots)",
+	},
+	&cli.StringFlag{
+		Name:  ConsulTLSCertFlag,
+		Usage: "path to the client certificate for consul mutual tls",
+	},
+	&cli.StringFlag{
+		Name:  ConsulTLSKeyFlag,
+		Usage: "path to the client key for consul mutual tls",
+	},
+	&cli.StringFlag{
+		Name:  ConsulTLSServerFlag,
+		Usage: "server name to verify on the consul certificate (defaults to the address host)",
+	},
+	&cli.BoolFlag{
+		Name:  ConsulTLSInsecureFlag,
+		Usage: "skip consul certificate verification (development only)",
+	},
+	&cli.BoolFlag{
+		Name:  ConsulCatalogFlag,
+		Usage: "register live relays as consul catalog services with a heartbeat-driven ttl check",
+	},
+	&cli.StringFlag{
+		Name:  ConsulCatalogAddrFlag,
+		Usage: "consul agent host:port every replica registers relay services through (defaults to --consul-addr)",
+	},
+	&cli.StringFlag{
+		Name:  ConsulCatalogServiceFlag,
+		Usage: "consul service name relays register under",
+		Value: "aero-arc-relay",
+	},
+	&cli.StringSliceFlag{
+		Name:  ConsulCatalogTagsFlag,
+		Usage: "tags attached to every relay service instance",
+	},
+	&cli.DurationFlag{
+		Name:  ConsulCatalogDeregisterFlag,
+		Usage: "let consul remove relay services critical for this long",
+		Value: 5 * time.Minute,
+	},
+}
+
+func configureConsul(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.Consul == nil {
+		cfg.Consul = &registry.ConsulConfig{}
+	}
+	c := cfg.Consul
+
+	flags.String(ConsulAddrFlag, &c.Address)
+	flags.String(ConsulTokenFlag, &c.Token)
+	flags.String(ConsulDatacenterFlag, &c.Datacenter)
+	flags.String(ConsulPrefixFlag, &c.Prefix)
+
+	flags.Bool(ConsulTLSFlag, &c.TLS.Enabled)
+	flags.String(ConsulTLSCAFlag, &c.TLS.CAPath)
+	flags.String(ConsulTLSCertFlag, &c.TLS.CertPath)
+	flags.String(ConsulTLSKeyFlag, &c.TLS.KeyPath)
+	flags.String(ConsulTLSServerFlag, &c.TLS.ServerName)
+	flags.Bool(ConsulTLSInsecureFlag, &c.TLS.InsecureSkipVerify)
+
+	flags.Bool(ConsulCatalogFlag, &c.Catalog.Enabled)
+	flags.String(ConsulCatalogAddrFlag, &c.Catalog.Address)
+	flags.String(ConsulCatalogServiceFlag, &c.Catalog.ServiceName)
+	flags.StringSlice(ConsulCatalogTagsFlag, &c.Catalog.Tags)
+	flags.Duration(ConsulCatalogDeregisterFlag, &c.Catalog.DeregisterAfter)
+}
+
+var etcdFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:  EtcdEndpointsFlag,
+		Usage: "etcd client host:port endpoints, tried in order",
+		Value: []string{"localhost:2379"},
+	},
+	&cli.DurationFlag{
+		Name:  EtcdDialTimeoutFlag,
+		Usage: "timeout for connecting to an etcd endpoint",
+		Value: time.Second * 5,
+	},
+	&cli.StringFlag{
+		Name:  EtcdUsernameFlag,
+		Usage: "etcd username (empty disables authentication)",
+	},
+	&cli.StringFlag{
+		Name:  EtcdPasswordFlag,
+		Usage: "etcd password",
+	},
+	&cli.StringFlag{
+		Name:  EtcdPrefixFlag,
+		Usage: "prefix for every etcd key",
+		Value: "/aero-arc-registry/",
+	},
+	&cli.BoolFlag{
+		Name:  EtcdTLSFlag,
+		Usage: "connect to etcd over tls",
+	},
+	&cli.StringFlag{
+		Name:  EtcdTLSCAFlag,
+		Usage: "path to a ca bundle used to verify etcd (defaults to system roots)",
+	},
+	&cli.StringFlag{
+		Name:  EtcdTLSCertFlag,
+		Usage: "path to the client certificate for etcd mutual tls",
+	},
+	&cli.StringFlag{
+		Name:  EtcdTLSKeyFlag,
+		Usage: "path to the client key for etcd mutual tls",
+	},
+	&cli.StringFlag{
+		Name:  EtcdTLSServerFlag,
+		Usage: "server name to verify on the etcd certificate (defaults to the endpoint host)",
+	},
+	&cli.BoolFlag{
+		Name:  EtcdTLSInsecureFlag,
+		Usage: "skip etcd certificate verification (development only)",
+	},
+}
+
+func configureEtcd(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.Etcd == nil {
+		cfg.Etcd = &registry.EtcdConfig{}
+	}
+	c := cfg.Etcd
+
+	flags.StringSlice(EtcdEndpointsFlag, &c.Endpoints)
+	flags.Duration(EtcdDialTimeoutFlag, &c.DialTimeout)
+	flags.String(EtcdUsernameFlag, &c.Username)
+	flags.String(EtcdPasswordFlag, &c.Password)
+	flags.String(EtcdPrefixFlag, &c.Prefix)
+
+	flags.Bool(EtcdTLSFlag, &c.TLS.Enabled)
+	flags.String(EtcdTLSCAFlag, &c.TLS.CAPath)
+	flags.String(EtcdTLSCertFlag, &c.TLS.CertPath)
+	flags.String(EtcdTLSKeyFlag, &c.TLS.KeyPath)
+	flags.String(EtcdTLSServerFlag, &c.TLS.ServerName)
+	flags.Bool(EtcdTLSInsecureFlag, &c.TLS.InsecureSkipVerify)
+}
+
+var fileFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  FilePathFlag,
+		Usage: "registry file used by the file backend",
+		Value: "aero-arc-registry.db",
+	},
+	&cli.DurationFlag{
+		Name:  FileCompactIntervalFlag,
+		Usage: "interval between compactions of the registry file",
+		Value: time.Minute * 5,
+	},
+}
+
+func configureFile(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.File == nil {
+		cfg.File = &registry.FileConfig{}
+	}
+	c := cfg.File
+
+	flags.String(FilePathFlag, &c.Path)
+	flags.Duration(FileCompactIntervalFlag, &c.CompactInterval)
+}
+
+var memoryFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  MemoryMaxRelaysFlag,
+		Usage: "maximum relays held by the memory backend (0 is unlimited)",
+		Value: 0,
+	},
+	&cli.IntFlag{
+		Name:  MemoryMaxAgentsFlag,
+		Usage: "maximum agents held by the memory backend (0 is unlimited)",
+		Value: 0,
+	},
+}
+
+func configureMemory(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.Memory == nil {
+		cfg.Memory = &registry.MemoryConfig{}
+	}
+	c := cfg.Memory
+
+	flags.Int(MemoryMaxRelaysFlag, &c.MaxRelays)
+	flags.Int(MemoryMaxAgentsFlag, &c.MaxAgents)
+}
+
+var postgresFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  PostgresAddrFlag,
+		Usage: "postgres server address (host:port)",
+		Value: "localhost:5432",
+	},
+	&cli.StringFlag{
+		Name:  PostgresDatabaseFlag,
+		Usage: "postgres database holding the registry tables",
+		Value: "aero_registry",
+	},
+	&cli.StringFlag{
+		Name:  PostgresUserFlag,
+		Usage: "postgres user",
+		Value: "aero_registry",
+	},
+	&cli.StringFlag{
+		Name:  PostgresPasswordFlag,
+		Usage: "postgres password",
+	},
+	&cli.IntFlag{
+		Name:  PostgresMaxConnsFlag,
+		Usage: "maximum pooled postgres connections",
+		Value: 10,
+	},
+	&cli.DurationFlag{
+		Name:  PostgresDialTimeoutFlag,
+		Usage: "timeout for connecting and authenticating to postgres",
+		Value: time.Second * 5,
+	},
+	&cli.BoolFlag{
+		Name:  PostgresTLSFlag,
+		Usage: "require tls for postgres connections",
+	},
+	&cli.StringFlag{
+		Name:  PostgresTLSCAFlag,
+		Usage: "path to the ca bundle used to verify postgres",
+	},
+	&cli.StringFlag{
+		Name:  PostgresTLSCertFlag,
+		Usage: "path to the client certificate for postgres certificate authentication",
+	},
+	&cli.StringFlag{
+		Name:  PostgresTLSKeyFlag,
+		Usage: "path to the client key for postgres certificate authentication",
+	},
+	&cli.StringFlag{
+		Name:  PostgresTLSServerFlag,
+		Usage: "server name to verify on the postgres certificate (defaults to the address host)",
+	},
+	&cli.BoolFlag{
+		Name:  PostgresTLSInsecureFlag,
+		Usage: "skip postgres certificate verification (development only)",
+	},
+}
+
+func configurePostgres(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.Postgres == nil {
+		cfg.Postgres = &registry.PostgresConfig{}
+	}
+	c := cfg.Postgres
+
+	flags.String(PostgresAddrFlag, &c.Address)
+	flags.String(PostgresDatabaseFlag, &c.Database)
+	flags.String(PostgresUserFlag, &c.User)
+	flags.String(PostgresPasswordFlag, &c.Password)
+	flags.Int(PostgresMaxConnsFlag, &c.MaxConns)
+	flags.Duration(PostgresDialTimeoutFlag, &c.DialTimeout)
+
+	flags.Bool(PostgresTLSFlag, &c.TLS.Enabled)
+	flags.String(PostgresTLSCAFlag, &c.TLS.CAPath)
+	flags.String(PostgresTLSCertFlag, &c.TLS.CertPath)
+	flags.String(PostgresTLSKeyFlag, &c.TLS.KeyPath)
+	flags.String(PostgresTLSServerFlag, &c.TLS.ServerName)
+	flags.Bool(PostgresTLSInsecureFlag, &c.TLS.InsecureSkipVerify)
+}
+
+var redisFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  RedisAddrFlag,
+		Usage: "redis instance address",
+		Value: "localhost",
+	},
+	&cli.IntFlag{
+		Name:  RedisPortFlag,
+		Usage: "redis instance port",
+		Value: 6379,
+	},
+	&cli.StringFlag{
+		Name:  RedisUsernameFlag,
+		Usage: "redis username",
+		Value: "default",
+	},
+	&cli.StringFlag{
+		Name:  RedisPasswordFlag,
+		Usage: "redis password",
+		Value: "",
+	},
+	&cli.IntFlag{
+		Name:  RedisDbFlag,
+		Usage: "specified redis db to use",
+		Value: 0,
+	},
+	&cli.IntFlag{
+		Name:  RedisPoolSizeFlag,
+		Usage: "maximum number of open redis connections",
+		Value: 10,
+	},
+	&cli.IntFlag{
+		Name:  RedisMinIdleFlag,
+		Usage: "number of idle redis connections kept warm",
+		Value: 0,
+	},
+	&cli.IntFlag{
+		Name:  RedisMaxIdleFlag,
+		Usage: "maximum idle redis connections retained (0 uses the pool size)",
+		Value: 0,
+	},
+	&cli.DurationFlag{
+		Name:  RedisHealthCheckFlag,
+		Usage: "idle time after which a redis connection is pinged before reuse",
+		Value: time.Second * 30,
+	},
+	&cli.BoolFlag{
+		Name:  RedisTLSFlag,
+		Usage: "connect to redis over tls",
+	},
+	&cli.StringFlag{
+		Name:  RedisTLSCAFlag,
+		Usage: "path to the ca bundle used to verify redis (defaults to system roots)",
+	},
+	&cli.StringFlag{
+		Name:  RedisTLSCertFlag,
+		Usage: "path to the client certificate presented to redis",
+	},
+	&cli.StringFlag{
+		Name:  RedisTLSKeyFlag,
+		Usage: "path to the client private key presented to redis",
+	},
+	&cli.StringFlag{
+		Name:  RedisTLSServerFlag,
+		Usage: "server name to verify on the redis certificate (defaults to redis-addr)",
+	},
+	&cli.BoolFlag{
+		Name:  RedisTLSInsecureFlag,
+		Usage: "skip redis certificate verification (development only)",
+	},
+	&cli.StringFlag{
+		Name:  RedisSentinelMasterFlag,
+		Usage: "sentinel master name; enables sentinel discovery instead of redis-addr",
+	},
+	&cli.StringSliceFlag{
+		Name:  RedisSentinelAddrsFlag,
+		Usage: "sentinel host:port addresses, tried in order",
+	},
+	&cli.StringFlag{
+		Name:  RedisSentinelUserFlag,
+		Usage: "username used to authenticate to sentinels",
+	},
+	&cli.StringFlag{
+		Name:  RedisSentinelPasswordFlag,
+		Usage: "password used to authenticate to sentinels",
+	},
+	&cli.StringFlag{
+		Name:  RedisNamespaceFlag,
+		Usage: "prefix for every redis key, so several registries can share one redis",
+	},
+	&cli.StringSliceFlag{
+		Name:  RedisClusterAddrsFlag,
+		Usage: "redis cluster seed host:port addresses; enables cluster mode instead of redis-addr",
+	},
+}
+
+func configureRedis(flags driver.FlagValues, cfg *registry.BackendConfig) {
+	if cfg.Redis == nil {
+		cfg.Redis = &registry.RedisConfig{}
+	}
+	c := cfg.Redis
+
+	flags.String(RedisAddrFlag, &c.Address)
+	flags.Int(RedisPortFlag, &c.Port)
+	flags.String(RedisUsernameFlag, &c.Username)
+	flags.String(RedisPasswordFlag, &c.Password)
+	flags.Int(RedisDbFlag, &c.DB)
+
+	flags.String(RedisNamespaceFlag, &c.Namespace)
+
+	flags.Int(RedisPoolSizeFlag, &c.PoolSize)
+	flags.Int(RedisMinIdleFlag, &c.MinIdleConns)
+	flags.Int(RedisMaxIdleFlag, &c.MaxIdleConns)
+	flags.Duration(RedisHealthCheckFlag, &c.HealthCheckAfter)
+
+	flags.Bool(RedisTLSFlag, &c.TLS.Enabled)
+	flags.String(RedisTLSCAFlag, &c.TLS.CAPath)
+	flags.String(RedisTLSCertFlag, &c.TLS.CertPath)
+	flags.String(RedisTLSKeyFlag, &c.TLS.KeyPath)
+	flags.String(RedisTLSServerFlag, &c.TLS.ServerName)
+	flags.Bool(RedisTLSInsecureFlag, &c.TLS.InsecureSkipVerify)
+
+	flags.String(RedisSentinelMasterFlag, &c.Sentinel.MasterName)
+	flags.StringSlice(RedisSentinelAddrsFlag, &c.Sentinel.Addrs)
+	flags.String(RedisSentinelUserFlag, &c.Sentinel.Username)
+	flags.String(RedisSentinelPasswordFlag, &c.Sentinel.Password)
+
+	flags.StringSlice(RedisClusterAddrsFlag, &c.Cluster.Addrs)
+}
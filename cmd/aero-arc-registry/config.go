@@ -1,52 +1,149 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
 	"github.com/urfave/cli/v3"
 )
 
+// envPrefix prefixes the environment variable backing every flag, e.g.
+// AERO_REGISTRY_REDIS_ADDR for --redis-addr.
+const envPrefix = "AERO_REGISTRY_"
+
+// buildConfigFromCLI assembles the registry config with loadConfigFromCLI,
+// then validates it.
 func buildConfigFromCLI(cmd *cli.Command) (*registry.Config, error) {
-	backendType, err := registry.ParseRegistryBackend(cmd.String(BackendFlag))
+	registryConfig, err := loadConfigFromCLI(cmd)
 	if err != nil {
 		return nil, err
 	}
 
-	registryConfig := &registry.Config{
-		Backend: registry.BackendConfig{
-			Type: backendType,
-		},
-		GRPC: registry.GRPCConfig{
-			ListenAddress: cmd.String(GRPCListenAddrFlag),
-			ListenPort:    cmd.Int(GRPCListenPortFlag),
-			TLS: registry.TLSConfig{
-				Enabled:  true,
-				CertPath: cmd.String(TLSCertPathFlag),
-				KeyPath:  cmd.String(TLSKeyPathFlag),
-			},
-		},
-		TTL: registry.TTLConfig{
-			Relay: cmd.Duration(RelayTTLFlag),
-			Agent: cmd.Duration(AgentTTLFlag),
-		},
-	}
-
-	switch registryConfig.Backend.Type {
-	case registry.RedisRegistryBackend:
-		registryConfig.Backend.Redis = &registry.RedisConfig{
-			Address:  cmd.String(RedisAddrFlag),
-			Port:     cmd.Int(RedisPortFlag),
-			Username: cmd.String(RedisUsernameFlag),
-			Password: cmd.String(RedisPasswordFlag),
-			DB:       cmd.Int(RedisDBFlag),
+	if err := driver.Validate(registryConfig); err != nil {
+		if errors.Is(err, registry.ErrTLSCertPathMissing) || errors.Is(err, registry.ErrTLSKeyPathMissing) {
+			return nil, fmt.Errorf("tls enabled but --%s/--%s not set (--%s=false serves plaintext): %w",
+				TLSCertPathFlag, TLSKeyPathFlag, TLSFlag, err)
+		}
+		return nil, err
+	}
+
+	return registryConfig, nil
+}
+
+// loadConfigFromCLI assembles the registry config from, in increasing
+// precedence, flag defaults, the --config file, AERO_REGISTRY_* environment
+// variables and flags given on the command line. It does not validate it.
+func loadConfigFromCLI(cmd *cli.Command) (*registry.Config, error) {
+	registryConfig := &registry.Config{}
+	if err := applyFlags(driver.AllFlags(cmd), registryConfig); err != nil {
+		return nil, err
+	}
+
+	var file *registry.ConfigFile
+	if path := cmd.String(ConfigFileFlag); path != "" {
+		var err error
+		if file, err = registry.ReadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	backend := cmd.String(BackendFlag)
+	if file != nil && file.BackendType() != "" && !cmd.IsSet(BackendFlag) {
+		backend = string(file.BackendType())
+	}
+
+	backendType, err := driver.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+	registryConfig.Backend.Type = backendType
+
+	d, _ := driver.Lookup(backendType)
+	if d.Options != nil {
+		registryConfig.Backend.Options = d.Options()
+	}
+	configure := backendConfigure(d)
+	if err := configure(driver.AllFlags(cmd), &registryConfig.Backend); err != nil {
+		return nil, err
+	}
+
+	if file != nil {
+		if err := file.Apply(registryConfig); err != nil {
+			return nil, err
+		}
+
+		registryConfig.Backend.Type = backendType
+		if err := applyFlags(driver.SetFlags(cmd), registryConfig); err != nil {
+			return nil, err
+		}
+		if err := configure(driver.SetFlags(cmd), &registryConfig.Backend); err != nil {
+			return nil, err
 		}
-	case registry.EtcdRegistryBackend:
-	case registry.ConsulRegistryBackend:
-	case registry.MemoryRegistryBackend:
-	default:
-		return nil, fmt.Errorf("%w: %s", ErrUnhandledBackend, registryConfig.Backend.Type)
 	}
 
 	return registryConfig, nil
 }
+
+// applyFlags copies the backend-independent flags into cfg.
+func applyFlags(flags driver.FlagValues, cfg *registry.Config) error {
+	flags.String(GRPCListenAddrFlag, &cfg.GRPC.ListenAddress)
+	flags.Int(GRPCListenPortFlag, &cfg.GRPC.ListenPort)
+	flags.Bool(TLSFlag, &cfg.GRPC.TLS.Enabled)
+	flags.String(TLSCertPathFlag, &cfg.GRPC.TLS.CertPath)
+	flags.String(TLSKeyPathFlag, &cfg.GRPC.TLS.KeyPath)
+	flags.String(TLSClientCAPathFlag, &cfg.GRPC.TLS.ClientCAPath)
+	flags.String(TLSMinVersionFlag, &cfg.GRPC.TLS.MinVersion)
+	flags.StringSlice(TLSCipherSuitesFlag, &cfg.GRPC.TLS.CipherSuites)
+	flags.Bool(JWTFlag, &cfg.GRPC.JWT.Enabled)
+	flags.String(JWTSecretFlag, &cfg.GRPC.JWT.Secret)
+	flags.String(JWTJWKSPathFlag, &cfg.GRPC.JWT.JWKSPath)
+	flags.Duration(JWTJWKSReloadIntervalFlag, &cfg.GRPC.JWT.JWKSReloadInterval)
+	flags.String(JWTIssuerFlag, &cfg.GRPC.JWT.Issuer)
+	flags.String(JWTAudienceFlag, &cfg.GRPC.JWT.Audience)
+	flags.Bool(AuthFlag, &cfg.GRPC.Auth.Enabled)
+	flags.StringSlice(AuthRelayPrincipalsFlag, &cfg.GRPC.Auth.RelayPrincipals)
+	flags.StringSlice(AuthControlPlanePrincipalsFlag, &cfg.GRPC.Auth.ControlPlanePrincipals)
+
+	flags.Duration(RelayTTLFlag, &cfg.TTL.Relay)
+	flags.Duration(AgentTTLFlag, &cfg.TTL.Agent)
+
+	flags.Duration(ReaperIntervalFlag, &cfg.Reaper.Interval)
+	flags.Duration(ReaperJitterFlag, &cfg.Reaper.Jitter)
+
+	flags.Duration(WatchIntervalFlag, &cfg.Watch.Interval)
+	flags.Int(WatchHistoryFlag, &cfg.Watch.History)
+
+	if flags.Copies(LogLevelFlag) {
+		if err := cfg.Log.Level.UnmarshalText([]byte(flags.Command().String(LogLevelFlag))); err != nil {
+			return fmt.Errorf("invalid %s: %w", LogLevelFlag, err)
+		}
+	}
+
+	return nil
+}
+
+// withEnvVars backs each flag with an AERO_REGISTRY_* environment variable
+// named after it. Flag types without a case are left without one.
+func withEnvVars(flags []cli.Flag) []cli.Flag {
+	for _, flag := range flags {
+		env := cli.EnvVars(envPrefix + strings.ToUpper(strings.ReplaceAll(flag.Names()[0], "-", "_")))
+
+		switch f := flag.(type) {
+		case *cli.StringFlag:
+			f.Sources = env
+		case *cli.IntFlag:
+			f.Sources = env
+		case *cli.BoolFlag:
+			f.Sources = env
+		case *cli.DurationFlag:
+			f.Sources = env
+		case *cli.StringSliceFlag:
+			f.Sources = env
+		}
+	}
+
+	return flags
+}
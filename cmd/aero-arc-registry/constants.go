@@ -2,18 +2,121 @@ package main
 
 // cli flag names
 const (
-	BackendFlag           = "backend"
-	GRPCListenAddrFlag    = "grpc-listen-address"
-	GRPCListenPortFlag    = "grpc-listen-port"
-	TLSKeyPathFlag        = "tls-key-path"
-	TLSCertPathFlag       = "tls-cert-path"
-	RelayTTLFlag          = "relay-ttl"
-	AgentTTLFlag          = "agent-ttl"
-	HeartbeatIntervalFlag = "heartbeat-interval"
-	RedisAddrFlag         = "redis-addr"
-	RedisPortFlag         = "redis-port"
-	RedisUsernameFlag     = "redis-user"
-	RedisPasswordFlag     = "redis-password"
-	RedisDBFlag           = "redis-db"
-	ShutDownTimeoutFlag   = "shutdown-timeout"
+	ConfigFileFlag                 = "config"
+	ConfigWatchIntervalFlag        = "config-watch-interval"
+	LogLevelFlag                   = "log-level"
+	BackendFlag                    = "backend"
+	GRPCListenAddrFlag             = "grpc-listen-address"
+	GRPCListenPortFlag             = "grpc-listen-port"
+	JWTFlag                        = "jwt"
+	JWTSecretFlag                  = "jwt-secret"
+	JWTJWKSPathFlag                = "jwt-jwks-path"
+	JWTJWKSReloadIntervalFlag      = "jwt-jwks-reload-interval"
+	JWTIssuerFlag                  = "jwt-issuer"
+	JWTAudienceFlag                = "jwt-audience"
+	AuthFlag                       = "auth"
+	AuthRelayPrincipalsFlag        = "auth-relay-principals"
+	AuthControlPlanePrincipalsFlag = "auth-control-plane-principals"
+	TLSFlag                        = "tls"
+	TLSClientCAPathFlag            = "tls-client-ca-path"
+	TLSMinVersionFlag              = "tls-min-version"
+	TLSCipherSuitesFlag            = "tls-cipher-suites"
+	TLSKeyPathFlag                 = "tls-key-path"
+	TLSCertPathFlag                = "tls-cert-path"
+	RelayTTLFlag                   = "relay-ttl"
+	AgentTTLFlag                   = "agent-ttl"
+	HeartbeatIntervalFlag          = "heartbeat-interval"
+	MigrateFromNamespaceFlag       = "from-namespace"
+	ShutDownTimeoutFlag            = "shutdown-timeout"
+	ReaperIntervalFlag             = "reaper-interval"
+	ReaperJitterFlag               = "reaper-jitter"
+	WatchIntervalFlag              = "watch-interval"
+	WatchHistoryFlag               = "watch-history"
+)
+
+// consul backend cli flag names
+const (
+	ConsulAddrFlag              = "consul-addr"
+	ConsulTokenFlag             = "consul-token"
+	ConsulDatacenterFlag        = "consul-datacenter"
+	ConsulPrefixFlag            = "consul-prefix"
+	ConsulTLSFlag               = "consul-tls"
+	ConsulTLSCAFlag             = "consul-tls-ca"
+	ConsulTLSCertFlag           = "consul-tls-cert"
+	ConsulTLSKeyFlag            = "consul-tls-key"
+	ConsulTLSServerFlag         = "consul-tls-server-name"
+	ConsulTLSInsecureFlag       = "consul-tls-insecure-skip-verify"
+	ConsulCatalogFlag           = "consul-catalog"
+	ConsulCatalogAddrFlag       = "consul-catalog-addr"
+	ConsulCatalogServiceFlag    = "consul-catalog-service"
+	ConsulCatalogTagsFlag       = "consul-catalog-tags"
+	ConsulCatalogDeregisterFlag = "consul-catalog-deregister-after"
+)
+
+// etcd backend cli flag names
+const (
+	EtcdEndpointsFlag   = "etcd-endpoints"
+	EtcdDialTimeoutFlag = "etcd-dial-timeout"
+	EtcdUsernameFlag    = "etcd-username"
+	EtcdPasswordFlag    = "etcd-password"
+	EtcdPrefixFlag      = "etcd-prefix"
+	EtcdTLSFlag         = "etcd-tls"
+	EtcdTLSCAFlag       = "etcd-tls-ca"
+	EtcdTLSCertFlag     = "etcd-tls-cert"
+	EtcdTLSKeyFlag      = "etcd-tls-key"
+	EtcdTLSServerFlag   = "etcd-tls-server-name"
+	EtcdTLSInsecureFlag = "etcd-tls-insecure-skip-verify"
+)
+
+// file backend cli flag names
+const (
+	FilePathFlag            = "file-path"
+	FileCompactIntervalFlag = "file-compact-interval"
+)
+
+// memory backend cli flag names
+const (
+	MemoryMaxRelaysFlag = "memory-max-relays"
+	MemoryMaxAgentsFlag = "memory-max-agents"
+)
+
+// postgres backend cli flag names
+const (
+	PostgresAddrFlag        = "postgres-addr"
+	PostgresDatabaseFlag    = "postgres-database"
+	PostgresUserFlag        = "postgres-user"
+	PostgresPasswordFlag    = "postgres-password"
+	PostgresMaxConnsFlag    = "postgres-max-conns"
+	PostgresDialTimeoutFlag = "postgres-dial-timeout"
+	PostgresTLSFlag         = "postgres-tls"
+	PostgresTLSCAFlag       = "postgres-tls-ca"
+	PostgresTLSCertFlag     = "postgres-tls-cert"
+	PostgresTLSKeyFlag      = "postgres-tls-key"
+	PostgresTLSServerFlag   = "postgres-tls-server-name"
+	PostgresTLSInsecureFlag = "postgres-tls-insecure-skip-verify"
+)
+
+// redis backend cli flag names
+const (
+	RedisAddrFlag             = "redis-addr"
+	RedisPortFlag             = "redis-port"
+	RedisUsernameFlag         = "redis-user"
+	RedisPasswordFlag         = "redis-password"
+	RedisDbFlag               = "redis-db"
+	RedisPoolSizeFlag         = "redis-pool-size"
+	RedisMinIdleFlag          = "redis-min-idle-conns"
+	RedisMaxIdleFlag          = "redis-max-idle-conns"
+	RedisHealthCheckFlag      = "redis-health-check-after"
+	RedisTLSFlag              = "redis-tls"
+	RedisTLSCAFlag            = "redis-tls-ca"
+	RedisTLSCertFlag          = "redis-tls-cert"
+	RedisTLSKeyFlag           = "redis-tls-key"
+	RedisTLSServerFlag        = "redis-tls-server-name"
+	RedisTLSInsecureFlag      = "redis-tls-insecure-skip-verify"
+	RedisSentinelMasterFlag   = "redis-sentinel-master"
+	RedisSentinelAddrsFlag    = "redis-sentinel-addrs"
+	RedisSentinelUserFlag     = "redis-sentinel-user"
+	RedisSentinelPasswordFlag = "redis-sentinel-password"
+	RedisClusterAddrsFlag     = "redis-cluster-addrs"
+	RedisNamespaceFlag        = "redis-namespace"
 )
@@ -2,4 +2,7 @@ package main
 
 import "errors"
 
-var ErrUnhandledBackend = errors.New("unhandled registry backend")
+var (
+	ErrMigrationRequiresRedis = errors.New("namespace migration requires the redis backend")
+	ErrNoClientCAs            = errors.New("no certificates found in client ca bundle")
+)
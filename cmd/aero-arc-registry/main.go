@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"log/slog"
@@ -13,17 +14,30 @@ import (
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
 	"github.com/Aero-Arc/aero-arc-registry/internal/transport/grpc"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
 	"github.com/urfave/cli/v3"
 	gogrpc "google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
-var homeDir, _ = os.UserHomeDir()
-
 var registryCmd = cli.Command{
-	Usage:  "run the aero arc registry process",
-	Action: RunRegistry,
-	Flags: []cli.Flag{
+	Usage:    "run the aero arc registry process",
+	Action:   RunRegistry,
+	Commands: []*cli.Command{&migrateNamespaceCmd},
+	Flags: withEnvVars(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  ConfigFileFlag,
+			Usage: "yaml or toml file holding the registry config; environment variables and flags override it",
+		},
+		&cli.DurationFlag{
+			Name:  ConfigWatchIntervalFlag,
+			Usage: "interval between checks of the config file for changes to reload (0 reloads on SIGHUP only)",
+		},
+		&cli.StringFlag{
+			Name:  LogLevelFlag,
+			Usage: "minimum log level: debug, info, warn or error",
+			Value: "info",
+		},
 		&cli.StringFlag{
 			Name:  BackendFlag,
 			Value: "memory",
@@ -39,15 +53,68 @@ var registryCmd = cli.Command{
 			Usage: "the port the registry's grpc server will listen on",
 			Value: 50051,
 		},
+		&cli.BoolFlag{
+			Name:  TLSFlag,
+			Usage: "serve grpc over tls, which requires --tls-cert-path and --tls-key-path (set to false for plaintext local development)",
+			Value: true,
+		},
 		&cli.StringFlag{
 			Name:  TLSKeyPathFlag,
-			Usage: "path to tls key file",
-			Value: fmt.Sprintf("%s/%s", homeDir, registry.DebugTLSKeyPath),
+			Usage: "path to tls key file (required with --tls)",
 		},
 		&cli.StringFlag{
 			Name:  TLSCertPathFlag,
-			Usage: "path to tls crt file",
-			Value: fmt.Sprintf("%s/%s", homeDir, registry.DebugTLSCertPath),
+			Usage: "path to tls crt file (required with --tls)",
+		},
+		&cli.StringFlag{
+			Name:  TLSClientCAPathFlag,
+			Usage: "path to a ca bundle for verifying client certificates; enables mutual tls, optional for token callers with --jwt",
+		},
+		&cli.StringFlag{
+			Name:  TLSMinVersionFlag,
+			Usage: "minimum tls version accepted (1.2 or 1.3)",
+			Value: "1.2",
+		},
+		&cli.StringSliceFlag{
+			Name:  TLSCipherSuitesFlag,
+			Usage: "tls 1.2 cipher suites to allow, by IANA name (defaults to the go defaults)",
+		},
+		&cli.BoolFlag{
+			Name:  JWTFlag,
+			Usage: "require a bearer token from callers without a verified client certificate",
+		},
+		&cli.StringFlag{
+			Name:  JWTSecretFlag,
+			Usage: "shared secret for verifying HS256 bearer tokens",
+		},
+		&cli.StringFlag{
+			Name:  JWTJWKSPathFlag,
+			Usage: "path to a jwks file with the keys for verifying RS256 and ES256 bearer tokens",
+		},
+		&cli.DurationFlag{
+			Name:  JWTJWKSReloadIntervalFlag,
+			Usage: "interval between reloads of the jwks file (0 loads it once)",
+			Value: time.Minute,
+		},
+		&cli.StringFlag{
+			Name:  JWTIssuerFlag,
+			Usage: "required iss claim of bearer tokens",
+		},
+		&cli.StringFlag{
+			Name:  JWTAudienceFlag,
+			Usage: "audience required in the aud claim of bearer tokens",
+		},
+		&cli.BoolFlag{
+			Name:  AuthFlag,
+			Usage: "authorize calls against the relay and control plane principal policy",
+		},
+		&cli.StringSliceFlag{
+			Name:  AuthRelayPrincipalsFlag,
+			Usage: "relay principal patterns containing {relay_id}, e.g. spiffe://aero-arc/relay/{relay_id}",
+		},
+		&cli.StringSliceFlag{
+			Name:  AuthControlPlanePrincipalsFlag,
+			Usage: "principals granted read-only access to relays and placements",
 		},
 		&cli.DurationFlag{
 			Name:  RelayTTLFlag,
@@ -64,37 +131,31 @@ var registryCmd = cli.Command{
 			Usage: "expected relay heartbeat interval",
 			Value: time.Second,
 		},
-		&cli.StringFlag{
-			Name:  RedisAddrFlag,
-			Usage: "redis instance address",
-			Value: "localhost",
-		},
-		&cli.IntFlag{
-			Name:  RedisPortFlag,
-			Usage: "redis instance port",
-			Value: 6379,
+		&cli.DurationFlag{
+			Name:  ReaperIntervalFlag,
+			Usage: "interval between sweeps of expired relays and agents (0 disables)",
+			Value: time.Second * 10,
 		},
-		&cli.StringFlag{
-			Name:  RedisUsernameFlag,
-			Usage: "redis username",
-			Value: "default",
+		&cli.DurationFlag{
+			Name:  ReaperJitterFlag,
+			Usage: "maximum random delay added to each reaper interval",
+			Value: time.Second * 2,
 		},
-		&cli.StringFlag{
-			Name:  RedisPasswordFlag,
-			Usage: "redis password",
-			Value: "",
+		&cli.DurationFlag{
+			Name:  WatchIntervalFlag,
+			Usage: "interval between change polls and expiry checks for watchers (0 disables; grpc does not serve watches yet)",
 		},
 		&cli.IntFlag{
-			Name:  RedisDBFlag,
-			Usage: "specified redis db to use",
-			Value: 0,
+			Name:  WatchHistoryFlag,
+			Usage: "number of recent change events retained for resuming watchers",
+			Value: 1024,
 		},
 		&cli.DurationFlag{
 			Name:  ShutDownTimeoutFlag,
 			Usage: "timeout that is enforced during a graceful shutdown",
 			Value: time.Second * 30,
 		},
-	},
+	}, backendFlags()...)),
 }
 
 func RunRegistry(ctx context.Context, cmd *cli.Command) error {
@@ -105,8 +166,9 @@ func RunRegistry(ctx context.Context, cmd *cli.Command) error {
 	if err != nil {
 		return err
 	}
+	slog.SetLogLoggerLevel(cfg.Log.Level)
 
-	backend, err := buildBackendFromConfig(cfg)
+	backend, err := driver.New(cfg)
 	if err != nil {
 		return err
 	}
@@ -116,21 +178,28 @@ func RunRegistry(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	var opts []gogrpc.ServerOption
+	var (
+		opts      []gogrpc.ServerOption
+		serverTLS *tlsReloader
+	)
 
 	if cfg.GRPC.TLS.Enabled {
-		creds, err := credentials.NewServerTLSFromFile(
-			cfg.GRPC.TLS.CertPath,
-			cfg.GRPC.TLS.KeyPath,
-		)
-		if err != nil {
-			return err
+		serverTLS = &tlsReloader{bearerTokens: cfg.GRPC.JWT.Enabled}
+		if err := serverTLS.load(cfg.GRPC.TLS); err != nil {
+			return fmt.Errorf("load grpc tls settings (--%s=false serves plaintext): %w", TLSFlag, err)
 		}
 
+		creds := credentials.NewTLS(&tls.Config{
+			GetConfigForClient: serverTLS.GetConfigForClient,
+		})
 		opts = append(opts, gogrpc.Creds(creds))
+	} else {
+		slog.Warn("grpc tls is disabled; serving plaintext")
 	}
 
-	grpcServer, err := grpc.New(aeroRegistry, opts...)
+	reload := newReloader(cmd, aeroRegistry, serverTLS, cfg)
+
+	grpcServer, err := grpc.New(aeroRegistry, cfg.GRPC, opts...)
 	if err != nil {
 		return err
 	}
@@ -143,11 +212,31 @@ func RunRegistry(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
+	reaperDone := make(chan struct{})
+	go func() {
+		defer close(reaperDone)
+		aeroRegistry.RunReaper(signalCtx)
+	}()
+
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		aeroRegistry.RunWatch(signalCtx)
+	}()
+
+	go reload.run(signalCtx, cmd.Duration(ConfigWatchIntervalFlag))
+
 	go func() {
 		<-signalCtx.Done()
 		slog.Info("shutting down grpc server")
 		grpcServer.GracefulStop()
 
+		slog.Info("waiting for reaper to stop")
+		<-reaperDone
+
+		slog.Info("waiting for watch stream to stop")
+		<-watchDone
+
 		slog.Info("shutting down backend")
 		if err := backend.Close(context.Background()); err != nil {
 			slog.Error("failed to close backend", "error", err)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/redis"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+	"github.com/urfave/cli/v3"
+)
+
+var migrateNamespaceCmd = cli.Command{
+	Name:   "migrate-redis-namespace",
+	Usage:  "rename existing redis records into the namespace set by --redis-namespace, then exit",
+	Action: MigrateRedisNamespace,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  MigrateFromNamespaceFlag,
+			Usage: "namespace to move records out of (empty for unprefixed keys)",
+		},
+	},
+}
+
+// MigrateRedisNamespace moves registry records between Redis namespaces.
+// Registries using either namespace should be stopped while it runs.
+func MigrateRedisNamespace(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := buildRedisConfigFromCLI(cmd)
+	if err != nil {
+		return err
+	}
+
+	backend, err := redis.New(cfg.Backend.Redis, cfg.TTL)
+	if err != nil {
+		return err
+	}
+	defer backend.Close(context.Background())
+
+	from := cmd.String(MigrateFromNamespaceFlag)
+	stats, err := backend.MigrateNamespace(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("migrated redis namespace",
+		"from", from,
+		"to", cfg.Backend.Redis.Namespace,
+		"relays", stats.Relays,
+		"agents", stats.Agents,
+		"skipped", stats.Skipped,
+		"expired", stats.Expired,
+	)
+	if stats.Skipped > 0 {
+		slog.Warn("some records were already present in the target namespace and were left in the source namespace",
+			"from", from,
+			"skipped", stats.Skipped,
+		)
+	}
+	return nil
+}
+
+// buildRedisConfigFromCLI assembles the config like buildConfigFromCLI but
+// validates only the Redis backend section and the TTLs. The migration
+// never serves gRPC, so it must not fail on the gRPC or TLS settings.
+func buildRedisConfigFromCLI(cmd *cli.Command) (*registry.Config, error) {
+	cfg, err := loadConfigFromCLI(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Backend.Type != registry.RedisRegistryBackend {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationRequiresRedis, cfg.Backend.Type)
+	}
+
+	d, _ := driver.Lookup(registry.RedisRegistryBackend)
+	if err := d.Validate(&cfg.Backend); err != nil {
+		return nil, fmt.Errorf("%s config invalid: %w", cfg.Backend.Type, err)
+	}
+	if err := cfg.TTL.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/urfave/cli/v3"
+)
+
+func TestBuildRedisConfigFromCLI(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr error
+	}{
+		{
+			// --tls defaults to on without a certificate, which only the
+			// serving command needs.
+			name: "ignores grpc settings",
+			args: []string{"--backend", "redis", "--redis-addr", "redis.internal", "--jwt"},
+		},
+		{
+			name:    "requires redis",
+			args:    []string{"--backend", "memory"},
+			wantErr: ErrMigrationRequiresRedis,
+		},
+		{
+			name:    "validates redis",
+			args:    []string{"--backend", "redis", "--redis-addr", ""},
+			wantErr: registry.ErrRedisAddrEmpty,
+		},
+		{
+			name:    "validates ttls",
+			args:    []string{"--backend", "redis", "--relay-ttl", "-1s"},
+			wantErr: registry.ErrTTLRelayInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				cfg *registry.Config
+				err error
+			)
+			cmd := &cli.Command{
+				Name:  "migrate",
+				Flags: registryCmd.Flags,
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					cfg, err = buildRedisConfigFromCLI(cmd)
+					return nil
+				},
+			}
+			if runErr := cmd.Run(context.Background(), append([]string{"migrate"}, test.args...)); runErr != nil {
+				t.Fatalf("run: %v", runErr)
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr == nil && cfg.Backend.Redis.Address != "redis.internal" {
+				t.Fatalf("expected redis address redis.internal, got %q", cfg.Backend.Redis.Address)
+			}
+		})
+	}
+}
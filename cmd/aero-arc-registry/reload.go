@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/urfave/cli/v3"
+)
+
+// reloader re-reads configuration on SIGHUP, and optionally whenever the
+// config file changes, and applies the settings that can change without a
+// restart: TTLs, unless the backend only takes them on restart, the gRPC
+// TLS settings other than whether TLS is enabled, and the log level. A
+// reload that fails validation or cannot load the TLS settings is rejected
+// and the running config stays in force.
+type reloader struct {
+	cmd      *cli.Command
+	registry *registry.Registry
+	tls      *tlsReloader
+	current  *registry.Config
+	hup      chan os.Signal
+}
+
+// newReloader subscribes to SIGHUP straight away, so a signal sent before
+// run starts is not lost or fatal to the process.
+func newReloader(cmd *cli.Command, reg *registry.Registry, serverTLS *tlsReloader, cfg *registry.Config) *reloader {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	return &reloader{
+		cmd:      cmd,
+		registry: reg,
+		tls:      serverTLS,
+		current:  cfg,
+		hup:      hup,
+	}
+}
+
+// run handles reload triggers until ctx is done. When interval is positive
+// and a config file is in use, the file is checked for changes that often.
+func (r *reloader) run(ctx context.Context, interval time.Duration) {
+	defer signal.Stop(r.hup)
+
+	path := r.cmd.String(ConfigFileFlag)
+
+	var (
+		tick  <-chan time.Time
+		stamp fileStamp
+	)
+	if path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		stamp, _ = statFile(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.hup:
+			r.reload("sighup")
+		case <-tick:
+			next, err := statFile(path)
+			if err != nil {
+				slog.Warn("failed to check config file for changes", "path", path, "error", err)
+				continue
+			}
+			if next == stamp {
+				continue
+			}
+			stamp = next
+			r.reload("config file changed")
+		}
+	}
+}
+
+func (r *reloader) reload(reason string) {
+	cfg, err := buildConfigFromCLI(r.cmd)
+	if err != nil {
+		slog.Error("config reload rejected", "reason", reason, "error", err)
+		return
+	}
+
+	if r.tls != nil && cfg.GRPC.TLS.Enabled {
+		if err := r.tls.load(cfg.GRPC.TLS); err != nil {
+			slog.Error("config reload rejected", "reason", reason, "error", err)
+			return
+		}
+	}
+
+	if err := r.registry.SetTTL(cfg.TTL); err != nil {
+		if !errors.Is(err, registry.ErrTTLRestartRequired) {
+			slog.Error("config reload rejected", "reason", reason, "error", err)
+			return
+		}
+
+		// The rest of the reload still applies; the running TTLs stay.
+		slog.Warn("ttl changes take effect after a restart", "reason", reason, "error", err,
+			"relay_ttl", cfg.TTL.Relay,
+			"agent_ttl", cfg.TTL.Agent,
+		)
+		cfg.TTL = r.current.TTL
+	}
+
+	slog.SetLogLoggerLevel(cfg.Log.Level)
+
+	if !reflect.DeepEqual(restartOnly(*r.current), restartOnly(*cfg)) {
+		slog.Warn("config changes other than ttls, tls settings and log level take effect after a restart")
+	}
+	r.current = cfg
+
+	slog.Info("config reloaded",
+		"reason", reason,
+		"relay_ttl", cfg.TTL.Relay,
+		"agent_ttl", cfg.TTL.Agent,
+		"log_level", cfg.Log.Level,
+	)
+}
+
+// restartOnly returns cfg without the settings a reload applies, leaving
+// those that only take effect on restart.
+func restartOnly(cfg registry.Config) registry.Config {
+	cfg.TTL = registry.TTLConfig{}
+	cfg.GRPC.TLS = registry.TLSConfig{Enabled: cfg.GRPC.TLS.Enabled}
+	cfg.Log = registry.LogConfig{}
+
+	return cfg
+}
+
+// fileStamp identifies a version of a file for change detection.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
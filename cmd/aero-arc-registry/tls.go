@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+// tlsReloader serves the gRPC server TLS settings through
+// GetConfigForClient, so the keypair, client CAs and protocol policy can be
+// replaced without restarting the listener.
+type tlsReloader struct {
+	// bearerTokens is set when the server accepts bearer tokens, making
+	// client certificates optional. It is fixed at startup, like the JWT
+	// settings themselves.
+	bearerTokens bool
+
+	config atomic.Pointer[tls.Config]
+}
+
+// load builds the server TLS settings from cfg and swaps them in. On error
+// the previous settings stay in use.
+func (r *tlsReloader) load(cfg registry.TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	minVersion, err := registry.ParseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return err
+	}
+
+	cipherSuites, err := registry.ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		// Replaces the config gRPC prepared, so it must advertise h2 itself.
+		NextProtos: []string{"h2"},
+	}
+
+	if cfg.ClientCAPath != "" {
+		pem, err := os.ReadFile(cfg.ClientCAPath)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: %s", ErrNoClientCAs, cfg.ClientCAPath)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if r.bearerTokens {
+			// Callers without a certificate must then send a bearer
+			// token, which the server checks after the handshake.
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	r.config.Store(tlsConfig)
+	return nil
+}
+
+func (r *tlsReloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return r.config.Load(), nil
+}
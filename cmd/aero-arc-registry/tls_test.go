@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
+	"github.com/Aero-Arc/aero-arc-registry/internal/transport/grpc"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testJWTSecret   = "shared-secret"
+	testJWTIssuer   = "https://auth.aero-arc"
+	testJWTAudience = "aero-arc-registry"
+)
+
+func TestServerClientAuth(t *testing.T) {
+	t.Parallel()
+
+	pki := newTestPKI(t)
+
+	tests := []struct {
+		name  string
+		jwt   bool
+		cert  bool
+		token bool
+		want  codes.Code
+	}{
+		{name: "mtls with certificate", cert: true, want: codes.OK},
+		{name: "mtls without certificate", want: codes.Unavailable},
+		{name: "jwt with certificate", jwt: true, cert: true, want: codes.OK},
+		{name: "jwt with token", jwt: true, token: true, want: codes.OK},
+		{name: "jwt with neither", jwt: true, want: codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTLSTestClient(t, pki, test.jwt, test.cert)
+
+			ctx := context.Background()
+			if test.token {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+signTestToken(t, "relay-1"))
+			}
+
+			_, err := client.ListRelays(ctx, &registryv1.ListRelaysRequest{})
+			if got := status.Code(err); got != test.want {
+				t.Fatalf("expected %s, got %s (%v)", test.want, got, err)
+			}
+		})
+	}
+}
+
+// TestServerClientAuthMixedFleet serves a certificate-only caller and a
+// token-only caller from one server.
+func TestServerClientAuthMixedFleet(t *testing.T) {
+	t.Parallel()
+
+	pki := newTestPKI(t)
+	lis := serveTLSTestRegistry(t, pki, true)
+
+	certClient := dialTLSTestRegistry(t, pki, lis, true)
+	tokenClient := dialTLSTestRegistry(t, pki, lis, false)
+	tokenCtx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+signTestToken(t, "relay-2"))
+
+	if _, err := certClient.ListRelays(context.Background(), &registryv1.ListRelaysRequest{}); err != nil {
+		t.Fatalf("certificate caller: %v", err)
+	}
+	if _, err := tokenClient.ListRelays(tokenCtx, &registryv1.ListRelaysRequest{}); err != nil {
+		t.Fatalf("token caller: %v", err)
+	}
+}
+
+type testPKI struct {
+	caPool     *x509.CertPool
+	caPath     string
+	certPath   string
+	keyPath    string
+	clientCert tls.Certificate
+}
+
+func newTLSTestClient(t *testing.T, pki *testPKI, jwt, cert bool) registryv1.AeroRegistryClient {
+	t.Helper()
+
+	return dialTLSTestRegistry(t, pki, serveTLSTestRegistry(t, pki, jwt), cert)
+}
+
+// serveTLSTestRegistry serves a registry over mutual TLS, accepting bearer
+// tokens as well when jwt is set, and returns its listener.
+func serveTLSTestRegistry(t *testing.T, pki *testPKI, jwt bool) net.Listener {
+	t.Helper()
+
+	cfg := &registry.Config{
+		Backend: registry.BackendConfig{Type: registry.MemoryRegistryBackend},
+		GRPC: registry.GRPCConfig{
+			ListenAddress: "127.0.0.1",
+			ListenPort:    50051,
+			TLS: registry.TLSConfig{
+				Enabled:      true,
+				CertPath:     pki.certPath,
+				KeyPath:      pki.keyPath,
+				ClientCAPath: pki.caPath,
+			},
+			JWT: registry.JWTConfig{
+				Enabled:  jwt,
+				Secret:   testJWTSecret,
+				Issuer:   testJWTIssuer,
+				Audience: testJWTAudience,
+			},
+		},
+		TTL: registry.TTLConfig{Relay: time.Minute, Agent: time.Minute},
+	}
+	backend, err := memory.New(&registry.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	reg, err := registry.New(cfg, backend)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	serverTLS := &tlsReloader{bearerTokens: cfg.GRPC.JWT.Enabled}
+	if err := serverTLS.load(cfg.GRPC.TLS); err != nil {
+		t.Fatalf("load tls: %v", err)
+	}
+	creds := credentials.NewTLS(&tls.Config{GetConfigForClient: serverTLS.GetConfigForClient})
+
+	server, err := grpc.New(reg, cfg.GRPC, gogrpc.Creds(creds))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.GracefulStop)
+
+	return lis
+}
+
+// dialTLSTestRegistry connects to lis, presenting the client certificate
+// when cert is set.
+func dialTLSTestRegistry(t *testing.T, pki *testPKI, lis net.Listener, cert bool) registryv1.AeroRegistryClient {
+	t.Helper()
+
+	clientTLS := &tls.Config{RootCAs: pki.caPool, ServerName: "localhost"}
+	if cert {
+		clientTLS.Certificates = []tls.Certificate{pki.clientCert}
+	}
+
+	conn, err := gogrpc.NewClient(lis.Addr().String(), gogrpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return registryv1.NewAeroRegistryClient(conn)
+}
+
+// newTestPKI generates a CA, a server certificate for localhost and a
+// client certificate, writing the files the server loads to disk.
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+	caKey := mustGenerateKey(t)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse ca: %v", err)
+	}
+
+	issue := func(serial int64, template *x509.Certificate) ([]byte, *ecdsa.PrivateKey) {
+		key := mustGenerateKey(t)
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("create certificate: %v", err)
+		}
+		return der, key
+	}
+
+	serverDER, serverKey := issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "registry"},
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	clientDER, clientKey := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "relay-1"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	pki := &testPKI{
+		caPool:     x509.NewCertPool(),
+		caPath:     filepath.Join(dir, "ca.pem"),
+		certPath:   filepath.Join(dir, "server.pem"),
+		keyPath:    filepath.Join(dir, "server-key.pem"),
+		clientCert: tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey},
+	}
+	pki.caPool.AddCert(ca)
+
+	serverKeyDER, err := x509.MarshalECPrivateKey(serverKey)
+	if err != nil {
+		t.Fatalf("marshal server key: %v", err)
+	}
+	mustWritePEM(t, pki.caPath, "CERTIFICATE", caDER)
+	mustWritePEM(t, pki.certPath, "CERTIFICATE", serverDER)
+	mustWritePEM(t, pki.keyPath, "EC PRIVATE KEY", serverKeyDER)
+	return pki
+}
+
+// signTestToken returns an HS256 bearer token for sub.
+func signTestToken(t *testing.T, sub string) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal token: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(map[string]any{
+		"sub": sub,
+		"iss": testJWTIssuer,
+		"aud": testJWTAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func mustWritePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -5,15 +5,17 @@ go 1.24.0
 toolchain go1.24.9
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/aero-arc/aero-arc-protos v0.0.0-20260121033609-725d944d04a6
 	github.com/urfave/cli/v3 v3.6.2
+	golang.org/x/sys v0.38.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251029180050-ab9386a59fda
 	google.golang.org/grpc v1.78.0
+	google.golang.org/protobuf v1.36.10
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20251029180050-ab9386a59fda // indirect
-	google.golang.org/protobuf v1.36.10 // indirect
 )
@@ -7,6 +7,11 @@ import (
 
 // Backend defines the persistence and coordination contract
 // required by the registry control plane.
+//
+// Liveness is judged by the registry from the stored timestamps. A backend
+// keeps a record until it is removed or, for backends with native expiry,
+// until that expiry drops it, and a heartbeat for a record it still holds
+// succeeds even if the record's timestamp is already past its TTL.
 type Backend interface {
 	// Relay lifecycle
 	RegisterRelay(ctx context.Context, relay Relay) error
@@ -18,11 +23,37 @@ type Backend interface {
 	RegisterAgent(ctx context.Context, agent Agent, relayID string) error
 	HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error
 	GetAgentPlacement(ctx context.Context, agentID string) (*AgentPlacement, error)
+	ListPlacements(ctx context.Context) ([]AgentPlacement, error)
+	RemoveAgent(ctx context.Context, agentID string) error
 
 	// Shutdown
 	Close(ctx context.Context) error
 }
 
+// Watcher is implemented by backends to stream their own changes (e.g.
+// keyspace notifications or native watches). A backend without a change
+// stream returns ErrWatchUnsupported from Watch, and is then observed by
+// periodically polling ListRelays and ListPlacements; backends that do not
+// implement Watcher at all are polled the same way.
+//
+// Watch emits an event for every relay and placement write or removal. The
+// channel is closed when ctx is done or when the backend can no longer
+// guarantee delivery, in which case the registry resynchronizes from a
+// fresh listing and calls Watch again. ResumeToken is ignored on events
+// produced by backends.
+type Watcher interface {
+	Watch(ctx context.Context) (<-chan Event, error)
+}
+
+// TTLSetter is implemented by backends that use the TTLs they were
+// constructed with, e.g. to expire state natively or to hide expired rows.
+// Registry.SetTTL hands new TTLs to the backend before enforcing them. A
+// backend that cannot change its TTLs while running returns
+// ErrTTLRestartRequired, and the registry keeps its current TTLs.
+type TTLSetter interface {
+	SetTTL(ttl TTLConfig) error
+}
+
 // Relay represents a relay instance registered with the registry.
 type Relay struct {
 	ID       string
@@ -0,0 +1,51 @@
+// Package clienttls builds the TLS configuration backends use to dial the
+// services they store registry state in.
+package clienttls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+// Load builds the client TLS configuration for cfg, or returns nil if TLS
+// is disabled. Certificates are loaded up front so misconfiguration fails at
+// startup rather than on the first request. service names the backend in
+// errors, e.g. "redis".
+func Load(service string, cfg registry.ClientTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAPath != "" {
+		pem, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("read %s tls ca: %w", service, err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s tls ca %s contains no certificates", service, cfg.CAPath)
+		}
+		tlsCfg.RootCAs = roots
+	}
+
+	if cfg.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("load %s tls client cert: %w", service, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
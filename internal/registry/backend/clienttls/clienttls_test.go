@@ -0,0 +1,55 @@
+package clienttls
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+func TestLoad(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, block, 0o600); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+
+	cfg, err := Load("test", registry.ClientTLSConfig{Enabled: true, CAPath: caPath, ServerName: "example"})
+	if err != nil || cfg == nil || cfg.RootCAs == nil {
+		t.Fatalf("expected tls config with roots, got %+v (%v)", cfg, err)
+	}
+	if cfg.ServerName != "example" {
+		t.Fatalf("expected server name example, got %q", cfg.ServerName)
+	}
+	if cfg, err := Load("test", registry.ClientTLSConfig{}); cfg != nil || err != nil {
+		t.Fatalf("expected no tls config when disabled, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tls  registry.ClientTLSConfig
+	}{
+		{name: "missing ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: filepath.Join(t.TempDir(), "missing.pem")}},
+		{name: "empty ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: notPEM}},
+		{name: "bad client key", tls: registry.ClientTLSConfig{Enabled: true, CertPath: notPEM, KeyPath: notPEM}},
+	}
+
+	for _, test := range tests {
+		if _, err := Load("test", test.tls); err == nil {
+			t.Fatalf("%s: expected error", test.name)
+		}
+	}
+}
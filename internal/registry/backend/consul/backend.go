@@ -1,32 +1,118 @@
-// Package consul provides a consul-shaped backend implementation.
+// Package consul provides a Consul KV and session backend implementation.
 package consul
 
 import (
 	"context"
-	"sync"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/clienttls"
+)
+
+const (
+	defaultPrefix = "aero-arc-registry/"
+
+	// registerAttempts bounds how often RegisterAgent retries when the
+	// relay is re-registered between reading it and placing the agent.
+	registerAttempts = 3
 )
 
 type Backend struct {
-	cfg *registry.ConsulConfig
+	cfg     *registry.ConsulConfig
+	ttl     registry.TTLConfig
+	prefix  string
+	catalog registry.ConsulCatalogConfig
+	client  *client
 
-	mu         sync.RWMutex
-	relays     map[string]registry.Relay
-	agents     map[string]registry.Agent
-	placements map[string]registry.AgentPlacement
+	// agent registers relay services. It is client unless
+	// cfg.Catalog.Address names another agent.
+	agent *client
+
+	catalogFailures atomic.Uint64
 }
 
-func New(cfg *registry.ConsulConfig) (*Backend, error) {
+// New returns a Consul backend. Records live in the KV store under
+// cfg.Prefix. Each relay key is locked by a session with a TTL of ttl.Relay
+// and each agent and its placement share a session of ttl.Agent; sessions
+// use the delete behavior, so Consul drops dead state on its own even when
+// no registry replica is running. Heartbeats renew the session. A zero TTL
+// writes the corresponding keys without a session.
+//
+// Consul only accepts session TTLs between 10s and 24h and may keep a
+// session for up to twice its TTL, so expiry in Consul is approximate; the
+// registry layer still enforces the configured TTLs itself.
+//
+// With cfg.Catalog enabled, relays are also registered as catalog services
+// whose TTL check is passed by each relay heartbeat. The services are
+// registered through the agent at cfg.Catalog.Address, so replicas that
+// talk to different agents still share one instance per relay. A failed
+// catalog update does not fail the relay write; it is logged and counted
+// by CatalogFailures.
+func New(cfg *registry.ConsulConfig, ttl registry.TTLConfig) (*Backend, error) {
+	if cfg == nil {
+		return nil, registry.ErrConsulConfigNil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := clienttls.Load("consul", cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+
+	catalog := cfg.Catalog
+	if catalog.ServiceName == "" {
+		catalog.ServiceName = defaultServiceName
+	}
+	if catalog.DeregisterAfter == 0 {
+		catalog.DeregisterAfter = defaultDeregisterAfter
+	}
+
+	kv := newClient(cfg.Address, tlsCfg, cfg.Token, cfg.Datacenter)
+	agent := kv
+	if catalog.Address != "" && catalog.Address != cfg.Address {
+		agent = newClient(catalog.Address, tlsCfg, cfg.Token, cfg.Datacenter)
+	}
+
 	return &Backend{
-		cfg:        cfg,
-		relays:     make(map[string]registry.Relay),
-		agents:     make(map[string]registry.Agent),
-		placements: make(map[string]registry.AgentPlacement),
+		cfg:     cfg,
+		ttl:     ttl,
+		prefix:  prefix,
+		catalog: catalog,
+		client:  kv,
+		agent:   agent,
 	}, nil
 }
 
+func (b *Backend) relaysPrefix() string {
+	return b.prefix + "relays/"
+}
+
+func (b *Backend) placementsPrefix() string {
+	return b.prefix + "placements/"
+}
+
+func (b *Backend) relayKey(relayID string) string {
+	return b.relaysPrefix() + relayID
+}
+
+func (b *Backend) agentKey(agentID string) string {
+	return b.prefix + "agents/" + agentID
+}
+
+func (b *Backend) placementKey(agentID string) string {
+	return b.placementsPrefix() + agentID
+}
+
 func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -38,9 +124,42 @@ func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error
 		relay.LastSeen = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.relays[relay.ID] = relay
+	payload, err := json.Marshal(relay)
+	if err != nil {
+		return err
+	}
+
+	key := b.relayKey(relay.ID)
+	existing, err := b.client.get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	session, err := b.newSession(ctx, "relay "+relay.ID, b.ttl.Relay)
+	if err != nil {
+		return err
+	}
+
+	// Deleting first releases a lock held by the previous registration's
+	// session so the new one can take it.
+	_, ok, err := b.client.txn(ctx, []txnOp{
+		deleteOp(key),
+		writeOp(key, payload, session),
+	})
+	if err != nil || !ok {
+		b.discardSession(ctx, session)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("consul: register relay %s: transaction rolled back", relay.ID)
+	}
+
+	if existing != nil && existing.Session != session {
+		b.discardSession(ctx, existing.Session)
+	}
+	if err := b.publishRelay(ctx, relay); err != nil {
+		b.catalogFailed(relay.ID, err)
+	}
 	return nil
 }
 
@@ -55,15 +174,36 @@ func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Ti
 		ts = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	relay, ok := b.relays[relayID]
-	if !ok {
+	kv, err := b.client.get(ctx, b.relayKey(relayID))
+	if err != nil {
+		return err
+	}
+	if kv == nil {
 		return registry.ErrRelayNotRegistered
 	}
+	if alive, err := b.renewSession(ctx, kv.Session); err != nil || !alive {
+		if err != nil {
+			return err
+		}
+		return registry.ErrRelayNotRegistered
+	}
+
+	var relay registry.Relay
+	if err := json.Unmarshal(kv.Value, &relay); err != nil {
+		return err
+	}
 	relay.LastSeen = ts
-	b.relays[relayID] = relay
+	payload, err := json.Marshal(relay)
+	if err != nil {
+		return err
+	}
+
+	if err := b.update(ctx, []kvPair{*kv}, [][]byte{payload}, registry.ErrRelayNotRegistered); err != nil {
+		return err
+	}
+	if err := b.refreshRelay(ctx, relay); err != nil {
+		b.catalogFailed(relay.ID, err)
+	}
 	return nil
 }
 
@@ -72,11 +212,17 @@ func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
 		return nil, err
 	}
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	kvs, err := b.client.list(ctx, b.relaysPrefix())
+	if err != nil {
+		return nil, err
+	}
 
-	relays := make([]registry.Relay, 0, len(b.relays))
-	for _, relay := range b.relays {
+	relays := make([]registry.Relay, 0, len(kvs))
+	for _, kv := range kvs {
+		var relay registry.Relay
+		if err := json.Unmarshal(kv.Value, &relay); err != nil {
+			return nil, err
+		}
 		relays = append(relays, relay)
 	}
 	return relays, nil
@@ -90,14 +236,24 @@ func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
 		return registry.ErrRelayIDEmpty
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if _, ok := b.relays[relayID]; !ok {
+	// Persistence-only responsibility: dependent agents are invalidated by
+	// the registry layer.
+	key := b.relayKey(relayID)
+	kvs, ok, err := b.client.txn(ctx, []txnOp{getOp(key), deleteOp(key)})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// The session may have taken the key with it already; the service
+		// instance still has to go.
+		if err := b.unpublishRelay(ctx, relayID); err != nil {
+			return err
+		}
 		return registry.ErrRelayNotRegistered
 	}
-	delete(b.relays, relayID)
-	return nil
+
+	b.discardSession(ctx, kvs[0].Session)
+	return b.unpublishRelay(ctx, relayID)
 }
 
 func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relayID string) error {
@@ -114,17 +270,63 @@ func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relay
 		agent.LastHeartbeat = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	agentPayload, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	placementPayload, err := json.Marshal(registry.AgentPlacement{AgentID: agent.ID, RelayID: relayID, UpdatedAt: agent.LastHeartbeat})
+	if err != nil {
+		return err
+	}
 
-	if _, ok := b.relays[relayID]; !ok {
-		return registry.ErrRelayNotRegistered
+	agentKey, placementKey := b.agentKey(agent.ID), b.placementKey(agent.ID)
+	existing, err := b.client.get(ctx, agentKey)
+	if err != nil {
+		return err
 	}
-	b.agents[agent.ID] = agent
-	b.placements[agent.ID] = registry.AgentPlacement{
-		AgentID:   agent.ID,
-		RelayID:   relayID,
-		UpdatedAt: agent.LastHeartbeat,
+
+	session, err := b.newSession(ctx, "agent "+agent.ID, b.ttl.Agent)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		relay, err := b.client.get(ctx, b.relayKey(relayID))
+		if err != nil {
+			b.discardSession(ctx, session)
+			return err
+		}
+		if relay == nil {
+			b.discardSession(ctx, session)
+			return registry.ErrRelayNotRegistered
+		}
+
+		// The relay check and the writes share one transaction so a
+		// concurrent RemoveRelay cannot leave an agent placed on a deleted
+		// relay. Checking the relay's session rather than its index keeps
+		// relay heartbeats from failing the check.
+		_, ok, err := b.client.txn(ctx, []txnOp{
+			{KV: &kvOp{Verb: "check-session", Key: relay.Key, Session: relay.Session}},
+			deleteOp(agentKey),
+			writeOp(agentKey, agentPayload, session),
+			deleteOp(placementKey),
+			writeOp(placementKey, placementPayload, session),
+		})
+		if err != nil {
+			b.discardSession(ctx, session)
+			return err
+		}
+		if ok {
+			break
+		}
+		if attempt+1 == registerAttempts {
+			b.discardSession(ctx, session)
+			return fmt.Errorf("consul: register agent %s: relay %s kept changing", agent.ID, relayID)
+		}
+	}
+
+	if existing != nil && existing.Session != session {
+		b.discardSession(ctx, existing.Session)
 	}
 	return nil
 }
@@ -140,23 +342,51 @@ func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Ti
 		ts = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	agent, ok := b.agents[agentID]
+	kvs, ok, err := b.client.txn(ctx, []txnOp{
+		getOp(b.agentKey(agentID)),
+		getOp(b.placementKey(agentID)),
+	})
+	if err != nil {
+		return err
+	}
 	if !ok {
 		return registry.ErrAgentNotRegistered
 	}
-	placement, ok := b.placements[agentID]
-	if !ok {
+	agentKV, placementKV := kvs[0], kvs[1]
+
+	// The agent and its placement share a session.
+	if alive, err := b.renewSession(ctx, agentKV.Session); err != nil || !alive {
+		if err != nil {
+			return err
+		}
 		return registry.ErrAgentNotRegistered
 	}
 
+	var agent registry.Agent
+	if err := json.Unmarshal(agentKV.Value, &agent); err != nil {
+		return err
+	}
+	var placement registry.AgentPlacement
+	if err := json.Unmarshal(placementKV.Value, &placement); err != nil {
+		return err
+	}
 	agent.LastHeartbeat = ts
 	placement.UpdatedAt = ts
-	b.agents[agentID] = agent
-	b.placements[agentID] = placement
-	return nil
+
+	agentPayload, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	placementPayload, err := json.Marshal(placement)
+	if err != nil {
+		return err
+	}
+
+	return b.update(ctx,
+		[]kvPair{agentKV, placementKV},
+		[][]byte{agentPayload, placementPayload},
+		registry.ErrAgentNotRegistered,
+	)
 }
 
 func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
@@ -167,17 +397,157 @@ func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*regis
 		return nil, registry.ErrAgentIDEmpty
 	}
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	kv, err := b.client.get(ctx, b.placementKey(agentID))
+	if err != nil {
+		return nil, err
+	}
+	if kv == nil {
+		return nil, registry.ErrAgentNotRegistered
+	}
+
+	var placement registry.AgentPlacement
+	if err := json.Unmarshal(kv.Value, &placement); err != nil {
+		return nil, err
+	}
+	return &placement, nil
+}
+
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kvs, err := b.client.list(ctx, b.placementsPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	placements := make([]registry.AgentPlacement, 0, len(kvs))
+	for _, kv := range kvs {
+		var placement registry.AgentPlacement
+		if err := json.Unmarshal(kv.Value, &placement); err != nil {
+			return nil, err
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
 
-	placement, ok := b.placements[agentID]
+	agentKey := b.agentKey(agentID)
+	kvs, ok, err := b.client.txn(ctx, []txnOp{
+		getOp(agentKey),
+		deleteOp(agentKey),
+		deleteOp(b.placementKey(agentID)),
+	})
+	if err != nil {
+		return err
+	}
 	if !ok {
-		return nil, registry.ErrAgentNotRegistered
+		return registry.ErrAgentNotRegistered
+	}
+
+	b.discardSession(ctx, kvs[0].Session)
+	return nil
+}
+
+// Watch implements registry.Watcher. Blocking queries on the KV prefix are
+// not followed yet, so the registry polls this backend instead.
+func (b *Backend) Watch(context.Context) (<-chan registry.Event, error) {
+	return nil, registry.ErrWatchUnsupported
+}
+
+// SetTTL returns registry.ErrTTLRestartRequired unless ttl matches the
+// TTLs the backend was constructed with. Consul fixes a session's TTL, and
+// a catalog check's, when it is created, and heartbeats renew the existing
+// ones, so a change would only apply to records registered after it.
+func (b *Backend) SetTTL(ttl registry.TTLConfig) error {
+	if ttl != b.ttl {
+		return fmt.Errorf("consul sessions: %w", registry.ErrTTLRestartRequired)
 	}
-	out := placement
-	return &out, nil
+	return nil
 }
 
 func (b *Backend) Close(ctx context.Context) error {
+	b.client.close()
+	if b.agent != b.client {
+		b.agent.close()
+	}
 	return nil
 }
+
+// newSession returns a session lasting at least ttl, or no session for a
+// zero TTL.
+func (b *Backend) newSession(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", nil
+	}
+	return b.client.createSession(ctx, name, ttl)
+}
+
+// renewSession renews session and reports whether it was still valid. Keys
+// written without a session are always alive.
+func (b *Backend) renewSession(ctx context.Context, session string) (bool, error) {
+	if session == "" {
+		return true, nil
+	}
+	return b.client.renewSession(ctx, session)
+}
+
+// discardSession destroys a session that no longer holds the keys it was
+// created for. Failures are ignored: the session expires on its own.
+func (b *Backend) discardSession(ctx context.Context, session string) {
+	if session == "" {
+		return
+	}
+	_ = b.client.destroySession(ctx, session)
+}
+
+// update rewrites kvs with values, keeping their sessions, provided none of
+// them changed since they were read. If another write got there first and
+// the first key still exists, that write is kept and update succeeds;
+// otherwise the record is gone and notFound is returned.
+func (b *Backend) update(ctx context.Context, kvs []kvPair, values [][]byte, notFound error) error {
+	ops := make([]txnOp, len(kvs))
+	for i, kv := range kvs {
+		ops[i] = txnOp{KV: &kvOp{Verb: "cas", Key: kv.Key, Value: values[i], Index: kv.ModifyIndex}}
+	}
+
+	_, ok, err := b.client.txn(ctx, ops)
+	if err != nil || ok {
+		return err
+	}
+
+	current, err := b.client.get(ctx, kvs[0].Key)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return notFound
+	}
+	return nil
+}
+
+func getOp(key string) txnOp {
+	return txnOp{KV: &kvOp{Verb: "get", Key: key}}
+}
+
+func deleteOp(key string) txnOp {
+	return txnOp{KV: &kvOp{Verb: "delete", Key: key}}
+}
+
+// writeOp sets key to value, locked by session if there is one so the key
+// goes away with the session.
+func writeOp(key string, value []byte, session string) txnOp {
+	if session == "" {
+		return txnOp{KV: &kvOp{Verb: "set", Key: key, Value: value}}
+	}
+	return txnOp{KV: &kvOp{Verb: "lock", Key: key, Value: value, Session: session}}
+}
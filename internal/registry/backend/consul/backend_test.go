@@ -7,101 +7,186 @@ import (
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
 )
 
 var _ registry.Backend = (*Backend)(nil)
 
-func TestRelayAndAgentLifecycle(t *testing.T) {
-	backend, err := New(&registry.ConsulConfig{})
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+func TestNewRequiresValidConfig(t *testing.T) {
+	if _, err := New(nil, registry.TTLConfig{}); !errors.Is(err, registry.ErrConsulConfigNil) {
+		t.Fatalf("expected ErrConsulConfigNil, got %v", err)
+	}
+	if _, err := New(&registry.ConsulConfig{}, registry.TTLConfig{}); !errors.Is(err, registry.ErrConsulAddrEmpty) {
+		t.Fatalf("expected ErrConsulAddrEmpty, got %v", err)
 	}
+}
 
-	ctx := context.Background()
-	now := time.Now()
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		srv := newFakeConsul(t, time.Now)
+		return newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+	})
+}
 
-	relay := registry.Relay{ID: "relay-1", Address: "127.0.0.1", GRPCPort: 50051, LastSeen: now}
-	if err := backend.RegisterRelay(ctx, relay); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
-	}
+func TestSetTTLRequiresRestart(t *testing.T) {
+	ttl := registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}
+	b := newFakeBackend(t, newFakeConsul(t, time.Now), registry.ConsulConfig{}, ttl)
 
-	relays, err := backend.ListRelays(ctx)
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.SetTTL(ttl); err != nil {
+		t.Fatalf("expected unchanged ttls to be accepted, got %v", err)
 	}
-	if len(relays) != 1 {
-		t.Fatalf("expected 1 relay, got %d", len(relays))
+	if err := b.SetTTL(registry.TTLConfig{Relay: 2 * time.Minute, Agent: time.Minute}); !errors.Is(err, registry.ErrTTLRestartRequired) {
+		t.Fatalf("expected ErrTTLRestartRequired, got %v", err)
 	}
+}
 
-	hb := now.Add(time.Second)
-	if err := backend.HeartbeatRelay(ctx, relay.ID, hb); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
-	}
+func TestSessionsExpireRecords(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeConsul(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second})
+	ctx := context.Background()
 
-	agent := registry.Agent{ID: "agent-1", LastHeartbeat: now}
-	if err := backend.RegisterAgent(ctx, agent, relay.ID); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-	if err := backend.HeartbeatAgent(ctx, agent.ID, hb); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
 	}
 
-	placement, err := backend.GetAgentPlacement(ctx, agent.ID)
+	clock.Advance(11 * time.Second)
+	relays, err := b.ListRelays(ctx)
 	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("list relays: %v", err)
 	}
-	if placement.RelayID != relay.ID {
-		t.Fatalf("expected placement relay id %s, got %s", relay.ID, placement.RelayID)
+	if len(relays) != 0 {
+		t.Fatalf("expected relay session to expire, got %+v", relays)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected ErrRelayNotRegistered after expiry, got %v", err)
+	}
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("expected placement to outlive the relay session: %v", err)
 	}
 
-	if err := backend.RemoveRelay(ctx, relay.ID); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	clock.Advance(10 * time.Second)
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, registry.ErrAgentNotRegistered) {
+		t.Fatalf("expected placement session to expire, got %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); !errors.Is(err, registry.ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered after expiry, got %v", err)
 	}
 
-	// Persistence-only behavior: agent state is not cascade-deleted in backend.
-	if _, err := backend.GetAgentPlacement(ctx, agent.ID); err != nil {
-		t.Fatalf("expected placement to remain persisted, got %v", err)
+	// The relay can register again right away; sessions have no lock delay.
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay after expiry: %v", err)
 	}
 }
 
-func TestValidationAndContextErrors(t *testing.T) {
-	backend, err := New(&registry.ConsulConfig{})
+func TestHeartbeatsRenewSessions(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeConsul(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{Relay: 10 * time.Second, Agent: 10 * time.Second})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	for range 3 {
+		clock.Advance(6 * time.Second)
+		if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+			t.Fatalf("heartbeat relay: %v", err)
+		}
+		if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); err != nil {
+			t.Fatalf("heartbeat agent: %v", err)
+		}
+	}
+
+	relays, err := b.ListRelays(ctx)
 	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("list relays: %v", err)
 	}
+	if len(relays) != 1 || !relays[0].LastSeen.Equal(clock.Now()) {
+		t.Fatalf("expected relay with the latest heartbeat, got %+v", relays)
+	}
+	placement, err := b.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if !placement.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected placement updated at %v, got %v", clock.Now(), placement.UpdatedAt)
+	}
+	if srv.session(b.agentKey("agent-1")) != srv.session(b.placementKey("agent-1")) {
+		t.Fatalf("expected agent and placement to share a session")
+	}
+	srv.mu.Lock()
+	renewals := srv.renewals
+	srv.mu.Unlock()
+	if renewals != 6 {
+		t.Fatalf("expected one renewal per heartbeat, got %d", renewals)
+	}
+}
 
+func TestReregisterReplacesSession(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{Prefix: "custom/"}, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
 	ctx := context.Background()
-	if err := backend.RegisterRelay(ctx, registry.Relay{}); !errors.Is(err, registry.ErrRelayIDEmpty) {
-		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "old"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-	if err := backend.HeartbeatAgent(ctx, "", time.Now()); !errors.Is(err, registry.ErrAgentIDEmpty) {
-		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	first := srv.session("custom/relays/relay-1")
+	if first == "" {
+		t.Fatalf("expected relay key to be locked by a session")
 	}
 
-	canceled, cancel := context.WithCancel(context.Background())
-	cancel()
-	if err := backend.RemoveRelay(canceled, "relay-1"); !errors.Is(err, context.Canceled) {
-		t.Fatalf("expected context canceled, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "new"}); err != nil {
+		t.Fatalf("register relay again: %v", err)
+	}
+	if second := srv.session("custom/relays/relay-1"); second == "" || second == first {
+		t.Fatalf("expected a new session, got %q (was %q)", second, first)
+	}
+	if got := srv.sessionCount(); got != 1 {
+		t.Fatalf("expected the old session to be destroyed, %d sessions remain", got)
 	}
-}
 
-func TestNotRegisteredErrors(t *testing.T) {
-	backend, err := New(&registry.ConsulConfig{})
+	relays, err := b.ListRelays(ctx)
 	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].Address != "new" {
+		t.Fatalf("expected the new registration, got %+v", relays)
+	}
+
+	if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+	if got := srv.sessionCount(); got != 0 {
+		t.Fatalf("expected remove to destroy the session, %d sessions remain", got)
 	}
+}
 
+func TestWithoutTTLWritesWithoutSession(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{})
 	ctx := context.Background()
 
-	if err := backend.HeartbeatRelay(ctx, "missing", time.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-
-	if err := backend.RemoveRelay(ctx, "missing"); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
 	}
-
-	if _, err := backend.GetAgentPlacement(ctx, "missing"); !errors.Is(err, registry.ErrAgentNotRegistered) {
-		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	if got := srv.sessionCount(); got != 0 {
+		t.Fatalf("expected no sessions with a zero ttl, got %d", got)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay without session: %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat agent without session: %v", err)
 	}
 }
@@ -0,0 +1,111 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+const (
+	defaultServiceName = "aero-arc-relay"
+
+	// defaultDeregisterAfter bounds how long Consul keeps a relay service
+	// whose check went critical without the registry removing it.
+	defaultDeregisterAfter = 5 * time.Minute
+)
+
+func (b *Backend) serviceID(relayID string) string {
+	return b.catalog.ServiceName + "-" + relayID
+}
+
+func (b *Backend) checkID(relayID string) string {
+	return "service:" + b.serviceID(relayID)
+}
+
+// publishRelay registers relay as an instance of the catalog service. The
+// registration goes through the catalog agent shared by every replica,
+// which keeps it in the catalog and owns its TTL check; heartbeats from any
+// replica pass the check. With a zero relay TTL the service is registered
+// without a check.
+func (b *Backend) publishRelay(ctx context.Context, relay registry.Relay) error {
+	if !b.catalog.Enabled {
+		return nil
+	}
+
+	svc := agentService{
+		ID:      b.serviceID(relay.ID),
+		Name:    b.catalog.ServiceName,
+		Tags:    b.catalog.Tags,
+		Address: relay.Address,
+		Port:    relay.GRPCPort,
+		Meta:    map[string]string{"relay_id": relay.ID},
+	}
+	if b.ttl.Relay > 0 {
+		svc.Check = &agentCheck{
+			CheckID: b.checkID(relay.ID),
+			Name:    "relay heartbeat",
+			TTL:     b.ttl.Relay.String(),
+		}
+		svc.Check.DeregisterCriticalServiceAfter = b.catalog.DeregisterAfter.String()
+	}
+
+	if err := b.agent.registerService(ctx, svc); err != nil {
+		return fmt.Errorf("consul: register relay %s service: %w", relay.ID, err)
+	}
+	if svc.Check == nil {
+		return nil
+	}
+	// A new TTL check starts out critical.
+	if _, err := b.agent.passCheck(ctx, svc.Check.CheckID, "registered"); err != nil {
+		return fmt.Errorf("consul: pass relay %s check: %w", relay.ID, err)
+	}
+	return nil
+}
+
+// refreshRelay passes the relay's TTL check. If the agent does not know the
+// check, because it lost its state or Consul deregistered the critical
+// service, the service is registered again.
+func (b *Backend) refreshRelay(ctx context.Context, relay registry.Relay) error {
+	if !b.catalog.Enabled || b.ttl.Relay <= 0 {
+		return nil
+	}
+
+	known, err := b.agent.passCheck(ctx, b.checkID(relay.ID), "heartbeat")
+	if err != nil {
+		return fmt.Errorf("consul: pass relay %s check: %w", relay.ID, err)
+	}
+	if !known {
+		return b.publishRelay(ctx, relay)
+	}
+	return nil
+}
+
+// catalogFailed logs and counts a failed catalog update. The relay is
+// already stored in the KV store, which the registry serves from, so the
+// register or heartbeat that triggered the update still succeeds, and the
+// relay's next heartbeat tries again.
+func (b *Backend) catalogFailed(relayID string, err error) {
+	b.catalogFailures.Add(1)
+	slog.Warn("consul catalog update failed", "relay_id", relayID, "error", err)
+}
+
+// CatalogFailures returns the number of catalog updates that failed since
+// the backend was created.
+func (b *Backend) CatalogFailures() uint64 {
+	return b.catalogFailures.Load()
+}
+
+// unpublishRelay removes the relay's service instance.
+func (b *Backend) unpublishRelay(ctx context.Context, relayID string) error {
+	if !b.catalog.Enabled {
+		return nil
+	}
+
+	if err := b.agent.deregisterService(ctx, b.serviceID(relayID)); err != nil {
+		return fmt.Errorf("consul: deregister relay %s service: %w", relayID, err)
+	}
+	return nil
+}
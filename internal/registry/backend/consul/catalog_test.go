@@ -0,0 +1,213 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+func TestCatalogPublishesRelays(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeConsul(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true, Tags: []string{"grpc", "prod"}},
+	}, registry.TTLConfig{Relay: 10 * time.Second})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "10.0.0.5", GRPCPort: 9443}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	svc, status, ok := srv.service("aero-arc-relay-relay-1")
+	if !ok {
+		t.Fatalf("expected relay service to be registered")
+	}
+	if svc.Name != "aero-arc-relay" || svc.Address != "10.0.0.5" || svc.Port != 9443 || !slices.Equal(svc.Tags, []string{"grpc", "prod"}) {
+		t.Fatalf("unexpected service registration: %+v", svc)
+	}
+	if svc.Meta["relay_id"] != "relay-1" || svc.Check == nil || svc.Check.TTL != "10s" {
+		t.Fatalf("unexpected service meta or check: %+v", svc)
+	}
+	if status != "passing" {
+		t.Fatalf("expected check passing after registration, got %q", status)
+	}
+
+	clock.Advance(6 * time.Second)
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	clock.Advance(6 * time.Second)
+	if _, status, _ := srv.service("aero-arc-relay-relay-1"); status != "passing" {
+		t.Fatalf("expected heartbeat to keep the check passing, got %q", status)
+	}
+
+	clock.Advance(5 * time.Second)
+	if _, status, _ := srv.service("aero-arc-relay-relay-1"); status != "critical" {
+		t.Fatalf("expected check critical without heartbeats, got %q", status)
+	}
+
+	if err := b.RemoveRelay(ctx, "relay-1"); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected ErrRelayNotRegistered once the session expired, got %v", err)
+	}
+	if _, _, ok := srv.service("aero-arc-relay-relay-1"); ok {
+		t.Fatalf("expected remove to deregister the service even after the session expired")
+	}
+}
+
+func TestCatalogReregistersUnknownCheck(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true, ServiceName: "relays"},
+	}, registry.TTLConfig{Relay: time.Minute})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", GRPCPort: 9443}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// The agent lost its local state, or another replica's agent holds it.
+	srv.mu.Lock()
+	srv.deregister("relays-relay-1")
+	srv.mu.Unlock()
+
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	svc, status, ok := srv.service("relays-relay-1")
+	if !ok || svc.Port != 9443 || status != "passing" {
+		t.Fatalf("expected heartbeat to register the service again, got %+v (%q)", svc, status)
+	}
+
+	if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+	if _, _, ok := srv.service("relays-relay-1"); ok {
+		t.Fatalf("expected service to be deregistered")
+	}
+}
+
+func TestCatalogSharesOneAgentAcrossReplicas(t *testing.T) {
+	catalogAgent := newFakeConsul(t, time.Now)
+	cfg := registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true, Address: catalogAgent.addr()},
+	}
+	ttl := registry.TTLConfig{Relay: time.Minute}
+
+	// Each replica talks to its own local agent.
+	localAgents := []*fakeConsul{newFakeConsul(t, time.Now), newFakeConsul(t, time.Now)}
+	for i, local := range localAgents {
+		b := newFakeBackend(t, local, cfg, ttl)
+		if err := b.RegisterRelay(context.Background(), registry.Relay{ID: "relay-1", GRPCPort: 9440 + i}); err != nil {
+			t.Fatalf("register relay on replica %d: %v", i, err)
+		}
+	}
+
+	for i, local := range localAgents {
+		local.mu.Lock()
+		services := len(local.services)
+		local.mu.Unlock()
+		if services != 0 {
+			t.Fatalf("expected no services on replica %d's local agent, got %d", i, services)
+		}
+	}
+
+	catalogAgent.mu.Lock()
+	services := len(catalogAgent.services)
+	catalogAgent.mu.Unlock()
+	if services != 1 {
+		t.Fatalf("expected one relay instance on the catalog agent, got %d", services)
+	}
+	if svc, status, _ := catalogAgent.service("aero-arc-relay-relay-1"); svc.Port != 9441 || status != "passing" {
+		t.Fatalf("expected the last registration to win, got %+v (%q)", svc, status)
+	}
+}
+
+func TestCatalogFailureDoesNotFailRelayWrites(t *testing.T) {
+	catalogAgent := newFakeConsul(t, time.Now)
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true, Address: catalogAgent.addr()},
+	}, registry.TTLConfig{Relay: time.Minute})
+	ctx := context.Background()
+
+	// The catalog agent is unreachable; the KV store is not.
+	catalogAgent.srv.Close()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("expected register to succeed without the catalog, got %v", err)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("expected heartbeat to succeed without the catalog, got %v", err)
+	}
+	if got := b.CatalogFailures(); got != 2 {
+		t.Fatalf("expected 2 catalog failures, got %d", got)
+	}
+
+	relays, err := b.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].ID != "relay-1" {
+		t.Fatalf("expected relay-1 to be stored, got %+v", relays)
+	}
+}
+
+func TestCatalogDeregisterAfterDefault(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true},
+	}, registry.TTLConfig{Relay: 10 * time.Second})
+
+	if err := b.RegisterRelay(context.Background(), registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	svc, _, _ := srv.service("aero-arc-relay-relay-1")
+	if svc.Check == nil || svc.Check.DeregisterCriticalServiceAfter != "5m0s" {
+		t.Fatalf("expected the default deregister timeout on the check, got %+v", svc.Check)
+	}
+}
+
+func TestCatalogDeregisterAfter(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeConsul(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{
+		Catalog: registry.ConsulCatalogConfig{Enabled: true, DeregisterAfter: time.Minute},
+	}, registry.TTLConfig{Relay: 10 * time.Second})
+
+	if err := b.RegisterRelay(context.Background(), registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	svc, _, _ := srv.service("aero-arc-relay-relay-1")
+	if svc.Check == nil || svc.Check.DeregisterCriticalServiceAfter != "1m0s" {
+		t.Fatalf("expected deregister timeout on the check, got %+v", svc.Check)
+	}
+
+	clock.Advance(71 * time.Second)
+	if _, _, ok := srv.service("aero-arc-relay-relay-1"); ok {
+		t.Fatalf("expected consul to drop the critical service")
+	}
+}
+
+func TestCatalogDisabled(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	b := newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{Relay: time.Minute})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+
+	srv.mu.Lock()
+	services, passes := len(srv.services), srv.passes
+	srv.mu.Unlock()
+	if services != 0 || passes != 0 {
+		t.Fatalf("expected no catalog calls, got %d services and %d check passes", services, passes)
+	}
+}
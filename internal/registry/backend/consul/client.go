@@ -0,0 +1,305 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Consul refuses session TTLs outside this range.
+	minSessionTTL = 10 * time.Second
+	maxSessionTTL = 24 * time.Hour
+)
+
+// client is a minimal Consul HTTP API client covering the KV, transaction,
+// session and agent service endpoints the backend needs.
+type client struct {
+	base       url.URL
+	token      string
+	datacenter string
+	http       *http.Client
+}
+
+func newClient(address string, tlsCfg *tls.Config, token, datacenter string) *client {
+	scheme := "http"
+	if tlsCfg != nil {
+		scheme = "https"
+	}
+
+	return &client{
+		base:       url.URL{Scheme: scheme, Host: address},
+		token:      token,
+		datacenter: datacenter,
+		http: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:     tlsCfg,
+			MaxIdleConnsPerHost: 16,
+		}},
+	}
+}
+
+// apiError is a non-success reply from Consul.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("consul: %d %s", e.Status, e.Message)
+}
+
+// kvPair is a KV entry as returned by the KV and transaction endpoints.
+// Value is base64 on the wire, which encoding/json handles for []byte.
+type kvPair struct {
+	Key         string
+	Value       []byte
+	Session     string `json:",omitempty"`
+	CreateIndex uint64 `json:",omitempty"`
+	ModifyIndex uint64 `json:",omitempty"`
+}
+
+// kvOp is one KV operation in a transaction. Only the fields the verb uses
+// are set.
+type kvOp struct {
+	Verb    string
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Index   uint64 `json:",omitempty"`
+	Session string `json:",omitempty"`
+}
+
+type txnOp struct {
+	KV *kvOp
+}
+
+type txnResult struct {
+	KV *kvPair
+}
+
+type txnError struct {
+	OpIndex int
+	What    string
+}
+
+type txnResponse struct {
+	Results []txnResult
+	Errors  []txnError
+}
+
+type sessionRequest struct {
+	Name      string
+	TTL       string
+	Behavior  string
+	LockDelay string
+	// Checks is sent even when empty so the session is not tied to the
+	// agent's serfHealth check.
+	Checks []string
+}
+
+type sessionResponse struct {
+	ID string
+}
+
+// agentService is a service registration on the local Consul agent, which
+// syncs it to the catalog.
+type agentService struct {
+	ID      string
+	Name    string
+	Tags    []string          `json:",omitempty"`
+	Address string            `json:",omitempty"`
+	Port    int               `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Check   *agentCheck       `json:",omitempty"`
+}
+
+type agentCheck struct {
+	CheckID                        string
+	Name                           string
+	TTL                            string
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
+func (c *client) get(ctx context.Context, key string) (*kvPair, error) {
+	var pairs []kvPair
+	status, err := c.do(ctx, http.MethodGet, "/v1/kv/"+key, nil, nil, &pairs)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound || len(pairs) == 0 {
+		return nil, nil
+	}
+	return &pairs[0], nil
+}
+
+// list returns every key under prefix.
+func (c *client) list(ctx context.Context, prefix string) ([]kvPair, error) {
+	var pairs []kvPair
+	query := url.Values{"recurse": {"true"}}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/kv/"+prefix, query, nil, &pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// txn applies ops atomically. It reports false, without an error, when
+// Consul rolled the transaction back because one of the ops failed, and
+// otherwise returns the entries produced by the ops that return one.
+func (c *client) txn(ctx context.Context, ops []txnOp) ([]kvPair, bool, error) {
+	var resp txnResponse
+	status, err := c.do(ctx, http.MethodPut, "/v1/txn", nil, ops, &resp)
+	if err != nil {
+		return nil, false, err
+	}
+	if status == http.StatusConflict {
+		return nil, false, nil
+	}
+
+	pairs := make([]kvPair, 0, len(resp.Results))
+	for _, result := range resp.Results {
+		if result.KV != nil {
+			pairs = append(pairs, *result.KV)
+		}
+	}
+	return pairs, true, nil
+}
+
+// createSession creates a session that Consul invalidates once ttl passes
+// without a renewal, deleting every key it holds. ttl is rounded up to
+// whole seconds and clamped to the range Consul accepts. The lock delay is
+// disabled so a key can be locked again as soon as its session is gone.
+func (c *client) createSession(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	req := sessionRequest{
+		Name:      name,
+		TTL:       sessionTTL(ttl),
+		Behavior:  "delete",
+		LockDelay: "0s",
+		Checks:    []string{},
+	}
+	var resp sessionResponse
+	if _, err := c.do(ctx, http.MethodPut, "/v1/session/create", nil, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// renewSession resets the TTL of a session and reports whether it was
+// still valid.
+func (c *client) renewSession(ctx context.Context, id string) (bool, error) {
+	status, err := c.do(ctx, http.MethodPut, "/v1/session/renew/"+id, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return status != http.StatusNotFound, nil
+}
+
+// destroySession invalidates a session, deleting the keys it still holds.
+func (c *client) destroySession(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPut, "/v1/session/destroy/"+id, nil, nil, nil)
+	return err
+}
+
+// registerService registers svc with the agent, replacing any earlier
+// registration with the same ID and its checks.
+func (c *client) registerService(ctx context.Context, svc agentService) error {
+	query := url.Values{"replace-existing-checks": {"true"}}
+	_, err := c.do(ctx, http.MethodPut, "/v1/agent/service/register", query, svc, nil)
+	return err
+}
+
+// passCheck marks a TTL check as passing and reports whether the agent
+// knows the check.
+func (c *client) passCheck(ctx context.Context, id, note string) (bool, error) {
+	query := url.Values{"note": {note}}
+	status, err := c.do(ctx, http.MethodPut, "/v1/agent/check/pass/"+id, query, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return status != http.StatusNotFound, nil
+}
+
+// deregisterService removes a service from the agent. A service the agent
+// does not know is not an error.
+func (c *client) deregisterService(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+id, nil, nil, nil)
+	return err
+}
+
+// do sends a request and decodes the reply into resp. Not found and, for
+// transactions, conflict replies are returned as a status for the caller
+// to interpret; any other non-success reply becomes an apiError.
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, resp any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	if c.datacenter != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("dc", c.datacenter)
+	}
+	u := c.base
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	httpResp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	switch httpResp.StatusCode {
+	case http.StatusOK, http.StatusConflict:
+		if resp != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, resp); err != nil {
+				return 0, err
+			}
+		}
+		return httpResp.StatusCode, nil
+	case http.StatusNotFound:
+		return httpResp.StatusCode, nil
+	default:
+		return 0, &apiError{Status: httpResp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+}
+
+func (c *client) close() {
+	c.http.CloseIdleConnections()
+}
+
+// sessionTTL formats ttl for a session request, rounded up to whole seconds
+// so the session never expires before the registry's own TTL check would.
+func sessionTTL(ttl time.Duration) string {
+	ttl = (ttl + time.Second - 1).Truncate(time.Second)
+	ttl = min(max(ttl, minSessionTTL), maxSessionTTL)
+	return strconv.FormatInt(int64(ttl/time.Second), 10) + "s"
+}
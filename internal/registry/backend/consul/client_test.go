@@ -0,0 +1,464 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+func TestClientSendsTokenAndDatacenter(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	srv.token = "secret"
+	ctx := context.Background()
+
+	good := newFakeBackend(t, srv, registry.ConsulConfig{Token: "secret", Datacenter: "dc1"}, registry.TTLConfig{Relay: time.Minute})
+	if err := good.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay with token: %v", err)
+	}
+	if err := good.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay with token: %v", err)
+	}
+
+	anonymous := newFakeBackend(t, srv, registry.ConsulConfig{}, registry.TTLConfig{})
+	if _, err := anonymous.ListRelays(ctx); err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Fatalf("expected permission error without a token, got %v", err)
+	}
+
+	otherDC := newFakeBackend(t, srv, registry.ConsulConfig{Token: "secret", Datacenter: "dc2"}, registry.TTLConfig{})
+	if _, err := otherDC.ListRelays(ctx); err == nil || !strings.Contains(err.Error(), "No path to datacenter") {
+		t.Fatalf("expected datacenter error, got %v", err)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := newFakeConsul(t, time.Now)
+	tlsSrv := httptest.NewTLSServer(srv.handler())
+	t.Cleanup(tlsSrv.Close)
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0o600); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	address := strings.TrimPrefix(tlsSrv.URL, "https://")
+
+	tests := []struct {
+		name    string
+		tls     registry.ClientTLSConfig
+		wantErr bool
+	}{
+		{name: "verified with ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: caPath}},
+		{name: "unknown authority", tls: registry.ClientTLSConfig{Enabled: true}, wantErr: true},
+		{name: "insecure skip verify", tls: registry.ClientTLSConfig{Enabled: true, InsecureSkipVerify: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := New(&registry.ConsulConfig{Address: address, TLS: test.tls}, registry.TTLConfig{})
+			if err != nil {
+				t.Fatalf("new backend: %v", err)
+			}
+			defer b.Close(context.Background())
+
+			_, err = b.ListRelays(context.Background())
+			if test.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{ttl: time.Second, want: "10s"},
+		{ttl: 15 * time.Second, want: "15s"},
+		{ttl: 15*time.Second + time.Millisecond, want: "16s"},
+		{ttl: 48 * time.Hour, want: "86400s"},
+	}
+
+	for _, test := range tests {
+		if got := sessionTTL(test.ttl); got != test.want {
+			t.Fatalf("sessionTTL(%v) = %q, want %q", test.ttl, got, test.want)
+		}
+	}
+}
+
+func newFakeBackend(t *testing.T, srv *fakeConsul, cfg registry.ConsulConfig, ttl registry.TTLConfig) *Backend {
+	t.Helper()
+
+	cfg.Address = srv.addr()
+	b, err := New(&cfg, ttl)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
+
+// fakeConsul is an in-process stand-in for the Consul HTTP API. It
+// implements the KV, transaction, session and agent service endpoints the
+// backend uses, with sessions and checks expiring against an injectable
+// clock. Unlike Consul, which
+// may wait up to twice the TTL, sessions expire exactly at their TTL.
+type fakeConsul struct {
+	srv *httptest.Server
+	now func() time.Time
+
+	// token, when set, is the only ACL token accepted.
+	token string
+	// datacenter is the only datacenter known to the fake.
+	datacenter string
+
+	mu          sync.Mutex
+	index       uint64
+	kvs         map[string]kvPair
+	sessions    map[string]*fakeSession
+	nextSession int
+	renewals    int
+	services    map[string]agentService
+	checks      map[string]*fakeCheck
+	passes      int
+}
+
+type fakeSession struct {
+	ttl      time.Duration
+	behavior string
+	expires  time.Time
+}
+
+// fakeCheck is a TTL check. It is critical once expires passes and its
+// service is removed deregisterAfter later, if that is set.
+type fakeCheck struct {
+	serviceID       string
+	ttl             time.Duration
+	deregisterAfter time.Duration
+	expires         time.Time
+}
+
+func newFakeConsul(t *testing.T, now func() time.Time) *fakeConsul {
+	t.Helper()
+
+	f := &fakeConsul{
+		now:        now,
+		datacenter: "dc1",
+		kvs:        make(map[string]kvPair),
+		sessions:   make(map[string]*fakeSession),
+		services:   make(map[string]agentService),
+		checks:     make(map[string]*fakeCheck),
+	}
+	f.srv = httptest.NewServer(f.handler())
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeConsul) addr() string {
+	return strings.TrimPrefix(f.srv.URL, "http://")
+}
+
+// session returns the session holding key, or "" if the key is missing or
+// unlocked.
+func (f *fakeConsul) session(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expire()
+	return f.kvs[key].Session
+}
+
+func (f *fakeConsul) sessionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expire()
+	return len(f.sessions)
+}
+
+// service returns the registered service with id and the status of its
+// check, "" if it has none.
+func (f *fakeConsul) service(id string) (agentService, string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expire()
+	svc, ok := f.services[id]
+	if !ok || svc.Check == nil {
+		return svc, "", ok
+	}
+	if f.now().Before(f.checks[svc.Check.CheckID].expires) {
+		return svc, "passing", true
+	}
+	return svc, "critical", true
+}
+
+func (f *fakeConsul) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.expire()
+
+		if f.token != "" && r.Header.Get("X-Consul-Token") != f.token {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+		if dc := r.URL.Query().Get("dc"); dc != "" && dc != f.datacenter {
+			http.Error(w, "No path to datacenter", http.StatusInternalServerError)
+			return
+		}
+
+		path := r.URL.Path
+		switch {
+		case strings.HasPrefix(path, "/v1/kv/") && r.Method == http.MethodGet:
+			f.getKV(w, strings.TrimPrefix(path, "/v1/kv/"), r.URL.Query().Has("recurse"))
+		case path == "/v1/txn" && r.Method == http.MethodPut:
+			var ops []txnOp
+			decode(r, &ops)
+			f.txn(w, ops)
+		case path == "/v1/session/create" && r.Method == http.MethodPut:
+			var req sessionRequest
+			decode(r, &req)
+			f.createSession(w, req)
+		case strings.HasPrefix(path, "/v1/session/renew/") && r.Method == http.MethodPut:
+			id := strings.TrimPrefix(path, "/v1/session/renew/")
+			session, ok := f.sessions[id]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Session id '%s' not found", id), http.StatusNotFound)
+				return
+			}
+			f.renewals++
+			session.expires = f.now().Add(session.ttl)
+			writeJSON(w, []map[string]string{{"ID": id}})
+		case strings.HasPrefix(path, "/v1/session/destroy/") && r.Method == http.MethodPut:
+			f.invalidate(strings.TrimPrefix(path, "/v1/session/destroy/"))
+			writeJSON(w, true)
+		case path == "/v1/agent/service/register" && r.Method == http.MethodPut:
+			var svc agentService
+			decode(r, &svc)
+			f.registerService(w, svc)
+		case strings.HasPrefix(path, "/v1/agent/check/pass/") && r.Method == http.MethodPut:
+			check, ok := f.checks[strings.TrimPrefix(path, "/v1/agent/check/pass/")]
+			if !ok {
+				http.Error(w, "Unknown check ID", http.StatusNotFound)
+				return
+			}
+			f.passes++
+			check.expires = f.now().Add(check.ttl)
+		case strings.HasPrefix(path, "/v1/agent/service/deregister/") && r.Method == http.MethodPut:
+			id := strings.TrimPrefix(path, "/v1/agent/service/deregister/")
+			if _, ok := f.services[id]; !ok {
+				http.Error(w, "Unknown service ID", http.StatusNotFound)
+				return
+			}
+			f.deregister(id)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// expire invalidates sessions past their deadline and deregisters services
+// whose check stayed critical for their deregistration timeout.
+func (f *fakeConsul) expire() {
+	for id, session := range f.sessions {
+		if f.now().Before(session.expires) {
+			continue
+		}
+		f.invalidate(id)
+	}
+	for _, check := range f.checks {
+		if check.deregisterAfter > 0 && !f.now().Before(check.expires.Add(check.deregisterAfter)) {
+			f.deregister(check.serviceID)
+		}
+	}
+}
+
+func (f *fakeConsul) registerService(w http.ResponseWriter, svc agentService) {
+	if svc.ID == "" || svc.Name == "" {
+		http.Error(w, "Missing service name", http.StatusBadRequest)
+		return
+	}
+
+	f.deregister(svc.ID)
+	if svc.Check != nil {
+		ttl, err := time.ParseDuration(svc.Check.TTL)
+		if err != nil {
+			http.Error(w, "Invalid check TTL", http.StatusBadRequest)
+			return
+		}
+		check := &fakeCheck{serviceID: svc.ID, ttl: ttl, expires: f.now()}
+		if svc.Check.DeregisterCriticalServiceAfter != "" {
+			if check.deregisterAfter, err = time.ParseDuration(svc.Check.DeregisterCriticalServiceAfter); err != nil {
+				http.Error(w, "Invalid deregister timeout", http.StatusBadRequest)
+				return
+			}
+		}
+		f.checks[svc.Check.CheckID] = check
+	}
+	f.services[svc.ID] = svc
+}
+
+func (f *fakeConsul) deregister(serviceID string) {
+	svc, ok := f.services[serviceID]
+	if !ok {
+		return
+	}
+	delete(f.services, serviceID)
+	if svc.Check != nil {
+		delete(f.checks, svc.Check.CheckID)
+	}
+}
+
+// invalidate removes a session and, following its behavior, deletes or
+// releases the keys it holds.
+func (f *fakeConsul) invalidate(id string) {
+	session, ok := f.sessions[id]
+	if !ok {
+		return
+	}
+	delete(f.sessions, id)
+
+	for key, kv := range f.kvs {
+		if kv.Session != id {
+			continue
+		}
+		if session.behavior == "delete" {
+			delete(f.kvs, key)
+			continue
+		}
+		kv.Session = ""
+		f.kvs[key] = kv
+	}
+}
+
+func (f *fakeConsul) getKV(w http.ResponseWriter, key string, recurse bool) {
+	var pairs []kvPair
+	for k, kv := range f.kvs {
+		if k == key || (recurse && strings.HasPrefix(k, key)) {
+			pairs = append(pairs, kv)
+		}
+	}
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	slices.SortFunc(pairs, func(a, b kvPair) int { return strings.Compare(a.Key, b.Key) })
+	writeJSON(w, pairs)
+}
+
+func (f *fakeConsul) createSession(w http.ResponseWriter, req sessionRequest) {
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil || ttl < minSessionTTL || ttl > maxSessionTTL {
+		http.Error(w, fmt.Sprintf("Invalid Session TTL '%s'", req.TTL), http.StatusBadRequest)
+		return
+	}
+
+	f.nextSession++
+	id := fmt.Sprintf("session-%d", f.nextSession)
+	f.sessions[id] = &fakeSession{ttl: ttl, behavior: req.Behavior, expires: f.now().Add(ttl)}
+	writeJSON(w, sessionResponse{ID: id})
+}
+
+// txn applies ops to a copy of the store and commits it only if every op
+// succeeds, as Consul does.
+func (f *fakeConsul) txn(w http.ResponseWriter, ops []txnOp) {
+	kvs := make(map[string]kvPair, len(f.kvs))
+	for k, kv := range f.kvs {
+		kvs[k] = kv
+	}
+	index := f.index + 1
+
+	var (
+		results []txnResult
+		errs    []txnError
+	)
+	for i, op := range ops {
+		kv, exists := kvs[op.KV.Key]
+		fail := func(what string) {
+			errs = append(errs, txnError{OpIndex: i, What: what})
+		}
+
+		switch op.KV.Verb {
+		case "get":
+			if !exists {
+				fail(fmt.Sprintf("key %q doesn't exist", op.KV.Key))
+				continue
+			}
+		case "check-session":
+			if !exists {
+				fail(fmt.Sprintf("key %q doesn't exist", op.KV.Key))
+				continue
+			}
+			if kv.Session != op.KV.Session {
+				fail(fmt.Sprintf("key %q is not currently locked by session %q", op.KV.Key, op.KV.Session))
+				continue
+			}
+		case "set", "cas", "lock":
+			if op.KV.Verb == "cas" && (exists && kv.ModifyIndex != op.KV.Index || !exists && op.KV.Index != 0) {
+				fail(fmt.Sprintf("failed to set key %q, index is stale", op.KV.Key))
+				continue
+			}
+			if op.KV.Verb == "lock" {
+				if _, ok := f.sessions[op.KV.Session]; !ok {
+					fail(fmt.Sprintf("invalid session %q", op.KV.Session))
+					continue
+				}
+				if exists && kv.Session != "" && kv.Session != op.KV.Session {
+					fail(fmt.Sprintf("failed to lock key %q, lock is already held", op.KV.Key))
+					continue
+				}
+			}
+			if !exists {
+				kv = kvPair{Key: op.KV.Key, CreateIndex: index}
+			}
+			if op.KV.Verb == "lock" {
+				kv.Session = op.KV.Session
+			}
+			kv.Value = op.KV.Value
+			kv.ModifyIndex = index
+			kvs[op.KV.Key] = kv
+		case "delete":
+			delete(kvs, op.KV.Key)
+			continue
+		default:
+			fail("unsupported verb " + op.KV.Verb)
+			continue
+		}
+
+		result := kv
+		results = append(results, txnResult{KV: &result})
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, txnResponse{Errors: errs})
+		return
+	}
+	f.kvs = kvs
+	f.index = index
+	writeJSON(w, txnResponse{Results: results})
+}
+
+func decode(r *http.Request, v any) {
+	_ = json.NewDecoder(r.Body).Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,24 @@
+package consul
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.ConsulRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.Consul, cfg.TTL)
+		},
+	})
+}
+
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.Consul == nil {
+		return registry.ErrConsulConfigNil
+	}
+
+	return cfg.Consul.Validate()
+}
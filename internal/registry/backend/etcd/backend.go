@@ -1,32 +1,83 @@
-// Package etcd provides an etcd-shaped backend implementation.
+// Package etcd provides an etcd v3 backend implementation.
 package etcd
 
 import (
 	"context"
-	"sync"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/clienttls"
 )
 
-type Backend struct {
-	cfg *registry.EtcdConfig
+const (
+	defaultPrefix      = "/aero-arc-registry/"
+	defaultDialTimeout = 5 * time.Second
+)
 
-	mu         sync.RWMutex
-	relays     map[string]registry.Relay
-	agents     map[string]registry.Agent
-	placements map[string]registry.AgentPlacement
+type Backend struct {
+	cfg    *registry.EtcdConfig
+	ttl    registry.TTLConfig
+	prefix string
+	client *client
 }
 
-func New(cfg *registry.EtcdConfig) (*Backend, error) {
+// New returns an etcd backend. Relay keys are attached to a lease of
+// ttl.Relay and agent and placement keys share a lease of ttl.Agent, so
+// etcd drops dead state on its own even when no registry replica is
+// running. Heartbeats renew the lease with a single keep-alive. A zero TTL
+// writes the corresponding keys without a lease.
+func New(cfg *registry.EtcdConfig, ttl registry.TTLConfig) (*Backend, error) {
+	if cfg == nil {
+		return nil, registry.ErrEtcdConfigNil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := clienttls.Load("etcd", cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
 	return &Backend{
-		cfg:        cfg,
-		relays:     make(map[string]registry.Relay),
-		agents:     make(map[string]registry.Agent),
-		placements: make(map[string]registry.AgentPlacement),
+		cfg:    cfg,
+		ttl:    ttl,
+		prefix: prefix,
+		client: newClient(cfg.Endpoints, dialTimeout, tlsCfg, cfg.Username, cfg.Password),
 	}, nil
 }
 
+func (b *Backend) relaysPrefix() string {
+	return b.prefix + "relays/"
+}
+
+func (b *Backend) placementsPrefix() string {
+	return b.prefix + "placements/"
+}
+
+func (b *Backend) relayKey(relayID string) string {
+	return b.relaysPrefix() + relayID
+}
+
+func (b *Backend) agentKey(agentID string) string {
+	return b.prefix + "agents/" + agentID
+}
+
+func (b *Backend) placementKey(agentID string) string {
+	return b.placementsPrefix() + agentID
+}
+
 func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -38,10 +89,16 @@ func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error
 		relay.LastSeen = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.relays[relay.ID] = relay
-	return nil
+	payload, err := json.Marshal(relay)
+	if err != nil {
+		return err
+	}
+
+	lease, err := b.grant(ctx, b.ttl.Relay)
+	if err != nil {
+		return err
+	}
+	return b.client.put(ctx, b.relayKey(relay.ID), payload, lease)
 }
 
 func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Time) error {
@@ -55,16 +112,31 @@ func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Ti
 		ts = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	relay, ok := b.relays[relayID]
-	if !ok {
+	kv, err := b.client.get(ctx, b.relayKey(relayID))
+	if err != nil {
+		return err
+	}
+	if kv == nil {
 		return registry.ErrRelayNotRegistered
 	}
+	if alive, err := b.keepAlive(ctx, kv.Lease); err != nil || !alive {
+		if err != nil {
+			return err
+		}
+		return registry.ErrRelayNotRegistered
+	}
+
+	var relay registry.Relay
+	if err := json.Unmarshal(kv.Value, &relay); err != nil {
+		return err
+	}
 	relay.LastSeen = ts
-	b.relays[relayID] = relay
-	return nil
+	payload, err := json.Marshal(relay)
+	if err != nil {
+		return err
+	}
+
+	return b.update(ctx, []keyValue{*kv}, [][]byte{payload}, registry.ErrRelayNotRegistered)
 }
 
 func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
@@ -72,11 +144,17 @@ func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
 		return nil, err
 	}
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	kvs, err := b.client.list(ctx, b.relaysPrefix())
+	if err != nil {
+		return nil, err
+	}
 
-	relays := make([]registry.Relay, 0, len(b.relays))
-	for _, relay := range b.relays {
+	relays := make([]registry.Relay, 0, len(kvs))
+	for _, kv := range kvs {
+		var relay registry.Relay
+		if err := json.Unmarshal(kv.Value, &relay); err != nil {
+			return nil, err
+		}
 		relays = append(relays, relay)
 	}
 	return relays, nil
@@ -90,13 +168,15 @@ func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
 		return registry.ErrRelayIDEmpty
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if _, ok := b.relays[relayID]; !ok {
+	// Persistence-only responsibility: the relay's lease is left to
+	// expire, and dependent agents are invalidated by the registry layer.
+	deleted, err := b.client.delete(ctx, b.relayKey(relayID))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
 		return registry.ErrRelayNotRegistered
 	}
-	delete(b.relays, relayID)
 	return nil
 }
 
@@ -114,17 +194,34 @@ func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relay
 		agent.LastHeartbeat = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	agentPayload, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	placementPayload, err := json.Marshal(registry.AgentPlacement{AgentID: agent.ID, RelayID: relayID, UpdatedAt: agent.LastHeartbeat})
+	if err != nil {
+		return err
+	}
+
+	lease, err := b.grant(ctx, b.ttl.Agent)
+	if err != nil {
+		return err
+	}
 
-	if _, ok := b.relays[relayID]; !ok {
-		return registry.ErrRelayNotRegistered
+	// The relay check and the writes share one transaction so a concurrent
+	// RemoveRelay cannot leave an agent placed on a deleted relay.
+	resp, err := b.client.txn(ctx, txnRequest{
+		Compare: []compare{{Key: []byte(b.relayKey(relayID)), Target: "VERSION", Result: "GREATER"}},
+		Success: []requestOp{
+			{RequestPut: &putRequest{Key: []byte(b.agentKey(agent.ID)), Value: agentPayload, Lease: lease}},
+			{RequestPut: &putRequest{Key: []byte(b.placementKey(agent.ID)), Value: placementPayload, Lease: lease}},
+		},
+	})
+	if err != nil {
+		return err
 	}
-	b.agents[agent.ID] = agent
-	b.placements[agent.ID] = registry.AgentPlacement{
-		AgentID:   agent.ID,
-		RelayID:   relayID,
-		UpdatedAt: agent.LastHeartbeat,
+	if !resp.Succeeded {
+		return registry.ErrRelayNotRegistered
 	}
 	return nil
 }
@@ -140,23 +237,53 @@ func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Ti
 		ts = time.Now()
 	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	agent, ok := b.agents[agentID]
-	if !ok {
+	resp, err := b.client.txn(ctx, txnRequest{
+		Success: []requestOp{
+			{RequestRange: &rangeRequest{Key: []byte(b.agentKey(agentID))}},
+			{RequestRange: &rangeRequest{Key: []byte(b.placementKey(agentID))}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	agentKV, placementKV := firstKV(resp, 0), firstKV(resp, 1)
+	if agentKV == nil || placementKV == nil {
 		return registry.ErrAgentNotRegistered
 	}
-	placement, ok := b.placements[agentID]
-	if !ok {
+
+	// The agent and its placement share a lease.
+	if alive, err := b.keepAlive(ctx, agentKV.Lease); err != nil || !alive {
+		if err != nil {
+			return err
+		}
 		return registry.ErrAgentNotRegistered
 	}
 
+	var agent registry.Agent
+	if err := json.Unmarshal(agentKV.Value, &agent); err != nil {
+		return err
+	}
+	var placement registry.AgentPlacement
+	if err := json.Unmarshal(placementKV.Value, &placement); err != nil {
+		return err
+	}
 	agent.LastHeartbeat = ts
 	placement.UpdatedAt = ts
-	b.agents[agentID] = agent
-	b.placements[agentID] = placement
-	return nil
+
+	agentPayload, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	placementPayload, err := json.Marshal(placement)
+	if err != nil {
+		return err
+	}
+
+	return b.update(ctx,
+		[]keyValue{*agentKV, *placementKV},
+		[][]byte{agentPayload, placementPayload},
+		registry.ErrAgentNotRegistered,
+	)
 }
 
 func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
@@ -167,17 +294,143 @@ func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*regis
 		return nil, registry.ErrAgentIDEmpty
 	}
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	placement, ok := b.placements[agentID]
-	if !ok {
+	kv, err := b.client.get(ctx, b.placementKey(agentID))
+	if err != nil {
+		return nil, err
+	}
+	if kv == nil {
 		return nil, registry.ErrAgentNotRegistered
 	}
-	out := placement
-	return &out, nil
+
+	var placement registry.AgentPlacement
+	if err := json.Unmarshal(kv.Value, &placement); err != nil {
+		return nil, err
+	}
+	return &placement, nil
+}
+
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kvs, err := b.client.list(ctx, b.placementsPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	placements := make([]registry.AgentPlacement, 0, len(kvs))
+	for _, kv := range kvs {
+		var placement registry.AgentPlacement
+		if err := json.Unmarshal(kv.Value, &placement); err != nil {
+			return nil, err
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+
+	resp, err := b.client.txn(ctx, txnRequest{
+		Compare: []compare{{Key: []byte(b.agentKey(agentID)), Target: "VERSION", Result: "GREATER"}},
+		Success: []requestOp{
+			{RequestDeleteRange: &deleteRangeRequest{Key: []byte(b.agentKey(agentID))}},
+			{RequestDeleteRange: &deleteRangeRequest{Key: []byte(b.placementKey(agentID))}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return registry.ErrAgentNotRegistered
+	}
+	return nil
+}
+
+// Watch implements registry.Watcher. The JSON gateway client has no
+// streaming watch, so the registry polls this backend instead.
+func (b *Backend) Watch(context.Context) (<-chan registry.Event, error) {
+	return nil, registry.ErrWatchUnsupported
+}
+
+// SetTTL returns registry.ErrTTLRestartRequired unless ttl matches the
+// TTLs the backend was constructed with. Every key is attached to a lease
+// granted for the old TTL and heartbeats keep that lease alive, so a new
+// TTL would never reach live records.
+func (b *Backend) SetTTL(ttl registry.TTLConfig) error {
+	if ttl != b.ttl {
+		return fmt.Errorf("etcd leases: %w", registry.ErrTTLRestartRequired)
+	}
+	return nil
 }
 
 func (b *Backend) Close(ctx context.Context) error {
+	b.client.close()
+	return nil
+}
+
+// grant returns a lease lasting at least ttl, or no lease for a zero TTL.
+// etcd leases have second granularity, so ttl is rounded up; the lease
+// never expires before the registry's own TTL check would.
+func (b *Backend) grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, nil
+	}
+	return b.client.grant(ctx, leaseSeconds(ttl))
+}
+
+func leaseSeconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+// keepAlive renews lease and reports whether it was still alive. Keys
+// written without a lease are always alive.
+func (b *Backend) keepAlive(ctx context.Context, lease int64) (bool, error) {
+	if lease == 0 {
+		return true, nil
+	}
+	return b.client.keepAliveOnce(ctx, lease)
+}
+
+// update rewrites kvs with values, keeping their leases, provided none of
+// them changed since they were read. If another write got there first and
+// the first key still exists, that write is kept and update succeeds;
+// otherwise the record is gone and notFound is returned.
+func (b *Backend) update(ctx context.Context, kvs []keyValue, values [][]byte, notFound error) error {
+	req := txnRequest{
+		Failure: []requestOp{{RequestRange: &rangeRequest{Key: kvs[0].Key}}},
+	}
+	for i, kv := range kvs {
+		req.Compare = append(req.Compare, compare{Key: kv.Key, Target: "MOD", Result: "EQUAL", ModRevision: kv.ModRevision})
+		req.Success = append(req.Success, requestOp{RequestPut: &putRequest{Key: kv.Key, Value: values[i], Lease: kv.Lease}})
+	}
+
+	resp, err := b.client.txn(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded && firstKV(resp, 0) == nil {
+		return notFound
+	}
 	return nil
 }
+
+// firstKV returns the first key of the i-th range response in a txn, or
+// nil if it matched nothing.
+func firstKV(resp *txnResponse, i int) *keyValue {
+	if i >= len(resp.Responses) || resp.Responses[i].ResponseRange == nil {
+		return nil
+	}
+	kvs := resp.Responses[i].ResponseRange.Kvs
+	if len(kvs) == 0 {
+		return nil
+	}
+	return &kvs[0]
+}
@@ -7,101 +7,148 @@ import (
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
 )
 
 var _ registry.Backend = (*Backend)(nil)
 
-func TestRelayAndAgentLifecycle(t *testing.T) {
-	backend, err := New(&registry.EtcdConfig{})
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+func TestNewRequiresValidConfig(t *testing.T) {
+	if _, err := New(nil, registry.TTLConfig{}); !errors.Is(err, registry.ErrEtcdConfigNil) {
+		t.Fatalf("expected ErrEtcdConfigNil, got %v", err)
+	}
+	if _, err := New(&registry.EtcdConfig{}, registry.TTLConfig{}); !errors.Is(err, registry.ErrEtcdEndpointsEmpty) {
+		t.Fatalf("expected ErrEtcdEndpointsEmpty, got %v", err)
 	}
+}
 
-	ctx := context.Background()
-	now := time.Now()
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		srv := newFakeEtcd(t, time.Now)
+		return newFakeBackend(t, srv, registry.EtcdConfig{}, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+	})
+}
 
-	relay := registry.Relay{ID: "relay-1", Address: "127.0.0.1", GRPCPort: 50051, LastSeen: now}
-	if err := backend.RegisterRelay(ctx, relay); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
-	}
+func TestSetTTLRequiresRestart(t *testing.T) {
+	ttl := registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}
+	b := newFakeBackend(t, newFakeEtcd(t, time.Now), registry.EtcdConfig{}, ttl)
 
-	relays, err := backend.ListRelays(ctx)
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.SetTTL(ttl); err != nil {
+		t.Fatalf("expected unchanged ttls to be accepted, got %v", err)
 	}
-	if len(relays) != 1 {
-		t.Fatalf("expected 1 relay, got %d", len(relays))
+	if err := b.SetTTL(registry.TTLConfig{Relay: 2 * time.Minute, Agent: time.Minute}); !errors.Is(err, registry.ErrTTLRestartRequired) {
+		t.Fatalf("expected ErrTTLRestartRequired, got %v", err)
 	}
+}
 
-	hb := now.Add(time.Second)
-	if err := backend.HeartbeatRelay(ctx, relay.ID, hb); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
-	}
+func TestLeasesExpireRecords(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeEtcd(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.EtcdConfig{}, registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second})
+	ctx := context.Background()
 
-	agent := registry.Agent{ID: "agent-1", LastHeartbeat: now}
-	if err := backend.RegisterAgent(ctx, agent, relay.ID); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-	if err := backend.HeartbeatAgent(ctx, agent.ID, hb); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
 	}
 
-	placement, err := backend.GetAgentPlacement(ctx, agent.ID)
+	clock.Advance(11 * time.Second)
+	relays, err := b.ListRelays(ctx)
 	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("list relays: %v", err)
 	}
-	if placement.RelayID != relay.ID {
-		t.Fatalf("expected placement relay id %s, got %s", relay.ID, placement.RelayID)
+	if len(relays) != 0 {
+		t.Fatalf("expected relay lease to expire, got %+v", relays)
 	}
-
-	if err := backend.RemoveRelay(ctx, relay.ID); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected ErrRelayNotRegistered after expiry, got %v", err)
 	}
-
-	// Persistence-only behavior: agent state is not cascade-deleted in backend.
-	if _, err := backend.GetAgentPlacement(ctx, agent.ID); err != nil {
-		t.Fatalf("expected placement to remain persisted, got %v", err)
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("expected placement to outlive the relay lease: %v", err)
 	}
-}
 
-func TestValidationAndContextErrors(t *testing.T) {
-	backend, err := New(&registry.EtcdConfig{})
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+	clock.Advance(10 * time.Second)
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, registry.ErrAgentNotRegistered) {
+		t.Fatalf("expected placement lease to expire, got %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); !errors.Is(err, registry.ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered after expiry, got %v", err)
 	}
+}
 
+func TestHeartbeatsKeepLeasesAlive(t *testing.T) {
+	clock := backendtest.NewClock()
+	srv := newFakeEtcd(t, clock.Now)
+	b := newFakeBackend(t, srv, registry.EtcdConfig{}, registry.TTLConfig{Relay: 10 * time.Second, Agent: 10 * time.Second})
 	ctx := context.Background()
-	if err := backend.RegisterRelay(ctx, registry.Relay{}); !errors.Is(err, registry.ErrRelayIDEmpty) {
-		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-	if err := backend.HeartbeatAgent(ctx, "", time.Now()); !errors.Is(err, registry.ErrAgentIDEmpty) {
-		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
 	}
 
-	canceled, cancel := context.WithCancel(context.Background())
-	cancel()
-	if err := backend.RemoveRelay(canceled, "relay-1"); !errors.Is(err, context.Canceled) {
-		t.Fatalf("expected context canceled, got %v", err)
+	for range 3 {
+		clock.Advance(6 * time.Second)
+		if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+			t.Fatalf("heartbeat relay: %v", err)
+		}
+		if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); err != nil {
+			t.Fatalf("heartbeat agent: %v", err)
+		}
 	}
-}
 
-func TestNotRegisteredErrors(t *testing.T) {
-	backend, err := New(&registry.EtcdConfig{})
+	relays, err := b.ListRelays(ctx)
 	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || !relays[0].LastSeen.Equal(clock.Now()) {
+		t.Fatalf("expected relay with the latest heartbeat, got %+v", relays)
+	}
+	placement, err := b.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if !placement.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected placement updated at %v, got %v", clock.Now(), placement.UpdatedAt)
 	}
+	srv.mu.Lock()
+	keepAlives := srv.keepAlives
+	srv.mu.Unlock()
+	if keepAlives != 6 {
+		t.Fatalf("expected one keep-alive per heartbeat, got %d", keepAlives)
+	}
+}
 
+func TestLeaseTTLs(t *testing.T) {
+	srv := newFakeEtcd(t, time.Now)
 	ctx := context.Background()
 
-	if err := backend.HeartbeatRelay(ctx, "missing", time.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	b := newFakeBackend(t, srv, registry.EtcdConfig{Prefix: "/rounded/"}, registry.TTLConfig{Relay: 1500 * time.Millisecond, Agent: 30 * time.Second})
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
 	}
-
-	if err := backend.RemoveRelay(ctx, "missing"); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if got := srv.leaseTTL(t, "/rounded/relays/relay-1"); got != 2 {
+		t.Fatalf("expected relay lease rounded up to 2s, got %d", got)
+	}
+	if got := srv.leaseTTL(t, "/rounded/placements/agent-1"); got != 30 {
+		t.Fatalf("expected placement lease of 30s, got %d", got)
 	}
 
-	if _, err := backend.GetAgentPlacement(ctx, "missing"); !errors.Is(err, registry.ErrAgentNotRegistered) {
-		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	// A zero TTL writes without a lease.
+	noTTL := newFakeBackend(t, srv, registry.EtcdConfig{}, registry.TTLConfig{})
+	if err := noTTL.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if got := srv.leaseTTL(t, defaultPrefix+"relays/relay-1"); got != 0 {
+		t.Fatalf("expected no lease with a zero ttl, got %d", got)
+	}
+	if err := noTTL.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay without lease: %v", err)
 	}
 }
@@ -0,0 +1,350 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is a minimal etcd v3 client. It speaks the JSON gateway etcd
+// serves on its client port, which covers the KV, lease and auth calls the
+// backend needs without pulling in the gRPC client and its dependencies.
+type client struct {
+	endpoints []string
+	http      *http.Client
+
+	username string
+	password string
+
+	mu      sync.Mutex
+	current int
+	token   string
+}
+
+func newClient(endpoints []string, dialTimeout time.Duration, tlsCfg *tls.Config, username, password string) *client {
+	scheme := "http://"
+	if tlsCfg != nil {
+		scheme = "https://"
+	}
+	urls := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		urls[i] = scheme + endpoint
+	}
+
+	return &client{
+		endpoints: urls,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: dialTimeout}).DialContext,
+			TLSClientConfig:     tlsCfg,
+			TLSHandshakeTimeout: dialTimeout,
+			MaxIdleConnsPerHost: 16,
+		}},
+		username: username,
+		password: password,
+	}
+}
+
+// apiError is an error returned by etcd itself, as opposed to a transport
+// failure that makes the client move on to the next endpoint.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return "etcd: " + e.Message
+}
+
+func isInvalidToken(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Message, "invalid auth token")
+}
+
+type keyValue struct {
+	Key            []byte `json:"key,omitempty"`
+	Value          []byte `json:"value,omitempty"`
+	CreateRevision int64  `json:"create_revision,omitempty,string"`
+	ModRevision    int64  `json:"mod_revision,omitempty,string"`
+	Version        int64  `json:"version,omitempty,string"`
+	Lease          int64  `json:"lease,omitempty,string"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Kvs []keyValue `json:"kvs,omitempty"`
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	Lease int64  `json:"lease,omitempty,string"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type deleteRangeResponse struct {
+	Deleted int64 `json:"deleted,omitempty,string"`
+}
+
+// compare is one txn guard. Only the field matching Target is set; a zero
+// value is left out and compared as zero.
+type compare struct {
+	Key            []byte `json:"key"`
+	Target         string `json:"target"`
+	Result         string `json:"result"`
+	Version        int64  `json:"version,omitempty,string"`
+	CreateRevision int64  `json:"create_revision,omitempty,string"`
+	ModRevision    int64  `json:"mod_revision,omitempty,string"`
+}
+
+type requestOp struct {
+	RequestRange       *rangeRequest       `json:"request_range,omitempty"`
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type responseOp struct {
+	ResponseRange       *rangeResponse       `json:"response_range,omitempty"`
+	ResponsePut         *struct{}            `json:"response_put,omitempty"`
+	ResponseDeleteRange *deleteRangeResponse `json:"response_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []compare   `json:"compare,omitempty"`
+	Success []requestOp `json:"success,omitempty"`
+	Failure []requestOp `json:"failure,omitempty"`
+}
+
+type txnResponse struct {
+	Succeeded bool         `json:"succeeded,omitempty"`
+	Responses []responseOp `json:"responses,omitempty"`
+}
+
+type leaseRequest struct {
+	TTL int64 `json:"TTL,omitempty,string"`
+	ID  int64 `json:"ID,omitempty,string"`
+}
+
+type leaseResponse struct {
+	TTL int64 `json:"TTL,omitempty,string"`
+	ID  int64 `json:"ID,omitempty,string"`
+}
+
+type keepAliveResponse struct {
+	Result leaseResponse `json:"result"`
+}
+
+type authenticateRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type authenticateResponse struct {
+	Token string `json:"token"`
+}
+
+func (c *client) get(ctx context.Context, key string) (*keyValue, error) {
+	var resp rangeResponse
+	if err := c.call(ctx, "/v3/kv/range", rangeRequest{Key: []byte(key)}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return &resp.Kvs[0], nil
+}
+
+// list returns every key under prefix.
+func (c *client) list(ctx context.Context, prefix string) ([]keyValue, error) {
+	var resp rangeResponse
+	req := rangeRequest{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}
+	if err := c.call(ctx, "/v3/kv/range", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Kvs, nil
+}
+
+func (c *client) put(ctx context.Context, key string, value []byte, lease int64) error {
+	return c.call(ctx, "/v3/kv/put", putRequest{Key: []byte(key), Value: value, Lease: lease}, &struct{}{})
+}
+
+func (c *client) delete(ctx context.Context, key string) (int64, error) {
+	var resp deleteRangeResponse
+	if err := c.call(ctx, "/v3/kv/deleterange", deleteRangeRequest{Key: []byte(key)}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
+
+func (c *client) txn(ctx context.Context, req txnRequest) (*txnResponse, error) {
+	var resp txnResponse
+	if err := c.call(ctx, "/v3/kv/txn", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// grant creates a lease that expires after ttl seconds.
+func (c *client) grant(ctx context.Context, ttl int64) (int64, error) {
+	var resp leaseResponse
+	if err := c.call(ctx, "/v3/lease/grant", leaseRequest{TTL: ttl}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// keepAliveOnce renews a lease and reports whether it was still alive.
+func (c *client) keepAliveOnce(ctx context.Context, id int64) (bool, error) {
+	var resp keepAliveResponse
+	if err := c.call(ctx, "/v3/lease/keepalive", leaseRequest{ID: id}, &resp); err != nil {
+		return false, err
+	}
+	return resp.Result.TTL > 0, nil
+}
+
+// call posts req to path and decodes the reply into resp. With
+// credentials configured it authenticates first, and once more if the
+// token has been invalidated since.
+func (c *client) call(ctx context.Context, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.post(ctx, path, body, token, resp)
+	if !isInvalidToken(err) || c.username == "" {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.token == token {
+		c.token = ""
+	}
+	c.mu.Unlock()
+
+	token, err = c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, path, body, token, resp)
+}
+
+func (c *client) authToken(ctx context.Context) (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+
+	body, err := json.Marshal(authenticateRequest{Name: c.username, Password: c.password})
+	if err != nil {
+		return "", err
+	}
+	var resp authenticateResponse
+	if err := c.post(ctx, "/v3/auth/authenticate", body, "", &resp); err != nil {
+		return "", fmt.Errorf("etcd authenticate: %w", err)
+	}
+
+	c.mu.Lock()
+	c.token = resp.Token
+	c.mu.Unlock()
+	return resp.Token, nil
+}
+
+// post sends body to the current endpoint, moving on to the next one when
+// an endpoint cannot be reached. The endpoint that answers becomes current.
+func (c *client) post(ctx context.Context, path string, body []byte, token string, resp any) error {
+	c.mu.Lock()
+	start := c.current
+	c.mu.Unlock()
+
+	var errs []error
+	for i := range c.endpoints {
+		idx := (start + i) % len(c.endpoints)
+		err := c.postTo(ctx, c.endpoints[idx], path, body, token, resp)
+
+		var apiErr *apiError
+		if err == nil || errors.As(err, &apiErr) || ctx.Err() != nil {
+			if idx != start {
+				c.mu.Lock()
+				c.current = idx
+				c.mu.Unlock()
+			}
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *client) postTo(ctx context.Context, endpoint, path string, body []byte, token string, resp any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	httpResp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		apiErr := &apiError{Code: httpResp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	return json.Unmarshal(data, resp)
+}
+
+func (c *client) close() {
+	c.http.CloseIdleConnections()
+}
+
+// prefixEnd returns the range end that selects every key with prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix is all 0xff; select to the end of the keyspace.
+	return []byte{0}
+}
@@ -0,0 +1,424 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+func TestClientAuthenticates(t *testing.T) {
+	srv := newFakeEtcd(t, time.Now)
+	srv.username, srv.password = "registry", "secret"
+	ctx := context.Background()
+
+	good := newFakeBackend(t, srv, registry.EtcdConfig{Username: "registry", Password: "secret"}, registry.TTLConfig{})
+	if err := good.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay with credentials: %v", err)
+	}
+
+	// An invalidated token is replaced transparently.
+	srv.revokeTokens()
+	if _, err := good.ListRelays(ctx); err != nil {
+		t.Fatalf("list relays after token revocation: %v", err)
+	}
+
+	bad := newFakeBackend(t, srv, registry.EtcdConfig{Username: "registry", Password: "wrong"}, registry.TTLConfig{})
+	if _, err := bad.ListRelays(ctx); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+
+	anonymous := newFakeBackend(t, srv, registry.EtcdConfig{}, registry.TTLConfig{})
+	if _, err := anonymous.ListRelays(ctx); err == nil {
+		t.Fatalf("expected error without credentials")
+	}
+}
+
+func TestClientFailsOverEndpoints(t *testing.T) {
+	srv := newFakeEtcd(t, time.Now)
+	b, err := New(&registry.EtcdConfig{Endpoints: []string{deadAddr(t), srv.addr()}}, registry.TTLConfig{})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+
+	if err := b.RegisterRelay(context.Background(), registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if b.client.current != 1 {
+		t.Fatalf("expected the answering endpoint to become current, got %d", b.client.current)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := newFakeEtcd(t, time.Now)
+	tlsSrv := httptest.NewTLSServer(srv.handler())
+	t.Cleanup(tlsSrv.Close)
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0o600); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	endpoint := strings.TrimPrefix(tlsSrv.URL, "https://")
+
+	tests := []struct {
+		name    string
+		tls     registry.ClientTLSConfig
+		wantErr bool
+	}{
+		{name: "verified with ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: caPath}},
+		{name: "unknown authority", tls: registry.ClientTLSConfig{Enabled: true}, wantErr: true},
+		{name: "insecure skip verify", tls: registry.ClientTLSConfig{Enabled: true, InsecureSkipVerify: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := New(&registry.EtcdConfig{Endpoints: []string{endpoint}, TLS: test.tls}, registry.TTLConfig{})
+			if err != nil {
+				t.Fatalf("new backend: %v", err)
+			}
+			defer b.Close(context.Background())
+
+			_, err = b.ListRelays(context.Background())
+			if test.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []byte
+	}{
+		{prefix: "/registry/relays/", want: []byte("/registry/relays0")},
+		{prefix: "a\xff", want: []byte("b")},
+		{prefix: "\xff\xff", want: []byte{0}},
+	}
+
+	for _, test := range tests {
+		if got := prefixEnd(test.prefix); !bytes.Equal(got, test.want) {
+			t.Fatalf("prefixEnd(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
+
+// deadAddr returns an address nothing is listening on.
+func deadAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+func newFakeBackend(t *testing.T, srv *fakeEtcd, cfg registry.EtcdConfig, ttl registry.TTLConfig) *Backend {
+	t.Helper()
+
+	cfg.Endpoints = []string{srv.addr()}
+	b, err := New(&cfg, ttl)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
+
+// fakeEtcd is an in-process stand-in for the etcd v3 JSON gateway. It
+// implements the KV, txn, lease and auth calls the backend makes, with
+// leases expiring against an injectable clock.
+type fakeEtcd struct {
+	srv *httptest.Server
+	now func() time.Time
+
+	// username and password, when set, require authentication.
+	username string
+	password string
+
+	mu         sync.Mutex
+	rev        int64
+	kvs        map[string]keyValue
+	leases     map[int64]*fakeLease
+	nextLease  int64
+	tokens     map[string]bool
+	keepAlives int
+}
+
+type fakeLease struct {
+	ttl     int64
+	expires time.Time
+}
+
+func newFakeEtcd(t *testing.T, now func() time.Time) *fakeEtcd {
+	t.Helper()
+
+	f := &fakeEtcd{
+		now:    now,
+		kvs:    make(map[string]keyValue),
+		leases: make(map[int64]*fakeLease),
+		tokens: make(map[string]bool),
+	}
+	f.srv = httptest.NewServer(f.handler())
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeEtcd) addr() string {
+	return strings.TrimPrefix(f.srv.URL, "http://")
+}
+
+func (f *fakeEtcd) revokeTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clear(f.tokens)
+}
+
+func (f *fakeEtcd) leaseTTL(t *testing.T, key string) int64 {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expire()
+	kv, ok := f.kvs[key]
+	if !ok {
+		t.Fatalf("key %s does not exist", key)
+	}
+	if kv.Lease == 0 {
+		return 0
+	}
+	return f.leases[kv.Lease].ttl
+}
+
+func (f *fakeEtcd) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.expire()
+
+		if f.username != "" && r.URL.Path != "/v3/auth/authenticate" && !f.tokens[r.Header.Get("Authorization")] {
+			writeGatewayError(w, http.StatusUnauthorized, 16, "etcdserver: invalid auth token")
+			return
+		}
+
+		var (
+			resp any
+			err  *apiError
+		)
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			var req rangeRequest
+			decode(r, &req)
+			resp = f.rangeKeys(req)
+		case "/v3/kv/put":
+			var req putRequest
+			decode(r, &req)
+			resp, err = f.put(req)
+		case "/v3/kv/deleterange":
+			var req deleteRangeRequest
+			decode(r, &req)
+			resp = f.deleteRange(req)
+		case "/v3/kv/txn":
+			var req txnRequest
+			decode(r, &req)
+			resp, err = f.txn(req)
+		case "/v3/lease/grant":
+			var req leaseRequest
+			decode(r, &req)
+			f.nextLease++
+			f.leases[f.nextLease] = &fakeLease{ttl: req.TTL, expires: f.now().Add(time.Duration(req.TTL) * time.Second)}
+			resp = leaseResponse{ID: f.nextLease, TTL: req.TTL}
+		case "/v3/lease/keepalive":
+			var req leaseRequest
+			decode(r, &req)
+			f.keepAlives++
+			out := keepAliveResponse{Result: leaseResponse{ID: req.ID}}
+			if lease, ok := f.leases[req.ID]; ok {
+				lease.expires = f.now().Add(time.Duration(lease.ttl) * time.Second)
+				out.Result.TTL = lease.ttl
+			}
+			resp = out
+		case "/v3/auth/authenticate":
+			var req authenticateRequest
+			decode(r, &req)
+			if req.Name != f.username || req.Password != f.password {
+				err = &apiError{Code: 3, Message: "etcdserver: authentication failed, invalid user ID or password"}
+				break
+			}
+			token := "token-" + strconv.Itoa(len(f.tokens)+1)
+			f.tokens[token] = true
+			resp = authenticateResponse{Token: token}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err.Code, err.Message)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// expire drops leases past their deadline together with their keys.
+func (f *fakeEtcd) expire() {
+	for id, lease := range f.leases {
+		if f.now().Before(lease.expires) {
+			continue
+		}
+		delete(f.leases, id)
+		for key, kv := range f.kvs {
+			if kv.Lease == id {
+				delete(f.kvs, key)
+			}
+		}
+	}
+}
+
+func (f *fakeEtcd) match(key, rangeEnd []byte) []string {
+	var keys []string
+	for k := range f.kvs {
+		switch {
+		case len(rangeEnd) == 0:
+			if k == string(key) {
+				keys = append(keys, k)
+			}
+		case bytes.Equal(rangeEnd, []byte{0}):
+			if k >= string(key) {
+				keys = append(keys, k)
+			}
+		case k >= string(key) && k < string(rangeEnd):
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (f *fakeEtcd) rangeKeys(req rangeRequest) rangeResponse {
+	var resp rangeResponse
+	for _, k := range f.match(req.Key, req.RangeEnd) {
+		resp.Kvs = append(resp.Kvs, f.kvs[k])
+	}
+	return resp
+}
+
+func (f *fakeEtcd) put(req putRequest) (struct{}, *apiError) {
+	if req.Lease != 0 {
+		if _, ok := f.leases[req.Lease]; !ok {
+			return struct{}{}, &apiError{Code: 5, Message: "etcdserver: requested lease not found"}
+		}
+	}
+
+	f.rev++
+	kv, ok := f.kvs[string(req.Key)]
+	if !ok {
+		kv = keyValue{Key: req.Key, CreateRevision: f.rev}
+	}
+	kv.Value = req.Value
+	kv.Lease = req.Lease
+	kv.ModRevision = f.rev
+	kv.Version++
+	f.kvs[string(req.Key)] = kv
+	return struct{}{}, nil
+}
+
+func (f *fakeEtcd) deleteRange(req deleteRangeRequest) deleteRangeResponse {
+	keys := f.match(req.Key, req.RangeEnd)
+	if len(keys) > 0 {
+		f.rev++
+	}
+	for _, k := range keys {
+		delete(f.kvs, k)
+	}
+	return deleteRangeResponse{Deleted: int64(len(keys))}
+}
+
+func (f *fakeEtcd) txn(req txnRequest) (txnResponse, *apiError) {
+	succeeded := true
+	for _, c := range req.Compare {
+		kv := f.kvs[string(c.Key)]
+		var have, want int64
+		switch c.Target {
+		case "VERSION":
+			have, want = kv.Version, c.Version
+		case "CREATE":
+			have, want = kv.CreateRevision, c.CreateRevision
+		case "MOD":
+			have, want = kv.ModRevision, c.ModRevision
+		default:
+			return txnResponse{}, &apiError{Code: 3, Message: "unsupported compare target " + c.Target}
+		}
+
+		var ok bool
+		switch c.Result {
+		case "", "EQUAL":
+			ok = have == want
+		case "GREATER":
+			ok = have > want
+		case "LESS":
+			ok = have < want
+		case "NOT_EQUAL":
+			ok = have != want
+		}
+		succeeded = succeeded && ok
+	}
+
+	ops := req.Success
+	if !succeeded {
+		ops = req.Failure
+	}
+
+	resp := txnResponse{Succeeded: succeeded}
+	for _, op := range ops {
+		switch {
+		case op.RequestRange != nil:
+			r := f.rangeKeys(*op.RequestRange)
+			resp.Responses = append(resp.Responses, responseOp{ResponseRange: &r})
+		case op.RequestPut != nil:
+			if _, err := f.put(*op.RequestPut); err != nil {
+				return txnResponse{}, err
+			}
+			resp.Responses = append(resp.Responses, responseOp{ResponsePut: &struct{}{}})
+		case op.RequestDeleteRange != nil:
+			d := f.deleteRange(*op.RequestDeleteRange)
+			resp.Responses = append(resp.Responses, responseOp{ResponseDeleteRange: &d})
+		}
+	}
+	return resp, nil
+}
+
+func decode(r *http.Request, v any) {
+	_ = json.NewDecoder(r.Body).Decode(v)
+}
+
+func writeGatewayError(w http.ResponseWriter, status, code int, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": message, "code": code, "message": message})
+}
@@ -0,0 +1,24 @@
+package etcd
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.EtcdRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.Etcd, cfg.TTL)
+		},
+	})
+}
+
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.Etcd == nil {
+		return registry.ErrEtcdConfigNil
+	}
+
+	return cfg.Etcd.Validate()
+}
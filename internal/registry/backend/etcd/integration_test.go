@@ -0,0 +1,57 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+// integrationEndpointsEnv names a comma-separated list of etcd endpoints.
+// When it is set, the conformance suite also runs against that cluster, so
+// the JSON gateway client is checked against a real etcd and not only the
+// fake above. Each subtest writes under its own prefix and deletes it when
+// done.
+const integrationEndpointsEnv = "AERO_ARC_REGISTRY_TEST_ETCD_ENDPOINTS"
+
+var integrationRuns atomic.Int64
+
+func TestIntegrationConformance(t *testing.T) {
+	endpoints := os.Getenv(integrationEndpointsEnv)
+	if endpoints == "" {
+		t.Skipf("%s not set", integrationEndpointsEnv)
+	}
+
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		cfg := &registry.EtcdConfig{
+			Endpoints: strings.Split(endpoints, ","),
+			Username:  os.Getenv("AERO_ARC_REGISTRY_TEST_ETCD_USERNAME"),
+			Password:  os.Getenv("AERO_ARC_REGISTRY_TEST_ETCD_PASSWORD"),
+			Prefix:    fmt.Sprintf("/aero-arc-registry-test/%d-%d/", time.Now().UnixNano(), integrationRuns.Add(1)),
+		}
+
+		b, err := New(cfg, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+		if err != nil {
+			t.Fatalf("new backend: %v", err)
+		}
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var resp deleteRangeResponse
+			req := deleteRangeRequest{Key: []byte(cfg.Prefix), RangeEnd: prefixEnd(cfg.Prefix)}
+			if err := b.client.call(ctx, "/v3/kv/deleterange", req, &resp); err != nil {
+				t.Errorf("delete test prefix: %v", err)
+			}
+			b.Close(ctx)
+		})
+
+		return b
+	})
+}
@@ -0,0 +1,557 @@
+// Package file provides an embedded backend that persists the registry to
+// a local file.
+//
+// It is meant for single-node deployments that need placements to survive
+// a restart without running an external store. State is held in memory and
+// every write is appended to the file and synced before it is
+// acknowledged. Only one process may use a file at a time; the backend
+// holds an exclusive lock on it while open.
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+const defaultCompactInterval = 5 * time.Minute
+
+type Backend struct {
+	cfg *registry.FileConfig
+	ttl registry.TTLConfig
+	now func() time.Time
+
+	mu         sync.RWMutex
+	relays     map[string]registry.Relay
+	agents     map[string]registry.Agent
+	placements map[string]registry.AgentPlacement
+
+	// log is the open registry file, size its length and entries the
+	// number of entries in it.
+	log     *os.File
+	size    int64
+	entries int
+	// failed is set when a write could not be rolled back, leaving the
+	// file in an unknown state; writes are refused until a compaction
+	// replaces the file.
+	failed error
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// New opens the registry file at cfg.Path, creating it if needed, and
+// loads its contents. Relays and agents whose last heartbeat is older than
+// ttl are dropped while loading. ttl is not used after that, so TTL changes
+// need nothing from this backend. The file is compacted on open, every
+// cfg.CompactInterval and on Close.
+func New(cfg *registry.FileConfig, ttl registry.TTLConfig) (*Backend, error) {
+	return open(cfg, ttl, time.Now)
+}
+
+func open(cfg *registry.FileConfig, ttl registry.TTLConfig, now func() time.Time) (*Backend, error) {
+	if cfg == nil {
+		return nil, registry.ErrFileConfigNil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	b := &Backend{
+		cfg:        cfg,
+		ttl:        ttl,
+		now:        now,
+		relays:     make(map[string]registry.Relay),
+		agents:     make(map[string]registry.Agent),
+		placements: make(map[string]registry.AgentPlacement),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	log, err := openLocked(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	b.log = log
+	if err := b.load(); err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+	if err := b.compact(); err != nil {
+		_ = b.log.Close()
+		return nil, err
+	}
+
+	interval := cfg.CompactInterval
+	if interval == 0 {
+		interval = defaultCompactInterval
+	}
+	go b.compactLoop(interval)
+
+	return b, nil
+}
+
+// openLocked opens the registry file at path, creating it if needed, and
+// locks it. Compaction replaces the file, so a lock taken on a file that
+// was renamed away in the meantime is retried on the current one.
+func openLocked(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open registry file: %w", err)
+		}
+		if err := lockFile(f); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock registry file %s: %w", path, err)
+		}
+
+		locked, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("open registry file: %w", err)
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		_ = f.Close()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("open registry file: %w", err)
+		}
+	}
+}
+
+// load replays the locked registry file, ignoring a torn tail left by a
+// crash, and drops records that expired while the registry was down.
+func (b *Backend) load() error {
+	// The torn tail, if any, disappears with the compaction that follows
+	// loading.
+	entries, err := readLog(b.log)
+	if err != nil {
+		return fmt.Errorf("read registry file: %w", err)
+	}
+	for _, e := range entries {
+		b.apply(e)
+	}
+
+	now := b.now()
+	for id, relay := range b.relays {
+		if expired(now, relay.LastSeen, b.ttl.Relay) {
+			delete(b.relays, id)
+		}
+	}
+	for id, placement := range b.placements {
+		if expired(now, placement.UpdatedAt, b.ttl.Agent) {
+			delete(b.agents, id)
+			delete(b.placements, id)
+		}
+	}
+	return nil
+}
+
+func expired(now, last time.Time, ttl time.Duration) bool {
+	return ttl > 0 && now.Sub(last) > ttl
+}
+
+// apply updates the in-memory state with a logged write.
+func (b *Backend) apply(e entry) {
+	switch e.Op {
+	case opPutRelay:
+		if e.Relay != nil {
+			b.relays[e.Relay.ID] = *e.Relay
+		}
+	case opDeleteRelay:
+		delete(b.relays, e.ID)
+	case opPutAgent:
+		if e.Agent != nil && e.Placement != nil {
+			b.agents[e.Agent.ID] = *e.Agent
+			b.placements[e.Agent.ID] = *e.Placement
+		}
+	case opDeleteAgent:
+		delete(b.agents, e.ID)
+		delete(b.placements, e.ID)
+	}
+}
+
+// commit durably appends e to the file and then applies it. It must be
+// called with the write lock held. If the append fails, the file is cut
+// back to its previous length so a torn frame cannot hide later writes.
+func (b *Backend) commit(e entry) error {
+	if b.failed != nil {
+		return b.failed
+	}
+
+	frame, err := encodeEntry(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.log.Write(frame); err == nil {
+		err = b.log.Sync()
+	}
+	if err != nil {
+		if rollbackErr := b.rollback(); rollbackErr != nil {
+			b.failed = fmt.Errorf("registry file is in an unknown state: %w", errors.Join(err, rollbackErr))
+			return b.failed
+		}
+		return fmt.Errorf("write registry file: %w", err)
+	}
+
+	b.size += int64(len(frame))
+	b.entries++
+	b.apply(e)
+	return nil
+}
+
+func (b *Backend) rollback() error {
+	if err := b.log.Truncate(b.size); err != nil {
+		return err
+	}
+	_, err := b.log.Seek(b.size, io.SeekStart)
+	return err
+}
+
+// compact rewrites the file with one entry per live record. The new file
+// is written and synced next to the old one and renamed over it, so a
+// crash at any point leaves either the old or the new file in place.
+func (b *Backend) compact() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tmpPath := b.cfg.Path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("compact registry file: %w", err)
+	}
+	// The new file is locked before it replaces the old one, so the lock
+	// is never released while the path is in use.
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("compact registry file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	size, entries, err := b.writeSnapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, b.cfg.Path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(b.cfg.Path))
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("compact registry file: %w", err)
+	}
+
+	if b.log != nil {
+		_ = b.log.Close()
+	}
+	b.log, b.size, b.entries = f, size, entries
+	// The snapshot was built from the in-memory state, so whatever a
+	// failed write left behind is gone.
+	b.failed = nil
+	return nil
+}
+
+func (b *Backend) writeSnapshot(w io.Writer) (int64, int, error) {
+	var (
+		size    int64
+		entries int
+	)
+	write := func(e entry) error {
+		frame, err := encodeEntry(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		size += int64(len(frame))
+		entries++
+		return nil
+	}
+
+	for _, relay := range b.relays {
+		if err := write(entry{Op: opPutRelay, Relay: &relay}); err != nil {
+			return 0, 0, err
+		}
+	}
+	for id, agent := range b.agents {
+		placement := b.placements[id]
+		if err := write(entry{Op: opPutAgent, Agent: &agent, Placement: &placement}); err != nil {
+			return 0, 0, err
+		}
+	}
+	return size, entries, nil
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func (b *Backend) compactLoop(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if !b.superseded() {
+				continue
+			}
+			// The file keeps growing until a later attempt succeeds.
+			if err := b.compact(); err != nil {
+				slog.Warn("registry file compaction failed", "path", b.cfg.Path, "error", err)
+			}
+		}
+	}
+}
+
+// superseded reports whether the file holds entries that no longer
+// describe a live record.
+func (b *Backend) superseded() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.entries > len(b.relays)+len(b.agents)
+}
+
+func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relay.ID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if relay.LastSeen.IsZero() {
+		relay.LastSeen = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.commit(entry{Op: opPutRelay, Relay: &relay})
+}
+
+func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	relay, ok := b.relays[relayID]
+	if !ok {
+		return registry.ErrRelayNotRegistered
+	}
+	relay.LastSeen = ts
+	return b.commit(entry{Op: opPutRelay, Relay: &relay})
+}
+
+func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	relays := make([]registry.Relay, 0, len(b.relays))
+	for _, relay := range b.relays {
+		relays = append(relays, relay)
+	}
+	slices.SortFunc(relays, func(a, b registry.Relay) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return relays, nil
+}
+
+func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.relays[relayID]; !ok {
+		return registry.ErrRelayNotRegistered
+	}
+
+	// Persistence-only responsibility: dependent placements are invalidated
+	// by the registry layer, not here.
+	return b.commit(entry{Op: opDeleteRelay, ID: relayID})
+}
+
+func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relayID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if agent.ID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if agent.LastHeartbeat.IsZero() {
+		agent.LastHeartbeat = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.relays[relayID]; !ok {
+		return registry.ErrRelayNotRegistered
+	}
+
+	placement := registry.AgentPlacement{
+		AgentID:   agent.ID,
+		RelayID:   relayID,
+		UpdatedAt: agent.LastHeartbeat,
+	}
+	return b.commit(entry{Op: opPutAgent, Agent: &agent, Placement: &placement})
+}
+
+func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	agent, ok := b.agents[agentID]
+	if !ok {
+		return registry.ErrAgentNotRegistered
+	}
+	placement, ok := b.placements[agentID]
+	if !ok {
+		return registry.ErrAgentNotRegistered
+	}
+
+	agent.LastHeartbeat = ts
+	placement.UpdatedAt = ts
+	return b.commit(entry{Op: opPutAgent, Agent: &agent, Placement: &placement})
+}
+
+func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if agentID == "" {
+		return nil, registry.ErrAgentIDEmpty
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	placement, ok := b.placements[agentID]
+	if !ok {
+		return nil, registry.ErrAgentNotRegistered
+	}
+	return &placement, nil
+}
+
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	placements := make([]registry.AgentPlacement, 0, len(b.placements))
+	for _, placement := range b.placements {
+		placements = append(placements, placement)
+	}
+	slices.SortFunc(placements, func(a, b registry.AgentPlacement) int {
+		return strings.Compare(a.AgentID, b.AgentID)
+	})
+	return placements, nil
+}
+
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.agents[agentID]; !ok {
+		return registry.ErrAgentNotRegistered
+	}
+	return b.commit(entry{Op: opDeleteAgent, ID: agentID})
+}
+
+// Watch implements registry.Watcher. Listing the in-memory state is cheap,
+// so the backend keeps no change stream and the registry polls it instead.
+func (b *Backend) Watch(context.Context) (<-chan registry.Event, error) {
+	return nil, registry.ErrWatchUnsupported
+}
+
+// Close stops periodic compaction, compacts the file a final time and
+// closes it.
+func (b *Backend) Close(ctx context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+
+		err = b.compact()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		err = errors.Join(err, b.log.Close())
+		b.failed = errors.New("registry file is closed")
+	})
+	return err
+}
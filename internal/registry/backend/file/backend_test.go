@@ -0,0 +1,312 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+var _ registry.Backend = (*Backend)(nil)
+
+func TestNewRequiresValidConfig(t *testing.T) {
+	if _, err := New(nil, registry.TTLConfig{}); !errors.Is(err, registry.ErrFileConfigNil) {
+		t.Fatalf("expected ErrFileConfigNil, got %v", err)
+	}
+	if _, err := New(&registry.FileConfig{}, registry.TTLConfig{}); !errors.Is(err, registry.ErrFilePathEmpty) {
+		t.Fatalf("expected ErrFilePathEmpty, got %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		b, err := New(&registry.FileConfig{Path: filepath.Join(t.TempDir(), "registry.db")}, registry.TTLConfig{})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		return b
+	})
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+	seen := time.Now().UTC().Truncate(time.Second)
+
+	b := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: seen}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2", LastSeen: seen}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: seen}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", seen.Add(time.Second)); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := b.RemoveRelay(ctx, "relay-2"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+	// Simulate a crash: the file is left without the final compaction.
+	b.stopForTest()
+
+	reopened := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	relays, err := reopened.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	want := registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: seen.Add(time.Second)}
+	if len(relays) != 1 || relays[0] != want {
+		t.Fatalf("expected %+v after restart, got %+v", want, relays)
+	}
+	placement, err := reopened.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if placement.RelayID != "relay-1" || !placement.UpdatedAt.Equal(seen) {
+		t.Fatalf("unexpected placement after restart: %+v", placement)
+	}
+}
+
+func TestLoadDropsExpiredRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+	now := time.Now()
+	ttl := registry.TTLConfig{Relay: 10 * time.Second, Agent: 30 * time.Second}
+
+	b := mustOpen(t, path, ttl, time.Now)
+	for _, relay := range []registry.Relay{
+		{ID: "stale", LastSeen: now.Add(-time.Minute)},
+		{ID: "fresh", LastSeen: now},
+	} {
+		if err := b.RegisterRelay(ctx, relay); err != nil {
+			t.Fatalf("register relay: %v", err)
+		}
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "stale-agent", LastHeartbeat: now.Add(-time.Minute)}, "fresh"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "fresh-agent", LastHeartbeat: now.Add(-20 * time.Second)}, "fresh"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened := mustOpen(t, path, ttl, func() time.Time { return now })
+	relays, err := reopened.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].ID != "fresh" {
+		t.Fatalf("expected only the fresh relay, got %+v", relays)
+	}
+	placements, err := reopened.ListPlacements(ctx)
+	if err != nil {
+		t.Fatalf("list placements: %v", err)
+	}
+	if len(placements) != 1 || placements[0].AgentID != "fresh-agent" {
+		t.Fatalf("expected only the fresh placement, got %+v", placements)
+	}
+}
+
+func TestLoadIgnoresTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+
+	b := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	b.stopForTest()
+
+	// Cut the last frame short, as a crash mid-write would.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	reopened := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	relays, err := reopened.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].ID != "relay-1" {
+		t.Fatalf("expected the intact prefix to load, got %+v", relays)
+	}
+
+	// Writes after recovery are readable again.
+	if err := reopened.RegisterRelay(ctx, registry.Relay{ID: "relay-3"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	reopened.stopForTest()
+	again := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	relays, err = again.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 2 {
+		t.Fatalf("expected relay-1 and relay-3, got %+v", relays)
+	}
+}
+
+func TestLoadRejectsCorruptionBeforeTheTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+
+	b := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	for _, id := range []string{"relay-1", "relay-2", "relay-3"} {
+		if err := b.RegisterRelay(ctx, registry.Relay{ID: id}); err != nil {
+			t.Fatalf("register relay: %v", err)
+		}
+	}
+	b.stopForTest()
+
+	// Flip a payload byte of the first frame; intact frames follow it.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	data[headerSize+2] ^= 0xff
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if _, err := open(&registry.FileConfig{Path: path}, registry.TTLConfig{}, time.Now); !errors.Is(err, registry.ErrFileCorrupt) {
+		t.Fatalf("expected ErrFileCorrupt, got %v", err)
+	}
+}
+
+func TestLoadIgnoresZeroFilledTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+
+	b := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	b.stopForTest()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	if _, err := f.Write(make([]byte, 64)); err != nil {
+		t.Fatalf("write zeros: %v", err)
+	}
+	_ = f.Close()
+
+	reopened := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	relays, err := reopened.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 {
+		t.Fatalf("expected relay-1 to load, got %+v", relays)
+	}
+}
+
+func TestFileIsLockedWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+
+	b := mustOpen(t, path, registry.TTLConfig{}, time.Now)
+	if _, err := open(&registry.FileConfig{Path: path}, registry.TTLConfig{}, time.Now); !errors.Is(err, registry.ErrFileLocked) {
+		t.Fatalf("expected ErrFileLocked, got %v", err)
+	}
+
+	// Compaction replaces the file with one that is locked too.
+	if err := b.compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if _, err := open(&registry.FileConfig{Path: path}, registry.TTLConfig{}, time.Now); !errors.Is(err, registry.ErrFileLocked) {
+		t.Fatalf("expected ErrFileLocked after compaction, got %v", err)
+	}
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	mustOpen(t, path, registry.TTLConfig{}, time.Now)
+}
+
+func TestCompactionDropsSupersededEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	ctx := context.Background()
+
+	b, err := New(&registry.FileConfig{Path: path, CompactInterval: 10 * time.Millisecond}, registry.TTLConfig{})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	for range 50 {
+		if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+			t.Fatalf("heartbeat relay: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.superseded() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected periodic compaction to run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	b.mu.RLock()
+	entries, size := b.entries, b.size
+	b.mu.RUnlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if entries != 1 || info.Size() != size {
+		t.Fatalf("expected one entry of %d bytes on disk, got %d entries and %d bytes", size, entries, info.Size())
+	}
+	if _, err := os.Stat(path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the temporary file to be renamed away, got %v", err)
+	}
+}
+
+func mustOpen(t *testing.T, path string, ttl registry.TTLConfig, now func() time.Time) *Backend {
+	t.Helper()
+
+	b, err := open(&registry.FileConfig{Path: path}, ttl, now)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
+
+// stopForTest stops the backend without the final compaction Close does,
+// leaving the file as a crash would.
+func (b *Backend) stopForTest() {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		_ = b.log.Close()
+	})
+}
@@ -0,0 +1,24 @@
+package file
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.FileRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.File, cfg.TTL)
+		},
+	})
+}
+
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.File == nil {
+		return registry.ErrFileConfigNil
+	}
+
+	return cfg.File.Validate()
+}
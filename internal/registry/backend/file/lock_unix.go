@@ -0,0 +1,21 @@
+//go:build unix
+
+package file
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+// lockFile takes an exclusive advisory lock on f without blocking. The lock
+// is released when f is closed.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return registry.ErrFileLocked
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package file
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock on f without blocking. The lock is
+// released when f is closed.
+func lockFile(f *os.File) error {
+	var overlapped windows.Overlapped
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return registry.ErrFileLocked
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package file
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+// The registry file is a log of entries, each framed as a big-endian
+// uint32 payload length, a CRC-32C of the payload, and the JSON payload.
+// Replaying the log in order rebuilds the state. A crash can leave at most
+// a partially written final frame, possibly followed by zero fill, which
+// is cut off on the next load. A damaged frame anywhere else means the
+// file is corrupt, and loading it fails rather than dropping the entries
+// after it.
+const (
+	headerSize = 8
+
+	// maxEntrySize guards against allocating for a corrupt length.
+	maxEntrySize = 1 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	opPutRelay    = "put_relay"
+	opDeleteRelay = "delete_relay"
+	opPutAgent    = "put_agent"
+	opDeleteAgent = "delete_agent"
+)
+
+// entry is one logged write. Agents are always logged together with their
+// placement.
+type entry struct {
+	Op        string                   `json:"op"`
+	ID        string                   `json:"id,omitempty"`
+	Relay     *registry.Relay          `json:"relay,omitempty"`
+	Agent     *registry.Agent          `json:"agent,omitempty"`
+	Placement *registry.AgentPlacement `json:"placement,omitempty"`
+}
+
+// encodeEntry returns the framed form of e.
+func encodeEntry(e entry) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[headerSize:], payload)
+	return frame, nil
+}
+
+// readLog decodes the entries in r. A damaged frame is taken for a torn
+// final write and ends the log only if no intact frame follows it;
+// otherwise the log is reported as registry.ErrFileCorrupt.
+func readLog(r io.Reader) ([]entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for offset := 0; offset < len(data); {
+		payload, ok := decodeFrame(data[offset:])
+		if !ok {
+			if tornTail(data[offset:]) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("%w: damaged entry at offset %d is followed by intact entries", registry.ErrFileCorrupt, offset)
+		}
+
+		// The checksum matched, so the frame was written in full.
+		var e entry
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("%w: entry at offset %d: %v", registry.ErrFileCorrupt, offset, err)
+		}
+		entries = append(entries, e)
+		offset += headerSize + len(payload)
+	}
+	return entries, nil
+}
+
+// decodeFrame returns the payload of the frame at the start of b, or false
+// if b does not start with an intact frame.
+func decodeFrame(b []byte) ([]byte, bool) {
+	if len(b) < headerSize {
+		return nil, false
+	}
+	size := binary.BigEndian.Uint32(b[0:4])
+	if size == 0 || size > maxEntrySize || int64(size) > int64(len(b)-headerSize) {
+		return nil, false
+	}
+	payload := b[headerSize : headerSize+int(size)]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(b[4:8]) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// tornTail reports whether tail, which starts with a damaged frame, is what
+// a crash mid-append leaves behind: a prefix of one frame, possibly followed
+// by zero fill, with no intact frame after it.
+func tornTail(tail []byte) bool {
+	for i := 1; i+headerSize <= len(tail); i++ {
+		if _, ok := decodeFrame(tail[i:]); ok {
+			return false
+		}
+	}
+	return true
+}
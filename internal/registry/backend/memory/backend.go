@@ -1,8 +1,14 @@
-// Package memory provides a stub in-memory backend implementation.
+// Package memory provides an in-process backend implementation.
+//
+// State lives only for the lifetime of the process, which makes this
+// backend suitable for development, tests and single-replica deployments.
 package memory
 
 import (
 	"context"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
@@ -10,40 +16,307 @@ import (
 
 type Backend struct {
 	cfg *registry.MemoryConfig
+
+	mu         sync.RWMutex
+	relays     map[string]registry.Relay
+	agents     map[string]registry.Agent
+	placements map[string]registry.AgentPlacement
+	watchers   map[chan registry.Event]struct{}
 }
 
+// watchBuffer is the number of undelivered events a watcher may fall behind
+// by before its channel is closed and the registry resyncs.
+const watchBuffer = 256
+
 func New(cfg *registry.MemoryConfig) (*Backend, error) {
-	return &Backend{cfg: cfg}, nil
+	if cfg == nil {
+		cfg = &registry.MemoryConfig{}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Backend{
+		cfg:        cfg,
+		relays:     make(map[string]registry.Relay),
+		agents:     make(map[string]registry.Agent),
+		placements: make(map[string]registry.AgentPlacement),
+		watchers:   make(map[chan registry.Event]struct{}),
+	}, nil
 }
 
 func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error {
-	return registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relay.ID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if relay.LastSeen.IsZero() {
+		relay.LastSeen = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.relays[relay.ID]; !ok && atCapacity(len(b.relays), b.cfg.MaxRelays) {
+		return registry.ErrCapacityExceeded
+	}
+	b.relays[relay.ID] = relay
+	b.notify(registry.Event{Type: registry.EventRelayAdded, Relay: relay})
+	return nil
 }
 
 func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Time) error {
-	return registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	relay, ok := b.relays[relayID]
+	if !ok {
+		return registry.ErrRelayNotRegistered
+	}
+	relay.LastSeen = ts
+	b.relays[relayID] = relay
+	b.notify(registry.Event{Type: registry.EventRelayHeartbeat, Relay: relay})
+	return nil
 }
 
 func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
-	return nil, registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.relays) == 0 {
+		return nil, nil
+	}
+
+	relays := make([]registry.Relay, 0, len(b.relays))
+	for _, relay := range b.relays {
+		relays = append(relays, relay)
+	}
+	slices.SortFunc(relays, func(a, b registry.Relay) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return relays, nil
 }
 
 func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
-	return registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.relays[relayID]; !ok {
+		return registry.ErrRelayNotRegistered
+	}
+
+	// Persistence-only responsibility: dependent placements are invalidated
+	// by the registry layer, not here.
+	delete(b.relays, relayID)
+	b.notify(registry.Event{Type: registry.EventRelayRemoved, Relay: registry.Relay{ID: relayID}})
+	return nil
 }
 
 func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relayID string) error {
-	return registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if agent.ID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if agent.LastHeartbeat.IsZero() {
+		agent.LastHeartbeat = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.relays[relayID]; !ok {
+		return registry.ErrRelayNotRegistered
+	}
+	if _, ok := b.agents[agent.ID]; !ok && atCapacity(len(b.agents), b.cfg.MaxAgents) {
+		return registry.ErrCapacityExceeded
+	}
+
+	placement := registry.AgentPlacement{
+		AgentID:   agent.ID,
+		RelayID:   relayID,
+		UpdatedAt: agent.LastHeartbeat,
+	}
+	b.agents[agent.ID] = agent
+	b.placements[agent.ID] = placement
+	b.notify(registry.Event{Type: registry.EventPlacementChanged, Placement: placement})
+	return nil
 }
 
 func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error {
-	return registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	agent, ok := b.agents[agentID]
+	if !ok {
+		return registry.ErrAgentNotRegistered
+	}
+	placement, ok := b.placements[agentID]
+	if !ok {
+		return registry.ErrAgentNotRegistered
+	}
+
+	agent.LastHeartbeat = ts
+	placement.UpdatedAt = ts
+	b.agents[agentID] = agent
+	b.placements[agentID] = placement
+	b.notify(registry.Event{Type: registry.EventPlacementChanged, Placement: placement})
+	return nil
 }
 
 func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
-	return nil, registry.ErrNotImplemented
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if agentID == "" {
+		return nil, registry.ErrAgentIDEmpty
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	placement, ok := b.placements[agentID]
+	if !ok {
+		return nil, registry.ErrAgentNotRegistered
+	}
+	return &placement, nil
+}
+
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.placements) == 0 {
+		return nil, nil
+	}
+
+	placements := make([]registry.AgentPlacement, 0, len(b.placements))
+	for _, placement := range b.placements {
+		placements = append(placements, placement)
+	}
+	slices.SortFunc(placements, func(a, b registry.AgentPlacement) int {
+		return strings.Compare(a.AgentID, b.AgentID)
+	})
+	return placements, nil
+}
+
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.agents[agentID]; !ok {
+		return registry.ErrAgentNotRegistered
+	}
+	delete(b.agents, agentID)
+	delete(b.placements, agentID)
+	b.notify(registry.Event{Type: registry.EventPlacementRemoved, Placement: registry.AgentPlacement{AgentID: agentID}})
+	return nil
+}
+
+// Watch implements registry.Watcher. Every successful write is delivered
+// on the returned channel until ctx is done or the backend is closed.
+func (b *Backend) Watch(ctx context.Context) (<-chan registry.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan registry.Event, watchBuffer)
+
+	b.mu.Lock()
+	b.watchers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.dropWatcher(ch)
+	}()
+
+	return ch, nil
 }
 
 func (b *Backend) Close(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.watchers {
+		b.dropWatcher(ch)
+	}
 	return nil
 }
+
+// notify must be called with the write lock held so events are delivered
+// in the order the writes were applied.
+func (b *Backend) notify(ev registry.Event) {
+	for ch := range b.watchers {
+		select {
+		case ch <- ev:
+		default:
+			b.dropWatcher(ch)
+		}
+	}
+}
+
+// dropWatcher must be called with the write lock held.
+func (b *Backend) dropWatcher(ch chan registry.Event) {
+	if _, ok := b.watchers[ch]; ok {
+		delete(b.watchers, ch)
+		close(ch)
+	}
+}
+
+// atCapacity reports whether adding one more record would exceed limit.
+// A limit of zero means unlimited.
+func atCapacity(size, limit int) bool {
+	return limit > 0 && size >= limit
+}
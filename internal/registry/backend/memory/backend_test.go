@@ -7,47 +7,181 @@ import (
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
 )
 
-var _ registry.Backend = (*Backend)(nil)
+var (
+	_ registry.Backend = (*Backend)(nil)
+	_ registry.Watcher = (*Backend)(nil)
+)
 
-func TestBackendMethodsReturnNotImplemented(t *testing.T) {
-	backend, err := New(&registry.MemoryConfig{})
-	if err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+func TestNewValidatesConfig(t *testing.T) {
+	if _, err := New(nil); err != nil {
+		t.Fatalf("expected nil config to be accepted, got %v", err)
+	}
+
+	if _, err := New(&registry.MemoryConfig{MaxRelays: -1}); !errors.Is(err, registry.ErrMemoryMaxRelaysInvalid) {
+		t.Fatalf("expected ErrMemoryMaxRelaysInvalid, got %v", err)
+	}
+
+	if _, err := New(&registry.MemoryConfig{MaxAgents: -1}); !errors.Is(err, registry.ErrMemoryMaxAgentsInvalid) {
+		t.Fatalf("expected ErrMemoryMaxAgentsInvalid, got %v", err)
 	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		return newTestBackend(t, &registry.MemoryConfig{})
+	})
+}
 
+func TestCapacityLimits(t *testing.T) {
+	backend := newTestBackend(t, &registry.MemoryConfig{MaxRelays: 1, MaxAgents: 1})
 	ctx := context.Background()
 
-	if err := backend.RegisterRelay(ctx, registry.Relay{}); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); !errors.Is(err, registry.ErrCapacityExceeded) {
+		t.Fatalf("expected ErrCapacityExceeded, got %v", err)
 	}
 
-	if err := backend.HeartbeatRelay(ctx, "relay", time.Now()); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	// Re-registering an existing relay does not consume capacity.
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "10.0.0.2"}); err != nil {
+		t.Fatalf("re-register relay: %v", err)
 	}
 
-	if _, err := backend.ListRelays(ctx); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	if err := backend.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := backend.RegisterAgent(ctx, registry.Agent{ID: "agent-2"}, "relay-1"); !errors.Is(err, registry.ErrCapacityExceeded) {
+		t.Fatalf("expected ErrCapacityExceeded, got %v", err)
+	}
+	if err := backend.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("re-register agent: %v", err)
 	}
 
-	if err := backend.RemoveRelay(ctx, "relay"); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	// Freed capacity can be reused.
+	if err := backend.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
 	}
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+		t.Fatalf("register relay after removal: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	backend := newTestBackend(t, &registry.MemoryConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	if err := backend.RegisterAgent(ctx, registry.Agent{}, "relay"); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	events, err := backend.Watch(ctx)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
 	}
 
-	if err := backend.HeartbeatAgent(ctx, "agent", time.Now()); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	now := time.Now()
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: now}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := backend.HeartbeatRelay(ctx, "relay-1", now.Add(time.Second)); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := backend.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := backend.RemoveAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("remove agent: %v", err)
+	}
+	if err := backend.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
 	}
 
-	if _, err := backend.GetAgentPlacement(ctx, "agent"); !errors.Is(err, registry.ErrNotImplemented) {
-		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	want := []registry.EventType{
+		registry.EventRelayAdded,
+		registry.EventRelayHeartbeat,
+		registry.EventPlacementChanged,
+		registry.EventPlacementRemoved,
+		registry.EventRelayRemoved,
+	}
+	for _, wantType := range want {
+		if ev := <-events; ev.Type != wantType {
+			t.Fatalf("expected %s, got %s", wantType, ev.Type)
+		}
+	}
+
+	// Failed writes publish nothing.
+	if err := backend.HeartbeatRelay(ctx, "relay-1", now); err == nil {
+		t.Fatalf("expected heartbeat of removed relay to fail")
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("expected no event, got %s", ev.Type)
+	default:
 	}
 
 	if err := backend.Close(ctx); err != nil {
-		t.Fatalf("expected nil error, got %v", err)
+		t.Fatalf("close: %v", err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatalf("expected watch channel to close with the backend")
+	}
+}
+
+func TestRegistryWatchFollowsBackend(t *testing.T) {
+	backend := newTestBackend(t, &registry.MemoryConfig{})
+	reg, err := registry.New(&registry.Config{
+		Backend: registry.BackendConfig{Type: registry.MemoryRegistryBackend},
+		GRPC:    registry.GRPCConfig{ListenAddress: "127.0.0.1", ListenPort: 50051},
+		TTL:     registry.TTLConfig{Relay: time.Minute, Agent: time.Minute},
+		Watch:   registry.WatchConfig{Interval: time.Hour},
+	}, backend)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := reg.WatchPlacements(ctx, "")
+	if err != nil {
+		t.Fatalf("watch placements: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reg.RunWatch(ctx)
+	}()
+
+	// The polling interval is an hour, so these can only arrive through the
+	// backend's native watch.
+	if err := reg.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Type != registry.EventPlacementChanged || ev.Placement.RelayID != "relay-1" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for placement event")
+	}
+
+	cancel()
+	<-done
+}
+
+func newTestBackend(t *testing.T, cfg *registry.MemoryConfig) *Backend {
+	t.Helper()
+
+	backend, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
 	}
+	return backend
 }
@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.MemoryRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.Memory)
+		},
+	})
+}
+
+// validate accepts a nil config, which New treats as unlimited.
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.Memory == nil {
+		return nil
+	}
+
+	return cfg.Memory.Validate()
+}
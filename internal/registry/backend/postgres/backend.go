@@ -0,0 +1,373 @@
+// Package postgres provides a PostgreSQL backend implementation.
+//
+// Relays, agents and placements are rows in three tables created by
+// versioned schema migrations when the backend starts. Registration and
+// heartbeats are single upsert or update statements, and expired rows are
+// filtered out of every read. Each write also publishes a notification in
+// the same statement, which Watch delivers as registry events.
+//
+// The backend speaks the PostgreSQL wire protocol directly rather than
+// through a driver, implementing only what it needs.
+package postgres
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/clienttls"
+)
+
+const defaultDialTimeout = 5 * time.Second
+
+type Backend struct {
+	cfg *registry.PostgresConfig
+	ttl atomic.Pointer[registry.TTLConfig]
+	now func() time.Time
+	tls *tls.Config
+
+	pool *pool
+
+	mu        sync.Mutex
+	listeners map[*conn]struct{}
+	closed    bool
+}
+
+// New connects to the server, migrates the schema and returns the backend.
+// Reads return expired rows too, so the registry judges liveness and its
+// reaper finds and removes them. Agents cannot be placed on a relay whose
+// last heartbeat is older than ttl.Relay; a zero TTL disables that check.
+func New(cfg *registry.PostgresConfig, ttl registry.TTLConfig) (*Backend, error) {
+	return open(cfg, ttl, time.Now)
+}
+
+func open(cfg *registry.PostgresConfig, ttl registry.TTLConfig, now func() time.Time) (*Backend, error) {
+	if cfg == nil {
+		return nil, registry.ErrPostgresConfigNil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := clienttls.Load("postgres", cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil && tlsCfg.ServerName == "" {
+		host, _, _ := net.SplitHostPort(cfg.Address)
+		tlsCfg.ServerName = host
+	}
+
+	b := &Backend{
+		cfg:       cfg,
+		now:       now,
+		tls:       tlsCfg,
+		listeners: make(map[*conn]struct{}),
+	}
+	b.ttl.Store(&ttl)
+	b.pool = newPool(b.dial, cfg.MaxConns)
+
+	if err := b.migrate(context.Background()); err != nil {
+		b.pool.close()
+		return nil, fmt.Errorf("migrate postgres schema: %w", err)
+	}
+	return b, nil
+}
+
+func (b *Backend) dial(ctx context.Context) (*conn, error) {
+	timeout := b.cfg.DialTimeout
+	if timeout == 0 {
+		timeout = defaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", b.cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := startup(ctx, netConn, startupOptions{
+		user:     b.cfg.User,
+		password: b.cfg.Password,
+		database: b.cfg.Database,
+		tls:      b.tls,
+	})
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (b *Backend) exec(ctx context.Context, query string, args ...any) (result, error) {
+	c, err := b.pool.get(ctx)
+	if err != nil {
+		return result{}, err
+	}
+	defer b.pool.put(c)
+
+	return c.exec(ctx, query, args...)
+}
+
+// cutoff returns the Unix nanosecond timestamp at or before which a record
+// with the given TTL has expired. It is taken from the registry's clock
+// rather than the server's now(), since that is the clock heartbeat
+// timestamps come from.
+func (b *Backend) cutoff(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return math.MinInt64
+	}
+	return b.now().Add(-ttl).UnixNano()
+}
+
+func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relay.ID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if relay.LastSeen.IsZero() {
+		relay.LastSeen = b.now()
+	}
+
+	_, err := b.exec(ctx, stmtRegisterRelay, relay.ID, relay.Address, relay.GRPCPort, relay.LastSeen.UnixNano())
+	return err
+}
+
+func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if ts.IsZero() {
+		ts = b.now()
+	}
+
+	res, err := b.exec(ctx, stmtHeartbeatRelay, relayID, ts.UnixNano())
+	if err != nil {
+		return err
+	}
+	if len(res.rows) == 0 {
+		return registry.ErrRelayNotRegistered
+	}
+	return nil
+}
+
+func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res, err := b.exec(ctx, stmtListRelays)
+	if err != nil {
+		return nil, err
+	}
+
+	relays := make([]registry.Relay, 0, len(res.rows))
+	for _, row := range res.rows {
+		relay, err := parseRelay(row)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+	return relays, nil
+}
+
+func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+
+	// Persistence-only responsibility: dependent placements are invalidated
+	// by the registry layer, not here.
+	res, err := b.exec(ctx, stmtRemoveRelay, relayID)
+	if err != nil {
+		return err
+	}
+	if len(res.rows) == 0 {
+		return registry.ErrRelayNotRegistered
+	}
+	return nil
+}
+
+func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relayID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if relayID == "" {
+		return registry.ErrRelayIDEmpty
+	}
+	if agent.ID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if agent.LastHeartbeat.IsZero() {
+		agent.LastHeartbeat = b.now()
+	}
+
+	res, err := b.exec(ctx, stmtRegisterAgent, agent.ID, relayID, agent.LastHeartbeat.UnixNano(), b.cutoff(b.ttl.Load().Relay))
+	if err != nil {
+		return err
+	}
+	if len(res.rows) == 0 {
+		return registry.ErrRelayNotRegistered
+	}
+	return nil
+}
+
+func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+	if ts.IsZero() {
+		ts = b.now()
+	}
+
+	res, err := b.exec(ctx, stmtHeartbeatAgent, agentID, ts.UnixNano())
+	if err != nil {
+		return err
+	}
+	if len(res.rows) == 0 {
+		return registry.ErrAgentNotRegistered
+	}
+	return nil
+}
+
+func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if agentID == "" {
+		return nil, registry.ErrAgentIDEmpty
+	}
+
+	res, err := b.exec(ctx, stmtGetPlacement, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.rows) == 0 {
+		return nil, registry.ErrAgentNotRegistered
+	}
+
+	placement, err := parsePlacement(res.rows[0])
+	if err != nil {
+		return nil, err
+	}
+	return &placement, nil
+}
+
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res, err := b.exec(ctx, stmtListPlacements)
+	if err != nil {
+		return nil, err
+	}
+
+	placements := make([]registry.AgentPlacement, 0, len(res.rows))
+	for _, row := range res.rows {
+		placement, err := parsePlacement(row)
+		if err != nil {
+			return nil, err
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
+	}
+
+	res, err := b.exec(ctx, stmtRemoveAgent, agentID)
+	if err != nil {
+		return err
+	}
+	if len(res.rows) == 0 {
+		return registry.ErrAgentNotRegistered
+	}
+	return nil
+}
+
+// SetTTL replaces the relay TTL checked when placing agents. It takes
+// effect on the next statement.
+func (b *Backend) SetTTL(ttl registry.TTLConfig) error {
+	b.ttl.Store(&ttl)
+	return nil
+}
+
+// Close closes pooled connections and ends every Watch.
+func (b *Backend) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	for c := range b.listeners {
+		_ = c.netConn.Close()
+	}
+	b.mu.Unlock()
+
+	b.pool.close()
+	return nil
+}
+
+func parseRelay(row []string) (registry.Relay, error) {
+	if len(row) != 4 {
+		return registry.Relay{}, errProtocol
+	}
+
+	port, err := strconv.Atoi(row[2])
+	if err != nil {
+		return registry.Relay{}, fmt.Errorf("parse relay %s grpc port: %w", row[0], err)
+	}
+	lastSeen, err := strconv.ParseInt(row[3], 10, 64)
+	if err != nil {
+		return registry.Relay{}, fmt.Errorf("parse relay %s last seen: %w", row[0], err)
+	}
+
+	return registry.Relay{
+		ID:       row[0],
+		Address:  row[1],
+		GRPCPort: port,
+		LastSeen: time.Unix(0, lastSeen),
+	}, nil
+}
+
+func parsePlacement(row []string) (registry.AgentPlacement, error) {
+	if len(row) != 3 {
+		return registry.AgentPlacement{}, errProtocol
+	}
+
+	updatedAt, err := strconv.ParseInt(row[2], 10, 64)
+	if err != nil {
+		return registry.AgentPlacement{}, fmt.Errorf("parse placement %s updated at: %w", row[0], err)
+	}
+
+	return registry.AgentPlacement{
+		AgentID:   row[0],
+		RelayID:   row[1],
+		UpdatedAt: time.Unix(0, updatedAt),
+	}, nil
+}
@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+var (
+	_ registry.Backend = (*Backend)(nil)
+	_ registry.Watcher = (*Backend)(nil)
+)
+
+func TestNewRequiresValidConfig(t *testing.T) {
+	if _, err := New(nil, registry.TTLConfig{}); !errors.Is(err, registry.ErrPostgresConfigNil) {
+		t.Fatalf("expected ErrPostgresConfigNil, got %v", err)
+	}
+	if _, err := New(&registry.PostgresConfig{}, registry.TTLConfig{}); !errors.Is(err, registry.ErrPostgresAddrEmpty) {
+		t.Fatalf("expected ErrPostgresAddrEmpty, got %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		return newFakeBackend(t, newFakePostgres(t), registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+	})
+}
+
+func TestMigrationsRunOnce(t *testing.T) {
+	f := newFakePostgres(t)
+	f.auth = "scram"
+	f.password = "s3cret"
+
+	for range 2 {
+		b, err := open(&registry.PostgresConfig{Address: f.addr, User: "registry", Password: "s3cret"}, registry.TTLConfig{}, time.Now)
+		if err != nil {
+			t.Fatalf("open backend: %v", err)
+		}
+		if err := b.Close(context.Background()); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.migrationRuns != 1 || len(f.versions) != len(migrations) {
+		t.Fatalf("expected each migration to run once, got %d runs and versions %v", f.migrationRuns, f.versions)
+	}
+}
+
+func TestNewRejectsNewerSchema(t *testing.T) {
+	f := newFakePostgres(t)
+	f.migrationsTable = true
+	f.versions = []int{1, 2, 3}
+
+	_, err := open(&registry.PostgresConfig{Address: f.addr, User: "registry"}, registry.TTLConfig{}, time.Now)
+	if err == nil || !strings.Contains(err.Error(), "newer than this build supports") {
+		t.Fatalf("expected a newer schema to be rejected, got %v", err)
+	}
+}
+
+func TestReadsReturnExpiredRows(t *testing.T) {
+	clock := backendtest.NewClock()
+	b := newFakeBackend(t, newFakePostgres(t), registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second}, clock.Now)
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	// Past both TTLs the rows are still returned, for the registry to
+	// judge and its reaper to remove.
+	clock.Advance(21 * time.Second)
+	if relays, err := b.ListRelays(ctx); err != nil || len(relays) != 1 {
+		t.Fatalf("expected the expired relay to be listed, got %+v (%v)", relays, err)
+	}
+	if placements, err := b.ListPlacements(ctx); err != nil || len(placements) != 1 {
+		t.Fatalf("expected the expired placement to be listed, got %+v (%v)", placements, err)
+	}
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("expected the expired placement to be returned, got %v", err)
+	}
+
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-2", LastHeartbeat: clock.Now()}, "relay-1"); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected placing on an expired relay to fail, got %v", err)
+	}
+
+	// A late heartbeat revives the relay for placements.
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+		t.Fatalf("expected a late relay heartbeat to succeed, got %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-2", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("expected placing on a revived relay to succeed, got %v", err)
+	}
+}
+
+func TestSweepRemovesExpiredRows(t *testing.T) {
+	clock := backendtest.NewClock()
+	f := newFakePostgres(t)
+	ttl := registry.TTLConfig{Relay: 10 * time.Second, Agent: 10 * time.Second}
+	b := newFakeBackend(t, f, ttl, clock.Now)
+	ctx := context.Background()
+
+	reg, err := registry.New(&registry.Config{
+		GRPC: registry.GRPCConfig{ListenAddress: "127.0.0.1", ListenPort: 50051},
+		TTL:  ttl,
+	}, b, registry.WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	clock.Advance(11 * time.Second)
+	if err := reg.Sweep(ctx); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.relays) != 0 || len(f.agents) != 0 || len(f.placements) != 0 {
+		t.Fatalf("expected the sweep to delete the expired rows, got relays %v, agents %v and placements %v", f.relays, f.agents, f.placements)
+	}
+}
+
+func TestSetTTLChangesThePlacementCheck(t *testing.T) {
+	clock := backendtest.NewClock()
+	b := newFakeBackend(t, newFakePostgres(t), registry.TTLConfig{Relay: 10 * time.Second, Agent: 10 * time.Second}, clock.Now)
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	clock.Advance(15 * time.Second)
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected placing on an expired relay to fail, got %v", err)
+	}
+
+	if err := b.SetTTL(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("expected the relay to be live under the new ttl, got %v", err)
+	}
+}
+
+func TestWatchDeliversNotifications(t *testing.T) {
+	b := newFakeBackend(t, newFakePostgres(t), registry.TTLConfig{}, time.Now)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := b.Watch(ctx)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	now := time.Now()
+	steps := []func() error{
+		func() error {
+			return b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: now})
+		},
+		func() error { return b.HeartbeatRelay(ctx, "relay-1", now.Add(time.Second)) },
+		func() error {
+			return b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1")
+		},
+		func() error { return b.HeartbeatAgent(ctx, "agent-1", now.Add(2*time.Second)) },
+		func() error { return b.RemoveAgent(ctx, "agent-1") },
+		func() error { return b.RemoveRelay(ctx, "relay-1") },
+		// Writes that change nothing publish nothing.
+		func() error {
+			if err := b.RemoveRelay(ctx, "relay-1"); !errors.Is(err, registry.ErrRelayNotRegistered) {
+				return err
+			}
+			return nil
+		},
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	want := []registry.Event{
+		{Type: registry.EventRelayAdded, Relay: registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: now}},
+		{Type: registry.EventRelayHeartbeat, Relay: registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: now.Add(time.Second)}},
+		{Type: registry.EventPlacementChanged, Placement: registry.AgentPlacement{AgentID: "agent-1", RelayID: "relay-1", UpdatedAt: now}},
+		{Type: registry.EventPlacementChanged, Placement: registry.AgentPlacement{AgentID: "agent-1", RelayID: "relay-1", UpdatedAt: now.Add(2 * time.Second)}},
+		{Type: registry.EventPlacementRemoved, Placement: registry.AgentPlacement{AgentID: "agent-1"}},
+		{Type: registry.EventRelayRemoved, Relay: registry.Relay{ID: "relay-1"}},
+	}
+	for i, w := range want {
+		select {
+		case got := <-events:
+			if got.Type != w.Type || !sameRelay(got.Relay, w.Relay) || !samePlacement(got.Placement, w.Placement) {
+				t.Fatalf("event %d: expected %+v, got %+v", i, w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected no further events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the channel to close once ctx is canceled")
+	}
+}
+
+func TestCloseEndsWatch(t *testing.T) {
+	f := newFakePostgres(t)
+	b, err := open(&registry.PostgresConfig{Address: f.addr, User: "registry"}, registry.TTLConfig{}, time.Now)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+
+	events, err := b.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected no events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Close to end the watch")
+	}
+	if _, err := b.Watch(context.Background()); err == nil {
+		t.Fatalf("expected Watch to fail after Close")
+	}
+}
+
+func sameRelay(a, b registry.Relay) bool {
+	return a.ID == b.ID && a.Address == b.Address && a.GRPCPort == b.GRPCPort && a.LastSeen.Equal(b.LastSeen)
+}
+
+func samePlacement(a, b registry.AgentPlacement) bool {
+	return a.AgentID == b.AgentID && a.RelayID == b.RelayID && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+func newFakeBackend(t *testing.T, f *fakePostgres, ttl registry.TTLConfig, now func() time.Time) *Backend {
+	t.Helper()
+
+	b, err := open(&registry.PostgresConfig{Address: f.addr, User: "registry", MaxConns: 4}, ttl, now)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
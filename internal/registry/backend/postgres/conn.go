@@ -0,0 +1,638 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The client speaks version 3.0 of the PostgreSQL frontend/backend
+// protocol. Only the parts the backend needs are implemented: password
+// authentication, the extended query protocol with text parameters and
+// results, simple queries, and asynchronous notifications.
+const (
+	protocolVersion = 3 << 16
+	sslRequestCode  = 80877103
+
+	// maxMessageSize guards against allocating for a corrupt length.
+	maxMessageSize = 1 << 24
+)
+
+// Authentication request codes sent in an 'R' message.
+const (
+	authOK                = 0
+	authCleartextPassword = 3
+	authMD5Password       = 5
+	authSASL              = 10
+	authSASLContinue      = 11
+	authSASLFinal         = 12
+)
+
+const scramMechanism = "SCRAM-SHA-256"
+
+var errProtocol = errors.New("postgres: protocol violation")
+
+// pgError is an ErrorResponse sent by the server. Errors reported while a
+// query runs leave the connection usable.
+type pgError struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+func (e *pgError) Error() string {
+	return fmt.Sprintf("postgres: %s (SQLSTATE %s)", e.Message, e.Code)
+}
+
+// notification is a NotificationResponse delivered to a listening session.
+type notification struct {
+	Channel string
+	Payload string
+}
+
+// result holds the rows returned by a statement in text format. NULL
+// columns are returned as empty strings; the backend never selects any.
+type result struct {
+	rows [][]string
+}
+
+type startupOptions struct {
+	user     string
+	password string
+	database string
+	tls      *tls.Config
+}
+
+// conn is a single authenticated session with a PostgreSQL server.
+type conn struct {
+	netConn  net.Conn
+	r        *bufio.Reader
+	buf      []byte
+	lastUsed time.Time
+
+	// broken is set when an I/O error or cancellation leaves the protocol
+	// state unknown; the connection must then be discarded.
+	broken bool
+}
+
+// startup negotiates TLS if configured, sends the startup message and
+// authenticates. It returns once the server is ready for queries.
+func startup(ctx context.Context, netConn net.Conn, opts startupOptions) (*conn, error) {
+	deadline, _ := ctx.Deadline()
+	_ = netConn.SetDeadline(deadline)
+
+	if opts.tls != nil {
+		tlsConn, err := negotiateTLS(ctx, netConn, opts.tls)
+		if err != nil {
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+
+	c := &conn{netConn: netConn, r: bufio.NewReader(netConn)}
+
+	c.buf = appendMessage(c.buf[:0], 0, func(b []byte) []byte {
+		b = binary.BigEndian.AppendUint32(b, protocolVersion)
+		b = appendString(b, "user")
+		b = appendString(b, opts.user)
+		if opts.database != "" {
+			b = appendString(b, "database")
+			b = appendString(b, opts.database)
+		}
+		b = appendString(b, "application_name")
+		b = appendString(b, "aero-arc-registry")
+		return append(b, 0)
+	})
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+
+	var scram *scramClient
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case 'R':
+			code := body.uint32()
+			switch code {
+			case authOK:
+			case authCleartextPassword:
+				err = c.sendPassword(opts.password)
+			case authMD5Password:
+				salt := body.next(4)
+				err = c.sendPassword(md5Password(opts.user, opts.password, salt))
+			case authSASL:
+				if !body.containsString(scramMechanism) {
+					return nil, errors.New("postgres: server offers no supported SASL mechanism")
+				}
+				scram, err = newSCRAMClient(opts.password)
+				if err == nil {
+					err = c.sendSASLInitial(scram.first())
+				}
+			case authSASLContinue:
+				if scram == nil {
+					return nil, errProtocol
+				}
+				var final []byte
+				final, err = scram.final(body)
+				if err == nil {
+					err = c.sendSASL(final)
+				}
+			case authSASLFinal:
+				if scram == nil {
+					return nil, errProtocol
+				}
+				err = scram.verify(body)
+			default:
+				return nil, fmt.Errorf("postgres: unsupported authentication method %d", code)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case 'K':
+			// Cancel keys are not used; cancellation drops the connection.
+		case 'Z':
+			_ = c.netConn.SetDeadline(time.Time{})
+			c.lastUsed = time.Now()
+			return c, nil
+		case 'E':
+			return nil, parseError(body)
+		default:
+			return nil, errProtocol
+		}
+	}
+}
+
+func negotiateTLS(ctx context.Context, netConn net.Conn, cfg *tls.Config) (net.Conn, error) {
+	req := appendMessage(nil, 0, func(b []byte) []byte {
+		return binary.BigEndian.AppendUint32(b, sslRequestCode)
+	})
+	if _, err := netConn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var reply [1]byte
+	if _, err := io.ReadFull(netConn, reply[:]); err != nil {
+		return nil, err
+	}
+	if reply[0] != 'S' {
+		return nil, errors.New("postgres: server does not support TLS")
+	}
+
+	tlsConn := tls.Client(netConn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("postgres tls handshake: %w", err)
+	}
+	return tlsConn, nil
+}
+
+func (c *conn) sendPassword(password string) error {
+	c.buf = appendMessage(c.buf[:0], 'p', func(b []byte) []byte {
+		return appendString(b, password)
+	})
+	return c.flush()
+}
+
+func (c *conn) sendSASLInitial(data []byte) error {
+	c.buf = appendMessage(c.buf[:0], 'p', func(b []byte) []byte {
+		b = appendString(b, scramMechanism)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		return append(b, data...)
+	})
+	return c.flush()
+}
+
+func (c *conn) sendSASL(data []byte) error {
+	c.buf = appendMessage(c.buf[:0], 'p', func(b []byte) []byte {
+		return append(b, data...)
+	})
+	return c.flush()
+}
+
+// exec runs query with args through the extended query protocol. Arguments
+// are sent as text; placeholders should carry casts so the server does not
+// have to infer their types.
+func (c *conn) exec(ctx context.Context, query string, args ...any) (result, error) {
+	params := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			params[i] = v
+		case int:
+			params[i] = strconv.Itoa(v)
+		case int64:
+			params[i] = strconv.FormatInt(v, 10)
+		default:
+			return result{}, fmt.Errorf("postgres: unsupported argument type %T", arg)
+		}
+	}
+
+	stop := c.guard(ctx)
+	res, err := c.execParams(query, params)
+	if stopErr := stop(); stopErr != nil {
+		return result{}, stopErr
+	}
+	return res, err
+}
+
+func (c *conn) execParams(query string, params []string) (result, error) {
+	buf := appendMessage(c.buf[:0], 'P', func(b []byte) []byte {
+		b = appendString(b, "")
+		b = appendString(b, query)
+		return binary.BigEndian.AppendUint16(b, 0)
+	})
+	buf = appendMessage(buf, 'B', func(b []byte) []byte {
+		b = appendString(b, "")
+		b = appendString(b, "")
+		b = binary.BigEndian.AppendUint16(b, 0)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(params)))
+		for _, p := range params {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(p)))
+			b = append(b, p...)
+		}
+		return binary.BigEndian.AppendUint16(b, 0)
+	})
+	buf = appendMessage(buf, 'E', func(b []byte) []byte {
+		b = appendString(b, "")
+		return binary.BigEndian.AppendUint32(b, 0)
+	})
+	c.buf = appendMessage(buf, 'S', nil)
+	if err := c.flush(); err != nil {
+		return result{}, err
+	}
+
+	return c.readResult()
+}
+
+// simpleExec runs query through the simple query protocol, which accepts
+// several statements separated by semicolons and runs them as one
+// transaction. Rows are discarded.
+func (c *conn) simpleExec(ctx context.Context, query string) error {
+	stop := c.guard(ctx)
+	c.buf = appendMessage(c.buf[:0], 'Q', func(b []byte) []byte {
+		return appendString(b, query)
+	})
+	err := c.flush()
+	if err == nil {
+		_, err = c.readResult()
+	}
+	if stopErr := stop(); stopErr != nil {
+		return stopErr
+	}
+	return err
+}
+
+// readResult consumes messages up to ReadyForQuery. A server error is
+// returned only after that, so the connection stays in sync.
+func (c *conn) readResult() (result, error) {
+	var (
+		res    result
+		errRes error
+	)
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return result{}, err
+		}
+
+		switch typ {
+		case 'D':
+			n := int(body.uint16())
+			row := make([]string, n)
+			for i := range row {
+				size := int32(body.uint32())
+				if size > 0 {
+					row[i] = string(body.next(int(size)))
+				}
+			}
+			res.rows = append(res.rows, row)
+		case 'E':
+			errRes = parseError(body)
+		case 'Z':
+			c.lastUsed = time.Now()
+			return res, errRes
+		case '1', '2', '3', 'C', 'I', 'n', 'T', 't':
+		default:
+			c.broken = true
+			return result{}, errProtocol
+		}
+	}
+}
+
+// nextNotification blocks until the server delivers a notification. It is
+// only used on sessions that do nothing but LISTEN.
+func (c *conn) nextNotification() (notification, error) {
+	for {
+		typ, body, err := c.readMessage()
+		if err != nil {
+			return notification{}, err
+		}
+
+		switch typ {
+		case 'A':
+			_ = body.uint32()
+			return notification{Channel: body.string(), Payload: body.string()}, nil
+		case 'E':
+			c.broken = true
+			return notification{}, parseError(body)
+		}
+	}
+}
+
+// guard applies ctx's deadline to the connection and interrupts blocked
+// I/O if ctx is canceled. The returned function must be called once the
+// exchange is over; it reports ctx's error if ctx interrupted it, in which
+// case the connection is marked broken.
+func (c *conn) guard(ctx context.Context) func() error {
+	deadline, _ := ctx.Deadline()
+	_ = c.netConn.SetDeadline(deadline)
+
+	if ctx.Done() == nil {
+		return func() error { return nil }
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = c.netConn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func() error {
+		close(done)
+		<-exited
+		if err := ctx.Err(); err != nil {
+			c.broken = true
+			return err
+		}
+		return nil
+	}
+}
+
+// receive reads the next message, skipping the asynchronous ones a session
+// may get at any time.
+func (c *conn) receive() (byte, *reader, error) {
+	for {
+		typ, body, err := c.readMessage()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch typ {
+		case 'N', 'S', 'A':
+			continue
+		}
+		return typ, body, nil
+	}
+}
+
+func (c *conn) readMessage() (byte, *reader, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		c.broken = true
+		return 0, nil, err
+	}
+
+	size := int(binary.BigEndian.Uint32(header[1:])) - 4
+	if size < 0 || size > maxMessageSize {
+		c.broken = true
+		return 0, nil, errProtocol
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.broken = true
+		return 0, nil, err
+	}
+	return header[0], &reader{buf: body}, nil
+}
+
+func (c *conn) flush() error {
+	if _, err := c.netConn.Write(c.buf); err != nil {
+		c.broken = true
+		return err
+	}
+	return nil
+}
+
+// close sends Terminate on a healthy connection and closes it.
+func (c *conn) close() {
+	if !c.broken {
+		_ = c.netConn.SetDeadline(time.Now().Add(time.Second))
+		_, _ = c.netConn.Write(appendMessage(nil, 'X', nil))
+	}
+	_ = c.netConn.Close()
+}
+
+// appendMessage appends a message of type typ, whose body is written by
+// fill, to buf. A zero typ writes an untyped startup-phase message.
+func appendMessage(buf []byte, typ byte, fill func([]byte) []byte) []byte {
+	if typ != 0 {
+		buf = append(buf, typ)
+	}
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	if fill != nil {
+		buf = fill(buf)
+	}
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(buf)-start))
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, s...)
+	return append(buf, 0)
+}
+
+// reader decodes a message body. Reads past the end yield zero values
+// rather than panicking on a malformed message.
+type reader struct {
+	buf []byte
+}
+
+func (r *reader) next(n int) []byte {
+	if n > len(r.buf) {
+		n = len(r.buf)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.next(4)
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.next(2)
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) string() string {
+	i := strings.IndexByte(string(r.buf), 0)
+	if i < 0 {
+		s := string(r.buf)
+		r.buf = nil
+		return s
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+	return s
+}
+
+// containsString reports whether the remaining body, a list of
+// null-terminated strings, contains s.
+func (r *reader) containsString(s string) bool {
+	for len(r.buf) > 0 {
+		if v := r.string(); v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func parseError(r *reader) *pgError {
+	e := &pgError{}
+	for len(r.buf) > 0 {
+		field := r.next(1)[0]
+		if field == 0 {
+			break
+		}
+		value := r.string()
+		switch field {
+		case 'S':
+			e.Severity = value
+		case 'C':
+			e.Code = value
+		case 'M':
+			e.Message = value
+		}
+	}
+	return e
+}
+
+func md5Password(user, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// scramClient runs the client side of a SCRAM-SHA-256 exchange (RFC 7677)
+// without channel binding. Passwords are used as given, without SASLprep.
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	s := &scramClient{password: password, clientNonce: base64.StdEncoding.EncodeToString(nonce)}
+	// The server takes the user from the startup message, so it is left
+	// empty here.
+	s.clientFirstBare = "n=,r=" + s.clientNonce
+	return s, nil
+}
+
+func (s *scramClient) first() []byte {
+	return []byte("n,," + s.clientFirstBare)
+}
+
+func (s *scramClient) final(r *reader) ([]byte, error) {
+	serverFirst := string(r.buf)
+	attrs := scramAttributes(serverFirst)
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, s.clientNonce) || len(nonce) == len(s.clientNonce) {
+		return nil, errors.New("postgres: scram server nonce is invalid")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, fmt.Errorf("postgres: scram salt is invalid: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 {
+		return nil, errors.New("postgres: scram iteration count is invalid")
+	}
+
+	salted, err := pbkdf2.Key(sha256.New, s.password, salt, iterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := s.clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	proof := hmacSHA256(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	s.serverSignature = hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *scramClient) verify(r *reader) error {
+	attrs := scramAttributes(string(r.buf))
+	if msg, ok := attrs["e"]; ok {
+		return fmt.Errorf("postgres: scram authentication failed: %s", msg)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, s.serverSignature) {
+		return errors.New("postgres: scram server signature does not match")
+	}
+	return nil
+}
+
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
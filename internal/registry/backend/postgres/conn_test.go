@@ -0,0 +1,673 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePostgres is a PostgreSQL stand-in that speaks enough of the wire
+// protocol for the backend and executes the backend's statements, matched
+// by their exact text, against in-memory tables.
+type fakePostgres struct {
+	t    *testing.T
+	ln   net.Listener
+	addr string
+
+	user     string
+	password string
+	// auth is the method requested at startup: "trust", "cleartext",
+	// "md5" or "scram".
+	auth string
+	tls  *tls.Config
+
+	// block, if set, is waited on by "SELECT pg_sleep(60)".
+	block chan struct{}
+
+	mu              sync.Mutex
+	migrationsTable bool
+	versions        []int
+	migrationRuns   int
+	relays          map[string]fakeRelay
+	agents          map[string]int64
+	placements      map[string]fakePlacement
+	listeners       map[*fakeSession]struct{}
+}
+
+type fakeRelay struct {
+	address  string
+	port     int
+	lastSeen int64
+}
+
+type fakePlacement struct {
+	relayID   string
+	updatedAt int64
+}
+
+func newFakePostgres(t *testing.T) *fakePostgres {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	f := &fakePostgres{
+		t:          t,
+		ln:         ln,
+		addr:       ln.Addr().String(),
+		user:       "registry",
+		auth:       "trust",
+		relays:     make(map[string]fakeRelay),
+		agents:     make(map[string]int64),
+		placements: make(map[string]fakePlacement),
+		listeners:  make(map[*fakeSession]struct{}),
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = ln.Close()
+		f.mu.Lock()
+		for s := range f.listeners {
+			_ = s.nc.Close()
+		}
+		f.mu.Unlock()
+		wg.Wait()
+	})
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s := &fakeSession{f: f, nc: nc}
+				s.serve()
+			}()
+		}
+	}()
+
+	return f
+}
+
+type fakeSession struct {
+	f  *fakePostgres
+	nc net.Conn
+	r  *bufio.Reader
+
+	// wmu serializes writes, since notifications are sent from the
+	// sessions that commit them.
+	wmu sync.Mutex
+}
+
+func (s *fakeSession) serve() {
+	defer func() {
+		s.f.mu.Lock()
+		delete(s.f.listeners, s)
+		s.f.mu.Unlock()
+		_ = s.nc.Close()
+	}()
+
+	if !s.startup() {
+		return
+	}
+
+	var (
+		query  string
+		params []string
+		failed bool
+	)
+	for {
+		typ, body, err := s.read()
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case 'Q':
+			rows, tag, pgErr := s.f.execute(s, body.string(), nil)
+			if pgErr != nil {
+				s.sendError(pgErr)
+			} else {
+				s.sendRows(rows, tag)
+			}
+			s.sendReady()
+		case 'P':
+			if failed {
+				continue
+			}
+			_ = body.string()
+			query = body.string()
+			s.send('1', nil)
+		case 'B':
+			if failed {
+				continue
+			}
+			_ = body.string()
+			_ = body.string()
+			body.next(2 * int(body.uint16()))
+			params = make([]string, body.uint16())
+			for i := range params {
+				params[i] = string(body.next(int(body.uint32())))
+			}
+			s.send('2', nil)
+		case 'E':
+			if failed {
+				continue
+			}
+			rows, tag, pgErr := s.f.execute(s, query, params)
+			if pgErr != nil {
+				s.sendError(pgErr)
+				failed = true
+				continue
+			}
+			s.sendRows(rows, tag)
+		case 'S':
+			failed = false
+			s.sendReady()
+		case 'X':
+			return
+		}
+	}
+}
+
+func (s *fakeSession) startup() bool {
+	s.r = bufio.NewReader(s.nc)
+	body, err := s.readStartup()
+	if err != nil {
+		return false
+	}
+
+	if code := body.uint32(); code == sslRequestCode {
+		if s.f.tls == nil {
+			_, _ = s.nc.Write([]byte{'N'})
+			return false
+		}
+		if _, err := s.nc.Write([]byte{'S'}); err != nil {
+			return false
+		}
+		tlsConn := tls.Server(s.nc, s.f.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			return false
+		}
+		s.nc = tlsConn
+		s.r = bufio.NewReader(s.nc)
+		if body, err = s.readStartup(); err != nil {
+			return false
+		}
+		_ = body.uint32()
+	}
+
+	startupParams := make(map[string]string)
+	for {
+		key := body.string()
+		if key == "" {
+			break
+		}
+		startupParams[key] = body.string()
+	}
+	if startupParams["user"] != s.f.user {
+		s.sendError(&pgError{Severity: "FATAL", Code: "28000", Message: fmt.Sprintf("role %q does not exist", startupParams["user"])})
+		return false
+	}
+
+	if !s.authenticate(startupParams["user"]) {
+		s.sendError(&pgError{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"})
+		return false
+	}
+
+	s.send('R', binary.BigEndian.AppendUint32(nil, authOK))
+	s.send('S', []byte("server_version\x0016.0\x00"))
+	s.send('K', make([]byte, 8))
+	s.sendReady()
+	return true
+}
+
+func (s *fakeSession) authenticate(user string) bool {
+	switch s.f.auth {
+	case "trust":
+		return true
+	case "cleartext":
+		s.send('R', binary.BigEndian.AppendUint32(nil, authCleartextPassword))
+		return s.readPassword() == s.f.password
+	case "md5":
+		salt := []byte{1, 2, 3, 4}
+		s.send('R', append(binary.BigEndian.AppendUint32(nil, authMD5Password), salt...))
+		inner := md5.Sum([]byte(s.f.password + user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+		return s.readPassword() == "md5"+hex.EncodeToString(outer[:])
+	case "scram":
+		return s.scram()
+	}
+	return false
+}
+
+func (s *fakeSession) readPassword() string {
+	typ, body, err := s.read()
+	if err != nil || typ != 'p' {
+		return ""
+	}
+	return body.string()
+}
+
+// scram runs the server side of SCRAM-SHA-256.
+func (s *fakeSession) scram() bool {
+	s.send('R', append(binary.BigEndian.AppendUint32(nil, authSASL), scramMechanism+"\x00\x00"...))
+
+	typ, body, err := s.read()
+	if err != nil || typ != 'p' || body.string() != scramMechanism {
+		return false
+	}
+	clientFirst := string(body.next(int(body.uint32())))
+	clientFirstBare, ok := strings.CutPrefix(clientFirst, "n,,")
+	if !ok {
+		return false
+	}
+	nonce := scramAttributes(clientFirstBare)["r"] + "server-nonce"
+	salt := []byte("fake-salt")
+	serverFirst := "r=" + nonce + ",s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	s.send('R', append(binary.BigEndian.AppendUint32(nil, authSASLContinue), serverFirst...))
+
+	typ, body, err = s.read()
+	if err != nil || typ != 'p' {
+		return false
+	}
+	clientFinal := string(body.buf)
+	withoutProof, proofB64, ok := strings.Cut(clientFinal, ",p=")
+	if !ok || withoutProof != "c=biws,r="+nonce {
+		return false
+	}
+	proof, err := base64.StdEncoding.DecodeString(proofB64)
+	if err != nil || len(proof) != sha256.Size {
+		return false
+	}
+
+	salted, err := pbkdf2.Key(sha256.New, s.f.password, salt, 4096, sha256.Size)
+	if err != nil {
+		return false
+	}
+	mac := func(key []byte, msg string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(msg))
+		return h.Sum(nil)
+	}
+	authMessage := clientFirstBare + "," + serverFirst + "," + withoutProof
+	storedKey := sha256.Sum256(mac(salted, "Client Key"))
+	signature := mac(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= signature[i]
+	}
+	if recovered := sha256.Sum256(proof); !hmac.Equal(recovered[:], storedKey[:]) {
+		return false
+	}
+
+	serverFinal := "v=" + base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage))
+	s.send('R', append(binary.BigEndian.AppendUint32(nil, authSASLFinal), serverFinal...))
+	return true
+}
+
+func (s *fakeSession) readStartup() (*reader, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[:])-4)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, err
+	}
+	return &reader{buf: body}, nil
+}
+
+func (s *fakeSession) read() (byte, *reader, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], &reader{buf: body}, nil
+}
+
+func (s *fakeSession) send(typ byte, body []byte) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	msg := appendMessage(nil, typ, func(b []byte) []byte { return append(b, body...) })
+	_, _ = s.nc.Write(msg)
+}
+
+func (s *fakeSession) sendRows(rows [][]string, tag string) {
+	for _, row := range rows {
+		body := binary.BigEndian.AppendUint16(nil, uint16(len(row)))
+		for _, col := range row {
+			body = binary.BigEndian.AppendUint32(body, uint32(len(col)))
+			body = append(body, col...)
+		}
+		s.send('D', body)
+	}
+	s.send('C', appendString(nil, tag))
+}
+
+func (s *fakeSession) sendError(e *pgError) {
+	var body []byte
+	body = appendString(append(body, 'S'), e.Severity)
+	body = appendString(append(body, 'C'), e.Code)
+	body = appendString(append(body, 'M'), e.Message)
+	s.send('E', append(body, 0))
+}
+
+func (s *fakeSession) sendReady() {
+	s.send('Z', []byte{'I'})
+}
+
+func (s *fakeSession) notify(payload map[string]any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		s.f.t.Errorf("marshal notification: %v", err)
+		return
+	}
+	body := binary.BigEndian.AppendUint32(nil, 1)
+	body = appendString(body, notifyChannel)
+	s.send('A', appendString(body, string(data)))
+}
+
+// execute runs one statement. Statements are applied atomically under the
+// server lock, as the single-statement writes are in PostgreSQL.
+func (f *fakePostgres) execute(s *fakeSession, query string, params []string) ([][]string, string, *pgError) {
+	if query == "SELECT pg_sleep(60)" && f.block != nil {
+		<-f.block
+		return [][]string{{""}}, "SELECT 1", nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	param := func(i int) string { return params[i-1] }
+	intParam := func(i int) int64 {
+		v, err := strconv.ParseInt(params[i-1], 10, 64)
+		if err != nil {
+			f.t.Errorf("parameter $%d of %q is not an integer: %q", i, query, params[i-1])
+		}
+		return v
+	}
+	notified := func(events []map[string]any) ([][]string, string, *pgError) {
+		rows := make([][]string, len(events))
+		for i, ev := range events {
+			for l := range f.listeners {
+				l.notify(ev)
+			}
+			rows[i] = []string{""}
+		}
+		return rows, fmt.Sprintf("SELECT %d", len(rows)), nil
+	}
+	ts := func(ns int64) string { return strconv.FormatInt(ns, 10) }
+
+	if !f.migrationsTable && query != "SELECT 1" && query != stmtLockMigrations && query != stmtCreateMigrationsTable && query != stmtUnlockMigrations {
+		return nil, "", &pgError{Severity: "ERROR", Code: "42P01", Message: "relation does not exist"}
+	}
+
+	switch query {
+	case "SELECT 1":
+		return [][]string{{"1"}}, "SELECT 1", nil
+	case stmtLockMigrations:
+		return [][]string{{""}}, "SELECT 1", nil
+	case stmtUnlockMigrations:
+		return [][]string{{"t"}}, "SELECT 1", nil
+	case stmtCreateMigrationsTable:
+		f.migrationsTable = true
+		return nil, "CREATE TABLE", nil
+	case stmtSchemaVersion:
+		version := 0
+		for _, v := range f.versions {
+			version = max(version, v)
+		}
+		return [][]string{{strconv.Itoa(version)}}, "SELECT 1", nil
+	case migrationQuery(1):
+		for _, v := range f.versions {
+			if v == 1 {
+				return nil, "", &pgError{Severity: "ERROR", Code: "42P07", Message: `relation "registry_relays" already exists`}
+			}
+		}
+		f.versions = append(f.versions, 1)
+		f.migrationRuns++
+		return nil, "INSERT 0 1", nil
+	case stmtListen:
+		f.listeners[s] = struct{}{}
+		return nil, "LISTEN", nil
+
+	case stmtRegisterRelay:
+		port, err := strconv.Atoi(param(3))
+		if err != nil {
+			return nil, "", &pgError{Severity: "ERROR", Code: "22P02", Message: "invalid input syntax for type integer"}
+		}
+		relay := fakeRelay{address: param(2), port: port, lastSeen: intParam(4)}
+		f.relays[param(1)] = relay
+		return notified([]map[string]any{{
+			"type": "relay_added", "relay_id": param(1), "address": relay.address, "grpc_port": relay.port, "last_seen_ns": relay.lastSeen,
+		}})
+	case stmtHeartbeatRelay:
+		relay, ok := f.relays[param(1)]
+		if !ok {
+			return notified(nil)
+		}
+		relay.lastSeen = intParam(2)
+		f.relays[param(1)] = relay
+		return notified([]map[string]any{{
+			"type": "relay_heartbeat", "relay_id": param(1), "address": relay.address, "grpc_port": relay.port, "last_seen_ns": relay.lastSeen,
+		}})
+	case stmtRemoveRelay:
+		if _, ok := f.relays[param(1)]; !ok {
+			return notified(nil)
+		}
+		delete(f.relays, param(1))
+		return notified([]map[string]any{{"type": "relay_removed", "relay_id": param(1)}})
+	case stmtRegisterAgent:
+		relay, ok := f.relays[param(2)]
+		if !ok || relay.lastSeen <= intParam(4) {
+			return notified(nil)
+		}
+		f.agents[param(1)] = intParam(3)
+		f.placements[param(1)] = fakePlacement{relayID: param(2), updatedAt: intParam(3)}
+		return notified([]map[string]any{{
+			"type": "placement_changed", "agent_id": param(1), "relay_id": param(2), "updated_at_ns": intParam(3),
+		}})
+	case stmtHeartbeatAgent:
+		placement, ok := f.placements[param(1)]
+		if !ok {
+			return notified(nil)
+		}
+		placement.updatedAt = intParam(2)
+		f.placements[param(1)] = placement
+		if _, ok := f.agents[param(1)]; ok {
+			f.agents[param(1)] = intParam(2)
+		}
+		return notified([]map[string]any{{
+			"type": "placement_changed", "agent_id": param(1), "relay_id": placement.relayID, "updated_at_ns": placement.updatedAt,
+		}})
+	case stmtRemoveAgent:
+		_, ok := f.agents[param(1)]
+		delete(f.agents, param(1))
+		delete(f.placements, param(1))
+		if !ok {
+			return notified(nil)
+		}
+		return notified([]map[string]any{{"type": "placement_removed", "agent_id": param(1)}})
+
+	case stmtListRelays:
+		var rows [][]string
+		for id, relay := range f.relays {
+			rows = append(rows, []string{id, relay.address, strconv.Itoa(relay.port), ts(relay.lastSeen)})
+		}
+		sortRows(rows)
+		return rows, fmt.Sprintf("SELECT %d", len(rows)), nil
+	case stmtGetPlacement:
+		placement, ok := f.placements[param(1)]
+		if !ok {
+			return nil, "SELECT 0", nil
+		}
+		return [][]string{{param(1), placement.relayID, ts(placement.updatedAt)}}, "SELECT 1", nil
+	case stmtListPlacements:
+		var rows [][]string
+		for id, placement := range f.placements {
+			rows = append(rows, []string{id, placement.relayID, ts(placement.updatedAt)})
+		}
+		sortRows(rows)
+		return rows, fmt.Sprintf("SELECT %d", len(rows)), nil
+	}
+
+	return nil, "", &pgError{Severity: "ERROR", Code: "42601", Message: fmt.Sprintf("fake postgres does not understand %q", query)}
+}
+
+func sortRows(rows [][]string) {
+	for i := 1; i < len(rows); i++ {
+		for j := i; j > 0 && rows[j][0] < rows[j-1][0]; j-- {
+			rows[j], rows[j-1] = rows[j-1], rows[j]
+		}
+	}
+}
+
+func dialFake(t *testing.T, f *fakePostgres, opts startupOptions) (*conn, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	netConn, err := net.Dial("tcp", f.addr)
+	if err != nil {
+		t.Fatalf("dial fake postgres: %v", err)
+	}
+	c, err := startup(ctx, netConn, opts)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	t.Cleanup(c.close)
+	return c, nil
+}
+
+func TestStartupAuthenticates(t *testing.T) {
+	for _, auth := range []string{"trust", "cleartext", "md5", "scram"} {
+		t.Run(auth, func(t *testing.T) {
+			f := newFakePostgres(t)
+			f.auth = auth
+			f.password = "s3cret"
+
+			c, err := dialFake(t, f, startupOptions{user: "registry", password: "s3cret"})
+			if err != nil {
+				t.Fatalf("expected startup to succeed, got %v", err)
+			}
+			res, err := c.exec(context.Background(), "SELECT 1")
+			if err != nil || len(res.rows) != 1 || res.rows[0][0] != "1" {
+				t.Fatalf("expected one row, got %+v (%v)", res, err)
+			}
+
+			if auth == "trust" {
+				return
+			}
+			_, err = dialFake(t, f, startupOptions{user: "registry", password: "wrong"})
+			var pgErr *pgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+				t.Fatalf("expected authentication failure, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStartupTLS(t *testing.T) {
+	// Borrow a certificate for 127.0.0.1 from httptest.
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	cert := srv.TLS.Certificates[0]
+	ca := srv.Certificate()
+	srv.Close()
+
+	f := newFakePostgres(t)
+	f.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	c, err := dialFake(t, f, startupOptions{user: "registry", tls: &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("expected tls startup to succeed, got %v", err)
+	}
+	if _, ok := c.netConn.(*tls.Conn); !ok {
+		t.Fatalf("expected a tls connection, got %T", c.netConn)
+	}
+	if _, err := c.exec(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("query over tls: %v", err)
+	}
+
+	if _, err := dialFake(t, f, startupOptions{user: "registry", tls: &tls.Config{ServerName: "127.0.0.1"}}); err == nil {
+		t.Fatalf("expected an untrusted certificate to be rejected")
+	}
+
+	plain := newFakePostgres(t)
+	if _, err := dialFake(t, plain, startupOptions{user: "registry", tls: &tls.Config{RootCAs: roots}}); err == nil {
+		t.Fatalf("expected startup to fail when the server refuses tls")
+	}
+}
+
+func TestQueryErrorKeepsConnection(t *testing.T) {
+	f := newFakePostgres(t)
+	c, err := dialFake(t, f, startupOptions{user: "registry"})
+	if err != nil {
+		t.Fatalf("startup: %v", err)
+	}
+
+	_, err = c.exec(context.Background(), "SELECT nonsense")
+	var pgErr *pgError
+	if !errors.As(err, &pgErr) {
+		t.Fatalf("expected a server error, got %v", err)
+	}
+	if c.broken {
+		t.Fatalf("expected a server error to leave the connection usable")
+	}
+	if _, err := c.exec(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("expected the next query to succeed, got %v", err)
+	}
+}
+
+func TestCanceledQueryBreaksConnection(t *testing.T) {
+	f := newFakePostgres(t)
+	f.block = make(chan struct{})
+	defer close(f.block)
+
+	c, err := dialFake(t, f, startupOptions{user: "registry"})
+	if err != nil {
+		t.Fatalf("startup: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := c.exec(ctx, "SELECT pg_sleep(60)"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !c.broken {
+		t.Fatalf("expected an interrupted query to mark the connection broken")
+	}
+}
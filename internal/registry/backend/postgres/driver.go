@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.PostgresRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.Postgres, cfg.TTL)
+		},
+	})
+}
+
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.Postgres == nil {
+		return registry.ErrPostgresConfigNil
+	}
+
+	return cfg.Postgres.Validate()
+}
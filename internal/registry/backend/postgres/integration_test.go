@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+// integrationAddrEnv names the host:port of a PostgreSQL server. When it is
+// set, the conformance suite also runs against that server, so the wire
+// protocol client and the statements are checked against a real database
+// and not only the fake. The registry tables are emptied before every
+// subtest, so the database must be a disposable one.
+const integrationAddrEnv = "AERO_ARC_REGISTRY_TEST_POSTGRES_ADDR"
+
+func TestIntegrationConformance(t *testing.T) {
+	addr := os.Getenv(integrationAddrEnv)
+	if addr == "" {
+		t.Skipf("%s not set", integrationAddrEnv)
+	}
+
+	cfg := &registry.PostgresConfig{
+		Address:  addr,
+		Database: os.Getenv("AERO_ARC_REGISTRY_TEST_POSTGRES_DATABASE"),
+		User:     os.Getenv("AERO_ARC_REGISTRY_TEST_POSTGRES_USER"),
+		Password: os.Getenv("AERO_ARC_REGISTRY_TEST_POSTGRES_PASSWORD"),
+	}
+	if cfg.User == "" {
+		cfg.User = "postgres"
+	}
+
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		b, err := New(cfg, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+		if err != nil {
+			t.Fatalf("new backend: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := b.exec(ctx, `TRUNCATE registry_placements, registry_agents, registry_relays`); err != nil {
+			t.Fatalf("empty registry tables: %v", err)
+		}
+
+		return b
+	})
+}
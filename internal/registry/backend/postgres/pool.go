@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConns         = 10
+	defaultHealthCheckAfter = 30 * time.Second
+)
+
+// pool is a bounded set of sessions with one PostgreSQL server.
+type pool struct {
+	dial             func(ctx context.Context) (*conn, error)
+	healthCheckAfter time.Duration
+
+	// slots holds one token per connection that may be checked out.
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func newPool(dial func(ctx context.Context) (*conn, error), size int) *pool {
+	if size <= 0 {
+		size = defaultMaxConns
+	}
+
+	return &pool{
+		dial:             dial,
+		healthCheckAfter: defaultHealthCheckAfter,
+		slots:            make(chan struct{}, size),
+	}
+}
+
+// get checks out a connection, reusing an idle one when possible. Idle
+// connections past the health check interval are probed first, so a
+// server restart does not surface as one failed query per pooled session.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		c, err := p.popIdle()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		if c == nil {
+			break
+		}
+		if time.Since(c.lastUsed) < p.healthCheckAfter || p.ping(ctx, c) {
+			return c, nil
+		}
+		c.close()
+	}
+
+	c, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool. Broken connections are closed.
+func (p *pool) put(c *conn) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c.broken || p.closed {
+		c.close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+func (p *pool) popIdle() (*conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, net.ErrClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	c := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return c, nil
+}
+
+func (p *pool) ping(ctx context.Context, c *conn) bool {
+	_, err := c.exec(ctx, "SELECT 1")
+	return err == nil
+}
+
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, c := range p.idle {
+		c.close()
+	}
+	p.idle = nil
+}
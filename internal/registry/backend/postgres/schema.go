@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// notifyChannel is the NOTIFY channel every write publishes to.
+const notifyChannel = "aero_registry_events"
+
+// migrations are applied in order, each in its own transaction, and
+// recorded in registry_schema_migrations. Released migrations must never
+// change; add a new one instead.
+//
+// Timestamps are Unix nanoseconds rather than timestamptz, which only has
+// microsecond precision, so heartbeat times round-trip exactly.
+var migrations = []string{
+	`CREATE TABLE registry_relays (
+	id text PRIMARY KEY,
+	address text NOT NULL,
+	grpc_port integer NOT NULL,
+	last_seen_ns bigint NOT NULL
+);
+CREATE TABLE registry_agents (
+	id text PRIMARY KEY,
+	last_heartbeat_ns bigint NOT NULL
+);
+CREATE TABLE registry_placements (
+	agent_id text PRIMARY KEY,
+	relay_id text NOT NULL,
+	updated_at_ns bigint NOT NULL
+);
+CREATE INDEX registry_relays_last_seen_idx ON registry_relays (last_seen_ns);
+CREATE INDEX registry_placements_updated_at_idx ON registry_placements (updated_at_ns)`,
+}
+
+const (
+	// The advisory lock serializes replicas migrating the same database.
+	// Session-level advisory locks are released when the session ends, so
+	// a crashed replica cannot leave it held.
+	stmtLockMigrations   = `SELECT pg_advisory_lock(7316254301)`
+	stmtUnlockMigrations = `SELECT pg_advisory_unlock(7316254301)`
+
+	stmtCreateMigrationsTable = `CREATE TABLE IF NOT EXISTS registry_schema_migrations (
+	version integer PRIMARY KEY,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`
+	stmtSchemaVersion = `SELECT coalesce(max(version), 0) FROM registry_schema_migrations`
+)
+
+// Every write is a single statement that ends in a pg_notify per changed
+// row, so the notification commits with the write and the number of rows
+// returned tells whether anything matched. Only placing an agent takes an
+// expiry cutoff, in Unix nanoseconds, refusing relays seen at or before
+// it. Reads and heartbeats take none: like every other backend, they see
+// and refresh any row that has not been removed yet, even one past its
+// TTL, and the registry judges liveness.
+const (
+	stmtRegisterRelay = `WITH relay AS (
+	INSERT INTO registry_relays (id, address, grpc_port, last_seen_ns)
+	VALUES ($1::text, $2::text, $3::integer, $4::bigint)
+	ON CONFLICT (id) DO UPDATE
+	SET address = EXCLUDED.address, grpc_port = EXCLUDED.grpc_port, last_seen_ns = EXCLUDED.last_seen_ns
+	RETURNING id, address, grpc_port, last_seen_ns
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'relay_added', 'relay_id', id, 'address', address, 'grpc_port', grpc_port, 'last_seen_ns', last_seen_ns
+)::text) FROM relay`
+
+	stmtHeartbeatRelay = `WITH relay AS (
+	UPDATE registry_relays SET last_seen_ns = $2::bigint
+	WHERE id = $1::text
+	RETURNING id, address, grpc_port, last_seen_ns
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'relay_heartbeat', 'relay_id', id, 'address', address, 'grpc_port', grpc_port, 'last_seen_ns', last_seen_ns
+)::text) FROM relay`
+
+	stmtRemoveRelay = `WITH relay AS (
+	DELETE FROM registry_relays WHERE id = $1::text
+	RETURNING id
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'relay_removed', 'relay_id', id
+)::text) FROM relay`
+
+	// stmtRegisterAgent only places the agent if its relay is live. The
+	// relay row is locked FOR SHARE so it cannot be removed until the
+	// placement commits.
+	stmtRegisterAgent = `WITH relay AS (
+	SELECT id FROM registry_relays
+	WHERE id = $2::text AND last_seen_ns > $4::bigint
+	FOR SHARE
+), agent AS (
+	INSERT INTO registry_agents (id, last_heartbeat_ns)
+	SELECT $1::text, $3::bigint FROM relay
+	ON CONFLICT (id) DO UPDATE SET last_heartbeat_ns = EXCLUDED.last_heartbeat_ns
+	RETURNING id
+), placement AS (
+	INSERT INTO registry_placements (agent_id, relay_id, updated_at_ns)
+	SELECT id, $2::text, $3::bigint FROM agent
+	ON CONFLICT (agent_id) DO UPDATE SET relay_id = EXCLUDED.relay_id, updated_at_ns = EXCLUDED.updated_at_ns
+	RETURNING agent_id, relay_id, updated_at_ns
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'placement_changed', 'agent_id', agent_id, 'relay_id', relay_id, 'updated_at_ns', updated_at_ns
+)::text) FROM placement`
+
+	stmtHeartbeatAgent = `WITH placement AS (
+	UPDATE registry_placements SET updated_at_ns = $2::bigint
+	WHERE agent_id = $1::text
+	RETURNING agent_id, relay_id, updated_at_ns
+), agent AS (
+	UPDATE registry_agents SET last_heartbeat_ns = $2::bigint
+	WHERE id IN (SELECT agent_id FROM placement)
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'placement_changed', 'agent_id', agent_id, 'relay_id', relay_id, 'updated_at_ns', updated_at_ns
+)::text) FROM placement`
+
+	stmtRemoveAgent = `WITH agent AS (
+	DELETE FROM registry_agents WHERE id = $1::text
+	RETURNING id
+), placement AS (
+	DELETE FROM registry_placements WHERE agent_id = $1::text
+)
+SELECT pg_notify('aero_registry_events', json_build_object(
+	'type', 'placement_removed', 'agent_id', id
+)::text) FROM agent`
+
+	stmtListRelays = `SELECT id, address, grpc_port, last_seen_ns FROM registry_relays
+ORDER BY id`
+
+	stmtGetPlacement = `SELECT agent_id, relay_id, updated_at_ns FROM registry_placements
+WHERE agent_id = $1::text`
+
+	stmtListPlacements = `SELECT agent_id, relay_id, updated_at_ns FROM registry_placements
+ORDER BY agent_id`
+
+	stmtListen = `LISTEN aero_registry_events`
+)
+
+// migrationQuery returns migration version together with the insert that
+// records it, to be run as one simple query and thus one transaction.
+func migrationQuery(version int) string {
+	return migrations[version-1] + ";\nINSERT INTO registry_schema_migrations (version) VALUES (" + strconv.Itoa(version) + ")"
+}
+
+// migrate brings the schema up to date. Replicas starting together take
+// turns through an advisory lock, and each migration commits atomically
+// with its version record, so an interrupted run resumes where it stopped.
+func (b *Backend) migrate(ctx context.Context) error {
+	c, err := b.pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	defer b.pool.put(c)
+
+	if _, err := c.exec(ctx, stmtLockMigrations); err != nil {
+		return err
+	}
+	defer func() {
+		if !c.broken {
+			_, _ = c.exec(context.Background(), stmtUnlockMigrations)
+		}
+	}()
+
+	if err := c.simpleExec(ctx, stmtCreateMigrationsTable); err != nil {
+		return err
+	}
+
+	res, err := c.exec(ctx, stmtSchemaVersion)
+	if err != nil {
+		return err
+	}
+	if len(res.rows) != 1 || len(res.rows[0]) != 1 {
+		return errProtocol
+	}
+	version, err := strconv.Atoi(res.rows[0][0])
+	if err != nil {
+		return fmt.Errorf("parse schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, len(migrations))
+	}
+
+	for v := version + 1; v <= len(migrations); v++ {
+		if err := c.simpleExec(ctx, migrationQuery(v)); err != nil {
+			return fmt.Errorf("apply migration %d: %w", v, err)
+		}
+	}
+	return nil
+}
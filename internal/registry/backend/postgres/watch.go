@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+// watchBuffer is the number of undelivered events a watcher may fall
+// behind by before it is disconnected.
+const watchBuffer = 256
+
+// payload is the JSON a write statement publishes with pg_notify.
+type payload struct {
+	Type        registry.EventType `json:"type"`
+	RelayID     string             `json:"relay_id"`
+	Address     string             `json:"address"`
+	GRPCPort    int                `json:"grpc_port"`
+	LastSeenNs  int64              `json:"last_seen_ns"`
+	AgentID     string             `json:"agent_id"`
+	UpdatedAtNs int64              `json:"updated_at_ns"`
+}
+
+// Watch implements registry.Watcher. It opens a dedicated session that
+// LISTENs on the notification channel, and delivers every committed write
+// until ctx is done, the backend is closed or the session is lost. The
+// server only queues notifications for connected sessions, so the channel
+// is closed on any interruption and the caller resyncs from a listing.
+func (b *Backend) Watch(ctx context.Context) (<-chan registry.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c, err := b.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.simpleExec(ctx, stmtListen); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		c.close()
+		return nil, net.ErrClosed
+	}
+	b.listeners[c] = struct{}{}
+	b.mu.Unlock()
+
+	ch := make(chan registry.Event, watchBuffer)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.netConn.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer close(ch)
+		defer close(done)
+		defer func() {
+			b.mu.Lock()
+			delete(b.listeners, c)
+			b.mu.Unlock()
+			c.close()
+		}()
+
+		for {
+			n, err := c.nextNotification()
+			if err != nil {
+				return
+			}
+			if n.Channel != notifyChannel {
+				continue
+			}
+
+			ev, ok := decodeEvent(n.Payload)
+			if !ok {
+				continue
+			}
+			select {
+			case ch <- ev:
+			default:
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func decodeEvent(data string) (registry.Event, bool) {
+	var p payload
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return registry.Event{}, false
+	}
+
+	ev := registry.Event{Type: p.Type}
+	switch p.Type {
+	case registry.EventRelayAdded, registry.EventRelayHeartbeat:
+		ev.Relay = registry.Relay{
+			ID:       p.RelayID,
+			Address:  p.Address,
+			GRPCPort: p.GRPCPort,
+			LastSeen: time.Unix(0, p.LastSeenNs),
+		}
+	case registry.EventRelayRemoved:
+		ev.Relay = registry.Relay{ID: p.RelayID}
+	case registry.EventPlacementChanged:
+		ev.Placement = registry.AgentPlacement{
+			AgentID:   p.AgentID,
+			RelayID:   p.RelayID,
+			UpdatedAt: time.Unix(0, p.UpdatedAtNs),
+		}
+	case registry.EventPlacementRemoved:
+		ev.Placement = registry.AgentPlacement{AgentID: p.AgentID}
+	default:
+		return registry.Event{}, false
+	}
+	return ev, true
+}
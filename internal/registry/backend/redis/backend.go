@@ -4,48 +4,47 @@ package redis
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/clienttls"
 )
 
 type Backend struct {
 	cfg *registry.RedisConfig
+	ttl atomic.Pointer[registry.TTLConfig]
+	tls *tls.Config
+
+	keys keyspace
 
 	do      func(ctx context.Context, args ...string) (any, error)
 	doMulti func(ctx context.Context, cmds [][]string) ([]any, error)
 
-	mu     sync.Mutex
-	conn   net.Conn
-	reader *bufio.Reader
-}
-
-const (
-	relaysSetKey = "registry:relays"
-	agentsSetKey = "registry:agents"
-)
-
-func relayKey(relayID string) string {
-	return "registry:relay:" + relayID
-}
-
-func agentKey(agentID string) string {
-	return "registry:agent:" + agentID
-}
-
-func placementKey(agentID string) string {
-	return "registry:placement:" + agentID
+	node     *node
+	sentinel *sentinel
+	cluster  *cluster
 }
 
-func New(cfg *registry.RedisConfig) (*Backend, error) {
+// dialTimeout bounds establishing and authenticating a new connection.
+const dialTimeout = 5 * time.Second
+
+// New returns a Redis backend. Relay keys are written with a PX expiry of
+// ttl.Relay and agent and placement keys with ttl.Agent, so Redis drops
+// dead state on its own even when no registry replica is running. A zero
+// TTL leaves the corresponding keys without expiry.
+//
+// Keys are prefixed with cfg.Namespace when set. In cluster mode every key
+// of a namespace shares one hash tag, and so one slot, so the layout
+// differs from standalone and Sentinel deployments.
+func New(cfg *registry.RedisConfig, ttl registry.TTLConfig) (*Backend, error) {
 	if cfg == nil {
 		return nil, registry.ErrRedisConfigNil
 	}
@@ -53,9 +52,29 @@ func New(cfg *registry.RedisConfig) (*Backend, error) {
 		return nil, err
 	}
 
-	b := &Backend{cfg: cfg}
-	b.do = b.exec
-	b.doMulti = b.execMulti
+	tlsCfg, err := clienttls.Load("redis", cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := len(cfg.Cluster.Addrs) > 0
+	b := &Backend{cfg: cfg, tls: tlsCfg, keys: newKeyspace(cfg.Namespace, cluster)}
+	b.ttl.Store(&ttl)
+	if cluster {
+		b.cluster = newCluster(cfg.Cluster.Addrs, func(addr string) *node {
+			return newNode(b.dialNode(addr), cfg)
+		})
+		b.do = b.cluster.do
+		b.doMulti = b.cluster.doMulti
+		return b, nil
+	}
+
+	b.node = newNode(b.dial, cfg)
+	if cfg.Sentinel.MasterName != "" {
+		b.sentinel = newSentinel(cfg.Sentinel, tlsCfg, b.node.pool.reset)
+	}
+	b.do = b.node.do
+	b.doMulti = b.node.execMulti
 	return b, nil
 }
 
@@ -77,8 +96,8 @@ func (b *Backend) RegisterRelay(ctx context.Context, relay registry.Relay) error
 	}
 
 	_, err = b.doMulti(ctx, [][]string{
-		{"SET", relayKey(relay.ID), string(payload)},
-		{"SADD", relaysSetKey, relay.ID},
+		setCmd(b.keys.relayKey(relay.ID), string(payload), b.ttl.Load().Relay),
+		{"SADD", b.keys.relaysSetKey(), relay.ID},
 	})
 	return err
 }
@@ -94,12 +113,14 @@ func (b *Backend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Ti
 		ts = time.Now()
 	}
 
-	relay, err := b.getRelay(ctx, relayID)
+	res, err := b.evalScript(ctx, heartbeatRelayScript,
+		[]string{b.keys.relayKey(relayID), b.keys.relaysSetKey()},
+		timeArg(ts), pxArg(b.ttl.Load().Relay), relayID,
+	)
 	if err != nil {
 		return err
 	}
-	relay.LastSeen = ts
-	return b.RegisterRelay(ctx, relay)
+	return scriptResult(res, registry.ErrRelayNotRegistered)
 }
 
 func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
@@ -107,7 +128,7 @@ func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
 		return nil, err
 	}
 
-	idsRaw, err := b.do(ctx, "SMEMBERS", relaysSetKey)
+	idsRaw, err := b.do(ctx, "SMEMBERS", b.keys.relaysSetKey())
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +142,7 @@ func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
 
 	keys := make([]string, len(ids))
 	for i, id := range ids {
-		keys[i] = relayKey(id)
+		keys[i] = b.keys.relayKey(id)
 	}
 
 	args := append([]string{"MGET"}, keys...)
@@ -150,9 +171,7 @@ func (b *Backend) ListRelays(ctx context.Context) ([]registry.Relay, error) {
 	}
 
 	// Clean up stale set members whose keys no longer exist.
-	for _, id := range staleIDs {
-		_, _ = b.do(ctx, "SREM", relaysSetKey, id)
-	}
+	b.pruneSet(ctx, b.keys.relaysSetKey(), staleIDs, b.keys.relayKey)
 
 	return relays, nil
 }
@@ -165,7 +184,7 @@ func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
 		return registry.ErrRelayIDEmpty
 	}
 
-	existsRaw, err := b.do(ctx, "EXISTS", relayKey(relayID))
+	existsRaw, err := b.do(ctx, "EXISTS", b.keys.relayKey(relayID))
 	if err != nil {
 		return err
 	}
@@ -178,8 +197,8 @@ func (b *Backend) RemoveRelay(ctx context.Context, relayID string) error {
 	}
 
 	_, err = b.doMulti(ctx, [][]string{
-		{"DEL", relayKey(relayID)},
-		{"SREM", relaysSetKey, relayID},
+		{"DEL", b.keys.relayKey(relayID)},
+		{"SREM", b.keys.relaysSetKey(), relayID},
 	})
 
 	// Persistence-only responsibility: remove relay record and relay index membership.
@@ -198,9 +217,6 @@ func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relay
 		return registry.ErrAgentIDEmpty
 	}
 
-	if _, err := b.getRelay(ctx, relayID); err != nil {
-		return err
-	}
 	if agent.LastHeartbeat.IsZero() {
 		agent.LastHeartbeat = time.Now()
 	}
@@ -214,12 +230,16 @@ func (b *Backend) RegisterAgent(ctx context.Context, agent registry.Agent, relay
 		return err
 	}
 
-	_, err = b.doMulti(ctx, [][]string{
-		{"SET", agentKey(agent.ID), string(agentPayload)},
-		{"SET", placementKey(agent.ID), string(placementPayload)},
-		{"SADD", agentsSetKey, agent.ID},
-	})
-	return err
+	// The relay check and the writes run as one script so a concurrent
+	// RemoveRelay cannot leave an agent placed on a deleted relay.
+	res, err := b.evalScript(ctx, registerAgentScript,
+		[]string{b.keys.relayKey(relayID), b.keys.agentKey(agent.ID), b.keys.placementKey(agent.ID), b.keys.agentsSetKey()},
+		agent.ID, string(agentPayload), string(placementPayload), pxArg(b.ttl.Load().Agent),
+	)
+	if err != nil {
+		return err
+	}
+	return scriptResult(res, registry.ErrRelayNotRegistered)
 }
 
 func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error {
@@ -230,34 +250,18 @@ func (b *Backend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Ti
 		return registry.ErrAgentIDEmpty
 	}
 
-	agent, err := b.getAgent(ctx, agentID)
-	if err != nil {
-		return err
-	}
 	if ts.IsZero() {
 		ts = time.Now()
 	}
-	agent.LastHeartbeat = ts
-	agentPayload, err := json.Marshal(agent)
-	if err != nil {
-		return err
-	}
 
-	placement, err := b.GetAgentPlacement(ctx, agentID)
+	res, err := b.evalScript(ctx, heartbeatAgentScript,
+		[]string{b.keys.agentKey(agentID), b.keys.placementKey(agentID), b.keys.agentsSetKey()},
+		timeArg(ts), pxArg(b.ttl.Load().Agent), agentID,
+	)
 	if err != nil {
 		return err
 	}
-	placement.UpdatedAt = ts
-	placementPayload, err := json.Marshal(placement)
-	if err != nil {
-		return err
-	}
-
-	_, err = b.doMulti(ctx, [][]string{
-		{"SET", agentKey(agentID), string(agentPayload)},
-		{"SET", placementKey(agentID), string(placementPayload)},
-	})
-	return err
+	return scriptResult(res, registry.ErrAgentNotRegistered)
 }
 
 func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*registry.AgentPlacement, error) {
@@ -268,7 +272,7 @@ func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*regis
 		return nil, registry.ErrAgentIDEmpty
 	}
 
-	raw, err := b.do(ctx, "GET", placementKey(agentID))
+	raw, err := b.do(ctx, "GET", b.keys.placementKey(agentID))
 	if err != nil {
 		return nil, err
 	}
@@ -284,187 +288,255 @@ func (b *Backend) GetAgentPlacement(ctx context.Context, agentID string) (*regis
 	return &placement, nil
 }
 
-func (b *Backend) getRelay(ctx context.Context, relayID string) (registry.Relay, error) {
-	raw, err := b.do(ctx, "GET", relayKey(relayID))
+func (b *Backend) ListPlacements(ctx context.Context) ([]registry.AgentPlacement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	idsRaw, err := b.do(ctx, "SMEMBERS", b.keys.agentsSetKey())
+	if err != nil {
+		return nil, err
+	}
+	ids, err := asStringSlice(idsRaw)
 	if err != nil {
-		return registry.Relay{}, err
+		return nil, err
 	}
-	relayBytes, ok := raw.([]byte)
-	if !ok || relayBytes == nil {
-		return registry.Relay{}, registry.ErrRelayNotRegistered
+	if len(ids) == 0 {
+		return nil, nil
 	}
 
-	var relay registry.Relay
-	if err := json.Unmarshal(relayBytes, &relay); err != nil {
-		return registry.Relay{}, err
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = b.keys.placementKey(id)
 	}
-	return relay, nil
-}
 
-func (b *Backend) getAgent(ctx context.Context, agentID string) (registry.Agent, error) {
-	raw, err := b.do(ctx, "GET", agentKey(agentID))
+	args := append([]string{"MGET"}, keys...)
+	raw, err := b.do(ctx, args...)
 	if err != nil {
-		return registry.Agent{}, err
+		return nil, err
 	}
-	agentBytes, ok := raw.([]byte)
-	if !ok || agentBytes == nil {
-		return registry.Agent{}, registry.ErrAgentNotRegistered
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected MGET response type: %T", raw)
 	}
 
-	var agent registry.Agent
-	if err := json.Unmarshal(agentBytes, &agent); err != nil {
-		return registry.Agent{}, err
+	placements := make([]registry.AgentPlacement, 0, len(ids))
+	var staleIDs []string
+	for i, item := range items {
+		b, ok := item.([]byte)
+		if !ok || b == nil {
+			staleIDs = append(staleIDs, ids[i])
+			continue
+		}
+		var placement registry.AgentPlacement
+		if err := json.Unmarshal(b, &placement); err != nil {
+			return nil, err
+		}
+		placements = append(placements, placement)
 	}
-	return agent, nil
+
+	// Clean up stale set members whose keys have expired.
+	b.pruneSet(ctx, b.keys.agentsSetKey(), staleIDs, b.keys.placementKey)
+
+	return placements, nil
 }
 
-func (b *Backend) Close(ctx context.Context) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	if b.conn != nil {
-		err := b.conn.Close()
-		b.conn = nil
-		b.reader = nil
+func (b *Backend) RemoveAgent(ctx context.Context, agentID string) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	return nil
-}
-
-// ensureConn establishes and authenticates a persistent connection if one
-// does not already exist. Must be called with b.mu held.
-func (b *Backend) ensureConn(ctx context.Context) error {
-	if b.conn != nil {
-		return nil
+	if agentID == "" {
+		return registry.ErrAgentIDEmpty
 	}
 
-	d := net.Dialer{}
-	conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", b.cfg.Address, b.cfg.Port))
+	existsRaw, err := b.do(ctx, "EXISTS", b.keys.agentKey(agentID))
 	if err != nil {
 		return err
 	}
-
-	if deadline, ok := ctx.Deadline(); ok {
-		_ = conn.SetDeadline(deadline)
+	exists, err := asInt(existsRaw)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return registry.ErrAgentNotRegistered
 	}
 
-	reader := bufio.NewReader(conn)
+	_, err = b.doMulti(ctx, [][]string{
+		{"DEL", b.keys.agentKey(agentID), b.keys.placementKey(agentID)},
+		{"SREM", b.keys.agentsSetKey(), agentID},
+	})
+	return err
+}
 
-	if b.cfg.Password != "" {
-		authArgs := []string{"AUTH", b.cfg.Password}
-		if b.cfg.Username != "" {
-			authArgs = []string{"AUTH", b.cfg.Username, b.cfg.Password}
-		}
-		if _, err := writeRESP(conn, authArgs...); err != nil {
-			conn.Close()
-			return err
-		}
-		if _, err := readRESP(reader); err != nil {
-			conn.Close()
-			return err
-		}
+// pruneSet removes ids from setKey if their record, named by recordKey,
+// still does not exist. It is best effort: a member left behind is pruned
+// by the next list.
+func (b *Backend) pruneSet(ctx context.Context, setKey string, ids []string, recordKey func(string) string) {
+	if len(ids) == 0 {
+		return
 	}
-	if b.cfg.DB > 0 {
-		if _, err := writeRESP(conn, "SELECT", strconv.Itoa(b.cfg.DB)); err != nil {
-			conn.Close()
-			return err
-		}
-		if _, err := readRESP(reader); err != nil {
-			conn.Close()
-			return err
-		}
+
+	keys := make([]string, 0, 1+len(ids))
+	keys = append(keys, setKey)
+	for _, id := range ids {
+		keys = append(keys, recordKey(id))
 	}
+	_, _ = b.evalScript(ctx, pruneSetScript, keys, ids...)
+}
 
-	b.conn = conn
-	b.reader = reader
-	return nil
+// setCmd builds a SET that expires after ttl. The expiry is measured from
+// the write, so it never fires before the registry's own TTL check, which
+// is measured from the record's heartbeat timestamp.
+func setCmd(key, value string, ttl time.Duration) []string {
+	if ttl <= 0 {
+		return []string{"SET", key, value}
+	}
+	return []string{"SET", key, value, "PX", pxArg(ttl)}
 }
 
-// closeConn tears down the persistent connection. Must be called with b.mu held.
-func (b *Backend) closeConn() {
-	if b.conn != nil {
-		b.conn.Close()
-		b.conn = nil
-		b.reader = nil
+// scriptResult maps a script's 0/1 reply to notFound or success.
+func scriptResult(res any, notFound error) error {
+	ok, err := asInt(res)
+	if err != nil {
+		return err
 	}
+	if ok == 0 {
+		return notFound
+	}
+	return nil
 }
 
-// exec sends a single command over the persistent connection.
-func (b *Backend) exec(ctx context.Context, args ...string) (any, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// Watch implements registry.Watcher. Keyspace notifications are disabled by
+// default on most deployments and are not replayed after a reconnect, so the
+// backend offers no change stream and the registry polls it instead.
+func (b *Backend) Watch(context.Context) (<-chan registry.Event, error) {
+	return nil, registry.ErrWatchUnsupported
+}
 
-	if err := b.ensureConn(ctx); err != nil {
-		return nil, err
+// SetTTL replaces the expiry given to keys written from now on. Heartbeats
+// rewrite a key's expiry, so live records pick up the new TTLs on their
+// next heartbeat.
+func (b *Backend) SetTTL(ttl registry.TTLConfig) error {
+	b.ttl.Store(&ttl)
+	return nil
+}
+
+func (b *Backend) Close(ctx context.Context) error {
+	if b.sentinel != nil {
+		b.sentinel.close()
+	}
+	if b.cluster != nil {
+		b.cluster.close()
 	}
+	if b.node != nil {
+		b.node.close()
+	}
+	return nil
+}
+
+// dial establishes and authenticates a new connection to the primary. In
+// Sentinel mode a failed dial re-resolves the primary once, so a failover
+// that was missed on +switch-master is picked up transparently.
+func (b *Backend) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
 
-	if deadline, ok := ctx.Deadline(); ok {
-		_ = b.conn.SetDeadline(deadline)
+	if b.sentinel == nil {
+		return b.dialAddr(ctx, net.JoinHostPort(b.cfg.Address, strconv.Itoa(b.cfg.Port)))
 	}
 
-	if _, err := writeRESP(b.conn, args...); err != nil {
-		b.closeConn()
+	addr, err := b.sentinel.masterAddr(ctx)
+	if err != nil {
 		return nil, err
 	}
-	res, err := readRESP(b.reader)
-	if err != nil {
-		b.closeConn()
+	c, err := b.dialAddr(ctx, addr)
+	if err == nil {
+		return c, nil
+	}
+
+	b.sentinel.forget(addr)
+	next, resolveErr := b.sentinel.masterAddr(ctx)
+	if resolveErr != nil || next == addr {
 		return nil, err
 	}
-	return res, nil
+	return b.dialAddr(ctx, next)
 }
 
-// execMulti wraps multiple commands in a MULTI/EXEC transaction for atomicity.
-func (b *Backend) execMulti(ctx context.Context, cmds [][]string) ([]any, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// dialNode returns a dial function for one cluster node.
+func (b *Backend) dialNode(addr string) func(ctx context.Context) (*conn, error) {
+	return func(ctx context.Context) (*conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+		defer cancel()
+		return b.dialAddr(ctx, addr)
+	}
+}
 
-	if err := b.ensureConn(ctx); err != nil {
+// dialAddr connects to addr and runs AUTH and SELECT. With TLS enabled the
+// handshake completes before AUTH is sent. In Sentinel mode the server
+// must also report the master role.
+func (b *Backend) dialAddr(ctx context.Context, addr string) (*conn, error) {
+	netConn, err := dialNet(ctx, addr, b.tls)
+	if err != nil {
 		return nil, err
 	}
+	c := newConn(netConn)
 
-	if deadline, ok := ctx.Deadline(); ok {
-		_ = b.conn.SetDeadline(deadline)
+	var setup [][]string
+	if b.cfg.Password != "" {
+		setup = append(setup, authCmd(b.cfg.Username, b.cfg.Password))
+	}
+	if b.cfg.DB > 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(b.cfg.DB)})
+	}
+	if b.sentinel != nil {
+		setup = append(setup, []string{"ROLE"})
+	}
+	if len(setup) == 0 {
+		return c, nil
 	}
 
-	// MULTI
-	if _, err := writeRESP(b.conn, "MULTI"); err != nil {
-		b.closeConn()
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, setup)
+	if err != nil {
+		c.close()
 		return nil, err
 	}
-	if _, err := readRESP(b.reader); err != nil {
-		b.closeConn()
-		return nil, err
+	for _, reply := range replies {
+		if replyErr, ok := reply.(redisError); ok {
+			c.close()
+			return nil, replyErr
+		}
 	}
 
-	// Queue each command
-	for _, cmd := range cmds {
-		if _, err := writeRESP(b.conn, cmd...); err != nil {
-			b.closeConn()
-			return nil, err
-		}
-		// Read +QUEUED
-		if _, err := readRESP(b.reader); err != nil {
-			b.closeConn()
-			return nil, err
-		}
+	if b.sentinel != nil && !isMasterRole(replies[len(replies)-1]) {
+		c.close()
+		return nil, fmt.Errorf("redis %s is not the master", addr)
 	}
+	return c, nil
+}
 
-	// EXEC
-	if _, err := writeRESP(b.conn, "EXEC"); err != nil {
-		b.closeConn()
-		return nil, err
+// isMasterRole reports whether a ROLE reply describes a master.
+func isMasterRole(reply any) bool {
+	items, ok := reply.([]any)
+	if !ok || len(items) == 0 {
+		return false
 	}
-	execResult, err := readRESP(b.reader)
-	if err != nil {
-		b.closeConn()
-		return nil, err
+	role, ok := items[0].([]byte)
+	return ok && string(role) == "master"
+}
+
+func dialNet(ctx context.Context, addr string, tlsCfg *tls.Config) (net.Conn, error) {
+	if tlsCfg != nil {
+		return (&tls.Dialer{Config: tlsCfg}).DialContext(ctx, "tcp", addr)
 	}
+	return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+}
 
-	results, ok := execResult.([]any)
-	if !ok {
-		return nil, fmt.Errorf("unexpected EXEC response type: %T", execResult)
+func authCmd(username, password string) []string {
+	if username != "" {
+		return []string{"AUTH", username, password}
 	}
-	return results, nil
+	return []string{"AUTH", password}
 }
 
 func writeRESP(w io.Writer, args ...string) (int, error) {
@@ -500,7 +572,7 @@ func readRESP(r *bufio.Reader) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New(line)
+		return nil, redisError(line)
 	case ':':
 		line, err := readLine(r)
 		if err != nil {
@@ -543,6 +615,12 @@ func readRESP(r *bufio.Reader) (any, error) {
 		out := make([]any, n)
 		for i := 0; i < n; i++ {
 			v, err := readRESP(r)
+			if replyErr, ok := err.(redisError); ok {
+				// Keep reading so the connection stays in sync; EXEC
+				// reports per-command failures this way.
+				out[i] = replyErr
+				continue
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -2,139 +2,513 @@ package redis
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
 )
 
 var _ registry.Backend = (*Backend)(nil)
 
 func TestNewRequiresValidConfig(t *testing.T) {
-	if _, err := New(nil); !errors.Is(err, registry.ErrRedisConfigNil) {
+	if _, err := New(nil, registry.TTLConfig{}); !errors.Is(err, registry.ErrRedisConfigNil) {
 		t.Fatalf("expected ErrRedisConfigNil, got %v", err)
 	}
 
-	if _, err := New(&registry.RedisConfig{}); !errors.Is(err, registry.ErrRedisAddrEmpty) {
+	if _, err := New(&registry.RedisConfig{}, registry.TTLConfig{}); !errors.Is(err, registry.ErrRedisAddrEmpty) {
 		t.Fatalf("expected ErrRedisAddrEmpty, got %v", err)
 	}
 }
 
-func TestRelayAndAgentLifecycle(t *testing.T) {
-	b := newTestBackend()
+func TestConformance(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		return newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+	})
+}
+
+func TestKeysExpireWithTTL(t *testing.T) {
+	clock := backendtest.NewClock()
+	b := newTestBackend(registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second}, clock.Now)
 	ctx := context.Background()
-	now := time.Now()
 
-	relay := registry.Relay{ID: "relay-1", Address: "127.0.0.1", GRPCPort: 50051, LastSeen: now}
-	if err := b.RegisterRelay(ctx, relay); err != nil {
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
 		t.Fatalf("register relay: %v", err)
 	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	assertPTTL(t, b, b.keys.relayKey("relay-1"), 10*time.Second)
+	assertPTTL(t, b, b.keys.agentKey("agent-1"), 20*time.Second)
+	assertPTTL(t, b, b.keys.placementKey("agent-1"), 20*time.Second)
+
+	// Heartbeats push the expiry out again.
+	clock.Advance(8 * time.Second)
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+	assertPTTL(t, b, b.keys.relayKey("relay-1"), 10*time.Second)
+	assertPTTL(t, b, b.keys.agentKey("agent-1"), 20*time.Second)
 
+	clock.Advance(11 * time.Second)
 	relays, err := b.ListRelays(ctx)
 	if err != nil {
 		t.Fatalf("list relays: %v", err)
 	}
-	if len(relays) != 1 || relays[0].ID != relay.ID {
-		t.Fatalf("unexpected relays: %#v", relays)
+	if len(relays) != 0 {
+		t.Fatalf("expected expired relay to be gone, got %+v", relays)
 	}
+	assertSetMembers(t, b, b.keys.relaysSetKey())
 
-	hb := now.Add(5 * time.Second)
-	if err := b.HeartbeatRelay(ctx, relay.ID, hb); err != nil {
-		t.Fatalf("heartbeat relay: %v", err)
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("expected placement to outlive relay key, got %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	placements, err := b.ListPlacements(ctx)
+	if err != nil {
+		t.Fatalf("list placements: %v", err)
+	}
+	if len(placements) != 0 {
+		t.Fatalf("expected expired placement to be gone, got %+v", placements)
 	}
+	assertSetMembers(t, b, b.keys.agentsSetKey())
+}
 
-	agent := registry.Agent{ID: "agent-1", LastHeartbeat: now}
-	if err := b.RegisterAgent(ctx, agent, relay.ID); err != nil {
+func TestSetTTLAppliesOnHeartbeat(t *testing.T) {
+	clock := backendtest.NewClock()
+	b := newTestBackend(registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second}, clock.Now)
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
 		t.Fatalf("register agent: %v", err)
 	}
 
-	if err := b.HeartbeatAgent(ctx, agent.ID, hb); err != nil {
+	if err := b.SetTTL(registry.TTLConfig{Relay: time.Minute, Agent: 2 * time.Minute}); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if err := b.HeartbeatRelay(ctx, "relay-1", clock.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", clock.Now()); err != nil {
 		t.Fatalf("heartbeat agent: %v", err)
 	}
+	assertPTTL(t, b, b.keys.relayKey("relay-1"), time.Minute)
+	assertPTTL(t, b, b.keys.agentKey("agent-1"), 2*time.Minute)
+	assertPTTL(t, b, b.keys.placementKey("agent-1"), 2*time.Minute)
+}
 
-	placement, err := b.GetAgentPlacement(ctx, agent.ID)
+func TestZeroTTLDisablesExpiry(t *testing.T) {
+	b := newTestBackend(registry.TTLConfig{}, time.Now)
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// PTTL reports -1 for keys that exist without an expiry.
+	raw, err := b.do(ctx, "PTTL", b.keys.relayKey("relay-1"))
 	if err != nil {
-		t.Fatalf("get placement: %v", err)
+		t.Fatalf("pttl: %v", err)
 	}
-	if placement.RelayID != relay.ID {
-		t.Fatalf("unexpected placement relay id: %s", placement.RelayID)
+	if got, _ := asInt(raw); got != -1 {
+		t.Fatalf("expected no expiry, got pttl %d", got)
 	}
+}
 
-	if err := b.RemoveRelay(ctx, relay.ID); err != nil {
-		t.Fatalf("remove relay: %v", err)
+func TestScriptsLoadOnNoScript(t *testing.T) {
+	b := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var cmds []string
+	do := b.do
+	b.do = func(ctx context.Context, args ...string) (any, error) {
+		mu.Lock()
+		cmds = append(cmds, strings.Join(args[:min(2, len(args))], " "))
+		mu.Unlock()
+		return do(ctx, args...)
+	}
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	for range 2 {
+		if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+			t.Fatalf("heartbeat relay: %v", err)
+		}
 	}
 
-	// Backend remains persistence-only: removing a relay does not cascade into agent cleanup.
-	if _, err := b.GetAgentPlacement(ctx, agent.ID); err != nil {
-		t.Fatalf("expected placement to remain persisted, got %v", err)
+	want := []string{
+		"EVALSHA " + heartbeatRelayScript.sha,
+		"SCRIPT LOAD",
+		"EVALSHA " + heartbeatRelayScript.sha,
+		"EVALSHA " + heartbeatRelayScript.sha,
+	}
+	if !slices.Equal(cmds, want) {
+		t.Fatalf("expected commands %v, got %v", want, cmds)
 	}
 }
 
-func TestValidationErrorsAndContext(t *testing.T) {
-	b := newTestBackend()
+func TestScriptsDoNotResurrectRemovedRecords(t *testing.T) {
+	b := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
 	ctx := context.Background()
 
-	if err := b.RegisterRelay(ctx, registry.Relay{}); !errors.Is(err, registry.ErrRelayIDEmpty) {
-		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+	if err := b.RemoveAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("remove agent: %v", err)
+	}
+
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", time.Now()); !errors.Is(err, registry.ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-2"}, "relay-1"); !errors.Is(err, registry.ErrRelayNotRegistered) {
+		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
 	}
 
-	if err := b.RegisterAgent(ctx, registry.Agent{}, "relay-1"); !errors.Is(err, registry.ErrAgentIDEmpty) {
-		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	for _, key := range []string{b.keys.relayKey("relay-1"), b.keys.agentKey("agent-1"), b.keys.placementKey("agent-1"), b.keys.agentKey("agent-2")} {
+		raw, err := b.do(ctx, "EXISTS", key)
+		if err != nil {
+			t.Fatalf("exists %s: %v", key, err)
+		}
+		if n, _ := asInt(raw); n != 0 {
+			t.Fatalf("expected %s to stay deleted", key)
+		}
 	}
+	assertSetMembers(t, b, b.keys.agentsSetKey())
+}
+
+func TestListPruneKeepsReregisteredMembers(t *testing.T) {
+	relay := registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051}
+	agent := registry.Agent{ID: "agent-1"}
 
-	if err := b.HeartbeatAgent(ctx, "", time.Now()); !errors.Is(err, registry.ErrAgentIDEmpty) {
-		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	tests := []struct {
+		name       string
+		expire     func(b *Backend) string
+		reregister func(ctx context.Context, b *Backend) error
+		list       func(ctx context.Context, b *Backend) error
+		set        func(b *Backend) string
+		want       []string
+	}{
+		{
+			name:   "relays",
+			expire: func(b *Backend) string { return b.keys.relayKey(relay.ID) },
+			reregister: func(ctx context.Context, b *Backend) error {
+				return b.RegisterRelay(ctx, relay)
+			},
+			list: func(ctx context.Context, b *Backend) error {
+				_, err := b.ListRelays(ctx)
+				return err
+			},
+			set:  func(b *Backend) string { return b.keys.relaysSetKey() },
+			want: []string{relay.ID},
+		},
+		{
+			name:   "placements",
+			expire: func(b *Backend) string { return b.keys.placementKey(agent.ID) },
+			reregister: func(ctx context.Context, b *Backend) error {
+				return b.RegisterAgent(ctx, agent, relay.ID)
+			},
+			list: func(ctx context.Context, b *Backend) error {
+				_, err := b.ListPlacements(ctx)
+				return err
+			},
+			set:  func(b *Backend) string { return b.keys.agentsSetKey() },
+			want: []string{agent.ID},
+		},
 	}
 
-	canceled, cancel := context.WithCancel(context.Background())
-	cancel()
-	if err := b.HeartbeatRelay(canceled, "relay-1", time.Now()); !errors.Is(err, context.Canceled) {
-		t.Fatalf("expected context canceled, got %v", err)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+			ctx := context.Background()
+
+			if err := b.RegisterRelay(ctx, relay); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			if err := b.RegisterAgent(ctx, agent, relay.ID); err != nil {
+				t.Fatalf("register agent: %v", err)
+			}
+			if _, err := b.do(ctx, "DEL", test.expire(b)); err != nil {
+				t.Fatalf("expire record: %v", err)
+			}
+
+			// Re-register right after the list reads the missing record and
+			// before it prunes the set member.
+			do := b.do
+			interleaved := false
+			b.do = func(ctx context.Context, args ...string) (any, error) {
+				res, err := do(ctx, args...)
+				if args[0] == "MGET" && !interleaved {
+					interleaved = true
+					if err := test.reregister(ctx, b); err != nil {
+						t.Fatalf("re-register: %v", err)
+					}
+				}
+				return res, err
+			}
+
+			if err := test.list(ctx, b); err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if !interleaved {
+				t.Fatalf("expected the list to read records with MGET")
+			}
+			assertSetMembers(t, b, test.set(b), test.want...)
+		})
 	}
 }
 
-func TestNotRegisteredErrors(t *testing.T) {
-	b := newTestBackend()
+func TestHeartbeatRestoresSetMembers(t *testing.T) {
+	b := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
 	ctx := context.Background()
 
-	if err := b.HeartbeatRelay(ctx, "missing", time.Now()); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	if _, err := b.do(ctx, "SREM", b.keys.relaysSetKey(), "relay-1"); err != nil {
+		t.Fatalf("srem relay: %v", err)
+	}
+	if _, err := b.do(ctx, "SREM", b.keys.agentsSetKey(), "agent-1"); err != nil {
+		t.Fatalf("srem agent: %v", err)
 	}
 
-	if err := b.RemoveRelay(ctx, "missing"); !errors.Is(err, registry.ErrRelayNotRegistered) {
-		t.Fatalf("expected ErrRelayNotRegistered, got %v", err)
+	if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := b.HeartbeatAgent(ctx, "agent-1", time.Now()); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
 	}
+	assertSetMembers(t, b, b.keys.relaysSetKey(), "relay-1")
+	assertSetMembers(t, b, b.keys.agentsSetKey(), "agent-1")
+}
 
-	if _, err := b.GetAgentPlacement(ctx, "missing"); !errors.Is(err, registry.ErrAgentNotRegistered) {
-		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+func TestHeartbeatScriptPreservesRecord(t *testing.T) {
+	b := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+	ctx := context.Background()
+
+	registered := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	relay := registry.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: registered}
+	if err := b.RegisterRelay(ctx, relay); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	heartbeat := registered.Add(1500 * time.Millisecond)
+	if err := b.HeartbeatRelay(ctx, "relay-1", heartbeat); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+
+	relays, err := b.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	relay.LastSeen = heartbeat
+	if len(relays) != 1 || !relays[0].LastSeen.Equal(heartbeat) || relays[0].Address != relay.Address || relays[0].GRPCPort != relay.GRPCPort {
+		t.Fatalf("expected %+v, got %+v", relay, relays)
+	}
+}
+
+func TestSetCmd(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want []string
+	}{
+		{name: "no ttl", ttl: 0, want: []string{"SET", "k", "v"}},
+		{name: "milliseconds", ttl: 1500 * time.Millisecond, want: []string{"SET", "k", "v", "PX", "1500"}},
+		{name: "sub millisecond rounds up", ttl: time.Microsecond, want: []string{"SET", "k", "v", "PX", "1"}},
+	}
+
+	for _, test := range tests {
+		if got := setCmd("k", "v", test.ttl); !slices.Equal(got, test.want) {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func assertPTTL(t *testing.T, b *Backend, key string, want time.Duration) {
+	t.Helper()
+
+	raw, err := b.do(context.Background(), "PTTL", key)
+	if err != nil {
+		t.Fatalf("pttl %s: %v", key, err)
+	}
+	got, err := asInt(raw)
+	if err != nil {
+		t.Fatalf("pttl %s: %v", key, err)
+	}
+	if got != int(want.Milliseconds()) {
+		t.Fatalf("expected %s pttl %d, got %d", key, want.Milliseconds(), got)
+	}
+}
+
+func assertSetMembers(t *testing.T, b *Backend, key string, want ...string) {
+	t.Helper()
+
+	raw, err := b.do(context.Background(), "SMEMBERS", key)
+	if err != nil {
+		t.Fatalf("smembers %s: %v", key, err)
+	}
+	got, err := asStringSlice(raw)
+	if err != nil {
+		t.Fatalf("smembers %s: %v", key, err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %s members %v, got %v", key, want, got)
 	}
 }
 
-func newTestBackend() *Backend {
-	b := &Backend{cfg: &registry.RedisConfig{Address: "fake", Port: 6379}}
-	fake := newFakeRedisDoer()
+func newTestBackend(ttl registry.TTLConfig, now func() time.Time) *Backend {
+	b := &Backend{cfg: &registry.RedisConfig{Address: "fake", Port: 6379}, keys: newKeyspace("", false)}
+	b.ttl.Store(&ttl)
+	fake := newFakeRedisDoer(now)
 	b.do = fake
 	b.doMulti = newFakeRedisMultiDoer(fake)
 	return b
 }
 
-func newFakeRedisDoer() func(ctx context.Context, args ...string) (any, error) {
+// newFakeRedisDoer returns an in-memory stand-in for the subset of Redis
+// commands the backend issues. Key expiry is evaluated lazily against now,
+// and the backend's Lua scripts are emulated in Go behind EVALSHA with the
+// same NOSCRIPT behavior as a real server.
+func newFakeRedisDoer(now func() time.Time) func(ctx context.Context, args ...string) (any, error) {
+	var mu sync.Mutex
 	kv := map[string]string{}
+	expires := map[string]time.Time{}
 	sets := map[string]map[string]struct{}{}
+	loaded := map[string]bool{}
 
-	return func(ctx context.Context, args ...string) (any, error) {
-		if len(args) == 0 {
-			return nil, fmt.Errorf("missing command")
+	var call func(args ...string) (any, error)
+	set := func(key, value, px string) {
+		if px == "0" {
+			_, _ = call("SET", key, value)
+			return
 		}
+		_, _ = call("SET", key, value, "PX", px)
+	}
+	scripts := map[string]func(keys, argv []string) (any, error){
+		heartbeatRelayScript.sha: func(keys, argv []string) (any, error) {
+			raw, ok := kv[keys[0]]
+			if !ok {
+				return 0, nil
+			}
+			set(keys[0], patchJSON(raw, "LastSeen", argv[0]), argv[1])
+			_, _ = call("SADD", keys[1], argv[2])
+			return 1, nil
+		},
+		registerAgentScript.sha: func(keys, argv []string) (any, error) {
+			if _, ok := kv[keys[0]]; !ok {
+				return 0, nil
+			}
+			set(keys[1], argv[1], argv[3])
+			set(keys[2], argv[2], argv[3])
+			_, _ = call("SADD", keys[3], argv[0])
+			return 1, nil
+		},
+		heartbeatAgentScript.sha: func(keys, argv []string) (any, error) {
+			agent, agentOK := kv[keys[0]]
+			placement, placementOK := kv[keys[1]]
+			if !agentOK || !placementOK {
+				return 0, nil
+			}
+			set(keys[0], patchJSON(agent, "LastHeartbeat", argv[0]), argv[1])
+			set(keys[1], patchJSON(placement, "UpdatedAt", argv[0]), argv[1])
+			_, _ = call("SADD", keys[2], argv[2])
+			return 1, nil
+		},
+		pruneSetScript.sha: func(keys, argv []string) (any, error) {
+			removed := 0
+			for i, member := range argv {
+				if _, ok := kv[keys[i+1]]; !ok {
+					n, _ := call("SREM", keys[0], member)
+					removed += n.(int)
+				}
+			}
+			return removed, nil
+		},
+	}
+
+	call = func(args ...string) (any, error) {
 		cmd := args[0]
 		switch cmd {
+		case "SCRIPT":
+			if len(args) != 3 || args[1] != "LOAD" {
+				return nil, fmt.Errorf("unsupported SCRIPT subcommand")
+			}
+			sum := sha1.Sum([]byte(args[2]))
+			sha := hex.EncodeToString(sum[:])
+			loaded[sha] = true
+			return []byte(sha), nil
+		case "EVALSHA":
+			if !loaded[args[1]] {
+				return nil, errors.New("NOSCRIPT No matching script. Please use EVAL.")
+			}
+			run, ok := scripts[args[1]]
+			if !ok {
+				return nil, fmt.Errorf("no emulation for script %s", args[1])
+			}
+			numKeys, err := strconv.Atoi(args[2])
+			if err != nil {
+				return nil, err
+			}
+			return run(args[3:3+numKeys], args[3+numKeys:])
 		case "SET":
+			opts := args[3:]
+			if len(opts) > 0 && opts[0] == "NX" {
+				if _, ok := kv[args[1]]; ok {
+					return nil, nil
+				}
+				opts = opts[1:]
+			}
 			kv[args[1]] = args[2]
+			delete(expires, args[1])
+			if len(opts) == 2 && opts[0] == "PX" {
+				ms, err := strconv.ParseInt(opts[1], 10, 64)
+				if err != nil || ms <= 0 {
+					return nil, fmt.Errorf("ERR invalid expire time in 'set' command")
+				}
+				expires[args[1]] = now().Add(time.Duration(ms) * time.Millisecond)
+			}
 			return "OK", nil
+		case "PTTL":
+			if _, ok := kv[args[1]]; !ok {
+				return -2, nil
+			}
+			deadline, ok := expires[args[1]]
+			if !ok {
+				return -1, nil
+			}
+			return int(deadline.Sub(now()).Milliseconds()), nil
 		case "GET":
 			v, ok := kv[args[1]]
 			if !ok {
@@ -156,8 +530,10 @@ func newFakeRedisDoer() func(ctx context.Context, args ...string) (any, error) {
 			if _, ok := sets[k]; !ok {
 				sets[k] = map[string]struct{}{}
 			}
-			sets[k][args[2]] = struct{}{}
-			return 1, nil
+			for _, member := range args[2:] {
+				sets[k][member] = struct{}{}
+			}
+			return len(args) - 2, nil
 		case "SMEMBERS":
 			k := args[1]
 			members := make([]any, 0, len(sets[k]))
@@ -171,10 +547,16 @@ func newFakeRedisDoer() func(ctx context.Context, args ...string) (any, error) {
 			}
 			return members, nil
 		case "SREM":
+			removed := 0
 			if set, ok := sets[args[1]]; ok {
-				delete(set, args[2])
+				for _, member := range args[2:] {
+					if _, ok := set[member]; ok {
+						delete(set, member)
+						removed++
+					}
+				}
 			}
-			return 1, nil
+			return removed, nil
 		case "EXISTS":
 			if _, ok := kv[args[1]]; ok {
 				return 1, nil
@@ -186,13 +568,67 @@ func newFakeRedisDoer() func(ctx context.Context, args ...string) (any, error) {
 				if _, ok := kv[key]; ok {
 					removed++
 				}
+				if _, ok := sets[key]; ok {
+					removed++
+				}
 				delete(kv, key)
+				delete(expires, key)
+				delete(sets, key)
 			}
 			return removed, nil
+		case "RENAMENX":
+			src, dst := args[1], args[2]
+			v, ok := kv[src]
+			if !ok {
+				return nil, errors.New("ERR no such key")
+			}
+			if _, ok := kv[dst]; ok {
+				return 0, nil
+			}
+			kv[dst] = v
+			delete(kv, src)
+			if deadline, ok := expires[src]; ok {
+				expires[dst] = deadline
+				delete(expires, src)
+			}
+			return 1, nil
 		default:
 			return nil, fmt.Errorf("unsupported command: %s", cmd)
 		}
 	}
+
+	return func(ctx context.Context, args ...string) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(args) == 0 {
+			return nil, fmt.Errorf("missing command")
+		}
+
+		for key, deadline := range expires {
+			if !now().Before(deadline) {
+				delete(kv, key)
+				delete(expires, key)
+			}
+		}
+
+		return call(args...)
+	}
+}
+
+// patchJSON sets a top-level string field on a JSON object, mirroring the
+// cjson edits the Lua scripts perform.
+func patchJSON(raw, field, value string) string {
+	var record map[string]any
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		panic(err)
+	}
+	record[field] = value
+	out, err := json.Marshal(record)
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
 }
 
 func newFakeRedisMultiDoer(do func(ctx context.Context, args ...string) (any, error)) func(ctx context.Context, cmds [][]string) ([]any, error) {
@@ -0,0 +1,362 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxRedirects bounds how many MOVED/ASK redirections a single command
+// follows before giving up.
+const maxRedirects = 5
+
+// cluster routes commands to the node serving their key's hash slot. The
+// slot map is loaded with CLUSTER SLOTS and patched from MOVED replies; it
+// is reloaded in full when a MOVED reply or a connection failure shows it
+// is out of date.
+type cluster struct {
+	seeds   []string
+	newNode func(addr string) *node
+
+	// refreshMu serializes slot map reloads.
+	refreshMu sync.Mutex
+
+	mu     sync.Mutex
+	slots  []string
+	stale  bool
+	nodes  map[string]*node
+	closed bool
+}
+
+func newCluster(seeds []string, newNode func(addr string) *node) *cluster {
+	return &cluster{
+		seeds:   seeds,
+		newNode: newNode,
+		nodes:   make(map[string]*node),
+	}
+}
+
+// do runs a single command on the node owning its key. SCRIPT LOAD is sent
+// to every node so EVALSHA finds the script wherever the slot lives.
+func (c *cluster) do(ctx context.Context, args ...string) (any, error) {
+	if len(args) >= 2 && strings.EqualFold(args[0], "SCRIPT") && strings.EqualFold(args[1], "LOAD") {
+		return c.broadcast(ctx, args)
+	}
+
+	return c.route(ctx, args, func(n *node, asking bool) (any, error) {
+		if !asking {
+			return n.do(ctx, args...)
+		}
+		replies, err := n.roundTrip(ctx, [][]string{{"ASKING"}, args})
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			if replyErr, ok := reply.(redisError); ok {
+				return nil, replyErr
+			}
+		}
+		return replies[1], nil
+	})
+}
+
+// doMulti runs a transaction on the node owning the first command's key.
+// The keyspace's hash tag keeps every key of a transaction in that slot.
+func (c *cluster) doMulti(ctx context.Context, cmds [][]string) ([]any, error) {
+	res, err := c.route(ctx, cmds[0], func(n *node, asking bool) (any, error) {
+		if !asking {
+			return n.execMulti(ctx, cmds)
+		}
+		// The ASKING flag survives until EXEC inside a transaction, so one
+		// ASKING covers every queued command.
+		replies, err := n.roundTrip(ctx, append([][]string{{"ASKING"}}, txCmds(cmds)...))
+		if err != nil {
+			return nil, err
+		}
+		if replyErr, ok := replies[0].(redisError); ok {
+			return nil, replyErr
+		}
+		return execResults(replies[1:])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]any), nil
+}
+
+// route sends a command for args' key with send, following redirections.
+// send is told whether the target expects ASKING first.
+func (c *cluster) route(ctx context.Context, args []string, send func(n *node, asking bool) (any, error)) (any, error) {
+	slot := -1
+	if key, ok := commandKey(args); ok {
+		slot = keySlot(key)
+	}
+
+	addr, err := c.addrFor(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	asking := false
+	for range maxRedirects {
+		n, err := c.node(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := send(n, asking)
+		kind, target, ok := parseRedirect(err, addr)
+		if !ok {
+			var replyErr redisError
+			if err != nil && !errors.As(err, &replyErr) && ctx.Err() == nil {
+				// The node may have failed over; reload the slot map on
+				// the next command.
+				c.markStale()
+			}
+			return res, err
+		}
+
+		if kind == "MOVED" {
+			c.moved(slot, target)
+		}
+		addr, asking = target, kind == "ASK"
+	}
+	return nil, fmt.Errorf("redis cluster: too many redirections for %s", args[0])
+}
+
+// broadcast sends args to every node that serves slots and returns the
+// first reply.
+func (c *cluster) broadcast(ctx context.Context, args []string) (any, error) {
+	if _, err := c.addrFor(ctx, -1); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	addrs := uniqueAddrs(c.slots)
+	c.mu.Unlock()
+
+	var first any
+	for i, addr := range addrs {
+		n, err := c.node(addr)
+		if err != nil {
+			return nil, err
+		}
+		res, err := n.do(ctx, args...)
+		if err != nil {
+			return nil, fmt.Errorf("redis node %s: %w", addr, err)
+		}
+		if i == 0 {
+			first = res
+		}
+	}
+	return first, nil
+}
+
+// addrFor returns the node serving slot, loading the slot map first if it
+// is missing or stale. A negative slot returns any serving node.
+func (c *cluster) addrFor(ctx context.Context, slot int) (string, error) {
+	c.mu.Lock()
+	loaded, stale := c.slots != nil, c.stale
+	c.mu.Unlock()
+
+	if !loaded || stale {
+		// A stale map is still usable; redirections correct it.
+		if err := c.refresh(ctx); err != nil && !loaded {
+			return "", err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slot < 0 {
+		for _, addr := range c.slots {
+			if addr != "" {
+				return addr, nil
+			}
+		}
+		return "", errors.New("redis cluster: no slots are served")
+	}
+	if c.slots[slot] == "" {
+		return "", fmt.Errorf("redis cluster: slot %d is not served", slot)
+	}
+	return c.slots[slot], nil
+}
+
+// refresh reloads the slot map from the first node that answers, trying
+// known nodes before the seeds.
+func (c *cluster) refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.Lock()
+	if c.slots != nil && !c.stale {
+		// Another caller refreshed while we waited.
+		c.mu.Unlock()
+		return nil
+	}
+	candidates := uniqueAddrs(append(append([]string(nil), c.slots...), c.seeds...))
+	c.mu.Unlock()
+
+	var errs []error
+	for _, addr := range candidates {
+		slots, err := c.loadSlots(ctx, addr)
+		if err == nil {
+			c.mu.Lock()
+			c.slots, c.stale = slots, false
+			c.mu.Unlock()
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("redis node %s: %w", addr, err))
+	}
+	return fmt.Errorf("load redis cluster slots: %w", errors.Join(errs...))
+}
+
+func (c *cluster) loadSlots(ctx context.Context, addr string) ([]string, error) {
+	n, err := c.node(addr)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := n.do(ctx, "CLUSTER", "SLOTS")
+	if err != nil {
+		return nil, err
+	}
+	return parseClusterSlots(raw, addr)
+}
+
+// parseClusterSlots builds a slot-to-primary table from a CLUSTER SLOTS
+// reply. Each entry is [start, end, [host, port, ...], replicas...]; an
+// empty host means the node that was asked.
+func parseClusterSlots(raw any, from string) ([]string, error) {
+	entries, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected CLUSTER SLOTS response type: %T", raw)
+	}
+
+	slots := make([]string, numSlots)
+	for _, entry := range entries {
+		fields, ok := entry.([]any)
+		if !ok || len(fields) < 3 {
+			return nil, fmt.Errorf("malformed CLUSTER SLOTS entry: %v", entry)
+		}
+		start, err := asInt(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := asInt(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 || end >= numSlots || start > end {
+			return nil, fmt.Errorf("invalid CLUSTER SLOTS range %d-%d", start, end)
+		}
+
+		primary, ok := fields[2].([]any)
+		if !ok || len(primary) < 2 {
+			return nil, fmt.Errorf("malformed CLUSTER SLOTS node: %v", fields[2])
+		}
+		host, _ := primary[0].([]byte)
+		port, err := asInt(primary[1])
+		if err != nil {
+			return nil, err
+		}
+		addr := nodeAddr(string(host), strconv.Itoa(port), from)
+
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = addr
+		}
+	}
+	return slots, nil
+}
+
+// moved points slot at addr and schedules a full reload, since a MOVED
+// reply usually means more than one slot has changed hands.
+func (c *cluster) moved(slot int, addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slot >= 0 && c.slots != nil {
+		c.slots[slot] = addr
+	}
+	c.stale = true
+}
+
+func (c *cluster) markStale() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stale = true
+}
+
+// node returns the client for addr, creating it on first use.
+func (c *cluster) node(addr string) (*node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, net.ErrClosed
+	}
+	n, ok := c.nodes[addr]
+	if !ok {
+		n = c.newNode(addr)
+		c.nodes[addr] = n
+	}
+	return n, nil
+}
+
+func (c *cluster) close() {
+	c.mu.Lock()
+	c.closed = true
+	nodes := c.nodes
+	c.nodes = nil
+	c.mu.Unlock()
+
+	for _, n := range nodes {
+		n.close()
+	}
+}
+
+// parseRedirect recognizes "MOVED <slot> <addr>" and "ASK <slot> <addr>"
+// error replies. from fills in the host when the server leaves it out.
+func parseRedirect(err error, from string) (kind, addr string, ok bool) {
+	var replyErr redisError
+	if !errors.As(err, &replyErr) {
+		return "", "", false
+	}
+
+	fields := strings.Fields(string(replyErr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", "", false
+	}
+	host, port, splitErr := net.SplitHostPort(fields[2])
+	if splitErr != nil {
+		return "", "", false
+	}
+	return fields[0], nodeAddr(host, port, from), true
+}
+
+func nodeAddr(host, port, from string) string {
+	if host == "" {
+		host, _, _ = net.SplitHostPort(from)
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// uniqueAddrs returns the distinct non-empty addresses in order of first
+// appearance.
+func uniqueAddrs(addrs []string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, addr := range addrs {
+		if _, ok := seen[addr]; ok || addr == "" {
+			continue
+		}
+		seen[addr] = struct{}{}
+		out = append(out, addr)
+	}
+	return out
+}
@@ -0,0 +1,301 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "123456789", want: 12739},
+		{key: "foo", want: 12182},
+		{key: "{user1000}.following", want: keySlot("user1000")},
+		{key: "foo{bar}{zap}", want: keySlot("bar")},
+		{key: "foo{{bar}}zap", want: keySlot("{bar")},
+		// An empty tag is not a tag; the whole key is hashed.
+		{key: "foo{}{bar}", want: int(crc16("foo{}{bar}") % numSlots)},
+	}
+
+	for _, test := range tests {
+		if got := keySlot(test.key); got != test.want {
+			t.Fatalf("keySlot(%q) = %d, want %d", test.key, got, test.want)
+		}
+	}
+}
+
+func TestClusterKeysShareSlot(t *testing.T) {
+	slots := map[int]string{}
+	for _, namespace := range []string{"", "prod", "staging"} {
+		keys := newKeyspace(namespace, true)
+		want := keySlot(keys.relaysSetKey())
+		for _, key := range []string{keys.agentsSetKey(), keys.relayKey("relay-1"), keys.agentKey("agent-1"), keys.placementKey("agent-1")} {
+			if got := keySlot(key); got != want {
+				t.Fatalf("%s hashes to slot %d, want %d", key, got, want)
+			}
+		}
+
+		if other, ok := slots[want]; ok {
+			t.Fatalf("namespaces %q and %q share slot %d", other, namespace, want)
+		}
+		slots[want] = namespace
+	}
+}
+
+func TestConformanceOverCluster(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		fc := newFakeCluster(t, 3)
+		// Seed with a node that does not own the registry's slot, so the
+		// slot map has to be used.
+		return newClusterBackend(t, fc.nodeWithout(homeSlot()).addr())
+	})
+}
+
+func TestClusterFollowsMoved(t *testing.T) {
+	fc := newFakeCluster(t, 3)
+	ctx := context.Background()
+	b := newClusterBackend(t, fc.nodes[0].addr())
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	owner := fc.owner(homeSlot())
+	assertServerHasKey(t, owner, b.keys.relayKey("relay-1"))
+
+	// Reshard the slot; the client only learns about it from MOVED.
+	next := fc.nodeWithout(homeSlot())
+	fc.assign(homeSlot(), next)
+	loads := fc.slotsCalls.Load()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+		t.Fatalf("register relay after reshard: %v", err)
+	}
+	assertServerHasKey(t, next, b.keys.relayKey("relay-2"))
+	if serverHasKey(t, owner, b.keys.relayKey("relay-2")) {
+		t.Fatalf("relay written to the old owner")
+	}
+
+	if _, err := b.ListRelays(ctx); err != nil {
+		t.Fatalf("list relays after reshard: %v", err)
+	}
+	if fc.slotsCalls.Load() == loads {
+		t.Fatalf("expected the slot map to be reloaded after MOVED")
+	}
+}
+
+func TestClusterFollowsAsk(t *testing.T) {
+	fc := newFakeCluster(t, 3)
+	ctx := context.Background()
+	b := newClusterBackend(t, fc.nodes[0].addr())
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	source := fc.owner(homeSlot())
+	target := fc.nodeWithout(homeSlot())
+	fc.migrate(homeSlot(), target)
+
+	// Move one key over, as MIGRATE would.
+	placement := b.keys.placementKey("agent-1")
+	raw, err := source.do(ctx, "GET", placement)
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if _, err := target.do(ctx, "SET", placement, string(raw.([]byte))); err != nil {
+		t.Fatalf("set placement: %v", err)
+	}
+	if _, err := source.do(ctx, "DEL", placement); err != nil {
+		t.Fatalf("del placement: %v", err)
+	}
+
+	// Single command.
+	if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("get migrated placement: %v", err)
+	}
+
+	// Transaction.
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+		t.Fatalf("register relay during migration: %v", err)
+	}
+	assertServerHasKey(t, target, b.keys.relayKey("relay-2"))
+
+	// Script, which also has to be loaded on the importing node.
+	if err := b.RegisterAgent(ctx, registry.Agent{ID: "agent-2"}, "relay-2"); err != nil {
+		t.Fatalf("register agent during migration: %v", err)
+	}
+	assertServerHasKey(t, target, b.keys.placementKey("agent-2"))
+
+	// ASK is a one-off; the slot still belongs to the source.
+	if addr, err := b.cluster.addrFor(ctx, homeSlot()); err != nil || addr != source.addr() {
+		t.Fatalf("expected slot to stay on %s, got %s (%v)", source.addr(), addr, err)
+	}
+}
+
+func TestClusterUnreachableSeeds(t *testing.T) {
+	b := newClusterBackend(t, deadAddr(t), deadAddr(t))
+	if _, err := b.ListRelays(context.Background()); err == nil {
+		t.Fatalf("expected error without a reachable seed")
+	}
+}
+
+func homeSlot() int {
+	return keySlot(newKeyspace("", true).relaysSetKey())
+}
+
+func newClusterBackend(t *testing.T, seeds ...string) *Backend {
+	t.Helper()
+
+	b, err := New(&registry.RedisConfig{
+		Cluster: registry.RedisClusterConfig{Addrs: seeds},
+	}, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
+
+// fakeCluster turns a set of RESP stand-ins into cluster nodes. Each node
+// keeps its own data; commands for slots a node does not serve get MOVED,
+// and commands for missing keys in a migrating slot get ASK.
+type fakeCluster struct {
+	nodes      []*respServer
+	slotsCalls atomic.Int64
+
+	mu        sync.Mutex
+	owners    []*respServer
+	migrating map[int]*respServer
+}
+
+// newFakeCluster starts n nodes and splits the slots evenly between them.
+func newFakeCluster(t *testing.T, n int) *fakeCluster {
+	t.Helper()
+
+	fc := &fakeCluster{
+		owners:    make([]*respServer, numSlots),
+		migrating: make(map[int]*respServer),
+	}
+	for i := range n {
+		srv := newRESPServer(t, "")
+		srv.cluster.Store(fc)
+		fc.nodes = append(fc.nodes, srv)
+		for slot := i * numSlots / n; slot < (i+1)*numSlots/n; slot++ {
+			fc.owners[slot] = srv
+		}
+	}
+	return fc
+}
+
+func (fc *fakeCluster) owner(slot int) *respServer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.owners[slot]
+}
+
+func (fc *fakeCluster) nodeWithout(slot int) *respServer {
+	owner := fc.owner(slot)
+	for _, srv := range fc.nodes {
+		if srv != owner {
+			return srv
+		}
+	}
+	panic("cluster has a single node")
+}
+
+// assign moves slot to srv, finishing any migration.
+func (fc *fakeCluster) assign(slot int, srv *respServer) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.owners[slot] = srv
+	delete(fc.migrating, slot)
+}
+
+// migrate starts moving slot to target.
+func (fc *fakeCluster) migrate(slot int, target *respServer) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.migrating[slot] = target
+}
+
+// redirect returns the MOVED or ASK reply srv sends for args, or nil if
+// srv serves the command itself.
+func (fc *fakeCluster) redirect(srv *respServer, args []string, asking bool) any {
+	key, ok := commandKey(args)
+	if !ok {
+		return nil
+	}
+	slot := keySlot(key)
+
+	if keys := multiKeys(args); len(keys) > 1 {
+		for _, other := range keys[1:] {
+			if keySlot(other) != slot {
+				return redisError("CROSSSLOT Keys in request don't hash to the same slot")
+			}
+		}
+	}
+
+	fc.mu.Lock()
+	owner := fc.owners[slot]
+	target, migrating := fc.migrating[slot]
+	fc.mu.Unlock()
+
+	switch {
+	case owner == srv && migrating && srv.exec([]string{"EXISTS", key}) == 0:
+		return redisError(fmt.Sprintf("ASK %d %s", slot, target.addr()))
+	case owner == srv:
+		return nil
+	case asking && migrating && target == srv:
+		return nil
+	default:
+		return redisError(fmt.Sprintf("MOVED %d %s", slot, owner.addr()))
+	}
+}
+
+// multiKeys returns every key of the multi-key commands the backend sends.
+func multiKeys(args []string) []string {
+	switch strings.ToUpper(args[0]) {
+	case "RENAMENX", "SUNIONSTORE", "DEL", "MGET":
+		return args[1:]
+	default:
+		return nil
+	}
+}
+
+func (fc *fakeCluster) slotsReply() any {
+	fc.slotsCalls.Add(1)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	var entries []any
+	for start := 0; start < numSlots; {
+		end := start
+		for end+1 < numSlots && fc.owners[end+1] == fc.owners[start] {
+			end++
+		}
+		owner := fc.owners[start]
+		entries = append(entries, []any{start, end, []any{[]byte("127.0.0.1"), owner.port(), []byte("node-" + strconv.Itoa(owner.port()))}})
+		start = end + 1
+	}
+	return entries
+}
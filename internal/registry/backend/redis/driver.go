@@ -0,0 +1,24 @@
+package redis
+
+import (
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+func init() {
+	driver.Register(driver.Driver{
+		Name:     registry.RedisRegistryBackend,
+		Validate: validate,
+		New: func(cfg *registry.Config) (registry.Backend, error) {
+			return New(cfg.Backend.Redis, cfg.TTL)
+		},
+	})
+}
+
+func validate(cfg *registry.BackendConfig) error {
+	if cfg.Redis == nil {
+		return registry.ErrRedisConfigNil
+	}
+
+	return cfg.Redis.Validate()
+}
@@ -0,0 +1,109 @@
+package redis
+
+import "strings"
+
+const (
+	defaultKeyPrefix = "registry:"
+
+	// clusterHashTag is the hash tag every key carries in cluster mode.
+	clusterHashTag = "registry"
+)
+
+// keyspace builds the Redis keys used by the backend.
+//
+// In cluster mode every key of a namespace carries the same hash tag and so
+// lives in one slot, on one primary. This is deliberate: registering an
+// agent, heartbeating it and removing a relay run as MULTI/EXEC
+// transactions or Lua scripts over several keys, which Redis Cluster only
+// allows within a slot. Cluster mode therefore lets the registry run
+// against a clustered deployment and follow slot migrations and failovers;
+// it does not spread one registry's load across nodes. The namespace is
+// part of the tag, so separate namespaces land on separate slots.
+type keyspace struct {
+	prefix string
+}
+
+// newKeyspace returns the keyspace for namespace.
+func newKeyspace(namespace string, cluster bool) keyspace {
+	if cluster {
+		tag := clusterHashTag
+		if namespace != "" {
+			tag = namespace + ":" + tag
+		}
+		return keyspace{prefix: "{" + tag + "}:"}
+	}
+
+	prefix := defaultKeyPrefix
+	if namespace != "" {
+		prefix = namespace + ":" + prefix
+	}
+	return keyspace{prefix: prefix}
+}
+
+func (k keyspace) relaysSetKey() string {
+	return k.prefix + "relays"
+}
+
+func (k keyspace) agentsSetKey() string {
+	return k.prefix + "agents"
+}
+
+func (k keyspace) relayKey(relayID string) string {
+	return k.prefix + "relay:" + relayID
+}
+
+func (k keyspace) agentKey(agentID string) string {
+	return k.prefix + "agent:" + agentID
+}
+
+func (k keyspace) placementKey(agentID string) string {
+	return k.prefix + "placement:" + agentID
+}
+
+// numSlots is the number of hash slots in a Redis Cluster.
+const numSlots = 16384
+
+// keySlot returns the cluster hash slot for key, honoring {hash tags}.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % numSlots)
+}
+
+// commandKey returns the first key a command operates on, if any.
+func commandKey(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "EVALSHA", "EVAL":
+		if len(args) < 4 || args[2] == "0" {
+			return "", false
+		}
+		return args[3], true
+	case "SCRIPT", "PING", "CLUSTER", "AUTH", "SELECT", "ROLE", "ASKING", "MULTI", "EXEC":
+		return "", false
+	default:
+		return args[1], true
+	}
+}
+
+// crc16 is the CRC-16/XMODEM checksum Redis Cluster uses for key slots.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,224 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MigrationStats reports what MigrateNamespace moved.
+type MigrationStats struct {
+	// Relays is the number of relay records renamed.
+	Relays int
+
+	// Agents is the number of agent records renamed, together with their
+	// placements.
+	Agents int
+
+	// Skipped is the number of records not moved because the target
+	// namespace already held a record with the same ID. Skipped records
+	// stay in the source namespace and in its index, so a registry using
+	// that namespace still sees them and a later run can move them once the
+	// conflict is resolved.
+	Skipped int
+
+	// Expired is the number of index entries dropped from the source
+	// namespace because their record had already expired.
+	Expired int
+}
+
+// moveResult is the outcome of moving one key between namespaces.
+type moveResult int
+
+const (
+	keyMoved moveResult = iota
+
+	// keyConflict means the target key existed; the source is untouched.
+	keyConflict
+
+	// keyMissing means the source key no longer exists.
+	keyMissing
+)
+
+// MigrateNamespace renames the records written under namespace from into
+// the backend's configured namespace, keeping their remaining TTL. Records
+// already present in the target namespace win over the migrated ones. It
+// is meant to be run once, while no registry is writing to either
+// namespace.
+func (b *Backend) MigrateNamespace(ctx context.Context, from string) (MigrationStats, error) {
+	var stats MigrationStats
+
+	src := newKeyspace(from, b.cluster != nil)
+	if src == b.keys {
+		return stats, errors.New("redis namespace migration: source and target namespaces are the same")
+	}
+
+	relayIDs, err := b.members(ctx, src.relaysSetKey())
+	if err != nil {
+		return stats, err
+	}
+	var movedRelays, expiredRelays []string
+	for _, id := range relayIDs {
+		result, err := b.moveKey(ctx, src.relayKey(id), b.keys.relayKey(id))
+		if err != nil {
+			return stats, err
+		}
+		switch result {
+		case keyMoved:
+			stats.Relays++
+			movedRelays = append(movedRelays, id)
+		case keyConflict:
+			stats.Skipped++
+		case keyMissing:
+			stats.Expired++
+			expiredRelays = append(expiredRelays, id)
+		}
+	}
+
+	agentIDs, err := b.members(ctx, src.agentsSetKey())
+	if err != nil {
+		return stats, err
+	}
+	var movedAgents, expiredAgents []string
+	for _, id := range agentIDs {
+		result, err := b.moveKey(ctx, src.agentKey(id), b.keys.agentKey(id))
+		if err != nil {
+			return stats, err
+		}
+		switch result {
+		case keyConflict:
+			stats.Skipped++
+			continue
+		case keyMissing:
+			stats.Expired++
+			expiredAgents = append(expiredAgents, id)
+			continue
+		}
+
+		placement, err := b.moveKey(ctx, src.placementKey(id), b.keys.placementKey(id))
+		if err != nil {
+			return stats, err
+		}
+		if placement == keyConflict {
+			// The target already places this agent; drop the source copy
+			// rather than leave it unindexed.
+			if _, err := b.do(ctx, "DEL", src.placementKey(id)); err != nil {
+				return stats, err
+			}
+		}
+		stats.Agents++
+		movedAgents = append(movedAgents, id)
+	}
+
+	// Update the indexes last, so they never name a record that has not
+	// been moved yet. Skipped records keep their source index entry.
+	if err := b.moveMembers(ctx, src.relaysSetKey(), b.keys.relaysSetKey(), movedRelays, expiredRelays); err != nil {
+		return stats, err
+	}
+	if err := b.moveMembers(ctx, src.agentsSetKey(), b.keys.agentsSetKey(), movedAgents, expiredAgents); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+func (b *Backend) members(ctx context.Context, setKey string) ([]string, error) {
+	raw, err := b.do(ctx, "SMEMBERS", setKey)
+	if err != nil {
+		return nil, err
+	}
+	return asStringSlice(raw)
+}
+
+// moveKey moves src to dst, keeping its remaining TTL, unless dst exists.
+// In cluster mode the namespaces hash to different slots, which RENAMENX
+// cannot span, so the value is copied and the source deleted instead.
+func (b *Backend) moveKey(ctx context.Context, src, dst string) (moveResult, error) {
+	if b.cluster == nil {
+		return b.renameNX(ctx, src, dst)
+	}
+
+	value, err := b.do(ctx, "GET", src)
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return keyMissing, nil
+	}
+	rawTTL, err := b.do(ctx, "PTTL", src)
+	if err != nil {
+		return 0, err
+	}
+	ttl, err := asInt(rawTTL)
+	if err != nil {
+		return 0, err
+	}
+
+	set := []string{"SET", dst, string(value.([]byte)), "NX"}
+	switch {
+	case ttl == -2:
+		// Expired between GET and PTTL.
+		return keyMissing, nil
+	case ttl > 0:
+		set = append(set, "PX", strconv.Itoa(ttl))
+	}
+
+	reply, err := b.do(ctx, set...)
+	if err != nil {
+		return 0, err
+	}
+	if reply == nil {
+		return keyConflict, nil
+	}
+	if _, err := b.do(ctx, "DEL", src); err != nil {
+		return 0, err
+	}
+	return keyMoved, nil
+}
+
+// moveMembers adds moved to the dst index and removes moved and gone from
+// the src index.
+func (b *Backend) moveMembers(ctx context.Context, src, dst string, moved, gone []string) error {
+	var cmds [][]string
+	if len(moved) > 0 {
+		cmds = append(cmds, append([]string{"SADD", dst}, moved...))
+	}
+	if drop := append(append([]string{}, moved...), gone...); len(drop) > 0 {
+		cmds = append(cmds, append([]string{"SREM", src}, drop...))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	// The indexes of two namespaces live on different slots in cluster
+	// mode, so they cannot share a transaction there.
+	if b.cluster == nil {
+		_, err := b.doMulti(ctx, cmds)
+		return err
+	}
+	for _, cmd := range cmds {
+		if _, err := b.do(ctx, cmd...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameNX renames src to dst unless dst exists.
+func (b *Backend) renameNX(ctx context.Context, src, dst string) (moveResult, error) {
+	raw, err := b.do(ctx, "RENAMENX", src, dst)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "ERR no such key") {
+			return keyMissing, nil
+		}
+		return 0, err
+	}
+	renamed, err := asInt(raw)
+	if err != nil {
+		return 0, err
+	}
+	if renamed == 0 {
+		return keyConflict, nil
+	}
+	return keyMoved, nil
+}
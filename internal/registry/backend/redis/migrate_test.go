@@ -0,0 +1,196 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+func TestKeyspaceNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		cluster   bool
+		want      string
+	}{
+		{want: "registry:relay:relay-1"},
+		{namespace: "prod", want: "prod:registry:relay:relay-1"},
+		{cluster: true, want: "{registry}:relay:relay-1"},
+		{namespace: "prod", cluster: true, want: "{prod:registry}:relay:relay-1"},
+	}
+
+	for _, test := range tests {
+		keys := newKeyspace(test.namespace, test.cluster)
+		if got := keys.relayKey("relay-1"); got != test.want {
+			t.Fatalf("relayKey in namespace %q (cluster %v) = %q, want %q", test.namespace, test.cluster, got, test.want)
+		}
+	}
+}
+
+func TestNamespacesAreIsolated(t *testing.T) {
+	staging := newTestBackend(registry.TTLConfig{Relay: time.Minute, Agent: time.Minute}, time.Now)
+	production := withNamespace(staging, "production")
+	ctx := context.Background()
+
+	if err := staging.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := staging.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	relays, err := production.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 0 {
+		t.Fatalf("expected no relays in another namespace, got %+v", relays)
+	}
+	if _, err := production.GetAgentPlacement(ctx, "agent-1"); err != registry.ErrAgentNotRegistered {
+		t.Fatalf("expected ErrAgentNotRegistered in another namespace, got %v", err)
+	}
+	if err := production.RegisterAgent(ctx, registry.Agent{ID: "agent-1"}, "relay-1"); err != registry.ErrRelayNotRegistered {
+		t.Fatalf("expected ErrRelayNotRegistered in another namespace, got %v", err)
+	}
+}
+
+func TestMigrateNamespace(t *testing.T) {
+	clock := backendtest.NewClock()
+	legacy := newTestBackend(registry.TTLConfig{Relay: 10 * time.Second, Agent: 20 * time.Second}, clock.Now)
+	target := withNamespace(legacy, "prod")
+	ctx := context.Background()
+
+	for _, id := range []string{"relay-1", "relay-2", "relay-3"} {
+		if err := legacy.RegisterRelay(ctx, registry.Relay{ID: id, Address: "legacy", LastSeen: clock.Now()}); err != nil {
+			t.Fatalf("register relay: %v", err)
+		}
+	}
+	if err := legacy.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+	// A record already written under the namespace is kept.
+	if err := target.RegisterRelay(ctx, registry.Relay{ID: "relay-2", Address: "current", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// A record that expired but is still indexed is dropped.
+	if _, err := legacy.do(ctx, "DEL", legacy.keys.relayKey("relay-3")); err != nil {
+		t.Fatalf("expire relay: %v", err)
+	}
+
+	clock.Advance(4 * time.Second)
+	stats, err := target.MigrateNamespace(ctx, "")
+	if err != nil {
+		t.Fatalf("migrate namespace: %v", err)
+	}
+	if want := (MigrationStats{Relays: 1, Agents: 1, Skipped: 1, Expired: 1}); stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, stats)
+	}
+
+	relays, err := target.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	addresses := map[string]string{}
+	for _, relay := range relays {
+		addresses[relay.ID] = relay.Address
+	}
+	if len(addresses) != 2 || addresses["relay-1"] != "legacy" || addresses["relay-2"] != "current" {
+		t.Fatalf("unexpected relays after migration: %+v", relays)
+	}
+	placement, err := target.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if placement.RelayID != "relay-1" {
+		t.Fatalf("expected placement on relay-1, got %+v", placement)
+	}
+	assertSetMembers(t, target, target.keys.agentsSetKey(), "agent-1")
+
+	// Renaming keeps the remaining TTL.
+	assertPTTL(t, target, target.keys.relayKey("relay-1"), 6*time.Second)
+	assertPTTL(t, target, target.keys.placementKey("agent-1"), 16*time.Second)
+
+	// The skipped relay stays listed in the legacy namespace, and the
+	// expired one is dropped from its index.
+	legacyRelays, err := legacy.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list legacy relays: %v", err)
+	}
+	if len(legacyRelays) != 1 || legacyRelays[0].ID != "relay-2" || legacyRelays[0].Address != "legacy" {
+		t.Fatalf("expected only the skipped relay in the legacy namespace, got %+v", legacyRelays)
+	}
+	assertSetMembers(t, legacy, legacy.keys.relaysSetKey(), "relay-2")
+
+	// Running it again only finds the conflict.
+	stats, err = target.MigrateNamespace(ctx, "")
+	if err != nil {
+		t.Fatalf("migrate namespace again: %v", err)
+	}
+	if want := (MigrationStats{Skipped: 1}); stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, stats)
+	}
+}
+
+func TestMigrateNamespaceAcrossClusterSlots(t *testing.T) {
+	fc := newFakeCluster(t, 3)
+	legacy := newClusterBackend(t, fc.nodes[0].addr())
+	target := withNamespace(legacy, "prod")
+	ctx := context.Background()
+
+	if keySlot(legacy.keys.relaysSetKey()) == keySlot(target.keys.relaysSetKey()) {
+		t.Fatalf("expected namespaces on different slots")
+	}
+
+	if err := legacy.RegisterRelay(ctx, registry.Relay{ID: "relay-1", LastSeen: time.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := legacy.RegisterAgent(ctx, registry.Agent{ID: "agent-1", LastHeartbeat: time.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	stats, err := target.MigrateNamespace(ctx, "")
+	if err != nil {
+		t.Fatalf("migrate namespace: %v", err)
+	}
+	if want := (MigrationStats{Relays: 1, Agents: 1}); stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, stats)
+	}
+
+	placement, err := target.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if placement.RelayID != "relay-1" {
+		t.Fatalf("expected placement on relay-1, got %+v", placement)
+	}
+	relays, err := legacy.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list legacy relays: %v", err)
+	}
+	if len(relays) != 0 {
+		t.Fatalf("expected legacy namespace to be empty, got %+v", relays)
+	}
+}
+
+func TestMigrateNamespaceRejectsSameNamespace(t *testing.T) {
+	b := withNamespace(newTestBackend(registry.TTLConfig{}, time.Now), "prod")
+	if _, err := b.MigrateNamespace(context.Background(), "prod"); err == nil {
+		t.Fatalf("expected error migrating a namespace onto itself")
+	}
+}
+
+// withNamespace returns a backend sharing b's storage under namespace.
+func withNamespace(b *Backend, namespace string) *Backend {
+	ns := &Backend{
+		cfg:     b.cfg,
+		keys:    newKeyspace(namespace, b.cluster != nil),
+		do:      b.do,
+		doMulti: b.doMulti,
+		cluster: b.cluster,
+	}
+	ns.ttl.Store(b.ttl.Load())
+	return ns
+}
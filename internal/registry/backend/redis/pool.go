@@ -0,0 +1,509 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+const (
+	defaultPoolSize         = 10
+	defaultHealthCheckAfter = 30 * time.Second
+
+	// maxPipelineBatch bounds how many queued commands are written to a
+	// connection in a single flush.
+	maxPipelineBatch = 128
+)
+
+// redisError is an error reply sent by the server. Unlike I/O errors it
+// leaves the connection usable.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// conn is a single authenticated connection to a Redis server.
+type conn struct {
+	netConn  net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	lastUsed time.Time
+
+	// gen is the pool generation the connection was dialed in.
+	gen uint64
+}
+
+func newConn(netConn net.Conn) *conn {
+	return &conn{
+		netConn:  netConn,
+		r:        bufio.NewReader(netConn),
+		w:        bufio.NewWriter(netConn),
+		lastUsed: time.Now(),
+	}
+}
+
+// roundTrip writes every command in one flush and then reads one reply per
+// command. Server error replies are returned in place of the reply; an I/O
+// error aborts the round trip and is returned as err.
+func (c *conn) roundTrip(deadline time.Time, cmds [][]string) ([]any, error) {
+	_ = c.netConn.SetDeadline(deadline)
+
+	for _, cmd := range cmds {
+		if _, err := writeRESP(c.w, cmd...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, len(cmds))
+	for i := range cmds {
+		reply, err := readRESP(c.r)
+		var replyErr redisError
+		switch {
+		case errors.As(err, &replyErr):
+			replies[i] = replyErr
+		case err != nil:
+			return nil, err
+		default:
+			replies[i] = reply
+		}
+	}
+
+	c.lastUsed = time.Now()
+	return replies, nil
+}
+
+func (c *conn) close() {
+	_ = c.netConn.Close()
+}
+
+// pool is a bounded set of connections to one Redis server.
+type pool struct {
+	dial             func(ctx context.Context) (*conn, error)
+	minIdle          int
+	maxIdle          int
+	healthCheckAfter time.Duration
+
+	// slots holds one token per connection that may be checked out.
+	slots chan struct{}
+
+	mu      sync.Mutex
+	idle    []*conn
+	gen     uint64
+	warming bool
+	closed  bool
+}
+
+func newPool(dial func(ctx context.Context) (*conn, error), size, minIdle, maxIdle int, healthCheckAfter time.Duration) *pool {
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+	if maxIdle <= 0 || maxIdle > size {
+		maxIdle = size
+	}
+	if healthCheckAfter <= 0 {
+		healthCheckAfter = defaultHealthCheckAfter
+	}
+
+	return &pool{
+		dial:             dial,
+		minIdle:          min(minIdle, maxIdle),
+		maxIdle:          maxIdle,
+		healthCheckAfter: healthCheckAfter,
+		slots:            make(chan struct{}, size),
+	}
+}
+
+// get checks out a connection, reusing an idle one when possible. Idle
+// connections past the health check interval are PINGed first and
+// discarded if they fail.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		c, err := p.popIdle()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		if c == nil {
+			break
+		}
+		if time.Since(c.lastUsed) < p.healthCheckAfter || p.ping(ctx, c) {
+			p.warm()
+			return c, nil
+		}
+		c.close()
+	}
+
+	c, err := p.dialConn(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	p.warm()
+	return c, nil
+}
+
+// dialConn dials a connection tagged with the current generation.
+func (p *pool) dialConn(ctx context.Context) (*conn, error) {
+	p.mu.Lock()
+	gen := p.gen
+	p.mu.Unlock()
+
+	c, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.gen = gen
+	return c, nil
+}
+
+// put returns a connection to the pool. Broken connections are closed.
+func (p *pool) put(c *conn, healthy bool) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !healthy || p.closed || c.gen != p.gen || len(p.idle) >= p.maxIdle {
+		c.close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+func (p *pool) popIdle() (*conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, net.ErrClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	c := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return c, nil
+}
+
+func (p *pool) ping(ctx context.Context, c *conn) bool {
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, [][]string{{"PING"}})
+	if err != nil {
+		return false
+	}
+	_, isErr := replies[0].(redisError)
+	return !isErr
+}
+
+// warm tops the idle list up to minIdle in the background.
+func (p *pool) warm() {
+	p.mu.Lock()
+	if p.warming || p.closed || len(p.idle) >= p.minIdle {
+		p.mu.Unlock()
+		return
+	}
+	p.warming = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.warming = false
+			p.mu.Unlock()
+		}()
+
+		for {
+			p.mu.Lock()
+			done := p.closed || len(p.idle) >= p.minIdle
+			p.mu.Unlock()
+			if done {
+				return
+			}
+
+			select {
+			case p.slots <- struct{}{}:
+			default:
+				// Every slot is in use; nothing would sit idle anyway.
+				return
+			}
+
+			c, err := p.dialConn(context.Background())
+			if err != nil {
+				<-p.slots
+				return
+			}
+			p.put(c, true)
+		}
+	}()
+}
+
+// reset discards every idle connection and retires those checked out, so
+// subsequent commands dial afresh, e.g. after a failover.
+func (p *pool) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.gen++
+	for _, c := range p.idle {
+		c.close()
+	}
+	p.idle = nil
+}
+
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, c := range p.idle {
+		c.close()
+	}
+	p.idle = nil
+}
+
+// pipeline batches concurrent single commands onto pooled connections.
+// While every connection is busy, new commands queue up and are written
+// together on the next connection that frees up.
+type pipeline struct {
+	pool     *pool
+	maxBatch int
+	reqs     chan *pipelineRequest
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type pipelineRequest struct {
+	ctx   context.Context
+	args  []string
+	reply chan pipelineReply
+}
+
+type pipelineReply struct {
+	val any
+	err error
+}
+
+func newPipeline(p *pool, maxBatch int) *pipeline {
+	pl := &pipeline{
+		pool:     p,
+		maxBatch: maxBatch,
+		reqs:     make(chan *pipelineRequest),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go pl.run()
+	return pl
+}
+
+func (pl *pipeline) do(ctx context.Context, args ...string) (any, error) {
+	req := &pipelineRequest{ctx: ctx, args: args, reply: make(chan pipelineReply, 1)}
+
+	select {
+	case pl.reqs <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-pl.stop:
+		return nil, net.ErrClosed
+	}
+
+	select {
+	case reply := <-req.reply:
+		return reply.val, reply.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pl *pipeline) run() {
+	defer close(pl.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-pl.stop
+		cancel()
+	}()
+
+	for {
+		var first *pipelineRequest
+		select {
+		case first = <-pl.reqs:
+		case <-pl.stop:
+			return
+		}
+
+		// Requests that arrive while waiting for a connection join the
+		// batch written on it.
+		c, err := pl.pool.get(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = net.ErrClosed
+			}
+			first.reply <- pipelineReply{err: err}
+			continue
+		}
+
+		batch := []*pipelineRequest{first}
+	drain:
+		for len(batch) < pl.maxBatch {
+			select {
+			case req := <-pl.reqs:
+				batch = append(batch, req)
+			default:
+				break drain
+			}
+		}
+
+		go pl.flush(c, batch)
+	}
+}
+
+func (pl *pipeline) flush(c *conn, batch []*pipelineRequest) {
+	// Requests can be canceled while queued or waiting for a connection;
+	// their commands must not reach Redis.
+	live := batch[:0]
+	for _, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			req.reply <- pipelineReply{err: err}
+			continue
+		}
+		live = append(live, req)
+	}
+	batch = live
+
+	if len(batch) == 0 {
+		pl.pool.put(c, true)
+		return
+	}
+
+	cmds := make([][]string, len(batch))
+	for i, req := range batch {
+		cmds[i] = req.args
+	}
+
+	replies, err := c.roundTrip(batchDeadline(batch), cmds)
+	pl.pool.put(c, err == nil)
+
+	for i, req := range batch {
+		if err != nil {
+			req.reply <- pipelineReply{err: err}
+			continue
+		}
+		if replyErr, ok := replies[i].(redisError); ok {
+			req.reply <- pipelineReply{err: replyErr}
+			continue
+		}
+		req.reply <- pipelineReply{val: replies[i]}
+	}
+}
+
+func (pl *pipeline) close() {
+	pl.stopOnce.Do(func() { close(pl.stop) })
+	<-pl.done
+}
+
+// batchDeadline is the latest deadline in the batch, so no request is cut
+// short by a neighbour. A request without a deadline disables it.
+func batchDeadline(batch []*pipelineRequest) time.Time {
+	var latest time.Time
+	for _, req := range batch {
+		deadline, ok := req.ctx.Deadline()
+		if !ok {
+			return time.Time{}
+		}
+		if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	return latest
+}
+
+// node is the pooled, pipelined client for a single Redis server.
+type node struct {
+	pool     *pool
+	pipeline *pipeline
+}
+
+func newNode(dial func(ctx context.Context) (*conn, error), cfg *registry.RedisConfig) *node {
+	p := newPool(dial, cfg.PoolSize, cfg.MinIdleConns, cfg.MaxIdleConns, cfg.HealthCheckAfter)
+	return &node{pool: p, pipeline: newPipeline(p, maxPipelineBatch)}
+}
+
+func (n *node) do(ctx context.Context, args ...string) (any, error) {
+	return n.pipeline.do(ctx, args...)
+}
+
+// roundTrip sends cmds back to back on one connection, bypassing the
+// pipeline. Commands that depend on connection state, such as a
+// transaction or ASKING, must not be split across connections.
+func (n *node) roundTrip(ctx context.Context, cmds [][]string) ([]any, error) {
+	c, err := n.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, cmds)
+	n.pool.put(c, err == nil)
+	return replies, err
+}
+
+// execMulti wraps multiple commands in a MULTI/EXEC transaction for
+// atomicity. The whole transaction is written in a single flush.
+func (n *node) execMulti(ctx context.Context, cmds [][]string) ([]any, error) {
+	replies, err := n.roundTrip(ctx, txCmds(cmds))
+	if err != nil {
+		return nil, err
+	}
+	return execResults(replies)
+}
+
+func (n *node) close() {
+	n.pipeline.close()
+	n.pool.close()
+}
+
+func txCmds(cmds [][]string) [][]string {
+	tx := make([][]string, 0, len(cmds)+2)
+	tx = append(tx, []string{"MULTI"})
+	tx = append(tx, cmds...)
+	return append(tx, []string{"EXEC"})
+}
+
+// execResults extracts the EXEC reply from the replies to a transaction
+// built by txCmds.
+func execResults(replies []any) ([]any, error) {
+	// A command rejected while queueing aborts the transaction; report
+	// its error rather than the generic EXECABORT.
+	for _, reply := range replies[:len(replies)-1] {
+		if replyErr, ok := reply.(redisError); ok {
+			return nil, replyErr
+		}
+	}
+
+	execResult := replies[len(replies)-1]
+	if replyErr, ok := execResult.(redisError); ok {
+		return nil, replyErr
+	}
+	results, ok := execResult.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected EXEC response type: %T", execResult)
+	}
+	return results, nil
+}
@@ -0,0 +1,471 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/backendtest"
+)
+
+func TestConformanceOverRESP(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) registry.Backend {
+		srv := newRESPServer(t, "")
+		return newRESPBackend(t, srv, registry.RedisConfig{})
+	})
+}
+
+func TestPoolBoundsConnections(t *testing.T) {
+	srv := newRESPServer(t, "")
+	b := newRESPBackend(t, srv, registry.RedisConfig{PoolSize: 2})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.HeartbeatRelay(ctx, "relay-1", time.Now()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+
+	if got := srv.maxOpen.Load(); got > 2 {
+		t.Fatalf("expected at most 2 open connections, got %d", got)
+	}
+}
+
+func TestPipelineBatchesConcurrentCommands(t *testing.T) {
+	srv := newRESPServer(t, "")
+	srv.delay = 10 * time.Millisecond
+	b := newRESPBackend(t, srv, registry.RedisConfig{PoolSize: 1})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.do(ctx, "GET", "key-"+strconv.Itoa(i)); err != nil {
+				t.Errorf("get: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := srv.maxBatch.Load(); got < 2 {
+		t.Fatalf("expected concurrent commands to share a flush, max batch was %d", got)
+	}
+}
+
+func TestPipelineSkipsCanceledCommands(t *testing.T) {
+	srv := newRESPServer(t, "")
+	b := newRESPBackend(t, srv, registry.RedisConfig{PoolSize: 1})
+	ctx := context.Background()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	stale := &pipelineRequest{ctx: canceled, args: []string{"SET", "stale", "1"}, reply: make(chan pipelineReply, 1)}
+	fresh := &pipelineRequest{ctx: ctx, args: []string{"SET", "fresh", "1"}, reply: make(chan pipelineReply, 1)}
+
+	c, err := b.node.pool.get(ctx)
+	if err != nil {
+		t.Fatalf("get conn: %v", err)
+	}
+	b.node.pipeline.flush(c, []*pipelineRequest{stale, fresh})
+
+	if reply := <-stale.reply; !errors.Is(reply.err, context.Canceled) {
+		t.Fatalf("expected canceled command to fail with context.Canceled, got %v", reply.err)
+	}
+	if reply := <-fresh.reply; reply.err != nil {
+		t.Fatalf("expected live command to succeed, got %v", reply.err)
+	}
+
+	if val, err := b.do(ctx, "GET", "stale"); err != nil || val != nil {
+		t.Fatalf("expected canceled SET not to reach redis, got %v, %v", val, err)
+	}
+	val, err := b.do(ctx, "GET", "fresh")
+	if got, _ := val.([]byte); err != nil || string(got) != "1" {
+		t.Fatalf("expected live SET to reach redis, got %q, %v", val, err)
+	}
+}
+
+func TestPoolReplacesDeadConnections(t *testing.T) {
+	srv := newRESPServer(t, "")
+	b := newRESPBackend(t, srv, registry.RedisConfig{PoolSize: 1, HealthCheckAfter: time.Nanosecond})
+	ctx := context.Background()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	srv.dropConns()
+
+	// The health check on checkout notices the dropped connection and
+	// dials a fresh one instead of failing the command.
+	if _, err := b.ListRelays(ctx); err != nil {
+		t.Fatalf("list relays after drop: %v", err)
+	}
+	if got := srv.accepted.Load(); got != 2 {
+		t.Fatalf("expected a second connection to be dialed, got %d", got)
+	}
+}
+
+func TestServerErrorsKeepConnection(t *testing.T) {
+	srv := newRESPServer(t, "")
+	b := newRESPBackend(t, srv, registry.RedisConfig{PoolSize: 1})
+	ctx := context.Background()
+
+	if _, err := b.do(ctx, "BOGUS"); err == nil || !strings.Contains(err.Error(), "unsupported command") {
+		t.Fatalf("expected server error reply, got %v", err)
+	}
+	if _, err := b.do(ctx, "GET", "missing"); err != nil {
+		t.Fatalf("get after error reply: %v", err)
+	}
+	if got := srv.accepted.Load(); got != 1 {
+		t.Fatalf("expected the connection to be reused, got %d dials", got)
+	}
+}
+
+func TestDialAuthenticates(t *testing.T) {
+	srv := newRESPServer(t, "secret")
+	ctx := context.Background()
+
+	good := newRESPBackend(t, srv, registry.RedisConfig{Password: "secret", DB: 2})
+	if _, err := good.ListRelays(ctx); err != nil {
+		t.Fatalf("list relays with password: %v", err)
+	}
+
+	bad := newRESPBackend(t, srv, registry.RedisConfig{Password: "wrong"})
+	if _, err := bad.ListRelays(ctx); err == nil || !strings.HasPrefix(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected WRONGPASS, got %v", err)
+	}
+}
+
+func TestCloseFailsPendingCommands(t *testing.T) {
+	srv := newRESPServer(t, "")
+	b := newRESPBackend(t, srv, registry.RedisConfig{})
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := b.ListRelays(context.Background()); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed after close, got %v", err)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+// BenchmarkHeartbeatRelay compares the original single-connection client,
+// which serialized one command at a time, against the pooled pipelining
+// client under concurrent load.
+func BenchmarkHeartbeatRelay(b *testing.B) {
+	b.Run("single_conn", func(b *testing.B) {
+		srv := newRESPServer(b, "")
+		backend := newRESPBackend(b, srv, registry.RedisConfig{PoolSize: 1})
+		backend.node.pipeline.close()
+		backend.node.pipeline = newPipeline(backend.node.pool, 1)
+		backend.do = backend.node.do
+		benchmarkHeartbeatRelay(b, backend)
+	})
+
+	b.Run("pooled_pipelined", func(b *testing.B) {
+		srv := newRESPServer(b, "")
+		backend := newRESPBackend(b, srv, registry.RedisConfig{})
+		benchmarkHeartbeatRelay(b, backend)
+	})
+}
+
+func benchmarkHeartbeatRelay(b *testing.B, backend *Backend) {
+	ctx := context.Background()
+	for i := range 64 {
+		if err := backend.RegisterRelay(ctx, registry.Relay{ID: fmt.Sprintf("relay-%d", i)}); err != nil {
+			b.Fatalf("register relay: %v", err)
+		}
+	}
+
+	var next atomic.Int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		relayID := fmt.Sprintf("relay-%d", next.Add(1)%64)
+		for pb.Next() {
+			if err := backend.HeartbeatRelay(ctx, relayID, time.Now()); err != nil {
+				b.Errorf("heartbeat relay: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// respServer is a local RESP stand-in for Redis. Commands are executed by
+// the fake doer, so the real connection, pool and pipelining code can be
+// exercised without a Redis server.
+type respServer struct {
+	ln       net.Listener
+	do       func(ctx context.Context, args ...string) (any, error)
+	password string
+
+	// delay is slept before each flush to make batching observable.
+	delay time.Duration
+
+	// replica makes ROLE report a demoted server.
+	replica atomic.Bool
+
+	// cluster, when set, makes the server a cluster node that redirects
+	// commands for slots it does not serve.
+	cluster atomic.Pointer[fakeCluster]
+
+	accepted atomic.Int64
+	open     atomic.Int64
+	maxOpen  atomic.Int64
+	maxBatch atomic.Int64
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func newRESPServer(t testing.TB, password string) *respServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return startRESPServer(t, ln, password)
+}
+
+func startRESPServer(t testing.TB, ln net.Listener, password string) *respServer {
+	t.Helper()
+
+	srv := &respServer{
+		ln:       ln,
+		do:       newFakeRedisDoer(time.Now),
+		password: password,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	srv.wg.Add(1)
+	go srv.serve()
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+		srv.dropConns()
+		srv.wg.Wait()
+	})
+	return srv
+}
+
+func (s *respServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *respServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *respServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.accepted.Add(1)
+		storeMax(&s.maxOpen, s.open.Add(1))
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.open.Add(-1)
+			s.handle(c)
+		}()
+	}
+}
+
+func (s *respServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		_ = c.Close()
+		delete(s.conns, c)
+	}
+}
+
+func (s *respServer) handle(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	authed := s.password == ""
+	var queued [][]string
+	inMulti, aborted, asking := false, false, false
+	batch := int64(0)
+
+	for {
+		raw, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		args, err := asStringSlice(raw)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		var redirect any
+		fc := s.cluster.Load()
+		if fc != nil {
+			redirect = fc.redirect(s, args, asking)
+		}
+
+		var reply any
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "AUTH":
+			if args[len(args)-1] != s.password {
+				reply = redisError("WRONGPASS invalid username-password pair")
+				break
+			}
+			authed = true
+			reply = "OK"
+		case !authed:
+			reply = redisError("NOAUTH Authentication required.")
+		case cmd == "PING":
+			reply = "PONG"
+		case cmd == "SELECT":
+			reply = "OK"
+		case cmd == "ROLE":
+			role := "master"
+			if s.replica.Load() {
+				role = "slave"
+			}
+			reply = []any{[]byte(role), 0, []any{}}
+		case cmd == "ASKING":
+			reply = "OK"
+		case cmd == "CLUSTER" && fc != nil:
+			reply = fc.slotsReply()
+		case redirect != nil:
+			aborted = aborted || inMulti
+			reply = redirect
+		case cmd == "MULTI":
+			inMulti = true
+			reply = "OK"
+		case cmd == "EXEC" && aborted:
+			queued, inMulti, aborted = nil, false, false
+			reply = redisError("EXECABORT Transaction discarded because of previous errors.")
+		case cmd == "EXEC":
+			results := make([]any, len(queued))
+			for i, q := range queued {
+				results[i] = s.exec(q)
+			}
+			queued, inMulti = nil, false
+			reply = results
+		case inMulti:
+			queued = append(queued, args)
+			reply = "QUEUED"
+		default:
+			reply = s.exec(args)
+		}
+
+		writeReply(w, reply)
+		batch++
+
+		// ASKING applies to the next command, or to a whole transaction.
+		asking = cmd == "ASKING" || (asking && inMulti)
+
+		// Flush only once every pipelined command has been answered.
+		if r.Buffered() == 0 {
+			storeMax(&s.maxBatch, batch)
+			batch = 0
+			time.Sleep(s.delay)
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *respServer) exec(args []string) any {
+	res, err := s.do(context.Background(), args...)
+	if err != nil {
+		return redisError(err.Error())
+	}
+	return res
+}
+
+func storeMax(max *atomic.Int64, v int64) {
+	for {
+		cur := max.Load()
+		if v <= cur || max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+func writeReply(w *bufio.Writer, v any) {
+	switch x := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		w.WriteString("+" + x + "\r\n")
+	case redisError:
+		w.WriteString("-" + string(x) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(x) + "\r\n")
+	case []byte:
+		w.WriteString("$" + strconv.Itoa(len(x)) + "\r\n")
+		w.Write(x)
+		w.WriteString("\r\n")
+	case []any:
+		w.WriteString("*" + strconv.Itoa(len(x)) + "\r\n")
+		for _, item := range x {
+			writeReply(w, item)
+		}
+	default:
+		w.WriteString(fmt.Sprintf("-ERR unsupported reply type %T\r\n", v))
+	}
+}
+
+func newRESPBackend(t testing.TB, srv *respServer, cfg registry.RedisConfig) *Backend {
+	t.Helper()
+
+	cfg.Address = "127.0.0.1"
+	cfg.Port = srv.port()
+	b, err := New(&cfg, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
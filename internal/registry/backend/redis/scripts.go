@@ -0,0 +1,152 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// script is a Lua script executed with EVALSHA. Scripts are loaded into
+// the server's script cache on first use and reloaded whenever the server
+// reports NOSCRIPT, e.g. after a restart or SCRIPT FLUSH.
+type script struct {
+	src string
+	sha string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	return &script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// setWithTTL is shared by every script; a PX argument of "0" writes the key
+// without expiry.
+const setWithTTL = `
+local function set(key, value, px)
+  if tonumber(px) > 0 then
+    redis.call('SET', key, value, 'PX', px)
+  else
+    redis.call('SET', key, value)
+  end
+end
+`
+
+// heartbeatRelayScript refreshes LastSeen on an existing relay record and
+// adds the relay back to the relays set, in case a list pruned it while
+// the record was briefly gone.
+//
+// KEYS[1] relay key, KEYS[2] relays set
+// ARGV[1] LastSeen (RFC 3339), ARGV[2] relay PX, ARGV[3] relay ID
+//
+// Returns 0 if the relay does not exist, 1 otherwise.
+var heartbeatRelayScript = newScript(setWithTTL + `
+local raw = redis.call('GET', KEYS[1])
+if not raw then
+  return 0
+end
+local relay = cjson.decode(raw)
+relay.LastSeen = ARGV[1]
+set(KEYS[1], cjson.encode(relay), ARGV[2])
+redis.call('SADD', KEYS[2], ARGV[3])
+return 1
+`)
+
+// registerAgentScript writes an agent and its placement only if the target
+// relay still exists.
+//
+// KEYS[1] relay key, KEYS[2] agent key, KEYS[3] placement key,
+// KEYS[4] agents set
+// ARGV[1] agent ID, ARGV[2] agent payload, ARGV[3] placement payload,
+// ARGV[4] agent PX
+//
+// Returns 0 if the relay does not exist, 1 otherwise.
+var registerAgentScript = newScript(setWithTTL + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+set(KEYS[2], ARGV[2], ARGV[4])
+set(KEYS[3], ARGV[3], ARGV[4])
+redis.call('SADD', KEYS[4], ARGV[1])
+return 1
+`)
+
+// heartbeatAgentScript refreshes an existing agent and its placement and
+// adds the agent back to the agents set, like heartbeatRelayScript.
+//
+// KEYS[1] agent key, KEYS[2] placement key, KEYS[3] agents set
+// ARGV[1] heartbeat time (RFC 3339), ARGV[2] agent PX, ARGV[3] agent ID
+//
+// Returns 0 if either record does not exist, 1 otherwise.
+var heartbeatAgentScript = newScript(setWithTTL + `
+local rawAgent = redis.call('GET', KEYS[1])
+local rawPlacement = redis.call('GET', KEYS[2])
+if not rawAgent or not rawPlacement then
+  return 0
+end
+local agent = cjson.decode(rawAgent)
+agent.LastHeartbeat = ARGV[1]
+local placement = cjson.decode(rawPlacement)
+placement.UpdatedAt = ARGV[1]
+set(KEYS[1], cjson.encode(agent), ARGV[2])
+set(KEYS[2], cjson.encode(placement), ARGV[2])
+redis.call('SADD', KEYS[3], ARGV[3])
+return 1
+`)
+
+// pruneSetScript removes set members whose record no longer exists. The
+// check and the removal run together, so a member re-registered after a
+// list read its missing record is kept.
+//
+// KEYS[1] set, KEYS[i+1] record key of ARGV[i]
+// ARGV[i] member
+//
+// Returns the number of members removed.
+var pruneSetScript = newScript(`
+local removed = 0
+for i, member in ipairs(ARGV) do
+  if redis.call('EXISTS', KEYS[i + 1]) == 0 then
+    removed = removed + redis.call('SREM', KEYS[1], member)
+  end
+end
+return removed
+`)
+
+// evalScript runs s with EVALSHA, loading it with SCRIPT LOAD and retrying
+// once if the server does not have it cached.
+func (b *Backend) evalScript(ctx context.Context, s *script, keys []string, args ...string) (any, error) {
+	cmd := make([]string, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVALSHA", s.sha, strconv.Itoa(len(keys)))
+	cmd = append(cmd, keys...)
+	cmd = append(cmd, args...)
+
+	res, err := b.do(ctx, cmd...)
+	if err == nil || !isNoScript(err) {
+		return res, err
+	}
+
+	if _, err := b.do(ctx, "SCRIPT", "LOAD", s.src); err != nil {
+		return nil, err
+	}
+	return b.do(ctx, cmd...)
+}
+
+func isNoScript(err error) bool {
+	return strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
+
+// pxArg formats ttl as a script PX argument.
+func pxArg(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+}
+
+// timeArg formats ts the way encoding/json marshals time.Time, so scripts
+// can splice it into stored records.
+func timeArg(ts time.Time) string {
+	return ts.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,241 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+const (
+	switchMasterChannel = "+switch-master"
+
+	// sentinelRetryDelay is how long the watcher waits before moving on to
+	// the next sentinel after a subscription fails.
+	sentinelRetryDelay = time.Second
+)
+
+// sentinel resolves and follows the current Redis primary. The address is
+// cached between dials and replaced when a sentinel announces a failover
+// on +switch-master, or when dialing the cached primary fails.
+type sentinel struct {
+	cfg registry.RedisSentinelConfig
+	tls *tls.Config
+
+	// onSwitch is called after the primary address changes.
+	onSwitch func()
+
+	mu     sync.Mutex
+	master string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newSentinel(cfg registry.RedisSentinelConfig, tlsCfg *tls.Config, onSwitch func()) *sentinel {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &sentinel{
+		cfg:      cfg,
+		tls:      tlsCfg,
+		onSwitch: onSwitch,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go s.watch(ctx)
+	return s
+}
+
+// masterAddr returns the cached primary address, asking the sentinels if
+// none is known.
+func (s *sentinel) masterAddr(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	master := s.master
+	s.mu.Unlock()
+	if master != "" {
+		return master, nil
+	}
+
+	master, err := s.query(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.setMaster(master)
+	return master, nil
+}
+
+// forget drops addr from the cache if it is still the cached primary.
+func (s *sentinel) forget(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.master == addr {
+		s.master = ""
+	}
+}
+
+// setMaster records addr as the primary and reports whether it changed
+// from a previously known address.
+func (s *sentinel) setMaster(addr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.master != "" && s.master != addr
+	s.master = addr
+	return changed
+}
+
+// query asks each sentinel in turn for the primary's address.
+func (s *sentinel) query(ctx context.Context) (string, error) {
+	var errs []error
+	for _, addr := range s.cfg.Addrs {
+		master, err := s.queryOne(ctx, addr)
+		if err == nil {
+			return master, nil
+		}
+		errs = append(errs, fmt.Errorf("sentinel %s: %w", addr, err))
+	}
+	return "", fmt.Errorf("resolve redis master %q: %w", s.cfg.MasterName, errors.Join(errs...))
+}
+
+func (s *sentinel) queryOne(ctx context.Context, addr string) (string, error) {
+	c, err := s.dial(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, [][]string{{"SENTINEL", "get-master-addr-by-name", s.cfg.MasterName}})
+	if err != nil {
+		return "", err
+	}
+	if replyErr, ok := replies[0].(redisError); ok {
+		return "", replyErr
+	}
+	if replies[0] == nil {
+		return "", fmt.Errorf("master %q is unknown", s.cfg.MasterName)
+	}
+
+	parts, err := asStringSlice(replies[0])
+	if err != nil {
+		return "", err
+	}
+	if len(parts) != 2 {
+		return "", fmt.Errorf("master %q is unknown", s.cfg.MasterName)
+	}
+	return net.JoinHostPort(parts[0], parts[1]), nil
+}
+
+// dial connects and authenticates to a sentinel. Sentinels use the same
+// TLS settings as the data connections.
+func (s *sentinel) dial(ctx context.Context, addr string) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	netConn, err := dialNet(ctx, addr, s.tls)
+	if err != nil {
+		return nil, err
+	}
+	c := newConn(netConn)
+
+	if s.cfg.Password == "" {
+		return c, nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, [][]string{authCmd(s.cfg.Username, s.cfg.Password)})
+	if err == nil {
+		if replyErr, ok := replies[0].(redisError); ok {
+			err = replyErr
+		}
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// watch subscribes to +switch-master on one sentinel at a time until ctx
+// is canceled, moving to the next sentinel whenever a subscription drops.
+func (s *sentinel) watch(ctx context.Context) {
+	defer close(s.done)
+
+	for i := 0; ; i = (i + 1) % len(s.cfg.Addrs) {
+		err := s.subscribe(ctx, s.cfg.Addrs[i])
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("redis sentinel subscription lost", "sentinel", s.cfg.Addrs[i], "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sentinelRetryDelay):
+		}
+	}
+}
+
+func (s *sentinel) subscribe(ctx context.Context, addr string) error {
+	c, err := s.dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	stop := context.AfterFunc(ctx, c.close)
+	defer stop()
+
+	replies, err := c.roundTrip(time.Time{}, [][]string{{"SUBSCRIBE", switchMasterChannel}})
+	if err != nil {
+		return err
+	}
+	if replyErr, ok := replies[0].(redisError); ok {
+		return replyErr
+	}
+
+	// A failover may have happened while no subscription was active.
+	if master, err := s.queryOne(ctx, addr); err == nil {
+		s.switchTo(master)
+	}
+
+	for {
+		msg, err := readRESP(c.r)
+		if err != nil {
+			return err
+		}
+
+		parts, err := asStringSlice(msg)
+		if err != nil || len(parts) != 3 || parts[0] != "message" || parts[1] != switchMasterChannel {
+			continue
+		}
+
+		// <master-name> <old-ip> <old-port> <new-ip> <new-port>
+		fields := strings.Fields(parts[2])
+		if len(fields) != 5 || fields[0] != s.cfg.MasterName {
+			continue
+		}
+		s.switchTo(net.JoinHostPort(fields[3], fields[4]))
+	}
+}
+
+func (s *sentinel) switchTo(master string) {
+	if !s.setMaster(master) {
+		return
+	}
+
+	slog.Info("redis master switched", "master_name", s.cfg.MasterName, "address", master)
+	s.onSwitch()
+}
+
+func (s *sentinel) close() {
+	s.cancel()
+	<-s.done
+}
@@ -0,0 +1,289 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+func TestSentinelDiscoversMaster(t *testing.T) {
+	primary := newRESPServer(t, "")
+	sentinel := newFakeSentinel(t, "mymaster", primary.addr())
+	ctx := context.Background()
+
+	// A dead sentinel listed first is skipped.
+	b := newSentinelBackend(t, deadAddr(t), sentinel.addr())
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	assertServerHasKey(t, primary, b.keys.relayKey("relay-1"))
+}
+
+func TestSentinelFollowsSwitchMaster(t *testing.T) {
+	primary := newRESPServer(t, "")
+	replica := newRESPServer(t, "")
+	sentinel := newFakeSentinel(t, "mymaster", primary.addr())
+	ctx := context.Background()
+
+	b := newSentinelBackend(t, sentinel.addr())
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	sentinel.waitSubscribed(t)
+
+	// The old primary stays up as a replica, so only the announcement can
+	// move traffic over.
+	primary.replica.Store(true)
+	sentinel.switchMaster(t, replica.addr(), true)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+			t.Fatalf("register relay after failover: %v", err)
+		}
+		if serverHasKey(t, replica, b.keys.relayKey("relay-2")) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("writes did not move to the new master")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSentinelRediscoversAfterMissedSwitch(t *testing.T) {
+	primary := newRESPServer(t, "")
+	replica := newRESPServer(t, "")
+	sentinel := newFakeSentinel(t, "mymaster", primary.addr())
+	ctx := context.Background()
+
+	b := newSentinelBackend(t, sentinel.addr())
+	b.node.pool.healthCheckAfter = time.Nanosecond
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// Fail over without announcing it; the old primary goes away.
+	sentinel.switchMaster(t, replica.addr(), false)
+	_ = primary.ln.Close()
+	primary.dropConns()
+
+	if err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-2"}); err != nil {
+		t.Fatalf("register relay after silent failover: %v", err)
+	}
+	assertServerHasKey(t, replica, b.keys.relayKey("relay-2"))
+}
+
+func TestSentinelRejectsReplica(t *testing.T) {
+	replica := newRESPServer(t, "")
+	replica.replica.Store(true)
+	sentinel := newFakeSentinel(t, "mymaster", replica.addr())
+
+	b := newSentinelBackend(t, sentinel.addr())
+	if _, err := b.ListRelays(context.Background()); err == nil || !strings.Contains(err.Error(), "not the master") {
+		t.Fatalf("expected replica to be rejected, got %v", err)
+	}
+}
+
+func TestSentinelUnknownMaster(t *testing.T) {
+	sentinel := newFakeSentinel(t, "other", deadAddr(t))
+
+	b := newSentinelBackend(t, sentinel.addr())
+	if _, err := b.ListRelays(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("expected unknown master error, got %v", err)
+	}
+}
+
+func newSentinelBackend(t *testing.T, sentinels ...string) *Backend {
+	t.Helper()
+
+	b, err := New(&registry.RedisConfig{
+		Sentinel: registry.RedisSentinelConfig{MasterName: "mymaster", Addrs: sentinels},
+	}, registry.TTLConfig{Relay: time.Minute, Agent: time.Minute})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close(context.Background())
+	})
+	return b
+}
+
+func serverHasKey(t *testing.T, srv *respServer, key string) bool {
+	t.Helper()
+
+	res, err := srv.do(context.Background(), "EXISTS", key)
+	if err != nil {
+		t.Fatalf("exists %s: %v", key, err)
+	}
+	return res == 1
+}
+
+func assertServerHasKey(t *testing.T, srv *respServer, key string) {
+	t.Helper()
+
+	if !serverHasKey(t, srv, key) {
+		t.Fatalf("expected %s on %s", key, srv.addr())
+	}
+}
+
+// deadAddr returns an address nothing is listening on.
+func deadAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+// fakeSentinel answers SENTINEL get-master-addr-by-name for one master and
+// publishes +switch-master to subscribers.
+type fakeSentinel struct {
+	ln   net.Listener
+	name string
+
+	mu          sync.Mutex
+	master      string
+	subscribers map[*bufio.Writer]struct{}
+	subscribed  chan struct{}
+}
+
+func newFakeSentinel(t *testing.T, name, master string) *fakeSentinel {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := &fakeSentinel{
+		ln:          ln,
+		name:        name,
+		master:      master,
+		subscribers: make(map[*bufio.Writer]struct{}),
+		subscribed:  make(chan struct{}, 16),
+	}
+
+	var wg sync.WaitGroup
+	var conns sync.Map
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns.Store(c, struct{}{})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(c)
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+		conns.Range(func(c, _ any) bool {
+			_ = c.(net.Conn).Close()
+			return true
+		})
+		wg.Wait()
+	})
+	return s
+}
+
+func (s *fakeSentinel) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeSentinel) handle(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, w)
+		s.mu.Unlock()
+	}()
+
+	for {
+		raw, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		args, err := asStringSlice(raw)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		s.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "SENTINEL":
+			if len(args) == 3 && args[2] == s.name {
+				host, port, _ := net.SplitHostPort(s.master)
+				writeReply(w, []any{[]byte(host), []byte(port)})
+			} else {
+				writeReply(w, nil)
+			}
+		case "SUBSCRIBE":
+			s.subscribers[w] = struct{}{}
+			writeReply(w, []any{[]byte("subscribe"), []byte(args[1]), 1})
+			s.subscribed <- struct{}{}
+		case "PING":
+			writeReply(w, "PONG")
+		default:
+			writeReply(w, redisError("ERR unknown command"))
+		}
+		err = w.Flush()
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeSentinel) waitSubscribed(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-s.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a sentinel subscription")
+	}
+}
+
+// switchMaster points the sentinel at master, announcing the change to
+// subscribers if announce is set.
+func (s *fakeSentinel) switchMaster(t *testing.T, master string, announce bool) {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldHost, oldPort, _ := net.SplitHostPort(s.master)
+	newHost, newPort, _ := net.SplitHostPort(master)
+	s.master = master
+	if !announce {
+		return
+	}
+
+	payload := strings.Join([]string{s.name, oldHost, oldPort, newHost, newPort}, " ")
+	for w := range s.subscribers {
+		writeReply(w, []any{[]byte("message"), []byte(switchMasterChannel), []byte(payload)})
+		_ = w.Flush()
+	}
+}
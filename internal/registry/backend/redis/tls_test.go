@@ -0,0 +1,235 @@
+package redis
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+)
+
+func TestTLSConnection(t *testing.T) {
+	pki := newTestPKI(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		clientAuth tls.ClientAuthType
+		tls        registry.ClientTLSConfig
+		wantErr    string
+	}{
+		{
+			name: "verified with ca",
+			tls:  registry.ClientTLSConfig{Enabled: true, CAPath: pki.caPath},
+		},
+		{
+			name:    "unknown authority",
+			tls:     registry.ClientTLSConfig{Enabled: true},
+			wantErr: "certificate",
+		},
+		{
+			name:    "server name mismatch",
+			tls:     registry.ClientTLSConfig{Enabled: true, CAPath: pki.caPath, ServerName: "other.example"},
+			wantErr: "certificate",
+		},
+		{
+			name: "insecure skip verify",
+			tls:  registry.ClientTLSConfig{Enabled: true, InsecureSkipVerify: true},
+		},
+		{
+			name:       "mutual tls",
+			clientAuth: tls.RequireAndVerifyClientCert,
+			tls:        registry.ClientTLSConfig{Enabled: true, CAPath: pki.caPath, CertPath: pki.clientCertPath, KeyPath: pki.clientKeyPath},
+		},
+		{
+			name:       "mutual tls without client cert",
+			clientAuth: tls.RequireAndVerifyClientCert,
+			tls:        registry.ClientTLSConfig{Enabled: true, CAPath: pki.caPath},
+			wantErr:    "certificate",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newTLSRESPServer(t, pki, test.clientAuth, "secret")
+			b := newRESPBackend(t, srv, registry.RedisConfig{Password: "secret", TLS: test.tls})
+
+			err := b.RegisterRelay(ctx, registry.Relay{ID: "relay-1"})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("register relay over tls: %v", err)
+				}
+				if _, err := b.ListRelays(ctx); err != nil {
+					t.Fatalf("list relays over tls: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewRejectsBadTLSFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	notPEM := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tls  registry.ClientTLSConfig
+	}{
+		{name: "missing ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: filepath.Join(t.TempDir(), "missing.pem")}},
+		{name: "empty ca", tls: registry.ClientTLSConfig{Enabled: true, CAPath: notPEM}},
+		{name: "bad client key", tls: registry.ClientTLSConfig{Enabled: true, CertPath: pki.clientCertPath, KeyPath: notPEM}},
+	}
+
+	for _, test := range tests {
+		_, err := New(&registry.RedisConfig{Address: "localhost", Port: 6379, TLS: test.tls}, registry.TTLConfig{})
+		if err == nil {
+			t.Fatalf("%s: expected error", test.name)
+		}
+	}
+}
+
+func newTLSRESPServer(t *testing.T, pki *testPKI, clientAuth tls.ClientAuthType, password string) *respServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	tlsLn := &tlsListener{Listener: ln, cfg: &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientCAs:    roots,
+		ClientAuth:   clientAuth,
+	}}
+	return startRESPServer(t, tlsLn, password)
+}
+
+// tlsListener keeps the TCP address reachable for respServer.port while
+// wrapping accepted connections in TLS.
+type tlsListener struct {
+	net.Listener
+	cfg *tls.Config
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Server(c, l.cfg), nil
+}
+
+type testPKI struct {
+	ca     *x509.Certificate
+	server tls.Certificate
+
+	caPath         string
+	clientCertPath string
+	clientKeyPath  string
+}
+
+// newTestPKI generates a CA, a server certificate for 127.0.0.1 and a
+// client certificate, writing the PEM files the client loads to disk.
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+	caKey := mustGenerateKey(t)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse ca: %v", err)
+	}
+
+	issue := func(serial int64, template *x509.Certificate) ([]byte, *ecdsa.PrivateKey) {
+		key := mustGenerateKey(t)
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("create certificate: %v", err)
+		}
+		return der, key
+	}
+
+	serverDER, serverKey := issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "redis"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	clientDER, clientKey := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "registry"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	pki := &testPKI{
+		ca:             ca,
+		server:         tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey},
+		caPath:         filepath.Join(dir, "ca.pem"),
+		clientCertPath: filepath.Join(dir, "client.pem"),
+		clientKeyPath:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("marshal client key: %v", err)
+	}
+	mustWritePEM(t, pki.caPath, "CERTIFICATE", caDER)
+	mustWritePEM(t, pki.clientCertPath, "CERTIFICATE", clientDER)
+	mustWritePEM(t, pki.clientKeyPath, "EC PRIVATE KEY", clientKeyDER)
+	return pki
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func mustWritePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -1,7 +1,11 @@
 package registry
 
 import (
+	"crypto/tls"
 	"fmt"
+	"log/slog"
+	"net"
+	"strings"
 	"time"
 )
 
@@ -10,40 +14,127 @@ import (
 // and liveness semantics (TTL) in a backend-agnostic way.
 type Config struct {
 	// Backend defines which registry backend implementation is used
-	// (e.g. memory, redis, etcd, consul) and its associated configuration.
-	Backend BackendConfig
+	// (e.g. memory, redis, etcd, consul, postgres) and its associated configuration.
+	Backend BackendConfig `yaml:"backend" toml:"backend"`
 
 	// GRPC defines the gRPC server configuration used to expose
 	// the registry control plane APIs.
-	GRPC GRPCConfig
+	GRPC GRPCConfig `yaml:"grpc" toml:"grpc"`
 
 	// TTL defines liveness and expiration semantics for relays and agents.
 	// These values are enforced at the registry layer, independent of backend.
-	TTL TTLConfig
+	TTL TTLConfig `yaml:"ttl" toml:"ttl"`
+
+	// Reaper defines how often expired relays and agents are garbage
+	// collected from the backend.
+	Reaper ReaperConfig `yaml:"reaper" toml:"reaper"`
+
+	// Watch defines how relay and placement changes are observed and
+	// retained for streaming watchers.
+	Watch WatchConfig `yaml:"watch" toml:"watch"`
+
+	// Log defines logging behavior for the registry process.
+	Log LogConfig `yaml:"log" toml:"log"`
 }
 
 // GRPCConfig defines the gRPC server configuration for the registry service.
 type GRPCConfig struct {
 	// ListenAddress is the network address the gRPC server binds to.
-	ListenAddress string
+	ListenAddress string `yaml:"listen_address" toml:"listen_address"`
 
 	// ListenPort is the TCP port the gRPC server listens on.
-	ListenPort int
+	ListenPort int `yaml:"listen_port" toml:"listen_port"`
 
 	// TLS defines TLS configuration for securing the gRPC transport.
-	TLS TLSConfig
+	TLS TLSConfig `yaml:"tls" toml:"tls"`
+
+	// Auth defines which authenticated callers may use which RPCs.
+	Auth AuthConfig `yaml:"auth" toml:"auth"`
+
+	// JWT defines bearer token authentication for callers that cannot
+	// present a client certificate.
+	JWT JWTConfig `yaml:"jwt" toml:"jwt"`
+}
+
+// JWTConfig defines how bearer tokens sent in the authorization metadata
+// are verified. A verified token's sub claim becomes the caller's principal
+// name. Keys are read locally, so verification never needs the network.
+type JWTConfig struct {
+	// Enabled requires every call to carry a valid bearer token, unless the
+	// caller presented a verified client certificate instead.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+
+	// Secret is the shared secret for HS256 tokens. Empty rejects HS256.
+	Secret string `yaml:"secret" toml:"secret"`
+
+	// JWKSPath is the filesystem path to a JSON Web Key Set holding the
+	// RSA and P-256 public keys for RS256 and ES256 tokens. Empty rejects
+	// both algorithms.
+	JWKSPath string `yaml:"jwks_path" toml:"jwks_path"`
+
+	// JWKSReloadInterval is how often the key set is re-read so rotated
+	// keys are picked up. A zero value reads it once at startup.
+	JWKSReloadInterval time.Duration `yaml:"jwks_reload_interval" toml:"jwks_reload_interval"`
+
+	// Issuer is the required iss claim.
+	Issuer string `yaml:"issuer" toml:"issuer"`
+
+	// Audience must appear in the aud claim.
+	Audience string `yaml:"audience" toml:"audience"`
+}
+
+// RelayIDPlaceholder marks where the relay ID appears in an
+// AuthConfig.RelayPrincipals pattern.
+const RelayIDPlaceholder = "{relay_id}"
+
+// AuthConfig defines the authorization policy of the gRPC service. Callers
+// are identified by principal name: the sub claim of a verified bearer
+// token, else the verified client certificate's first URI SAN, else its
+// first DNS SAN, else its common name.
+type AuthConfig struct {
+	// Enabled rejects callers without a principal and restricts the rest
+	// to the RPCs their policy grants. When false every caller may use
+	// every RPC.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+
+	// RelayPrincipals are patterns of relay principal names, each holding
+	// RelayIDPlaceholder once, e.g. "spiffe://aero-arc/relay/{relay_id}".
+	// A matching principal may register and heartbeat only the relay ID
+	// it captures, and register and heartbeat only agents placed on it. It
+	// may not take an agent placed on another live relay.
+	RelayPrincipals []string `yaml:"relay_principals" toml:"relay_principals"`
+
+	// ControlPlanePrincipals are principal names granted read-only access:
+	// listing relays and looking up agent placements.
+	ControlPlanePrincipals []string `yaml:"control_plane_principals" toml:"control_plane_principals"`
 }
 
 // TLSConfig defines TLS settings for securing gRPC communication.
 type TLSConfig struct {
 	// Enabled determines whether TLS is enabled for the gRPC server.
-	Enabled bool
+	Enabled bool `yaml:"enabled" toml:"enabled"`
 
 	// CertPath is the filesystem path to the TLS certificate.
-	CertPath string
+	CertPath string `yaml:"cert_path" toml:"cert_path"`
 
 	// KeyPath is the filesystem path to the TLS private key.
-	KeyPath string
+	KeyPath string `yaml:"key_path" toml:"key_path"`
+
+	// ClientCAPath is the filesystem path to a PEM bundle of CAs that sign
+	// client certificates. When set, every client must present a
+	// certificate that verifies against it (mutual TLS). With JWT enabled
+	// the certificate is optional, and callers without one must send a
+	// bearer token instead.
+	ClientCAPath string `yaml:"client_ca_path" toml:"client_ca_path"`
+
+	// MinVersion is the oldest TLS version accepted, "1.2" or "1.3".
+	// Empty uses 1.2.
+	MinVersion string `yaml:"min_version" toml:"min_version"`
+
+	// CipherSuites restricts the TLS 1.2 cipher suites to the listed IANA
+	// names, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Empty uses the
+	// Go defaults. TLS 1.3 suites are not configurable.
+	CipherSuites []string `yaml:"cipher_suites" toml:"cipher_suites"`
 }
 
 // TTLConfig defines time-to-live and liveness expectations
@@ -51,96 +142,307 @@ type TLSConfig struct {
 type TTLConfig struct {
 	// Relay defines the maximum allowed duration since the last
 	// heartbeat before a relay is considered unhealthy.
-	Relay time.Duration
+	Relay time.Duration `yaml:"relay" toml:"relay"`
 
 	// Agent defines the maximum allowed duration since the last
 	// heartbeat before an agent is considered unhealthy.
-	Agent time.Duration
+	Agent time.Duration `yaml:"agent" toml:"agent"`
+}
+
+// ReaperConfig defines the background sweep that removes expired relays
+// and agents from the backend and invalidates placements on dead relays.
+type ReaperConfig struct {
+	// Interval is the base delay between sweeps. A zero value disables
+	// the reaper; expired state is then only hidden from reads.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+
+	// Jitter is the upper bound of a random delay added to each interval
+	// so replicas sharing a backend do not sweep in lockstep.
+	Jitter time.Duration `yaml:"jitter" toml:"jitter"`
+}
+
+// WatchConfig defines change notification behavior for watchers.
+type WatchConfig struct {
+	// Interval is how often backend state is polled for backends that do
+	// not implement Watcher, and how often TTL expiry is evaluated for
+	// those that do. A zero value disables watching, and is the default
+	// while no transport serves watches.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+
+	// History is the number of recent events retained so reconnecting
+	// watchers can resume without missing changes. Zero uses a default.
+	History int `yaml:"history" toml:"history"`
+}
+
+// LogConfig defines logging behavior for the registry process.
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level slog.Level `yaml:"level" toml:"level"`
 }
 
 // BackendConfig defines which registry backend implementation is used
 // and provides backend-specific configuration.
 type BackendConfig struct {
 	// Type specifies the registry backend implementation.
-	Type RegistryBackend
+	Type RegistryBackend `yaml:"type" toml:"type"`
 
 	// Redis contains Redis-specific configuration when the Redis backend is used.
 	// It must be non-nil when Type is set to the Redis backend.
-	Redis  *RedisConfig
-	Etcd   *EtcdConfig
-	Consul *ConsulConfig
-	Memory *MemoryConfig
+	Redis    *RedisConfig    `yaml:"redis" toml:"redis"`
+	Etcd     *EtcdConfig     `yaml:"etcd" toml:"etcd"`
+	Consul   *ConsulConfig   `yaml:"consul" toml:"consul"`
+	Memory   *MemoryConfig   `yaml:"memory" toml:"memory"`
+	File     *FileConfig     `yaml:"file" toml:"file"`
+	Postgres *PostgresConfig `yaml:"postgres" toml:"postgres"`
+
+	// Options holds the configuration of backends registered from outside
+	// this module. Its type is defined by the backend's driver.
+	Options any `yaml:"-" toml:"-"`
 }
 
-// RegistryBackend represents the supported registry backend implementations.
+// RegistryBackend names a registry backend implementation. The available
+// names are those registered with the driver package.
 type RegistryBackend string
 
 // RedisConfig defines configuration for the Redis-backed registry implementation.
 type RedisConfig struct {
 	// Address is the Redis server hostname or IP.
-	Address string
+	Address string `yaml:"address" toml:"address"`
 
 	// Port is the Redis server port.
-	Port int
+	Port int `yaml:"port" toml:"port"`
 
 	// Username is the Redis username used for authentication.
-	Username string
+	Username string `yaml:"username" toml:"username"`
 
 	// Password is the Redis password used for authentication.
-	Password string
+	Password string `yaml:"password" toml:"password"`
 
 	// DB is the Redis logical database index to use.
-	DB int
+	DB int `yaml:"db" toml:"db"`
+
+	// Namespace is prepended to every key, so several registries can
+	// share one Redis deployment. An empty value keeps the unprefixed
+	// layout.
+	Namespace string `yaml:"namespace" toml:"namespace"`
+
+	// PoolSize is the maximum number of open connections. Zero uses a
+	// default of 10.
+	PoolSize int `yaml:"pool_size" toml:"pool_size"`
+
+	// MinIdleConns is the number of idle connections kept warm for
+	// bursts of traffic.
+	MinIdleConns int `yaml:"min_idle_conns" toml:"min_idle_conns"`
+
+	// MaxIdleConns caps the idle connections retained after use. Zero
+	// retains up to PoolSize.
+	MaxIdleConns int `yaml:"max_idle_conns" toml:"max_idle_conns"`
+
+	// HealthCheckAfter is how long a connection may sit idle before it is
+	// PINGed on checkout. Zero uses a default of 30 seconds.
+	HealthCheckAfter time.Duration `yaml:"health_check_after" toml:"health_check_after"`
+
+	// TLS defines TLS settings for the connection to Redis. The handshake
+	// completes before AUTH, so credentials never cross the network in
+	// cleartext.
+	TLS ClientTLSConfig `yaml:"tls" toml:"tls"`
+
+	// Sentinel enables discovery of the current primary through Redis
+	// Sentinel. When set, Address and Port are ignored.
+	Sentinel RedisSentinelConfig `yaml:"sentinel" toml:"sentinel"`
+
+	// Cluster enables Redis Cluster mode. When set, Address and Port are
+	// ignored.
+	Cluster RedisClusterConfig `yaml:"cluster" toml:"cluster"`
+}
+
+// RedisClusterConfig defines how a Redis Cluster is reached. Keys are laid
+// out under a single hash tag, so every registry key lives in one slot and
+// transactions never span nodes.
+type RedisClusterConfig struct {
+	// Addrs lists seed nodes as host:port pairs. The slot map is loaded
+	// from the first one that answers. An empty list disables cluster mode.
+	Addrs []string `yaml:"addrs" toml:"addrs"`
+}
+
+// RedisSentinelConfig defines how the Redis primary is discovered through
+// Sentinel and followed across failovers.
+type RedisSentinelConfig struct {
+	// MasterName is the name the sentinels monitor the primary under. An
+	// empty value disables Sentinel mode.
+	MasterName string `yaml:"master_name" toml:"master_name"`
+
+	// Addrs lists the sentinels as host:port pairs. They are tried in
+	// order until one answers.
+	Addrs []string `yaml:"addrs" toml:"addrs"`
+
+	// Username is the username used to authenticate to the sentinels.
+	Username string `yaml:"username" toml:"username"`
+
+	// Password is the password used to authenticate to the sentinels.
+	Password string `yaml:"password" toml:"password"`
+}
+
+// ClientTLSConfig defines TLS settings for the registry's connection to a
+// backend service.
+type ClientTLSConfig struct {
+	// Enabled determines whether connections to the service use TLS.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+
+	// CAPath is the filesystem path to a PEM bundle used to verify the
+	// server. Empty uses the system roots.
+	CAPath string `yaml:"ca_path" toml:"ca_path"`
+
+	// CertPath is the filesystem path to the client certificate presented
+	// for mutual TLS.
+	CertPath string `yaml:"cert_path" toml:"cert_path"`
+
+	// KeyPath is the filesystem path to the client private key.
+	KeyPath string `yaml:"key_path" toml:"key_path"`
+
+	// ServerName overrides the name checked against the server
+	// certificate. Empty uses the host the backend dials.
+	ServerName string `yaml:"server_name" toml:"server_name"`
+
+	// InsecureSkipVerify disables server certificate verification. It is
+	// intended for local development only.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
 }
 
 // EtcdConfig defines configuration for the Etcd-backed registry backend.
-//
-// TODO:
-//   - Validate endpoint formatting (host:port)
-//   - Support TLS configuration (CA / cert / key)
-//   - Add optional dial timeout configuration
-//   - Consider lease-based TTL enforcement
-type EtcdConfig struct{}
+// Relay, agent and placement keys are attached to leases whose TTLs come
+// from TTLConfig, so etcd drops dead state on its own.
+type EtcdConfig struct {
+	// Endpoints lists the etcd client endpoints as host:port pairs. They
+	// are tried in order until one answers.
+	Endpoints []string `yaml:"endpoints" toml:"endpoints"`
+
+	// DialTimeout bounds establishing a connection to an endpoint. Zero
+	// uses a default of 5 seconds.
+	DialTimeout time.Duration `yaml:"dial_timeout" toml:"dial_timeout"`
+
+	// Username is the etcd user used for authentication. Empty disables
+	// authentication.
+	Username string `yaml:"username" toml:"username"`
+
+	// Password is the etcd password used for authentication.
+	Password string `yaml:"password" toml:"password"`
+
+	// Prefix is prepended to every key. Empty uses "/aero-arc-registry/".
+	Prefix string `yaml:"prefix" toml:"prefix"`
+
+	// TLS defines TLS settings for the connection to etcd.
+	TLS ClientTLSConfig `yaml:"tls" toml:"tls"`
+}
 
 // ConsulConfig defines configuration for the Consul-backed registry backend.
-//
-// TODO:
-//   - Validate address formatting
-//   - Support ACL token authentication
-//   - Support TLS configuration
-//   - Decide on session vs KV-based liveness tracking
-type ConsulConfig struct{}
+type ConsulConfig struct {
+	// Address is the host:port of the Consul agent's HTTP API.
+	Address string `yaml:"address" toml:"address"`
+
+	// Token is the ACL token sent with every request. Empty uses the
+	// agent's default token.
+	Token string `yaml:"token" toml:"token"`
+
+	// Datacenter selects the datacenter whose KV store holds the registry.
+	// Empty uses the agent's own datacenter.
+	Datacenter string `yaml:"datacenter" toml:"datacenter"`
+
+	// Prefix is prepended to every KV key. Empty uses "aero-arc-registry/".
+	Prefix string `yaml:"prefix" toml:"prefix"`
+
+	// TLS defines TLS settings for the connection to the Consul agent.
+	TLS ClientTLSConfig `yaml:"tls" toml:"tls"`
+
+	// Catalog optionally publishes live relays as a Consul service.
+	Catalog ConsulCatalogConfig `yaml:"catalog" toml:"catalog"`
+}
+
+// ConsulCatalogConfig defines how relays are published to the Consul
+// catalog. Each relay becomes an instance of ServiceName, registered
+// through one agent with a TTL check that relay heartbeats keep passing.
+type ConsulCatalogConfig struct {
+	// Enabled determines whether relays are registered as services.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+
+	// Address is the host:port of the Consul agent relays are registered
+	// through. Services belong to the agent that registered them, so every
+	// replica must name the same agent; otherwise each replica's agent
+	// holds its own instance of a relay and its own TTL check, which only
+	// that replica's heartbeats pass. Empty uses ConsulConfig.Address,
+	// which is only correct when all replicas share it.
+	Address string `yaml:"address" toml:"address"`
+
+	// ServiceName is the catalog service relays register under. Empty uses
+	// "aero-arc-relay".
+	ServiceName string `yaml:"service_name" toml:"service_name"`
+
+	// Tags are attached to every relay service instance.
+	Tags []string `yaml:"tags" toml:"tags"`
+
+	// DeregisterAfter lets Consul remove a relay whose check has been
+	// critical this long. It covers relays the registry never removes
+	// explicitly, such as ones whose session expired first or that outlived
+	// the registry itself. Zero uses a default of 5 minutes.
+	DeregisterAfter time.Duration `yaml:"deregister_after" toml:"deregister_after"`
+}
+
+// PostgresConfig defines configuration for the PostgreSQL registry backend.
+type PostgresConfig struct {
+	// Address is the host:port of the PostgreSQL server.
+	Address string `yaml:"address" toml:"address"`
+
+	// Database is the database holding the registry tables. Empty uses
+	// the server default, a database named after the user.
+	Database string `yaml:"database" toml:"database"`
+
+	// User is the role the registry connects as.
+	User string `yaml:"user" toml:"user"`
+
+	// Password authenticates User with SCRAM-SHA-256, MD5 or cleartext
+	// password authentication, whichever the server requests.
+	Password string `yaml:"password" toml:"password"`
+
+	// MaxConns is the maximum number of pooled connections. Zero uses a
+	// default of 10. Each Watch holds one more connection for LISTEN.
+	MaxConns int `yaml:"max_conns" toml:"max_conns"`
+
+	// DialTimeout bounds connecting and authenticating. Zero uses a
+	// default of 5 seconds.
+	DialTimeout time.Duration `yaml:"dial_timeout" toml:"dial_timeout"`
+
+	// TLS defines TLS settings for the connection to the server. When
+	// enabled, TLS is required and the connection fails if the server
+	// refuses it.
+	TLS ClientTLSConfig `yaml:"tls" toml:"tls"`
+}
 
 // MemoryConfig defines configuration for the in-memory registry backend.
 //
 // TODO:
-//   - Add optional capacity limits
 //   - Add debug logging / metrics toggles
-type MemoryConfig struct{}
-
-func ParseRegistryBackend(backend string) (RegistryBackend, error) {
-	if registryBackend, ok := registryMap[backend]; ok {
-		return registryBackend, nil
-	}
+type MemoryConfig struct {
+	// MaxRelays caps the number of relays held in memory. Zero means unlimited.
+	MaxRelays int `yaml:"max_relays" toml:"max_relays"`
 
-	return "", fmt.Errorf("%w: %s", ErrUnsupportedBackend, backend)
+	// MaxAgents caps the number of agents held in memory. Zero means unlimited.
+	MaxAgents int `yaml:"max_agents" toml:"max_agents"`
 }
 
-func (c *Config) Validate() error {
-	switch c.Backend.Type {
-	case RedisRegistryBackend:
-		if c.Backend.Redis == nil {
-			return ErrRedisConfigNil
-		}
+// FileConfig defines configuration for the embedded file registry backend.
+type FileConfig struct {
+	// Path is the registry file. It is created if missing. Path+".tmp" is
+	// used while compacting.
+	Path string `yaml:"path" toml:"path"`
 
-		if err := c.Backend.Redis.Validate(); err != nil {
-			return fmt.Errorf("redis config invalid: %w", err)
-		}
-	case MemoryRegistryBackend, EtcdRegistryBackend, ConsulRegistryBackend:
-	default:
-		return fmt.Errorf("unknown registry backend: %s", c.Backend.Type)
-	}
+	// CompactInterval is how often the file is rewritten to drop superseded
+	// records. Zero uses a default of 5 minutes.
+	CompactInterval time.Duration `yaml:"compact_interval" toml:"compact_interval"`
+}
 
+// Validate checks every section except the backend's, which the driver
+// registered for c.Backend.Type validates.
+func (c *Config) Validate() error {
 	if err := c.GRPC.Validate(); err != nil {
 		return fmt.Errorf("GRPC Config invalid: %w", err)
 	}
@@ -149,37 +451,178 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("TTL Config invalid: %w", err)
 	}
 
+	if err := c.Reaper.Validate(); err != nil {
+		return fmt.Errorf("Reaper Config invalid: %w", err)
+	}
+
+	if err := c.Watch.Validate(); err != nil {
+		return fmt.Errorf("Watch Config invalid: %w", err)
+	}
+
 	return nil
 }
 
 func (r *RedisConfig) Validate() error {
-	if r.Address == "" {
-		return ErrRedisAddrEmpty
-	}
+	switch {
+	case len(r.Cluster.Addrs) > 0:
+		if r.Sentinel.MasterName != "" {
+			return ErrRedisClusterSentinel
+		}
+		if r.DB != 0 {
+			return ErrRedisClusterDB
+		}
+	case r.Sentinel.MasterName != "":
+		if len(r.Sentinel.Addrs) == 0 {
+			return ErrRedisSentinelAddrsEmpty
+		}
+	default:
+		if r.Address == "" {
+			return ErrRedisAddrEmpty
+		}
 
-	if r.Port <= 0 {
-		return ErrRedisPortInvalid
+		if r.Port <= 0 {
+			return ErrRedisPortInvalid
+		}
 	}
 
 	if r.DB < 0 {
 		return ErrRedisDBInvalid
 	}
 
+	// Braces would introduce a competing cluster hash tag.
+	if strings.ContainsAny(r.Namespace, "{} \t\r\n") {
+		return ErrRedisNamespaceInvalid
+	}
+
+	if r.PoolSize < 0 {
+		return ErrRedisPoolSizeInvalid
+	}
+
+	if r.MinIdleConns < 0 || r.MaxIdleConns < 0 {
+		return ErrRedisIdleConnsInvalid
+	}
+
+	if r.MaxIdleConns > 0 && r.MinIdleConns > r.MaxIdleConns {
+		return ErrRedisIdleConnsInvalid
+	}
+
+	if r.HealthCheckAfter < 0 {
+		return ErrRedisHealthCheckInvalid
+	}
+
+	if r.TLS.Enabled && (r.TLS.CertPath == "") != (r.TLS.KeyPath == "") {
+		return ErrRedisTLSClientCertIncomplete
+	}
+
 	return nil
 }
 
 func (c *EtcdConfig) Validate() error {
-	// TODO: implement etcd config validation
+	if len(c.Endpoints) == 0 {
+		return ErrEtcdEndpointsEmpty
+	}
+
+	for _, endpoint := range c.Endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || host == "" || port == "" {
+			return fmt.Errorf("%w: %q", ErrEtcdEndpointInvalid, endpoint)
+		}
+	}
+
+	if c.DialTimeout < 0 {
+		return ErrEtcdDialTimeoutInvalid
+	}
+
+	if c.TLS.Enabled && (c.TLS.CertPath == "") != (c.TLS.KeyPath == "") {
+		return ErrEtcdTLSClientCertIncomplete
+	}
+
 	return nil
 }
 
 func (c *ConsulConfig) Validate() error {
-	// TODO: implement consul config validation
+	if c.Address == "" {
+		return ErrConsulAddrEmpty
+	}
+
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil || host == "" || port == "" {
+		return fmt.Errorf("%w: %q", ErrConsulAddrInvalid, c.Address)
+	}
+
+	// Consul rejects keys with a leading slash.
+	if strings.HasPrefix(c.Prefix, "/") {
+		return ErrConsulPrefixInvalid
+	}
+
+	if c.TLS.Enabled && (c.TLS.CertPath == "") != (c.TLS.KeyPath == "") {
+		return ErrConsulTLSClientCertIncomplete
+	}
+
+	if c.Catalog.Address != "" {
+		host, port, err := net.SplitHostPort(c.Catalog.Address)
+		if err != nil || host == "" || port == "" {
+			return fmt.Errorf("%w: %q", ErrConsulCatalogAddrInvalid, c.Catalog.Address)
+		}
+	}
+
+	if c.Catalog.DeregisterAfter < 0 {
+		return ErrConsulCatalogDeregisterInvalid
+	}
+
+	return nil
+}
+
+func (c *PostgresConfig) Validate() error {
+	if c.Address == "" {
+		return ErrPostgresAddrEmpty
+	}
+
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil || host == "" || port == "" {
+		return fmt.Errorf("%w: %q", ErrPostgresAddrInvalid, c.Address)
+	}
+
+	if c.User == "" {
+		return ErrPostgresUserEmpty
+	}
+
+	if c.MaxConns < 0 {
+		return ErrPostgresMaxConnsInvalid
+	}
+
+	if c.DialTimeout < 0 {
+		return ErrPostgresDialTimeoutInvalid
+	}
+
+	if c.TLS.Enabled && (c.TLS.CertPath == "") != (c.TLS.KeyPath == "") {
+		return ErrPostgresTLSClientCertIncomplete
+	}
+
 	return nil
 }
 
 func (c *MemoryConfig) Validate() error {
-	// TODO: implement memory config validation
+	if c.MaxRelays < 0 {
+		return ErrMemoryMaxRelaysInvalid
+	}
+
+	if c.MaxAgents < 0 {
+		return ErrMemoryMaxAgentsInvalid
+	}
+
+	return nil
+}
+
+func (c *FileConfig) Validate() error {
+	if c.Path == "" {
+		return ErrFilePathEmpty
+	}
+
+	if c.CompactInterval < 0 {
+		return ErrFileCompactIntervalInvalid
+	}
+
 	return nil
 }
 
@@ -188,19 +631,126 @@ func (g *GRPCConfig) Validate() error {
 		return ErrGRPCPortInvalid
 	}
 
-	if g.TLS.Enabled {
-		if g.TLS.CertPath == "" {
-			return ErrTLSCertPathMissing
+	if err := g.Auth.Validate(); err != nil {
+		return err
+	}
+
+	if err := g.JWT.Validate(); err != nil {
+		return err
+	}
+
+	if !g.TLS.Enabled {
+		if g.TLS.ClientCAPath != "" {
+			return ErrTLSClientCAWithoutTLS
+		}
+
+		return nil
+	}
+
+	if g.TLS.CertPath == "" {
+		return ErrTLSCertPathMissing
+	}
+
+	if g.TLS.KeyPath == "" {
+		return ErrTLSKeyPathMissing
+	}
+
+	if _, err := ParseTLSVersion(g.TLS.MinVersion); err != nil {
+		return err
+	}
+
+	if _, err := ParseCipherSuites(g.TLS.CipherSuites); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthConfig) Validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if len(a.RelayPrincipals) == 0 && len(a.ControlPlanePrincipals) == 0 {
+		return ErrAuthPolicyEmpty
+	}
+
+	for _, pattern := range a.RelayPrincipals {
+		if strings.Count(pattern, RelayIDPlaceholder) != 1 {
+			return fmt.Errorf("%w: %q", ErrAuthRelayPrincipalInvalid, pattern)
 		}
+	}
 
-		if g.TLS.KeyPath == "" {
-			return ErrTLSKeyPathMissing
+	for _, name := range a.ControlPlanePrincipals {
+		if name == "" {
+			return ErrAuthControlPlanePrincipalEmpty
 		}
 	}
 
 	return nil
 }
 
+func (j *JWTConfig) Validate() error {
+	if !j.Enabled {
+		return nil
+	}
+
+	if j.Secret == "" && j.JWKSPath == "" {
+		return ErrJWTKeyMissing
+	}
+
+	if j.JWKSReloadInterval < 0 {
+		return ErrJWTJWKSReloadIntervalInvalid
+	}
+
+	if j.Issuer == "" {
+		return ErrJWTIssuerEmpty
+	}
+
+	if j.Audience == "" {
+		return ErrJWTAudienceEmpty
+	}
+
+	return nil
+}
+
+// ParseTLSVersion converts a TLSConfig.MinVersion value to its crypto/tls
+// constant.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrTLSMinVersionInvalid, version)
+	}
+}
+
+// ParseCipherSuites converts TLSConfig.CipherSuites names to their
+// crypto/tls IDs. Only suites Go considers secure are accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrTLSCipherSuiteInvalid, name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 func (t *TTLConfig) Validate() error {
 	if t.Agent <= 0 {
 		return ErrTTLAgentInvalid
@@ -212,3 +762,27 @@ func (t *TTLConfig) Validate() error {
 
 	return nil
 }
+
+func (r *ReaperConfig) Validate() error {
+	if r.Interval < 0 {
+		return ErrReaperIntervalInvalid
+	}
+
+	if r.Jitter < 0 {
+		return ErrReaperJitterInvalid
+	}
+
+	return nil
+}
+
+func (w *WatchConfig) Validate() error {
+	if w.Interval < 0 {
+		return ErrWatchIntervalInvalid
+	}
+
+	if w.History < 0 {
+		return ErrWatchHistoryInvalid
+	}
+
+	return nil
+}
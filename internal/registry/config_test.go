@@ -6,59 +6,6 @@ import (
 	"time"
 )
 
-func TestParseRegistryBackend(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name    string
-		input   string
-		want    RegistryBackend
-		wantErr error
-	}{
-		{
-			name:  "redis backend",
-			input: "redis",
-			want:  RedisRegistryBackend,
-		},
-		{
-			name:  "etcd backend",
-			input: "etcd",
-			want:  EtcdRegistryBackend,
-		},
-		{
-			name:  "consul backend",
-			input: "consul",
-			want:  ConsulRegistryBackend,
-		},
-		{
-			name:  "memory backend",
-			input: "memory",
-			want:  MemoryRegistryBackend,
-		},
-		{
-			name:    "unsupported backend",
-			input:   "unknown",
-			want:    "",
-			wantErr: ErrUnsupportedBackend,
-		},
-	}
-
-	for _, test := range tests {
-		test := test
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
-
-			got, err := ParseRegistryBackend(test.input)
-			if !errors.Is(err, test.wantErr) {
-				t.Fatalf("expected error %v, got %v", test.wantErr, err)
-			}
-			if got != test.want {
-				t.Fatalf("expected backend %q, got %q", test.want, got)
-			}
-		})
-	}
-}
-
 func TestConfigValidate(t *testing.T) {
 	t.Parallel()
 
@@ -75,13 +22,6 @@ func TestConfigValidate(t *testing.T) {
 		Relay: 5 * time.Second,
 		Agent: 10 * time.Second,
 	}
-	validRedis := &RedisConfig{
-		Address:  "localhost",
-		Port:     6379,
-		Username: "user",
-		Password: "pass",
-		DB:       0,
-	}
 
 	tests := []struct {
 		name    string
@@ -100,30 +40,6 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: nil,
 		},
-		{
-			name: "redis backend with valid redis config",
-			config: Config{
-				Backend: BackendConfig{
-					Type:  RedisRegistryBackend,
-					Redis: validRedis,
-				},
-				GRPC: validGRPC,
-				TTL:  validTTL,
-			},
-			wantErr: nil,
-		},
-		{
-			name: "redis backend with nil redis config",
-			config: Config{
-				Backend: BackendConfig{
-					Type:  RedisRegistryBackend,
-					Redis: nil,
-				},
-				GRPC: validGRPC,
-				TTL:  validTTL,
-			},
-			wantErr: ErrRedisConfigNil,
-		},
 		{
 			name: "invalid grpc listen port",
 			config: Config{
@@ -204,6 +120,64 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: ErrTTLRelayInvalid,
 		},
+		{
+			name: "invalid reaper interval",
+			config: Config{
+				Backend: BackendConfig{
+					Type: MemoryRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+				Reaper: ReaperConfig{
+					Interval: -time.Second,
+				},
+			},
+			wantErr: ErrReaperIntervalInvalid,
+		},
+		{
+			name: "invalid reaper jitter",
+			config: Config{
+				Backend: BackendConfig{
+					Type: MemoryRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+				Reaper: ReaperConfig{
+					Interval: time.Second,
+					Jitter:   -time.Second,
+				},
+			},
+			wantErr: ErrReaperJitterInvalid,
+		},
+		{
+			name: "invalid watch interval",
+			config: Config{
+				Backend: BackendConfig{
+					Type: MemoryRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+				Watch: WatchConfig{
+					Interval: -time.Second,
+				},
+			},
+			wantErr: ErrWatchIntervalInvalid,
+		},
+		{
+			name: "invalid watch history",
+			config: Config{
+				Backend: BackendConfig{
+					Type: MemoryRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+				Watch: WatchConfig{
+					Interval: time.Second,
+					History:  -1,
+				},
+			},
+			wantErr: ErrWatchHistoryInvalid,
+		},
 	}
 
 	for _, test := range tests {
@@ -263,6 +237,304 @@ func TestRedisConfigValidate(t *testing.T) {
 			},
 			wantErr: ErrRedisDBInvalid,
 		},
+		{
+			name: "invalid pool size",
+			config: RedisConfig{
+				Address:  "localhost",
+				Port:     6379,
+				PoolSize: -1,
+			},
+			wantErr: ErrRedisPoolSizeInvalid,
+		},
+		{
+			name: "min idle exceeds max idle",
+			config: RedisConfig{
+				Address:      "localhost",
+				Port:         6379,
+				MinIdleConns: 4,
+				MaxIdleConns: 2,
+			},
+			wantErr: ErrRedisIdleConnsInvalid,
+		},
+		{
+			name: "invalid health check interval",
+			config: RedisConfig{
+				Address:          "localhost",
+				Port:             6379,
+				HealthCheckAfter: -time.Second,
+			},
+			wantErr: ErrRedisHealthCheckInvalid,
+		},
+		{
+			name: "tls client cert without key",
+			config: RedisConfig{
+				Address: "localhost",
+				Port:    6379,
+				TLS: ClientTLSConfig{
+					Enabled:  true,
+					CertPath: "/tmp/client.pem",
+				},
+			},
+			wantErr: ErrRedisTLSClientCertIncomplete,
+		},
+		{
+			name: "sentinel without addresses",
+			config: RedisConfig{
+				Sentinel: RedisSentinelConfig{MasterName: "mymaster"},
+			},
+			wantErr: ErrRedisSentinelAddrsEmpty,
+		},
+		{
+			name: "sentinel ignores address",
+			config: RedisConfig{
+				Sentinel: RedisSentinelConfig{
+					MasterName: "mymaster",
+					Addrs:      []string{"localhost:26379"},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "namespace with hash tag",
+			config: RedisConfig{
+				Address:   "localhost",
+				Port:      6379,
+				Namespace: "prod{x}",
+			},
+			wantErr: ErrRedisNamespaceInvalid,
+		},
+		{
+			name: "namespace",
+			config: RedisConfig{
+				Address:   "localhost",
+				Port:      6379,
+				Namespace: "prod",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "cluster ignores address",
+			config: RedisConfig{
+				Cluster: RedisClusterConfig{Addrs: []string{"localhost:7000"}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "cluster with sentinel",
+			config: RedisConfig{
+				Cluster: RedisClusterConfig{Addrs: []string{"localhost:7000"}},
+				Sentinel: RedisSentinelConfig{
+					MasterName: "mymaster",
+					Addrs:      []string{"localhost:26379"},
+				},
+			},
+			wantErr: ErrRedisClusterSentinel,
+		},
+		{
+			name: "cluster with non-zero db",
+			config: RedisConfig{
+				Cluster: RedisClusterConfig{Addrs: []string{"localhost:7000"}},
+				DB:      1,
+			},
+			wantErr: ErrRedisClusterDB,
+		},
+		{
+			name: "tls with ca only",
+			config: RedisConfig{
+				Address: "localhost",
+				Port:    6379,
+				TLS: ClientTLSConfig{
+					Enabled: true,
+					CAPath:  "/tmp/ca.pem",
+				},
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.config.Validate()
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEtcdConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  EtcdConfig
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			config:  EtcdConfig{Endpoints: []string{"etcd-0:2379", "etcd-1:2379"}},
+			wantErr: nil,
+		},
+		{
+			name:    "no endpoints",
+			config:  EtcdConfig{},
+			wantErr: ErrEtcdEndpointsEmpty,
+		},
+		{
+			name:    "endpoint without port",
+			config:  EtcdConfig{Endpoints: []string{"etcd-0"}},
+			wantErr: ErrEtcdEndpointInvalid,
+		},
+		{
+			name:    "endpoint with scheme",
+			config:  EtcdConfig{Endpoints: []string{"http://etcd-0:2379"}},
+			wantErr: ErrEtcdEndpointInvalid,
+		},
+		{
+			name:    "negative dial timeout",
+			config:  EtcdConfig{Endpoints: []string{"etcd-0:2379"}, DialTimeout: -time.Second},
+			wantErr: ErrEtcdDialTimeoutInvalid,
+		},
+		{
+			name: "tls key without cert",
+			config: EtcdConfig{
+				Endpoints: []string{"etcd-0:2379"},
+				TLS:       ClientTLSConfig{Enabled: true, KeyPath: "/tmp/client-key.pem"},
+			},
+			wantErr: ErrEtcdTLSClientCertIncomplete,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.config.Validate()
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConsulConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  ConsulConfig
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			config:  ConsulConfig{Address: "consul:8500", Token: "token", Datacenter: "dc1", Prefix: "registry/"},
+			wantErr: nil,
+		},
+		{
+			name:    "no address",
+			config:  ConsulConfig{},
+			wantErr: ErrConsulAddrEmpty,
+		},
+		{
+			name:    "address without port",
+			config:  ConsulConfig{Address: "consul"},
+			wantErr: ErrConsulAddrInvalid,
+		},
+		{
+			name:    "prefix with leading slash",
+			config:  ConsulConfig{Address: "consul:8500", Prefix: "/registry/"},
+			wantErr: ErrConsulPrefixInvalid,
+		},
+		{
+			name: "tls cert without key",
+			config: ConsulConfig{
+				Address: "consul:8501",
+				TLS:     ClientTLSConfig{Enabled: true, CertPath: "/tmp/client.pem"},
+			},
+			wantErr: ErrConsulTLSClientCertIncomplete,
+		},
+		{
+			name: "invalid catalog address",
+			config: ConsulConfig{
+				Address: "consul:8500",
+				Catalog: ConsulCatalogConfig{Enabled: true, Address: "consul-catalog"},
+			},
+			wantErr: ErrConsulCatalogAddrInvalid,
+		},
+		{
+			name: "negative catalog deregister timeout",
+			config: ConsulConfig{
+				Address: "consul:8500",
+				Catalog: ConsulCatalogConfig{Enabled: true, DeregisterAfter: -time.Minute},
+			},
+			wantErr: ErrConsulCatalogDeregisterInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.config.Validate()
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostgresConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  PostgresConfig
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			config:  PostgresConfig{Address: "postgres:5432", Database: "registry", User: "registry", Password: "secret"},
+			wantErr: nil,
+		},
+		{
+			name:    "no address",
+			config:  PostgresConfig{User: "registry"},
+			wantErr: ErrPostgresAddrEmpty,
+		},
+		{
+			name:    "address without port",
+			config:  PostgresConfig{Address: "postgres", User: "registry"},
+			wantErr: ErrPostgresAddrInvalid,
+		},
+		{
+			name:    "no user",
+			config:  PostgresConfig{Address: "postgres:5432"},
+			wantErr: ErrPostgresUserEmpty,
+		},
+		{
+			name:    "negative max conns",
+			config:  PostgresConfig{Address: "postgres:5432", User: "registry", MaxConns: -1},
+			wantErr: ErrPostgresMaxConnsInvalid,
+		},
+		{
+			name:    "negative dial timeout",
+			config:  PostgresConfig{Address: "postgres:5432", User: "registry", DialTimeout: -time.Second},
+			wantErr: ErrPostgresDialTimeoutInvalid,
+		},
+		{
+			name: "tls key without cert",
+			config: PostgresConfig{
+				Address: "postgres:5432",
+				User:    "registry",
+				TLS:     ClientTLSConfig{Enabled: true, KeyPath: "/tmp/client.key"},
+			},
+			wantErr: ErrPostgresTLSClientCertIncomplete,
+		},
 	}
 
 	for _, test := range tests {
@@ -331,6 +603,186 @@ func TestGRPCConfigValidate(t *testing.T) {
 			},
 			wantErr: ErrTLSKeyPathMissing,
 		},
+		{
+			name: "mutual tls",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				TLS: TLSConfig{
+					Enabled:      true,
+					CertPath:     "cert.pem",
+					KeyPath:      "key.pem",
+					ClientCAPath: "clients.pem",
+					MinVersion:   "1.3",
+					CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "client ca without tls",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				TLS: TLSConfig{
+					Enabled:      false,
+					ClientCAPath: "clients.pem",
+				},
+			},
+			wantErr: ErrTLSClientCAWithoutTLS,
+		},
+		{
+			name: "invalid min version",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				TLS: TLSConfig{
+					Enabled:    true,
+					CertPath:   "cert.pem",
+					KeyPath:    "key.pem",
+					MinVersion: "1.0",
+				},
+			},
+			wantErr: ErrTLSMinVersionInvalid,
+		},
+		{
+			name: "insecure cipher suite",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				TLS: TLSConfig{
+					Enabled:      true,
+					CertPath:     "cert.pem",
+					KeyPath:      "key.pem",
+					CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+				},
+			},
+			wantErr: ErrTLSCipherSuiteInvalid,
+		},
+		{
+			name: "auth policy",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				Auth: AuthConfig{
+					Enabled:                true,
+					RelayPrincipals:        []string{"spiffe://aero-arc/relay/{relay_id}"},
+					ControlPlanePrincipals: []string{"spiffe://aero-arc/control-plane"},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "auth without principals",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				Auth:          AuthConfig{Enabled: true},
+			},
+			wantErr: ErrAuthPolicyEmpty,
+		},
+		{
+			name: "auth relay principal without placeholder",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				Auth: AuthConfig{
+					Enabled:         true,
+					RelayPrincipals: []string{"spiffe://aero-arc/relay"},
+				},
+			},
+			wantErr: ErrAuthRelayPrincipalInvalid,
+		},
+		{
+			name: "auth empty control plane principal",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				Auth: AuthConfig{
+					Enabled:                true,
+					ControlPlanePrincipals: []string{""},
+				},
+			},
+			wantErr: ErrAuthControlPlanePrincipalEmpty,
+		},
+		{
+			name: "jwt",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				JWT: JWTConfig{
+					Enabled:            true,
+					JWKSPath:           "jwks.json",
+					JWKSReloadInterval: time.Minute,
+					Issuer:             "https://auth.aero-arc",
+					Audience:           "aero-arc-registry",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "jwt without keys",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				JWT: JWTConfig{
+					Enabled:  true,
+					Issuer:   "https://auth.aero-arc",
+					Audience: "aero-arc-registry",
+				},
+			},
+			wantErr: ErrJWTKeyMissing,
+		},
+		{
+			name: "jwt negative reload interval",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				JWT: JWTConfig{
+					Enabled:            true,
+					JWKSPath:           "jwks.json",
+					JWKSReloadInterval: -time.Second,
+					Issuer:             "https://auth.aero-arc",
+					Audience:           "aero-arc-registry",
+				},
+			},
+			wantErr: ErrJWTJWKSReloadIntervalInvalid,
+		},
+		{
+			name: "jwt without issuer",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				JWT: JWTConfig{
+					Enabled:  true,
+					Secret:   "secret",
+					Audience: "aero-arc-registry",
+				},
+			},
+			wantErr: ErrJWTIssuerEmpty,
+		},
+		{
+			name: "jwt without audience",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				JWT: JWTConfig{
+					Enabled: true,
+					Secret:  "secret",
+					Issuer:  "https://auth.aero-arc",
+				},
+			},
+			wantErr: ErrJWTAudienceEmpty,
+		},
+		{
+			name: "auth disabled skips policy",
+			config: GRPCConfig{
+				ListenAddress: "127.0.0.1",
+				ListenPort:    50051,
+				Auth:          AuthConfig{RelayPrincipals: []string{"relay"}},
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, test := range tests {
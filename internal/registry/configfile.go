@@ -0,0 +1,186 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is a parsed YAML or TOML configuration file. Keys mirror the
+// Config structs in snake_case, e.g. grpc.listen_port or
+// backend.redis.tls.ca_path, and durations are strings such as "30s".
+//
+// The options of a backend registered from outside this module live under
+// backend.options and are decoded into cfg.Backend.Options, which the
+// caller allocates from the driver's Options function.
+type ConfigFile struct {
+	path   string
+	format configFormat
+	tree   map[string]any
+}
+
+// configFormat parses and strictly decodes one configuration file syntax.
+// Decoding goes through a generic tree so backend options can be split off
+// and decoded once the backend, and so the options type, is known.
+type configFormat interface {
+	parse(data []byte, tree *map[string]any) error
+	marshal(tree map[string]any) ([]byte, error)
+	decodeStrict(data []byte, v any) error
+}
+
+// ReadConfigFile parses the file at path. The syntax is chosen by extension:
+// .yaml or .yml for YAML and .toml for TOML.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	var format configFormat
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = yamlFormat{}
+	case ".toml":
+		format = tomlFormat{}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrConfigFileFormat, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ConfigFile{path: path, format: format}
+	if err := format.parse(data, &file.tree); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrConfigFileInvalid, path, err)
+	}
+
+	return file, nil
+}
+
+// BackendType returns backend.type from the file, or an empty value when
+// the file does not set it.
+func (f *ConfigFile) BackendType() RegistryBackend {
+	backend, _ := f.tree["backend"].(map[string]any)
+	backendType, _ := backend["type"].(string)
+
+	return RegistryBackend(backendType)
+}
+
+// Apply decodes the file over cfg. Keys the file leaves out keep their
+// current value, so cfg typically already holds defaults. Unknown keys are
+// an error, as is backend.options when cfg.Backend.Options is nil.
+func (f *ConfigFile) Apply(cfg *Config) error {
+	tree := make(map[string]any, len(f.tree))
+	for key, value := range f.tree {
+		tree[key] = value
+	}
+
+	var options map[string]any
+	if backend, ok := tree["backend"].(map[string]any); ok {
+		if raw, ok := backend["options"]; ok {
+			if options, ok = raw.(map[string]any); !ok {
+				return fmt.Errorf("%w: %s: backend.options must be a table", ErrConfigFileInvalid, f.path)
+			}
+
+			rest := make(map[string]any, len(backend))
+			for key, value := range backend {
+				if key != "options" {
+					rest[key] = value
+				}
+			}
+			tree["backend"] = rest
+		}
+	}
+
+	if err := f.decode(tree, cfg); err != nil {
+		return err
+	}
+
+	if options == nil {
+		return nil
+	}
+
+	if cfg.Backend.Options == nil {
+		return fmt.Errorf("%w: %s: backend %s does not take options", ErrConfigFileInvalid, f.path, cfg.Backend.Type)
+	}
+
+	return f.decode(options, cfg.Backend.Options)
+}
+
+func (f *ConfigFile) decode(tree map[string]any, v any) error {
+	if len(tree) == 0 {
+		return nil
+	}
+
+	data, err := f.format.marshal(tree)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrConfigFileInvalid, f.path, err)
+	}
+
+	if err := f.format.decodeStrict(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrConfigFileInvalid, f.path, err)
+	}
+
+	return nil
+}
+
+type yamlFormat struct{}
+
+func (yamlFormat) parse(data []byte, tree *map[string]any) error {
+	return yaml.Unmarshal(data, tree)
+}
+
+func (yamlFormat) marshal(tree map[string]any) ([]byte, error) {
+	return yaml.Marshal(tree)
+}
+
+func (yamlFormat) decodeStrict(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+type tomlFormat struct{}
+
+func (tomlFormat) parse(data []byte, tree *map[string]any) error {
+	_, err := toml.Decode(string(data), tree)
+	return err
+}
+
+func (tomlFormat) marshal(tree map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(tree); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (tomlFormat) decodeStrict(data []byte, v any) error {
+	md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(v)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		slices.Sort(keys)
+
+		return fmt.Errorf("unknown keys %s", strings.Join(keys, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestConfigFileApply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+	}{
+		{
+			name: "yaml",
+			file: "registry.yaml",
+			contents: `
+backend:
+  type: etcd
+  etcd:
+    endpoints: [etcd-0:2379, etcd-1:2379]
+    tls:
+      enabled: true
+      ca_path: /etc/ca.pem
+grpc:
+  listen_port: 6000
+ttl:
+  relay: 15s
+log:
+  level: debug
+`,
+		},
+		{
+			name: "toml",
+			file: "registry.toml",
+			contents: `
+[backend]
+type = "etcd"
+
+[backend.etcd]
+endpoints = ["etcd-0:2379", "etcd-1:2379"]
+
+[backend.etcd.tls]
+enabled = true
+ca_path = "/etc/ca.pem"
+
+[grpc]
+listen_port = 6000
+
+[ttl]
+relay = "15s"
+
+[log]
+level = "debug"
+`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := ReadConfigFile(writeConfigFile(t, test.file, test.contents))
+			if err != nil {
+				t.Fatalf("read config file: %v", err)
+			}
+			if got := file.BackendType(); got != EtcdRegistryBackend {
+				t.Fatalf("expected backend type %q, got %q", EtcdRegistryBackend, got)
+			}
+
+			cfg := &Config{
+				Backend: BackendConfig{Etcd: &EtcdConfig{Prefix: "/default/"}},
+				GRPC:    GRPCConfig{ListenAddress: "0.0.0.0", ListenPort: 50051},
+				TTL:     TTLConfig{Relay: 30 * time.Second, Agent: 30 * time.Second},
+			}
+			if err := file.Apply(cfg); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+
+			if cfg.Backend.Type != EtcdRegistryBackend {
+				t.Fatalf("expected backend type %q, got %q", EtcdRegistryBackend, cfg.Backend.Type)
+			}
+			if !slices.Equal(cfg.Backend.Etcd.Endpoints, []string{"etcd-0:2379", "etcd-1:2379"}) {
+				t.Fatalf("unexpected endpoints %v", cfg.Backend.Etcd.Endpoints)
+			}
+			if !cfg.Backend.Etcd.TLS.Enabled || cfg.Backend.Etcd.TLS.CAPath != "/etc/ca.pem" {
+				t.Fatalf("unexpected etcd tls %+v", cfg.Backend.Etcd.TLS)
+			}
+			if cfg.GRPC.ListenPort != 6000 || cfg.TTL.Relay != 15*time.Second {
+				t.Fatalf("expected file values to apply, got %+v %+v", cfg.GRPC, cfg.TTL)
+			}
+
+			if cfg.Log.Level != slog.LevelDebug {
+				t.Fatalf("expected log level debug, got %v", cfg.Log.Level)
+			}
+
+			// Keys the file leaves out keep their prior values.
+			if cfg.Backend.Etcd.Prefix != "/default/" || cfg.GRPC.ListenAddress != "0.0.0.0" || cfg.TTL.Agent != 30*time.Second {
+				t.Fatalf("expected omitted keys to be preserved, got %+v %+v", cfg.Backend.Etcd, cfg.TTL)
+			}
+		})
+	}
+}
+
+func TestConfigFileUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+	}{
+		{
+			name:     "yaml",
+			file:     "registry.yml",
+			contents: "ttl:\n  relai: 15s\n",
+		},
+		{
+			name:     "toml",
+			file:     "registry.toml",
+			contents: "[ttl]\nrelai = \"15s\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := ReadConfigFile(writeConfigFile(t, test.file, test.contents))
+			if err != nil {
+				t.Fatalf("read config file: %v", err)
+			}
+
+			err = file.Apply(&Config{})
+			if !errors.Is(err, ErrConfigFileInvalid) {
+				t.Fatalf("expected ErrConfigFileInvalid, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "relai") {
+				t.Fatalf("expected error to name the unknown key, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ReadConfigFile(writeConfigFile(t, "registry.json", "{}")); !errors.Is(err, ErrConfigFileFormat) {
+		t.Fatalf("expected ErrConfigFileFormat, got %v", err)
+	}
+
+	if _, err := ReadConfigFile(writeConfigFile(t, "registry.toml", "[ttl\n")); !errors.Is(err, ErrConfigFileInvalid) {
+		t.Fatalf("expected ErrConfigFileInvalid, got %v", err)
+	}
+
+	file, err := ReadConfigFile(writeConfigFile(t, "registry.yaml", "backend:\n  type: memory\n  options:\n    size: 1\n"))
+	if err != nil {
+		t.Fatalf("read config file: %v", err)
+	}
+	if err := file.Apply(&Config{}); !errors.Is(err, ErrConfigFileInvalid) {
+		t.Fatalf("expected options on a built-in backend to be rejected, got %v", err)
+	}
+}
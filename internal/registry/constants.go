@@ -1,20 +1,10 @@
 package registry
 
 const (
-	DebugTLSCertPath = ".aeroarc/local-certs/localhost.crt"
-	DebugTLSKeyPath  = ".aeroarc/local-certs/localhost.key"
+	RedisRegistryBackend    RegistryBackend = "redis"
+	EtcdRegistryBackend     RegistryBackend = "etcd"
+	ConsulRegistryBackend   RegistryBackend = "consul"
+	MemoryRegistryBackend   RegistryBackend = "memory"
+	FileRegistryBackend     RegistryBackend = "file"
+	PostgresRegistryBackend RegistryBackend = "postgres"
 )
-
-const (
-	RedisRegistryBackend  RegistryBackend = "redis"
-	EtcdRegistryBackend   RegistryBackend = "etcd"
-	ConsulRegistryBackend RegistryBackend = "consul"
-	MemoryRegistryBackend RegistryBackend = "memory"
-)
-
-var registryMap = map[string]RegistryBackend{
-	"redis":  RedisRegistryBackend,
-	"etcd":   EtcdRegistryBackend,
-	"consul": ConsulRegistryBackend,
-	"memory": MemoryRegistryBackend,
-}
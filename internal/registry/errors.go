@@ -3,21 +3,79 @@ package registry
 import "errors"
 
 var (
-	ErrUnsupportedBackend = errors.New("unsupported registry backend")
-	ErrRedisConfigNil     = errors.New("redis config is nil")
-	ErrRedisAddrEmpty     = errors.New("redis address is empty")
-	ErrRedisPortInvalid   = errors.New("redis port must be > 0")
-	ErrRedisDBInvalid     = errors.New("redis db must be >= 0")
-	ErrGRPCPortInvalid    = errors.New("grpc port must be > 0")
-	ErrTLSCertPathMissing = errors.New("grpc tls cert path empty")
-	ErrTLSKeyPathMissing  = errors.New("grpc tls key path empty")
-	ErrTTLRelayInvalid    = errors.New("relay ttl must be > 0")
-	ErrTTLAgentInvalid    = errors.New("agent ttl must be > 0")
-	ErrNilConfig          = errors.New("registry config is nil")
-	ErrNotImplemented     = errors.New("not implemented")
+	ErrUnsupportedBackend              = errors.New("unsupported registry backend")
+	ErrRedisConfigNil                  = errors.New("redis config is nil")
+	ErrRedisAddrEmpty                  = errors.New("redis address is empty")
+	ErrRedisPortInvalid                = errors.New("redis port must be > 0")
+	ErrRedisDBInvalid                  = errors.New("redis db must be >= 0")
+	ErrRedisNamespaceInvalid           = errors.New("redis namespace must not contain braces or whitespace")
+	ErrRedisPoolSizeInvalid            = errors.New("redis pool size must be >= 0")
+	ErrRedisIdleConnsInvalid           = errors.New("redis idle conns must be >= 0 and min must not exceed max")
+	ErrRedisHealthCheckInvalid         = errors.New("redis health check interval must be >= 0")
+	ErrRedisTLSClientCertIncomplete    = errors.New("redis tls client cert and key must be set together")
+	ErrRedisSentinelAddrsEmpty         = errors.New("redis sentinel addresses are empty")
+	ErrRedisClusterSentinel            = errors.New("redis cluster and sentinel modes are mutually exclusive")
+	ErrRedisClusterDB                  = errors.New("redis cluster only supports db 0")
+	ErrEtcdConfigNil                   = errors.New("etcd config is nil")
+	ErrEtcdEndpointsEmpty              = errors.New("etcd endpoints are empty")
+	ErrEtcdEndpointInvalid             = errors.New("etcd endpoint must be host:port")
+	ErrEtcdDialTimeoutInvalid          = errors.New("etcd dial timeout must be >= 0")
+	ErrEtcdTLSClientCertIncomplete     = errors.New("etcd tls client cert and key must be set together")
+	ErrConsulConfigNil                 = errors.New("consul config is nil")
+	ErrConsulAddrEmpty                 = errors.New("consul address is empty")
+	ErrConsulAddrInvalid               = errors.New("consul address must be host:port")
+	ErrConsulPrefixInvalid             = errors.New("consul prefix must not start with a slash")
+	ErrConsulTLSClientCertIncomplete   = errors.New("consul tls client cert and key must be set together")
+	ErrConsulCatalogAddrInvalid        = errors.New("consul catalog address must be host:port")
+	ErrConsulCatalogDeregisterInvalid  = errors.New("consul catalog deregister-after must be >= 0")
+	ErrPostgresConfigNil               = errors.New("postgres config is nil")
+	ErrPostgresAddrEmpty               = errors.New("postgres address is empty")
+	ErrPostgresAddrInvalid             = errors.New("postgres address must be host:port")
+	ErrPostgresUserEmpty               = errors.New("postgres user is empty")
+	ErrPostgresMaxConnsInvalid         = errors.New("postgres max conns must be >= 0")
+	ErrPostgresDialTimeoutInvalid      = errors.New("postgres dial timeout must be >= 0")
+	ErrPostgresTLSClientCertIncomplete = errors.New("postgres tls client cert and key must be set together")
+	ErrMemoryMaxRelaysInvalid          = errors.New("memory max relays must be >= 0")
+	ErrMemoryMaxAgentsInvalid          = errors.New("memory max agents must be >= 0")
+	ErrFileConfigNil                   = errors.New("file config is nil")
+	ErrFilePathEmpty                   = errors.New("file path is empty")
+	ErrFileCompactIntervalInvalid      = errors.New("file compact interval must be >= 0")
+	ErrFileCorrupt                     = errors.New("registry file is corrupt")
+	ErrFileLocked                      = errors.New("registry file is in use by another process")
+	ErrGRPCPortInvalid                 = errors.New("grpc port must be > 0")
+	ErrTLSCertPathMissing              = errors.New("grpc tls cert path empty")
+	ErrTLSKeyPathMissing               = errors.New("grpc tls key path empty")
+	ErrTLSClientCAWithoutTLS           = errors.New("grpc tls client ca requires tls to be enabled")
+	ErrTLSMinVersionInvalid            = errors.New("grpc tls min version must be 1.2 or 1.3")
+	ErrTLSCipherSuiteInvalid           = errors.New("grpc tls cipher suite is unknown or insecure")
+	ErrAuthPolicyEmpty                 = errors.New("grpc auth requires relay or control plane principals")
+	ErrAuthRelayPrincipalInvalid       = errors.New("grpc auth relay principal must contain {relay_id} exactly once")
+	ErrAuthControlPlanePrincipalEmpty  = errors.New("grpc auth control plane principal is empty")
+	ErrJWTKeyMissing                   = errors.New("grpc jwt requires a secret or jwks path")
+	ErrJWTJWKSReloadIntervalInvalid    = errors.New("grpc jwt jwks reload interval must be >= 0")
+	ErrJWTIssuerEmpty                  = errors.New("grpc jwt issuer is empty")
+	ErrJWTAudienceEmpty                = errors.New("grpc jwt audience is empty")
+	ErrTTLRelayInvalid                 = errors.New("relay ttl must be > 0")
+	ErrTTLAgentInvalid                 = errors.New("agent ttl must be > 0")
+	ErrReaperIntervalInvalid           = errors.New("reaper interval must be >= 0")
+	ErrReaperJitterInvalid             = errors.New("reaper jitter must be >= 0")
+	ErrWatchIntervalInvalid            = errors.New("watch interval must be >= 0")
+	ErrWatchHistoryInvalid             = errors.New("watch history must be >= 0")
+	ErrConfigFileFormat                = errors.New("config file must end in .yaml, .yml or .toml")
+	ErrConfigFileInvalid               = errors.New("config file invalid")
+	ErrNilConfig                       = errors.New("registry config is nil")
+	ErrNilBackend                      = errors.New("registry backend is nil")
+	ErrNotImplemented                  = errors.New("not implemented")
+	ErrTTLRestartRequired              = errors.New("ttl change requires a restart")
 
 	ErrRelayNotRegistered = errors.New("relay not registered")
 	ErrAgentNotRegistered = errors.New("agent not registered")
 	ErrRelayIDEmpty       = errors.New("relay id is empty")
 	ErrAgentIDEmpty       = errors.New("agent id is empty")
+	ErrCapacityExceeded   = errors.New("backend capacity exceeded")
+
+	ErrWatchDisabled      = errors.New("watch is disabled")
+	ErrWatchUnsupported   = errors.New("backend does not support native watch")
+	ErrResumeTokenInvalid = errors.New("resume token is invalid")
+	ErrResumeTokenExpired = errors.New("resume token is no longer retained")
 )
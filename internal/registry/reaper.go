@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Eviction reasons reported in reaper logs.
+const (
+	EvictReasonRelayExpired = "relay_expired"
+	EvictReasonRelayGone    = "relay_gone"
+	EvictReasonAgentExpired = "agent_expired"
+)
+
+// ReaperStats is a snapshot of the evictions performed by the reaper since
+// the registry was created.
+type ReaperStats struct {
+	Sweeps        uint64
+	RelaysEvicted uint64
+
+	// PlacementsInvalidated counts agents removed because the relay they
+	// were placed on expired or no longer exists.
+	PlacementsInvalidated uint64
+
+	// AgentsExpired counts agents removed because their own TTL lapsed.
+	AgentsExpired uint64
+}
+
+type reaperCounters struct {
+	sweeps                atomic.Uint64
+	relaysEvicted         atomic.Uint64
+	placementsInvalidated atomic.Uint64
+	agentsExpired         atomic.Uint64
+}
+
+// ReaperStats returns the eviction counters accumulated so far.
+func (r *Registry) ReaperStats() ReaperStats {
+	return ReaperStats{
+		Sweeps:                r.reaped.sweeps.Load(),
+		RelaysEvicted:         r.reaped.relaysEvicted.Load(),
+		PlacementsInvalidated: r.reaped.placementsInvalidated.Load(),
+		AgentsExpired:         r.reaped.agentsExpired.Load(),
+	}
+}
+
+// RunReaper sweeps expired state every cfg.Reaper.Interval (plus jitter)
+// until ctx is canceled. It returns immediately if the reaper is disabled.
+func (r *Registry) RunReaper(ctx context.Context) {
+	interval := r.cfg.Reaper.Interval
+	if interval <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(r.nextSweep())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if err := r.Sweep(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("registry sweep failed", "error", err)
+		}
+
+		timer.Reset(r.nextSweep())
+	}
+}
+
+func (r *Registry) nextSweep() time.Duration {
+	delay := r.cfg.Reaper.Interval
+	if jitter := r.cfg.Reaper.Jitter; jitter > 0 {
+		delay += rand.N(jitter)
+	}
+	return delay
+}
+
+// Sweep performs a single garbage-collection pass. Expired relays are
+// removed first, then every placement pointing at a relay that was evicted
+// or is confirmed missing is invalidated, and finally agents whose own TTL
+// lapsed are removed. Records already removed by a concurrent replica are
+// ignored.
+func (r *Registry) Sweep(ctx context.Context) error {
+	r.reaped.sweeps.Add(1)
+	now := r.now()
+
+	relays, err := r.backend.ListRelays(ctx)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]struct{}, len(relays))
+	evicted := make(map[string]struct{})
+	for _, relay := range relays {
+		if !r.relayExpired(relay, now) {
+			live[relay.ID] = struct{}{}
+			continue
+		}
+
+		err := r.backend.RemoveRelay(ctx, relay.ID)
+		if err != nil && !errors.Is(err, ErrRelayNotRegistered) {
+			return err
+		}
+
+		evicted[relay.ID] = struct{}{}
+		r.reaped.relaysEvicted.Add(1)
+		slog.Info("evicted relay",
+			"relay_id", relay.ID,
+			"reason", EvictReasonRelayExpired,
+			"last_seen", relay.LastSeen,
+		)
+	}
+
+	placements, err := r.backend.ListPlacements(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A relay can register, and have agents placed on it, between the two
+	// listings. Relays unknown to the first listing are looked up again
+	// before their placements are treated as orphaned.
+	var present map[string]struct{}
+	relayGone := func(relayID string) (bool, error) {
+		if _, ok := live[relayID]; ok {
+			return false, nil
+		}
+		if _, ok := evicted[relayID]; ok {
+			return true, nil
+		}
+
+		if present == nil {
+			relays, err := r.backend.ListRelays(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			present = make(map[string]struct{}, len(relays))
+			for _, relay := range relays {
+				present[relay.ID] = struct{}{}
+			}
+		}
+
+		_, ok := present[relayID]
+		return !ok, nil
+	}
+
+	for _, placement := range placements {
+		gone, err := relayGone(placement.RelayID)
+		if err != nil {
+			return err
+		}
+
+		var reason string
+		switch {
+		case gone:
+			reason = EvictReasonRelayGone
+		case r.placementExpired(placement, now):
+			reason = EvictReasonAgentExpired
+		default:
+			continue
+		}
+
+		err = r.backend.RemoveAgent(ctx, placement.AgentID)
+		if err != nil && !errors.Is(err, ErrAgentNotRegistered) {
+			return err
+		}
+
+		if reason == EvictReasonRelayGone {
+			r.reaped.placementsInvalidated.Add(1)
+		} else {
+			r.reaped.agentsExpired.Add(1)
+		}
+		slog.Info("evicted agent",
+			"agent_id", placement.AgentID,
+			"relay_id", placement.RelayID,
+			"reason", reason,
+			"updated_at", placement.UpdatedAt,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSweepEvictsExpiredRelaysAndCascades(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	backend := newFakeBackend()
+	reg := newTestRegistry(t, backend, WithClock(clock.Now))
+	ctx := context.Background()
+
+	mustRegisterRelay(t, reg, "dead", clock.Now())
+	mustRegisterAgent(t, reg, "agent-on-dead", "dead", clock.Now())
+
+	clock.Advance(20 * time.Second)
+	mustRegisterRelay(t, reg, "alive", clock.Now())
+	mustRegisterAgent(t, reg, "agent-on-alive", "alive", clock.Now())
+	mustRegisterAgent(t, reg, "agent-stale", "alive", clock.Now().Add(-25*time.Second))
+
+	clock.Advance(15 * time.Second)
+	if err := reg.Sweep(ctx); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	if _, ok := backend.relays["dead"]; ok {
+		t.Fatalf("expected expired relay to be removed from backend")
+	}
+	if _, ok := backend.relays["alive"]; !ok {
+		t.Fatalf("expected live relay to remain in backend")
+	}
+	if _, err := backend.GetAgentPlacement(ctx, "agent-on-dead"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected placement on expired relay to be invalidated, got %v", err)
+	}
+	if _, err := backend.GetAgentPlacement(ctx, "agent-stale"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected expired agent to be removed, got %v", err)
+	}
+	if _, err := backend.GetAgentPlacement(ctx, "agent-on-alive"); err != nil {
+		t.Fatalf("expected live agent to remain, got %v", err)
+	}
+
+	want := ReaperStats{Sweeps: 1, RelaysEvicted: 1, PlacementsInvalidated: 1, AgentsExpired: 1}
+	if got := reg.ReaperStats(); got != want {
+		t.Fatalf("expected stats %+v, got %+v", want, got)
+	}
+}
+
+func TestSweepInvalidatesPlacementsOnMissingRelay(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	backend := newFakeBackend()
+	reg := newTestRegistry(t, backend, WithClock(clock.Now))
+	ctx := context.Background()
+
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	mustRegisterAgent(t, reg, "agent-1", "relay-1", clock.Now())
+
+	// Relay removed out of band, e.g. by another replica's sweep.
+	if err := backend.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+
+	if err := reg.Sweep(ctx); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if _, err := backend.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected orphaned placement to be invalidated, got %v", err)
+	}
+	if got := reg.ReaperStats().PlacementsInvalidated; got != 1 {
+		t.Fatalf("expected 1 invalidated placement, got %d", got)
+	}
+}
+
+func TestSweepKeepsPlacementsOnRelayRegisteredMidSweep(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	backend := &listHookBackend{fakeBackend: newFakeBackend()}
+	reg := newTestRegistry(t, backend, WithClock(clock.Now))
+	ctx := context.Background()
+
+	// Runs after the relay listing and before the placement listing.
+	backend.beforeListPlacements = func() {
+		mustRegisterRelay(t, reg, "relay-new", clock.Now())
+		mustRegisterAgent(t, reg, "agent-new", "relay-new", clock.Now())
+	}
+
+	if err := reg.Sweep(ctx); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if _, err := backend.GetAgentPlacement(ctx, "agent-new"); err != nil {
+		t.Fatalf("expected placement on newly registered relay to remain, got %v", err)
+	}
+	if got := reg.ReaperStats().PlacementsInvalidated; got != 0 {
+		t.Fatalf("expected no invalidated placements, got %d", got)
+	}
+}
+
+func TestRunReaperStopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	cfg := validTestConfig()
+	cfg.Reaper = ReaperConfig{Interval: time.Millisecond, Jitter: time.Millisecond}
+	reg, err := New(cfg, newFakeBackend())
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reg.RunReaper(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for reg.ReaperStats().Sweeps < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("reaper did not sweep in time")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("reaper did not stop after cancel")
+	}
+}
+
+func TestRunReaperDisabled(t *testing.T) {
+	t.Parallel()
+
+	reg := newTestRegistry(t, newFakeBackend())
+
+	// With a zero interval RunReaper must return without blocking.
+	reg.RunReaper(context.Background())
+
+	if got := reg.ReaperStats().Sweeps; got != 0 {
+		t.Fatalf("expected no sweeps, got %d", got)
+	}
+}
+
+// mustRegisterRelay writes the relay through the backend, since the
+// registry would stamp it with the current time instead of lastSeen.
+func mustRegisterRelay(t *testing.T, reg *Registry, relayID string, lastSeen time.Time) {
+	t.Helper()
+
+	if err := reg.backend.RegisterRelay(context.Background(), Relay{ID: relayID, LastSeen: lastSeen}); err != nil {
+		t.Fatalf("register relay %s: %v", relayID, err)
+	}
+}
+
+// mustRegisterAgent writes the agent through the backend, like
+// mustRegisterRelay.
+func mustRegisterAgent(t *testing.T, reg *Registry, agentID, relayID string, lastHeartbeat time.Time) {
+	t.Helper()
+
+	if err := reg.backend.RegisterAgent(context.Background(), Agent{ID: agentID, LastHeartbeat: lastHeartbeat}, relayID); err != nil {
+		t.Fatalf("register agent %s: %v", agentID, err)
+	}
+}
+
+// listHookBackend runs beforeListPlacements once, ahead of the first
+// ListPlacements call.
+type listHookBackend struct {
+	*fakeBackend
+	beforeListPlacements func()
+}
+
+func (b *listHookBackend) ListPlacements(ctx context.Context) ([]AgentPlacement, error) {
+	if hook := b.beforeListPlacements; hook != nil {
+		b.beforeListPlacements = nil
+		hook()
+	}
+
+	return b.fakeBackend.ListPlacements(ctx)
+}
@@ -21,16 +21,41 @@
 // deployed as a standalone, horizontally scalable control plane service.
 package registry
 
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
 type Registry struct {
 	cfg     *Config
 	backend Backend
+	now     func() time.Time
+	ttl     atomic.Pointer[TTLConfig]
+	reaped  reaperCounters
+	watch   *watchHub
+}
+
+// Option customizes a Registry at construction time.
+type Option func(*Registry)
+
+// WithClock overrides the clock used for TTL enforcement.
+func WithClock(now func() time.Time) Option {
+	return func(r *Registry) {
+		r.now = now
+	}
 }
 
-func New(cfg *Config, backend Backend) (*Registry, error) {
+func New(cfg *Config, backend Backend, opts ...Option) (*Registry, error) {
 	if cfg == nil {
 		return nil, ErrNilConfig
 	}
 
+	if backend == nil {
+		return nil, ErrNilBackend
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -38,7 +63,152 @@ func New(cfg *Config, backend Backend) (*Registry, error) {
 	aeroRegistry := &Registry{
 		cfg:     cfg,
 		backend: backend,
+		now:     time.Now,
+	}
+
+	ttl := cfg.TTL
+	aeroRegistry.ttl.Store(&ttl)
+
+	for _, opt := range opts {
+		opt(aeroRegistry)
+	}
+
+	if cfg.Watch.Interval > 0 {
+		aeroRegistry.watch = newWatchHub(cfg.Watch, aeroRegistry.now())
 	}
 
 	return aeroRegistry, nil
 }
+
+// RegisterRelay records a relay and marks it live as of now. Liveness is
+// measured on the registry's clock alone, so relay.LastSeen is replaced
+// and a caller with a skewed clock cannot shorten or stretch its TTL.
+func (r *Registry) RegisterRelay(ctx context.Context, relay Relay) error {
+	if relay.ID == "" {
+		return ErrRelayIDEmpty
+	}
+
+	relay.LastSeen = r.now()
+	return r.backend.RegisterRelay(ctx, relay)
+}
+
+// HeartbeatRelay renews the liveness of a previously registered relay as
+// of now.
+func (r *Registry) HeartbeatRelay(ctx context.Context, relayID string) error {
+	if relayID == "" {
+		return ErrRelayIDEmpty
+	}
+
+	return r.backend.HeartbeatRelay(ctx, relayID, r.now())
+}
+
+// ListRelays returns the relays whose TTL has not yet expired.
+func (r *Registry) ListRelays(ctx context.Context) ([]Relay, error) {
+	relays, err := r.backend.ListRelays(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+	live := make([]Relay, 0, len(relays))
+	for _, relay := range relays {
+		if r.relayExpired(relay, now) {
+			continue
+		}
+		live = append(live, relay)
+	}
+
+	return live, nil
+}
+
+// RegisterAgent records an agent and places it on the given relay as of
+// now, replacing agent.LastHeartbeat like RegisterRelay replaces LastSeen.
+func (r *Registry) RegisterAgent(ctx context.Context, agent Agent, relayID string) error {
+	if agent.ID == "" {
+		return ErrAgentIDEmpty
+	}
+
+	if relayID == "" {
+		return ErrRelayIDEmpty
+	}
+
+	agent.LastHeartbeat = r.now()
+	return r.backend.RegisterAgent(ctx, agent, relayID)
+}
+
+// HeartbeatAgent renews the ownership of a previously registered agent as
+// of now.
+func (r *Registry) HeartbeatAgent(ctx context.Context, agentID string) error {
+	if agentID == "" {
+		return ErrAgentIDEmpty
+	}
+
+	return r.backend.HeartbeatAgent(ctx, agentID, r.now())
+}
+
+// GetAgentPlacement resolves the relay that currently owns the given agent.
+// Placements whose agent TTL has expired, or whose relay is no longer
+// live, are reported as not registered.
+func (r *Registry) GetAgentPlacement(ctx context.Context, agentID string) (*AgentPlacement, error) {
+	if agentID == "" {
+		return nil, ErrAgentIDEmpty
+	}
+
+	placement, err := r.backend.GetAgentPlacement(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+	if r.placementExpired(*placement, now) {
+		return nil, ErrAgentNotRegistered
+	}
+
+	relays, err := r.backend.ListRelays(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := slices.ContainsFunc(relays, func(relay Relay) bool {
+		return relay.ID == placement.RelayID && !r.relayExpired(relay, now)
+	})
+	if !live {
+		return nil, ErrAgentNotRegistered
+	}
+
+	return placement, nil
+}
+
+// TTL returns the relay and agent TTLs currently enforced.
+func (r *Registry) TTL() TTLConfig {
+	return *r.ttl.Load()
+}
+
+// SetTTL atomically replaces the relay and agent TTLs enforced on reads
+// and sweeps. A backend implementing TTLSetter gets the new TTLs first; if
+// it rejects them, including with ErrTTLRestartRequired, the current TTLs
+// stay in force.
+func (r *Registry) SetTTL(ttl TTLConfig) error {
+	if err := ttl.Validate(); err != nil {
+		return err
+	}
+
+	if setter, ok := r.backend.(TTLSetter); ok {
+		if err := setter.SetTTL(ttl); err != nil {
+			return err
+		}
+	}
+
+	r.ttl.Store(&ttl)
+	return nil
+}
+
+// relayExpired reports whether the relay has missed its heartbeat window.
+func (r *Registry) relayExpired(relay Relay, now time.Time) bool {
+	return now.Sub(relay.LastSeen) > r.ttl.Load().Relay
+}
+
+// placementExpired reports whether the placement's agent has missed its
+// heartbeat window. Placements are refreshed on every agent heartbeat.
+func (r *Registry) placementExpired(placement AgentPlacement, now time.Time) bool {
+	return now.Sub(placement.UpdatedAt) > r.ttl.Load().Agent
+}
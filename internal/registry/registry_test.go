@@ -0,0 +1,459 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewRequiresConfigAndBackend(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(nil, newFakeBackend()); !errors.Is(err, ErrNilConfig) {
+		t.Fatalf("expected ErrNilConfig, got %v", err)
+	}
+
+	if _, err := New(validTestConfig(), nil); !errors.Is(err, ErrNilBackend) {
+		t.Fatalf("expected ErrNilBackend, got %v", err)
+	}
+}
+
+func TestRegistryValidatesIDs(t *testing.T) {
+	t.Parallel()
+
+	backend := newFakeBackend()
+	reg := newTestRegistry(t, backend)
+	ctx := context.Background()
+
+	if err := reg.RegisterRelay(ctx, Relay{}); !errors.Is(err, ErrRelayIDEmpty) {
+		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+	}
+	if err := reg.HeartbeatRelay(ctx, ""); !errors.Is(err, ErrRelayIDEmpty) {
+		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{}, "relay-1"); !errors.Is(err, ErrAgentIDEmpty) {
+		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{ID: "agent-1"}, ""); !errors.Is(err, ErrRelayIDEmpty) {
+		t.Fatalf("expected ErrRelayIDEmpty, got %v", err)
+	}
+	if err := reg.HeartbeatAgent(ctx, ""); !errors.Is(err, ErrAgentIDEmpty) {
+		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	}
+	if _, err := reg.GetAgentPlacement(ctx, ""); !errors.Is(err, ErrAgentIDEmpty) {
+		t.Fatalf("expected ErrAgentIDEmpty, got %v", err)
+	}
+
+	if backend.calls != 0 {
+		t.Fatalf("expected invalid requests to never reach the backend, got %d calls", backend.calls)
+	}
+}
+
+func TestRegistryDelegatesToBackend(t *testing.T) {
+	t.Parallel()
+
+	reg := newTestRegistry(t, newFakeBackend())
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := reg.RegisterRelay(ctx, Relay{ID: "relay-1", LastSeen: now}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	relays, err := reg.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || relays[0].ID != "relay-1" {
+		t.Fatalf("unexpected relays: %#v", relays)
+	}
+
+	placement, err := reg.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if placement.RelayID != "relay-1" {
+		t.Fatalf("expected relay-1, got %s", placement.RelayID)
+	}
+
+	if err := reg.HeartbeatAgent(ctx, "missing"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	}
+}
+
+func TestListRelaysFiltersExpired(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg := newTestRegistry(t, newFakeBackend(), WithClock(clock.Now))
+	ctx := context.Background()
+
+	if err := reg.RegisterRelay(ctx, Relay{ID: "stale", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	clock.Advance(20 * time.Second)
+	if err := reg.RegisterRelay(ctx, Relay{ID: "fresh", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// Exactly at the TTL boundary the stale relay is still live.
+	clock.Advance(10 * time.Second)
+	assertRelayIDs(t, reg, "fresh", "stale")
+
+	clock.Advance(time.Millisecond)
+	assertRelayIDs(t, reg, "fresh")
+
+	// A heartbeat brings the relay back into the live set.
+	if err := reg.HeartbeatRelay(ctx, "stale"); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	assertRelayIDs(t, reg, "fresh", "stale")
+
+	clock.Advance(time.Minute)
+	assertRelayIDs(t, reg)
+}
+
+func TestSetTTL(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg := newTestRegistry(t, newFakeBackend(), WithClock(clock.Now))
+	ctx := context.Background()
+
+	if err := reg.RegisterRelay(ctx, Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	clock.Advance(45 * time.Second)
+	assertRelayIDs(t, reg)
+
+	if err := reg.SetTTL(TTLConfig{Relay: time.Minute, Agent: time.Minute}); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if got := reg.TTL(); got.Relay != time.Minute || got.Agent != time.Minute {
+		t.Fatalf("expected updated ttl, got %+v", got)
+	}
+	assertRelayIDs(t, reg, "relay-1")
+
+	// An invalid TTL is rejected and the current one stays in force.
+	if err := reg.SetTTL(TTLConfig{Relay: 0, Agent: time.Second}); !errors.Is(err, ErrTTLRelayInvalid) {
+		t.Fatalf("expected ErrTTLRelayInvalid, got %v", err)
+	}
+	if got := reg.TTL(); got.Relay != time.Minute {
+		t.Fatalf("expected ttl to be kept, got %+v", got)
+	}
+}
+
+// fixedTTLBackend is a fakeBackend whose TTLs can only change on restart.
+type fixedTTLBackend struct {
+	*fakeBackend
+}
+
+func (fixedTTLBackend) SetTTL(TTLConfig) error {
+	return ErrTTLRestartRequired
+}
+
+func TestSetTTLRejectedByBackend(t *testing.T) {
+	t.Parallel()
+
+	reg := newTestRegistry(t, fixedTTLBackend{newFakeBackend()})
+	before := reg.TTL()
+
+	if err := reg.SetTTL(TTLConfig{Relay: time.Minute, Agent: time.Minute}); !errors.Is(err, ErrTTLRestartRequired) {
+		t.Fatalf("expected ErrTTLRestartRequired, got %v", err)
+	}
+	if got := reg.TTL(); got != before {
+		t.Fatalf("expected ttl %+v to be kept, got %+v", before, got)
+	}
+}
+
+func TestGetAgentPlacementExpires(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg := newTestRegistry(t, newFakeBackend(), WithClock(clock.Now))
+	ctx := context.Background()
+
+	if err := reg.RegisterRelay(ctx, Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	clock.Advance(25 * time.Second)
+	if err := reg.HeartbeatRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	if err := reg.HeartbeatAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+
+	clock.Advance(25 * time.Second)
+	if _, err := reg.GetAgentPlacement(ctx, "agent-1"); err != nil {
+		t.Fatalf("expected placement to be live, got %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	if _, err := reg.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	}
+}
+
+func TestGetAgentPlacementHidesExpiredRelay(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg := newTestRegistry(t, newFakeBackend(), WithClock(clock.Now))
+	ctx := context.Background()
+
+	if err := reg.RegisterRelay(ctx, Relay{ID: "relay-1"}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{ID: "agent-1"}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	// The agent keeps heartbeating after its relay has stopped.
+	clock.Advance(25 * time.Second)
+	if err := reg.HeartbeatAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+
+	clock.Advance(25 * time.Second)
+	if _, err := reg.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, ErrAgentNotRegistered) {
+		t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+	}
+}
+
+func TestRegistryStampsTimestamps(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg := newTestRegistry(t, newFakeBackend(), WithClock(clock.Now))
+	ctx := context.Background()
+
+	// A caller whose clock runs ahead cannot keep itself live past its TTL.
+	future := clock.Now().Add(time.Hour)
+	if err := reg.RegisterRelay(ctx, Relay{ID: "relay-1", LastSeen: future}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := reg.RegisterAgent(ctx, Agent{ID: "agent-1", LastHeartbeat: future}, "relay-1"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	relays, err := reg.ListRelays(ctx)
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(relays) != 1 || !relays[0].LastSeen.Equal(clock.Now()) {
+		t.Fatalf("expected relay last seen %s, got %+v", clock.Now(), relays)
+	}
+
+	clock.Advance(10 * time.Second)
+	if err := reg.HeartbeatAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+	placement, err := reg.GetAgentPlacement(ctx, "agent-1")
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	if !placement.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected placement updated at %s, got %s", clock.Now(), placement.UpdatedAt)
+	}
+
+	clock.Advance(25 * time.Second)
+	assertRelayIDs(t, reg)
+}
+
+func assertRelayIDs(t *testing.T, reg *Registry, want ...string) {
+	t.Helper()
+
+	relays, err := reg.ListRelays(context.Background())
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+
+	got := make([]string, 0, len(relays))
+	for _, relay := range relays {
+		got = append(got, relay.ID)
+	}
+	slices.Sort(got)
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected relays %v, got %v", want, got)
+	}
+}
+
+// fakeClock is a manually advanced clock for TTL tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func validTestConfig() *Config {
+	return &Config{
+		Backend: BackendConfig{Type: MemoryRegistryBackend},
+		GRPC:    GRPCConfig{ListenAddress: "127.0.0.1", ListenPort: 50051},
+		TTL:     TTLConfig{Relay: 30 * time.Second, Agent: 30 * time.Second},
+	}
+}
+
+func newTestRegistry(t *testing.T, backend Backend, opts ...Option) *Registry {
+	t.Helper()
+
+	reg, err := New(validTestConfig(), backend, opts...)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	return reg
+}
+
+// fakeBackend is a map-backed Backend that counts calls reaching it.
+type fakeBackend struct {
+	mu         sync.Mutex
+	calls      int
+	relays     map[string]Relay
+	agents     map[string]Agent
+	placements map[string]AgentPlacement
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		relays:     make(map[string]Relay),
+		agents:     make(map[string]Agent),
+		placements: make(map[string]AgentPlacement),
+	}
+}
+
+func (f *fakeBackend) RegisterRelay(ctx context.Context, relay Relay) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.relays[relay.ID] = relay
+	return nil
+}
+
+func (f *fakeBackend) HeartbeatRelay(ctx context.Context, relayID string, ts time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	relay, ok := f.relays[relayID]
+	if !ok {
+		return ErrRelayNotRegistered
+	}
+	relay.LastSeen = ts
+	f.relays[relayID] = relay
+	return nil
+}
+
+func (f *fakeBackend) ListRelays(ctx context.Context) ([]Relay, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	relays := make([]Relay, 0, len(f.relays))
+	for _, relay := range f.relays {
+		relays = append(relays, relay)
+	}
+	return relays, nil
+}
+
+func (f *fakeBackend) RemoveRelay(ctx context.Context, relayID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if _, ok := f.relays[relayID]; !ok {
+		return ErrRelayNotRegistered
+	}
+	delete(f.relays, relayID)
+	return nil
+}
+
+func (f *fakeBackend) RegisterAgent(ctx context.Context, agent Agent, relayID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if _, ok := f.relays[relayID]; !ok {
+		return ErrRelayNotRegistered
+	}
+	f.agents[agent.ID] = agent
+	f.placements[agent.ID] = AgentPlacement{AgentID: agent.ID, RelayID: relayID, UpdatedAt: agent.LastHeartbeat}
+	return nil
+}
+
+func (f *fakeBackend) HeartbeatAgent(ctx context.Context, agentID string, ts time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	agent, ok := f.agents[agentID]
+	if !ok {
+		return ErrAgentNotRegistered
+	}
+	agent.LastHeartbeat = ts
+	f.agents[agentID] = agent
+	placement := f.placements[agentID]
+	placement.UpdatedAt = ts
+	f.placements[agentID] = placement
+	return nil
+}
+
+func (f *fakeBackend) GetAgentPlacement(ctx context.Context, agentID string) (*AgentPlacement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	placement, ok := f.placements[agentID]
+	if !ok {
+		return nil, ErrAgentNotRegistered
+	}
+	return &placement, nil
+}
+
+func (f *fakeBackend) ListPlacements(ctx context.Context) ([]AgentPlacement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	placements := make([]AgentPlacement, 0, len(f.placements))
+	for _, placement := range f.placements {
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+func (f *fakeBackend) RemoveAgent(ctx context.Context, agentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if _, ok := f.agents[agentID]; !ok {
+		return ErrAgentNotRegistered
+	}
+	delete(f.agents, agentID)
+	delete(f.placements, agentID)
+	return nil
+}
+
+func (f *fakeBackend) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,470 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change carried by an Event.
+type EventType string
+
+const (
+	EventRelayAdded       EventType = "relay_added"
+	EventRelayHeartbeat   EventType = "relay_heartbeat"
+	EventRelayExpired     EventType = "relay_expired"
+	EventRelayRemoved     EventType = "relay_removed"
+	EventPlacementChanged EventType = "placement_changed"
+	EventPlacementRemoved EventType = "placement_removed"
+	EventPlacementExpired EventType = "placement_expired"
+)
+
+// IsRelay reports whether the event describes a relay rather than a
+// placement.
+func (t EventType) IsRelay() bool {
+	return strings.HasPrefix(string(t), "relay_")
+}
+
+// Event describes a single relay or placement change. Only the field
+// matching Type is populated.
+type Event struct {
+	Type      EventType
+	Relay     Relay
+	Placement AgentPlacement
+
+	// ResumeToken identifies the position of this event in the registry's
+	// change stream. Passing it back to WatchRelays or WatchPlacements
+	// resumes delivery with the next event.
+	ResumeToken string
+}
+
+const (
+	// defaultWatchHistory is used when WatchConfig.History is zero.
+	defaultWatchHistory = 1024
+
+	// watchBuffer is the number of undelivered events a watcher may fall
+	// behind by before it is disconnected.
+	watchBuffer = 256
+)
+
+// WatchRelays streams relay events until ctx is canceled. An empty token
+// starts with a relay_added event for every live relay, followed by live
+// changes; a token from a previous event resumes after that event.
+//
+// Resume tokens are local to this process: a token issued by another
+// replica, or before a restart, is rejected with ErrResumeTokenExpired and
+// the caller starts over with an empty token. Watching is an in-process API
+// only; the AeroRegistry gRPC service has no watch RPCs.
+//
+// The channel is closed when ctx is done or when the watcher falls too far
+// behind, in which case the caller should reconnect with the last token it
+// received.
+func (r *Registry) WatchRelays(ctx context.Context, token string) (<-chan Event, error) {
+	if r.watch == nil {
+		return nil, ErrWatchDisabled
+	}
+
+	return r.watch.subscribe(ctx, token, true)
+}
+
+// WatchPlacements streams placement events until ctx is canceled. It
+// follows the same token and delivery rules as WatchRelays.
+func (r *Registry) WatchPlacements(ctx context.Context, token string) (<-chan Event, error) {
+	if r.watch == nil {
+		return nil, ErrWatchDisabled
+	}
+
+	return r.watch.subscribe(ctx, token, false)
+}
+
+// RunWatch feeds the watch stream until ctx is canceled. Backends with a
+// native change stream are followed through Watcher; those returning
+// ErrWatchUnsupported, or not implementing Watcher, are polled every
+// cfg.Watch.Interval. In both cases TTL expiry is evaluated on every tick.
+// It returns immediately if watching is disabled.
+func (r *Registry) RunWatch(ctx context.Context) {
+	if r.watch == nil {
+		return
+	}
+
+	ticker := time.NewTicker(r.cfg.Watch.Interval)
+	defer ticker.Stop()
+
+	watcher, native := r.backend.(Watcher)
+	state := newWatchState()
+	var events <-chan Event
+
+	refresh := func() {
+		if native && events != nil {
+			r.reconcile(state, r.now())
+			return
+		}
+
+		if native {
+			ch, err := watcher.Watch(ctx)
+			switch {
+			case errors.Is(err, ErrWatchUnsupported):
+				native = false
+				slog.Info("backend has no native watch, polling for changes", "interval", r.cfg.Watch.Interval)
+			case err != nil:
+				if ctx.Err() == nil {
+					slog.Warn("backend watch failed, falling back to polling", "error", err)
+				}
+			default:
+				events = ch
+			}
+		}
+
+		// Resync from a full listing so nothing is missed while the native
+		// watch was down or between polls.
+		if err := state.load(ctx, r.backend); err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("registry watch poll failed", "error", err)
+			}
+			return
+		}
+		r.reconcile(state, r.now())
+	}
+
+	refresh()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				refresh()
+				continue
+			}
+			r.reconcileEvent(state, ev, r.now())
+		}
+	}
+}
+
+// watchState is the latest backend state observed by RunWatch, including
+// records whose TTL has lapsed but which have not been reaped yet.
+type watchState struct {
+	relays     map[string]Relay
+	placements map[string]AgentPlacement
+}
+
+func newWatchState() *watchState {
+	return &watchState{
+		relays:     make(map[string]Relay),
+		placements: make(map[string]AgentPlacement),
+	}
+}
+
+func (s *watchState) load(ctx context.Context, backend Backend) error {
+	relays, err := backend.ListRelays(ctx)
+	if err != nil {
+		return err
+	}
+
+	placements, err := backend.ListPlacements(ctx)
+	if err != nil {
+		return err
+	}
+
+	clear(s.relays)
+	for _, relay := range relays {
+		s.relays[relay.ID] = relay
+	}
+
+	clear(s.placements)
+	for _, placement := range placements {
+		s.placements[placement.AgentID] = placement
+	}
+
+	return nil
+}
+
+// apply folds a backend event into the state. Writes older than the state
+// already held are ignored so replays after a resync are harmless.
+func (s *watchState) apply(ev Event) {
+	switch ev.Type {
+	case EventRelayAdded, EventRelayHeartbeat:
+		if cur, ok := s.relays[ev.Relay.ID]; ok && ev.Relay.LastSeen.Before(cur.LastSeen) {
+			return
+		}
+		s.relays[ev.Relay.ID] = ev.Relay
+	case EventRelayRemoved, EventRelayExpired:
+		delete(s.relays, ev.Relay.ID)
+	case EventPlacementChanged:
+		if cur, ok := s.placements[ev.Placement.AgentID]; ok && ev.Placement.UpdatedAt.Before(cur.UpdatedAt) {
+			return
+		}
+		s.placements[ev.Placement.AgentID] = ev.Placement
+	case EventPlacementRemoved, EventPlacementExpired:
+		delete(s.placements, ev.Placement.AgentID)
+	}
+}
+
+// reconcile publishes the difference between state and what watchers have
+// already been told.
+func (r *Registry) reconcile(state *watchState, now time.Time) {
+	h := r.watch
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range unionKeys(state.relays, h.relays) {
+		r.reconcileRelay(state, id, now)
+	}
+
+	for _, id := range unionKeys(state.placements, h.placements) {
+		r.reconcilePlacement(state, id, now)
+	}
+}
+
+func (r *Registry) reconcileEvent(state *watchState, ev Event, now time.Time) {
+	state.apply(ev)
+
+	h := r.watch
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if ev.Type.IsRelay() {
+		r.reconcileRelay(state, ev.Relay.ID, now)
+		return
+	}
+	r.reconcilePlacement(state, ev.Placement.AgentID, now)
+}
+
+// reconcileRelay must be called with the hub lock held.
+func (r *Registry) reconcileRelay(state *watchState, relayID string, now time.Time) {
+	h := r.watch
+	latest, exists := state.relays[relayID]
+	published, known := h.relays[relayID]
+	live := exists && !r.relayExpired(latest, now)
+
+	switch {
+	case live && (!known || latest.Address != published.Address || latest.GRPCPort != published.GRPCPort):
+		h.publish(Event{Type: EventRelayAdded, Relay: latest})
+	case live && latest.LastSeen.After(published.LastSeen):
+		h.publish(Event{Type: EventRelayHeartbeat, Relay: latest})
+	case !exists && known:
+		h.publish(Event{Type: EventRelayRemoved, Relay: published})
+	case !live && known:
+		h.publish(Event{Type: EventRelayExpired, Relay: latest})
+	}
+}
+
+// reconcilePlacement must be called with the hub lock held. Agent
+// heartbeats only refresh the placement and are not published.
+func (r *Registry) reconcilePlacement(state *watchState, agentID string, now time.Time) {
+	h := r.watch
+	latest, exists := state.placements[agentID]
+	published, known := h.placements[agentID]
+	live := exists && !r.placementExpired(latest, now)
+
+	switch {
+	case live && (!known || latest.RelayID != published.RelayID):
+		h.publish(Event{Type: EventPlacementChanged, Placement: latest})
+	case live:
+		h.placements[agentID] = latest
+	case !exists && known:
+		h.publish(Event{Type: EventPlacementRemoved, Placement: published})
+	case !live && known:
+		h.publish(Event{Type: EventPlacementExpired, Placement: latest})
+	}
+}
+
+// watchHub sequences published events, retains recent history for resume
+// and fans events out to subscribers.
+type watchHub struct {
+	mu sync.Mutex
+
+	// epoch distinguishes this process's stream from earlier ones, so a
+	// token issued before a restart is reported as expired.
+	epoch   string
+	seq     uint64
+	limit   int
+	history []Event
+
+	// relays and placements are the live state as published to watchers.
+	relays     map[string]Relay
+	placements map[string]AgentPlacement
+
+	subs map[*watchSub]struct{}
+}
+
+type watchSub struct {
+	ch     chan Event
+	relays bool
+}
+
+func newWatchHub(cfg WatchConfig, now time.Time) *watchHub {
+	limit := cfg.History
+	if limit == 0 {
+		limit = defaultWatchHistory
+	}
+
+	return &watchHub{
+		epoch:      strconv.FormatInt(now.UnixNano(), 36),
+		limit:      limit,
+		relays:     make(map[string]Relay),
+		placements: make(map[string]AgentPlacement),
+		subs:       make(map[*watchSub]struct{}),
+	}
+}
+
+// publish must be called with the hub lock held.
+func (h *watchHub) publish(ev Event) {
+	switch ev.Type {
+	case EventRelayAdded, EventRelayHeartbeat:
+		h.relays[ev.Relay.ID] = ev.Relay
+	case EventRelayRemoved, EventRelayExpired:
+		delete(h.relays, ev.Relay.ID)
+	case EventPlacementChanged:
+		h.placements[ev.Placement.AgentID] = ev.Placement
+	case EventPlacementRemoved, EventPlacementExpired:
+		delete(h.placements, ev.Placement.AgentID)
+	}
+
+	h.seq++
+	ev.ResumeToken = h.token(h.seq)
+
+	if len(h.history) == h.limit {
+		h.history = h.history[1:]
+	}
+	h.history = append(h.history, ev)
+
+	for sub := range h.subs {
+		if sub.relays != ev.Type.IsRelay() {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+		default:
+			// Slow watchers are cut off rather than blocking the stream;
+			// they can resume from their last token.
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (h *watchHub) subscribe(ctx context.Context, token string, relays bool) (<-chan Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []Event
+	if token == "" {
+		backlog = h.snapshot(relays)
+	} else {
+		seq, err := h.parseToken(token)
+		if err != nil {
+			return nil, err
+		}
+
+		oldest := h.seq - uint64(len(h.history))
+		for _, ev := range h.history[seq-oldest:] {
+			if ev.Type.IsRelay() == relays {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+
+	sub := &watchSub{
+		ch:     make(chan Event, len(backlog)+watchBuffer),
+		relays: relays,
+	}
+	for _, ev := range backlog {
+		sub.ch <- ev
+	}
+	h.subs[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+func (h *watchHub) unsubscribe(sub *watchSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// snapshot renders the published state as synthetic events positioned at
+// the current end of the stream. It must be called with the hub lock held.
+func (h *watchHub) snapshot(relays bool) []Event {
+	token := h.token(h.seq)
+
+	if relays {
+		events := make([]Event, 0, len(h.relays))
+		for _, id := range slices.Sorted(maps.Keys(h.relays)) {
+			events = append(events, Event{Type: EventRelayAdded, Relay: h.relays[id], ResumeToken: token})
+		}
+		return events
+	}
+
+	events := make([]Event, 0, len(h.placements))
+	for _, id := range slices.Sorted(maps.Keys(h.placements)) {
+		events = append(events, Event{Type: EventPlacementChanged, Placement: h.placements[id], ResumeToken: token})
+	}
+	return events
+}
+
+func (h *watchHub) token(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseToken returns the sequence number encoded in token, provided the
+// events that follow it are still retained.
+func (h *watchHub) parseToken(token string) (uint64, error) {
+	epoch, rawSeq, ok := strings.Cut(token, "-")
+	if !ok {
+		return 0, ErrResumeTokenInvalid
+	}
+
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return 0, ErrResumeTokenInvalid
+	}
+
+	if epoch != h.epoch {
+		return 0, ErrResumeTokenExpired
+	}
+
+	if seq > h.seq {
+		return 0, ErrResumeTokenInvalid
+	}
+
+	if seq < h.seq-uint64(len(h.history)) {
+		return 0, ErrResumeTokenExpired
+	}
+
+	return seq, nil
+}
+
+// unionKeys returns the sorted set of keys present in either map.
+func unionKeys[V, W any](a map[string]V, b map[string]W) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,322 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchDisabled(t *testing.T) {
+	t.Parallel()
+
+	reg := newTestRegistry(t, newFakeBackend())
+	ctx := context.Background()
+
+	if _, err := reg.WatchRelays(ctx, ""); !errors.Is(err, ErrWatchDisabled) {
+		t.Fatalf("expected ErrWatchDisabled, got %v", err)
+	}
+	if _, err := reg.WatchPlacements(ctx, ""); !errors.Is(err, ErrWatchDisabled) {
+		t.Fatalf("expected ErrWatchDisabled, got %v", err)
+	}
+
+	// RunWatch must return immediately when disabled.
+	reg.RunWatch(ctx)
+}
+
+func TestWatchRelayLifecycle(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg, poll := newWatchRegistry(t, newFakeBackend(), clock, 0)
+	ctx := context.Background()
+
+	events, err := reg.WatchRelays(ctx, "")
+	if err != nil {
+		t.Fatalf("watch relays: %v", err)
+	}
+
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	mustRegisterRelay(t, reg, "relay-2", clock.Now())
+	poll()
+	assertEvent(t, events, EventRelayAdded, "relay-1")
+	assertEvent(t, events, EventRelayAdded, "relay-2")
+
+	clock.Advance(10 * time.Second)
+	if err := reg.HeartbeatRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	poll()
+	assertEvent(t, events, EventRelayHeartbeat, "relay-1")
+
+	// Polling without changes publishes nothing.
+	poll()
+	assertNoEvent(t, events)
+
+	if err := reg.backend.RemoveRelay(ctx, "relay-1"); err != nil {
+		t.Fatalf("remove relay: %v", err)
+	}
+	clock.Advance(25 * time.Second)
+	poll()
+	assertEvent(t, events, EventRelayRemoved, "relay-1")
+	assertEvent(t, events, EventRelayExpired, "relay-2")
+
+	// A heartbeat after expiry brings the relay back.
+	if err := reg.HeartbeatRelay(ctx, "relay-2"); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+	poll()
+	assertEvent(t, events, EventRelayAdded, "relay-2")
+}
+
+func TestWatchPlacementChanges(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg, poll := newWatchRegistry(t, newFakeBackend(), clock, 0)
+	ctx := context.Background()
+
+	events, err := reg.WatchPlacements(ctx, "")
+	if err != nil {
+		t.Fatalf("watch placements: %v", err)
+	}
+
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	mustRegisterRelay(t, reg, "relay-2", clock.Now())
+	mustRegisterAgent(t, reg, "agent-1", "relay-1", clock.Now())
+	poll()
+	assertEvent(t, events, EventPlacementChanged, "agent-1")
+
+	// Heartbeats that keep the agent on the same relay are not published.
+	clock.Advance(10 * time.Second)
+	if err := reg.HeartbeatAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+	poll()
+	assertNoEvent(t, events)
+
+	mustRegisterAgent(t, reg, "agent-1", "relay-2", clock.Now())
+	poll()
+	ev := assertEvent(t, events, EventPlacementChanged, "agent-1")
+	if ev.Placement.RelayID != "relay-2" {
+		t.Fatalf("expected placement on relay-2, got %q", ev.Placement.RelayID)
+	}
+
+	clock.Advance(31 * time.Second)
+	poll()
+	assertEvent(t, events, EventPlacementExpired, "agent-1")
+}
+
+func TestWatchSnapshotAndResume(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg, poll := newWatchRegistry(t, newFakeBackend(), clock, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	poll()
+
+	first, err := reg.WatchRelays(ctx, "")
+	if err != nil {
+		t.Fatalf("watch relays: %v", err)
+	}
+	snapshot := assertEvent(t, first, EventRelayAdded, "relay-1")
+	if snapshot.ResumeToken == "" {
+		t.Fatalf("expected snapshot event to carry a resume token")
+	}
+
+	mustRegisterRelay(t, reg, "relay-2", clock.Now())
+	poll()
+	assertEvent(t, first, EventRelayAdded, "relay-2")
+
+	mustRegisterRelay(t, reg, "relay-3", clock.Now())
+	poll()
+
+	// Resuming from the snapshot replays everything published since.
+	resumed, err := reg.WatchRelays(ctx, snapshot.ResumeToken)
+	if err != nil {
+		t.Fatalf("resume watch: %v", err)
+	}
+	assertEvent(t, resumed, EventRelayAdded, "relay-2")
+	assertEvent(t, resumed, EventRelayAdded, "relay-3")
+	assertNoEvent(t, resumed)
+
+	cancel()
+	if _, ok := <-resumed; ok {
+		t.Fatalf("expected channel to close after cancel")
+	}
+}
+
+func TestWatchResumeTokenErrors(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg, poll := newWatchRegistry(t, newFakeBackend(), clock, 2)
+	ctx := context.Background()
+
+	events, err := reg.WatchRelays(ctx, "")
+	if err != nil {
+		t.Fatalf("watch relays: %v", err)
+	}
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	poll()
+	oldest := assertEvent(t, events, EventRelayAdded, "relay-1")
+
+	for _, id := range []string{"relay-2", "relay-3"} {
+		mustRegisterRelay(t, reg, id, clock.Now())
+	}
+	poll()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "malformed", token: "garbage", wantErr: ErrResumeTokenInvalid},
+		{name: "bad sequence", token: reg.watch.epoch + "-x", wantErr: ErrResumeTokenInvalid},
+		{name: "future sequence", token: reg.watch.epoch + "-99", wantErr: ErrResumeTokenInvalid},
+		{name: "previous process", token: "other-1", wantErr: ErrResumeTokenExpired},
+		{name: "evicted from history", token: reg.watch.token(0), wantErr: ErrResumeTokenExpired},
+	}
+
+	for _, test := range tests {
+		if _, err := reg.WatchRelays(ctx, test.token); !errors.Is(err, test.wantErr) {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+	}
+
+	// The oldest retained event can still be resumed from.
+	if _, err := reg.WatchRelays(ctx, oldest.ResumeToken); err != nil {
+		t.Fatalf("expected resume from oldest retained token, got %v", err)
+	}
+}
+
+func TestWatchDropsSlowWatchers(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock(time.Now())
+	reg, poll := newWatchRegistry(t, newFakeBackend(), clock, 0)
+
+	events, err := reg.WatchRelays(context.Background(), "")
+	if err != nil {
+		t.Fatalf("watch relays: %v", err)
+	}
+
+	mustRegisterRelay(t, reg, "relay-1", clock.Now())
+	for i := 0; i <= watchBuffer; i++ {
+		clock.Advance(time.Millisecond)
+		if err := reg.HeartbeatRelay(context.Background(), "relay-1"); err != nil {
+			t.Fatalf("heartbeat relay: %v", err)
+		}
+		poll()
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != watchBuffer {
+		t.Fatalf("expected %d buffered events before disconnect, got %d", watchBuffer, received)
+	}
+}
+
+func TestRunWatchPollsUnsupportedWatcher(t *testing.T) {
+	t.Parallel()
+
+	cfg := validTestConfig()
+	cfg.Watch = WatchConfig{Interval: 10 * time.Millisecond}
+
+	backend := &unsupportedWatchBackend{fakeBackend: newFakeBackend()}
+	reg, err := New(cfg, backend)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reg.RunWatch(ctx)
+
+	events, err := reg.WatchRelays(ctx, "")
+	if err != nil {
+		t.Fatalf("watch relays: %v", err)
+	}
+
+	mustRegisterRelay(t, reg, "relay-1", time.Now())
+	assertEvent(t, events, EventRelayAdded, "relay-1")
+
+	if calls := backend.watchCalls.Load(); calls != 1 {
+		t.Fatalf("expected Watch to be tried once, got %d", calls)
+	}
+}
+
+// newWatchRegistry returns a registry with watching enabled and a function
+// that performs one polling pass, so tests control when changes are seen.
+func newWatchRegistry(t *testing.T, backend Backend, clock *fakeClock, history int) (*Registry, func()) {
+	t.Helper()
+
+	cfg := validTestConfig()
+	cfg.Watch = WatchConfig{Interval: time.Second, History: history}
+
+	reg, err := New(cfg, backend, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	state := newWatchState()
+	poll := func() {
+		t.Helper()
+
+		if err := state.load(context.Background(), backend); err != nil {
+			t.Fatalf("load watch state: %v", err)
+		}
+		reg.reconcile(state, clock.Now())
+	}
+
+	return reg, poll
+}
+
+func assertEvent(t *testing.T, events <-chan Event, wantType EventType, wantID string) Event {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("expected %s for %s, channel closed", wantType, wantID)
+		}
+		id := ev.Placement.AgentID
+		if ev.Type.IsRelay() {
+			id = ev.Relay.ID
+		}
+		if ev.Type != wantType || id != wantID {
+			t.Fatalf("expected %s for %s, got %s for %s", wantType, wantID, ev.Type, id)
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s for %s", wantType, wantID)
+	}
+	return Event{}
+}
+
+func assertNoEvent(t *testing.T, events <-chan Event) {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		t.Fatalf("expected no event, got %s", ev.Type)
+	default:
+	}
+}
+
+// unsupportedWatchBackend reports that it has no native change stream.
+type unsupportedWatchBackend struct {
+	*fakeBackend
+	watchCalls atomic.Int32
+}
+
+func (b *unsupportedWatchBackend) Watch(context.Context) (<-chan Event, error) {
+	b.watchCalls.Add(1)
+	return nil, ErrWatchUnsupported
+}
@@ -0,0 +1,152 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorizer enforces a registry.AuthConfig policy on each call. It runs
+// after the caller's Principal has been attached to the context.
+type authorizer struct {
+	registry     *registry.Registry
+	relays       []relayPattern
+	controlPlane map[string]struct{}
+}
+
+// relayPattern is a RelayPrincipals pattern split around the relay ID.
+type relayPattern struct {
+	prefix string
+	suffix string
+}
+
+func newAuthorizer(reg *registry.Registry, cfg registry.AuthConfig) *authorizer {
+	a := &authorizer{
+		registry:     reg,
+		controlPlane: make(map[string]struct{}, len(cfg.ControlPlanePrincipals)),
+	}
+
+	for _, pattern := range cfg.RelayPrincipals {
+		prefix, suffix, _ := strings.Cut(pattern, registry.RelayIDPlaceholder)
+		a.relays = append(a.relays, relayPattern{prefix: prefix, suffix: suffix})
+	}
+
+	for _, name := range cfg.ControlPlanePrincipals {
+		a.controlPlane[name] = struct{}{}
+	}
+
+	return a
+}
+
+// relayID returns the relay ID a principal may act for, if it is a relay.
+func (a *authorizer) relayID(name string) (string, bool) {
+	for _, pattern := range a.relays {
+		if len(name) <= len(pattern.prefix)+len(pattern.suffix) ||
+			!strings.HasPrefix(name, pattern.prefix) ||
+			!strings.HasSuffix(name, pattern.suffix) {
+			continue
+		}
+
+		return name[len(pattern.prefix) : len(name)-len(pattern.suffix)], true
+	}
+
+	return "", false
+}
+
+func (a *authorizer) isControlPlane(name string) bool {
+	_, ok := a.controlPlane[name]
+	return ok
+}
+
+// authorize reports whether the caller in ctx may make the request. Relays
+// may only write state for their own relay ID and may not take an agent
+// from another live relay, control plane principals may only read, and
+// requests the policy does not cover are denied.
+func (a *authorizer) authorize(ctx context.Context, method string, req any) error {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "caller is not authenticated")
+	}
+
+	relayID, isRelay := a.relayID(principal.Name)
+
+	switch req := req.(type) {
+	case *registryv1.ListRelaysRequest, *registryv1.GetAgentPlacementRequest:
+		if a.isControlPlane(principal.Name) {
+			return nil
+		}
+	case *registryv1.RegisterRelayRequest:
+		if isRelay && req.GetRelay().GetRelayId() == relayID {
+			return nil
+		}
+	case *registryv1.HeartbeatRelayRequest:
+		if isRelay && req.GetRelayId() == relayID {
+			return nil
+		}
+	case *registryv1.RegisterAgentRequest:
+		if isRelay && req.GetRelayId() == relayID {
+			taken, err := a.placedElsewhere(ctx, req.GetAgent().GetAgentId(), relayID)
+			if err != nil {
+				return toStatus(err)
+			}
+			if !taken {
+				return nil
+			}
+		}
+	case *registryv1.HeartbeatAgentRequest:
+		if isRelay {
+			placement, err := a.registry.GetAgentPlacement(ctx, req.GetAgentId())
+			if err != nil {
+				return toStatus(err)
+			}
+			if placement.RelayID == relayID {
+				return nil
+			}
+		}
+	}
+
+	return status.Errorf(codes.PermissionDenied, "%s may not call %s", principal.Name, method)
+}
+
+// placedElsewhere reports whether agentID is placed on a live relay other
+// than relayID. An agent left on an expired relay may be placed again; the
+// registry reports its placement as not registered.
+func (a *authorizer) placedElsewhere(ctx context.Context, agentID, relayID string) (bool, error) {
+	placement, err := a.registry.GetAgentPlacement(ctx, agentID)
+	if errors.Is(err, registry.ErrAgentNotRegistered) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return placement.RelayID != relayID, nil
+}
+
+func (a *authorizer) unaryInterceptor(ctx context.Context, req any, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (any, error) {
+	if err := a.authorize(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// streamInterceptor admits only control plane principals, since streams
+// carry no request to check a relay ID against.
+func (a *authorizer) streamInterceptor(srv any, ss gogrpc.ServerStream, info *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) error {
+	principal, ok := PrincipalFromContext(ss.Context())
+	if !ok {
+		return status.Error(codes.Unauthenticated, "caller is not authenticated")
+	}
+	if !a.isControlPlane(principal.Name) {
+		return status.Errorf(codes.PermissionDenied, "%s may not call %s", principal.Name, info.FullMethod)
+	}
+
+	return handler(srv, ss)
+}
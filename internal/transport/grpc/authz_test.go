@@ -0,0 +1,287 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorizerUnary(t *testing.T) {
+	t.Parallel()
+
+	authz := newTestAuthorizer(t)
+
+	tests := []struct {
+		name      string
+		principal string
+		req       any
+		want      codes.Code
+	}{
+		{
+			name:      "relay registers itself",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterRelayRequest{Relay: &registryv1.Relay{RelayId: "relay-1"}},
+			want:      codes.OK,
+		},
+		{
+			name:      "relay registers another relay",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterRelayRequest{Relay: &registryv1.Relay{RelayId: "relay-2"}},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay heartbeats itself",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.HeartbeatRelayRequest{RelayId: "relay-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "relay heartbeats another relay",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.HeartbeatRelayRequest{RelayId: "relay-2"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay places agent on itself",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-3"}, RelayId: "relay-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "relay places agent on another relay",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-3"}, RelayId: "relay-2"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay places its own agent again",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-1"}, RelayId: "relay-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "relay takes an agent from another live relay",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-2"}, RelayId: "relay-1"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay takes an agent from an expired relay",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-stranded"}, RelayId: "relay-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "control plane places an agent",
+			principal: "spiffe://aero-arc/control-plane",
+			req:       &registryv1.RegisterAgentRequest{Agent: &registryv1.Agent{AgentId: "agent-2"}, RelayId: "relay-1"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay heartbeats its agent",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.HeartbeatAgentRequest{AgentId: "agent-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "relay heartbeats another relay's agent",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.HeartbeatAgentRequest{AgentId: "agent-2"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay heartbeats unknown agent",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.HeartbeatAgentRequest{AgentId: "agent-9"},
+			want:      codes.NotFound,
+		},
+		{
+			name:      "relay lists relays",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			req:       &registryv1.ListRelaysRequest{},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "control plane lists relays",
+			principal: "spiffe://aero-arc/control-plane",
+			req:       &registryv1.ListRelaysRequest{},
+			want:      codes.OK,
+		},
+		{
+			name:      "control plane gets placement",
+			principal: "spiffe://aero-arc/control-plane",
+			req:       &registryv1.GetAgentPlacementRequest{AgentId: "agent-1"},
+			want:      codes.OK,
+		},
+		{
+			name:      "control plane registers relay",
+			principal: "spiffe://aero-arc/control-plane",
+			req:       &registryv1.RegisterRelayRequest{Relay: &registryv1.Relay{RelayId: "relay-1"}},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "unknown principal",
+			principal: "spiffe://aero-arc/other",
+			req:       &registryv1.GetAgentPlacementRequest{AgentId: "agent-1"},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name:      "relay pattern with empty relay id",
+			principal: "spiffe://aero-arc/relay/",
+			req:       &registryv1.RegisterRelayRequest{Relay: &registryv1.Relay{}},
+			want:      codes.PermissionDenied,
+		},
+		{
+			name: "unauthenticated",
+			req:  &registryv1.ListRelaysRequest{},
+			want: codes.Unauthenticated,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if test.principal != "" {
+				ctx = principalContext(t, test.principal)
+			}
+
+			called := false
+			handler := func(context.Context, any) (any, error) {
+				called = true
+				return nil, nil
+			}
+
+			_, err := authz.unaryInterceptor(ctx, test.req, &gogrpc.UnaryServerInfo{FullMethod: "/test"}, handler)
+			if status.Code(err) != test.want {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+			if called != (test.want == codes.OK) {
+				t.Fatalf("expected handler called %v, got %v", test.want == codes.OK, called)
+			}
+		})
+	}
+}
+
+func TestAuthorizerStream(t *testing.T) {
+	t.Parallel()
+
+	authz := newTestAuthorizer(t)
+
+	tests := []struct {
+		name      string
+		principal string
+		want      codes.Code
+	}{
+		{name: "control plane", principal: "spiffe://aero-arc/control-plane", want: codes.OK},
+		{name: "relay", principal: "spiffe://aero-arc/relay/relay-1", want: codes.PermissionDenied},
+		{name: "unauthenticated", want: codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if test.principal != "" {
+				ctx = principalContext(t, test.principal)
+			}
+
+			handler := func(any, gogrpc.ServerStream) error { return nil }
+
+			err := authz.streamInterceptor(nil, &fakeStream{ctx: ctx}, &gogrpc.StreamServerInfo{FullMethod: "/test"}, handler)
+			if status.Code(err) != test.want {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+// newTestAuthorizer returns an authorizer over a registry holding agent-1
+// on relay-1, agent-2 on relay-2 and agent-stranded, still live, on the
+// expired relay-gone.
+func newTestAuthorizer(t *testing.T) *authorizer {
+	t.Helper()
+
+	cfg := &registry.Config{
+		Backend: registry.BackendConfig{Type: registry.MemoryRegistryBackend},
+		GRPC:    registry.GRPCConfig{ListenAddress: "bufconn", ListenPort: 50051},
+		TTL:     registry.TTLConfig{Relay: time.Minute, Agent: time.Minute},
+	}
+	backend, err := memory.New(&registry.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	reg, err := registry.New(cfg, backend)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, placement := range []struct{ agent, relay string }{
+		{agent: "agent-1", relay: "relay-1"},
+		{agent: "agent-2", relay: "relay-2"},
+	} {
+		if err := reg.RegisterRelay(ctx, registry.Relay{ID: placement.relay, LastSeen: now}); err != nil {
+			t.Fatalf("register relay: %v", err)
+		}
+		if err := reg.RegisterAgent(ctx, registry.Agent{ID: placement.agent, LastHeartbeat: now}, placement.relay); err != nil {
+			t.Fatalf("register agent: %v", err)
+		}
+	}
+
+	// The backend does not enforce TTLs, so it accepts a placement on a
+	// relay the registry already considers expired.
+	if err := backend.RegisterRelay(ctx, registry.Relay{ID: "relay-gone", LastSeen: now.Add(-2 * time.Minute)}); err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+	if err := backend.RegisterAgent(ctx, registry.Agent{ID: "agent-stranded", LastHeartbeat: now}, "relay-gone"); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	return newAuthorizer(reg, registry.AuthConfig{
+		Enabled:                true,
+		RelayPrincipals:        []string{"spiffe://aero-arc/relay/{relay_id}"},
+		ControlPlanePrincipals: []string{"spiffe://aero-arc/control-plane"},
+	})
+}
+
+// principalContext returns a context whose peer presented a verified
+// client certificate with name as its URI SAN.
+func principalContext(t *testing.T, name string) context.Context {
+	t.Helper()
+
+	uri, err := url.Parse(name)
+	if err != nil {
+		t.Fatalf("parse principal: %v", err)
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: verifiedTLS(&x509.Certificate{URIs: []*url.URL{uri}}),
+	})
+
+	return withPrincipal(ctx)
+}
+
+type fakeStream struct {
+	gogrpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+)
+
+// toUnixMillis converts a time.Time into a protobuf millisecond timestamp.
+func toUnixMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixMilli()
+}
+
+// relayFromProto drops last_heartbeat_unix_ms, since the registry stamps
+// registrations with its own clock.
+func relayFromProto(relay *registryv1.Relay) registry.Relay {
+	return registry.Relay{
+		ID:       relay.GetRelayId(),
+		Address:  relay.GetAddress(),
+		GRPCPort: int(relay.GetGrpcPort()),
+	}
+}
+
+func relayToProto(relay registry.Relay) *registryv1.Relay {
+	return &registryv1.Relay{
+		RelayId:             relay.ID,
+		Address:             relay.Address,
+		GrpcPort:            int32(relay.GRPCPort),
+		LastHeartbeatUnixMs: toUnixMillis(relay.LastSeen),
+	}
+}
+
+// agentFromProto drops last_heartbeat_unix_ms, like relayFromProto.
+func agentFromProto(agent *registryv1.Agent) registry.Agent {
+	return registry.Agent{
+		ID: agent.GetAgentId(),
+	}
+}
+
+func placementToProto(placement *registry.AgentPlacement) *registryv1.AgentPlacement {
+	return &registryv1.AgentPlacement{
+		AgentId:           placement.AgentID,
+		RelayId:           placement.RelayID,
+		LastUpdatedUnixMs: toUnixMillis(placement.UpdatedAt),
+	}
+}
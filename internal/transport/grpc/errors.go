@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// unavailableRetryDelay is the backoff hint attached to Unavailable errors
+// so clients know when a retry is reasonable.
+const unavailableRetryDelay = time.Second
+
+// toStatus translates a registry domain error into a gRPC status error.
+// Errors that already carry a gRPC status are returned unchanged.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, registry.ErrRelayNotRegistered),
+		errors.Is(err, registry.ErrAgentNotRegistered):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, registry.ErrRelayIDEmpty),
+		errors.Is(err, registry.ErrAgentIDEmpty):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, registry.ErrCapacityExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, registry.ErrNotImplemented):
+		return status.Error(codes.Unimplemented, err.Error())
+	case isBackendUnavailable(err):
+		return unavailable(err)
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// isBackendUnavailable reports whether err is a transport-level failure
+// talking to the backend, as opposed to a logical error.
+func isBackendUnavailable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func unavailable(err error) error {
+	st := status.New(codes.Unavailable, err.Error())
+
+	detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(unavailableRetryDelay),
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	t.Parallel()
+
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "relay not registered", err: registry.ErrRelayNotRegistered, want: codes.NotFound},
+		{name: "agent not registered", err: registry.ErrAgentNotRegistered, want: codes.NotFound},
+		{name: "wrapped not registered", err: fmt.Errorf("lookup: %w", registry.ErrAgentNotRegistered), want: codes.NotFound},
+		{name: "relay id empty", err: registry.ErrRelayIDEmpty, want: codes.InvalidArgument},
+		{name: "agent id empty", err: registry.ErrAgentIDEmpty, want: codes.InvalidArgument},
+		{name: "capacity exceeded", err: registry.ErrCapacityExceeded, want: codes.ResourceExhausted},
+		{name: "context canceled", err: context.Canceled, want: codes.Canceled},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{name: "not implemented", err: registry.ErrNotImplemented, want: codes.Unimplemented},
+		{name: "dial refused", err: dialErr, want: codes.Unavailable},
+		{name: "connection dropped", err: io.EOF, want: codes.Unavailable},
+		{name: "existing status", err: status.Error(codes.PermissionDenied, "nope"), want: codes.PermissionDenied},
+		{name: "unknown error", err: errors.New("boom"), want: codes.Internal},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := status.Code(toStatus(test.err))
+			if got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+
+	if toStatus(nil) != nil {
+		t.Fatalf("expected nil error to map to nil")
+	}
+}
+
+func TestUnavailableCarriesRetryInfo(t *testing.T) {
+	t.Parallel()
+
+	err := toStatus(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if info.GetRetryDelay().AsDuration() != unavailableRetryDelay {
+				t.Fatalf("expected retry delay %s, got %s", unavailableRetryDelay, info.GetRetryDelay().AsDuration())
+			}
+			return
+		}
+	}
+	t.Fatalf("expected RetryInfo detail, got %v", st.Details())
+}
+
+func TestHandlersReturnNotFound(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.HeartbeatRelay(ctx, &registryv1.HeartbeatRelayRequest{RelayId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	_, err = client.RegisterAgent(ctx, &registryv1.RegisterAgentRequest{
+		Agent:   &registryv1.Agent{AgentId: "agent-1"},
+		RelayId: "missing",
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	_, err = client.GetAgentPlacement(ctx, &registryv1.GetAgentPlacementRequest{AgentId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
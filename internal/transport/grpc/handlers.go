@@ -8,26 +8,98 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// maxPort is the largest valid TCP port a relay may advertise.
+const maxPort = 65535
+
 func (s *Server) RegisterRelay(ctx context.Context, req *registryv1.RegisterRelayRequest) (*registryv1.RegisterRelayResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "RegisterRelay not implemented")
+	if req.GetRelay() == nil {
+		return nil, status.Error(codes.InvalidArgument, "relay is required")
+	}
+	if req.GetRelay().GetRelayId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "relay_id is required")
+	}
+	if port := req.GetRelay().GetGrpcPort(); port < 0 || port > maxPort {
+		return nil, status.Errorf(codes.InvalidArgument, "grpc_port %d out of range", port)
+	}
+
+	if err := s.registry.RegisterRelay(ctx, relayFromProto(req.GetRelay())); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &registryv1.RegisterRelayResponse{}, nil
 }
 
 func (s *Server) HeartbeatRelay(ctx context.Context, req *registryv1.HeartbeatRelayRequest) (*registryv1.HeartbeatRelayResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "HeartbeatRelay not implemented")
+	if req.GetRelayId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "relay_id is required")
+	}
+
+	// timestamp_unix_ms is ignored; the registry stamps heartbeats itself.
+	if err := s.registry.HeartbeatRelay(ctx, req.GetRelayId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &registryv1.HeartbeatRelayResponse{}, nil
 }
 
 func (s *Server) ListRelays(ctx context.Context, req *registryv1.ListRelaysRequest) (*registryv1.ListRelaysResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "ListRelays not implemented")
+	relays, err := s.registry.ListRelays(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &registryv1.ListRelaysResponse{
+		Relays: make([]*registryv1.Relay, 0, len(relays)),
+	}
+	for _, relay := range relays {
+		resp.Relays = append(resp.Relays, relayToProto(relay))
+	}
+
+	return resp, nil
 }
 
 func (s *Server) RegisterAgent(ctx context.Context, req *registryv1.RegisterAgentRequest) (*registryv1.RegisterAgentResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "RegisterAgent not implemented")
+	if req.GetAgent() == nil {
+		return nil, status.Error(codes.InvalidArgument, "agent is required")
+	}
+	if req.GetAgent().GetAgentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent_id is required")
+	}
+	if req.GetRelayId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "relay_id is required")
+	}
+
+	if err := s.registry.RegisterAgent(ctx, agentFromProto(req.GetAgent()), req.GetRelayId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &registryv1.RegisterAgentResponse{}, nil
 }
 
 func (s *Server) HeartbeatAgent(ctx context.Context, req *registryv1.HeartbeatAgentRequest) (*registryv1.HeartbeatAgentResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "HeartbeatAgent not implemented")
+	if req.GetAgentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent_id is required")
+	}
+
+	// timestamp_unix_ms is ignored; the registry stamps heartbeats itself.
+	if err := s.registry.HeartbeatAgent(ctx, req.GetAgentId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &registryv1.HeartbeatAgentResponse{}, nil
 }
 
 func (s *Server) GetAgentPlacement(ctx context.Context, req *registryv1.GetAgentPlacementRequest) (*registryv1.GetAgentPlacementResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "GetAgentPlacement not implemented")
+	if req.GetAgentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent_id is required")
+	}
+
+	placement, err := s.registry.GetAgentPlacement(ctx, req.GetAgentId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &registryv1.GetAgentPlacementResponse{
+		Placement: placementToProto(placement),
+	}, nil
 }
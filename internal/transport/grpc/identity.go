@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Principal is the identity of a caller authenticated by a verified client
+// certificate or bearer token.
+type Principal struct {
+	// Name identifies the caller: a token's sub claim, or the certificate's
+	// first URI SAN, else its first DNS SAN, else its subject common name.
+	Name string
+
+	// Certificate is the verified leaf certificate the caller presented,
+	// when it authenticated with one.
+	Certificate *x509.Certificate
+
+	// Claims holds the verified token claims, when the caller
+	// authenticated with a bearer token.
+	Claims map[string]any
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the caller identity attached to a request
+// context. It reports false when the caller presented neither a verified
+// client certificate nor a verified bearer token.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// withPrincipal attaches the identity of the peer's verified client
+// certificate, if any, to ctx.
+func withPrincipal(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	return context.WithValue(ctx, principalKey{}, Principal{
+		Name:        principalName(cert),
+		Certificate: cert,
+	})
+}
+
+func principalName(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+
+	return cert.Subject.CommonName
+}
+
+func principalUnaryInterceptor(ctx context.Context, req any, _ *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (any, error) {
+	return handler(withPrincipal(ctx), req)
+}
+
+func principalStreamInterceptor(srv any, ss gogrpc.ServerStream, _ *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: withPrincipal(ss.Context())})
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	gogrpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestWithPrincipal(t *testing.T) {
+	t.Parallel()
+
+	spiffe, _ := url.Parse("spiffe://aero-arc/relay/relay-1")
+
+	tests := []struct {
+		name     string
+		authInfo credentials.AuthInfo
+		wantOK   bool
+		wantName string
+	}{
+		{
+			name:     "uri san",
+			authInfo: verifiedTLS(&x509.Certificate{URIs: []*url.URL{spiffe}, DNSNames: []string{"relay-1.aero-arc"}}),
+			wantOK:   true,
+			wantName: "spiffe://aero-arc/relay/relay-1",
+		},
+		{
+			name:     "dns san",
+			authInfo: verifiedTLS(&x509.Certificate{DNSNames: []string{"relay-1.aero-arc"}, Subject: pkix.Name{CommonName: "relay-1"}}),
+			wantOK:   true,
+			wantName: "relay-1.aero-arc",
+		},
+		{
+			name:     "common name",
+			authInfo: verifiedTLS(&x509.Certificate{Subject: pkix.Name{CommonName: "relay-1"}}),
+			wantOK:   true,
+			wantName: "relay-1",
+		},
+		{
+			name:     "no client certificate",
+			authInfo: credentials.TLSInfo{},
+		},
+		{
+			name: "plaintext",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: test.authInfo})
+
+			principal, ok := PrincipalFromContext(withPrincipal(ctx))
+			if ok != test.wantOK {
+				t.Fatalf("expected ok %v, got %v", test.wantOK, ok)
+			}
+			if principal.Name != test.wantName {
+				t.Fatalf("expected name %q, got %q", test.wantName, principal.Name)
+			}
+		})
+	}
+}
+
+func TestPrincipalFromContextWithoutPeer(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := PrincipalFromContext(withPrincipal(context.Background())); ok {
+		t.Fatalf("expected no principal without a peer")
+	}
+}
+
+func verifiedTLS(leaf *x509.Certificate) credentials.TLSInfo {
+	return credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}},
+	}
+}
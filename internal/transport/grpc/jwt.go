@@ -0,0 +1,412 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// jwtLeeway tolerates clock skew between the token issuer and the registry
+// when checking exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+var (
+	errTokenMalformed      = errors.New("token is malformed")
+	errTokenAlgorithm      = errors.New("token algorithm is not accepted")
+	errTokenSignature      = errors.New("token signature is invalid")
+	errTokenExpired        = errors.New("token is expired")
+	errTokenNotYetValid    = errors.New("token is not valid yet")
+	errTokenIssuer         = errors.New("token issuer is not accepted")
+	errTokenAudience       = errors.New("token audience is not accepted")
+	errTokenSubjectMissing = errors.New("token has no sub claim")
+	errJWKSEmpty           = errors.New("jwks holds no usable RSA or P-256 signing keys")
+)
+
+// jwtVerifier authenticates callers by the bearer token in their
+// authorization metadata. HS256 tokens are checked against a shared secret
+// and RS256 and ES256 tokens against a local JWKS file, which is re-read
+// periodically so key rotation needs no restart.
+type jwtVerifier struct {
+	cfg    registry.JWTConfig
+	secret []byte
+	keys   atomic.Pointer[[]jwk]
+	now    func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// jwk is a public key from the JWKS file.
+type jwk struct {
+	kid string
+	key crypto.PublicKey
+}
+
+func newJWTVerifier(cfg registry.JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		cfg:    cfg,
+		secret: []byte(cfg.Secret),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+
+	if cfg.JWKSPath != "" {
+		if err := v.loadJWKS(); err != nil {
+			return nil, err
+		}
+
+		if cfg.JWKSReloadInterval > 0 {
+			go v.reloadJWKS(cfg.JWKSReloadInterval)
+		}
+	}
+
+	return v, nil
+}
+
+// close stops reloading the JWKS file.
+func (v *jwtVerifier) close() {
+	v.stopOnce.Do(func() { close(v.stop) })
+}
+
+func (v *jwtVerifier) reloadJWKS(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			if err := v.loadJWKS(); err != nil {
+				slog.Warn("jwks reload failed; keeping previous keys", "path", v.cfg.JWKSPath, "error", err)
+			}
+		}
+	}
+}
+
+func (v *jwtVerifier) loadJWKS() error {
+	data, err := os.ReadFile(v.cfg.JWKSPath)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("parse jwks %s: %w", v.cfg.JWKSPath, err)
+	}
+
+	v.keys.Store(&keys)
+	return nil
+}
+
+// parseJWKS decodes the signing keys of a JSON Web Key Set. Keys of other
+// types or uses are skipped, so a shared key set may hold them.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jwk
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		switch {
+		case raw.Kty == "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(raw.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: n: %w", raw.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(raw.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: e: %w", raw.Kid, err)
+			}
+			exponent := new(big.Int).SetBytes(e)
+			if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("key %q: invalid rsa public key", raw.Kid)
+			}
+
+			keys = append(keys, jwk{kid: raw.Kid, key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(exponent.Int64()),
+			}})
+		case raw.Kty == "EC" && raw.Crv == "P-256":
+			x, err := base64.RawURLEncoding.DecodeString(raw.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: x: %w", raw.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(raw.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: y: %w", raw.Kid, err)
+			}
+			if len(x) != 32 || len(y) != 32 {
+				return nil, fmt.Errorf("key %q: invalid p-256 public key", raw.Kid)
+			}
+			// ecdh rejects points that are not on the curve.
+			if _, err := ecdh.P256().NewPublicKey(slices.Concat([]byte{4}, x, y)); err != nil {
+				return nil, fmt.Errorf("key %q: %w", raw.Kid, err)
+			}
+
+			keys = append(keys, jwk{kid: raw.Kid, key: &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errJWKSEmpty
+	}
+
+	return keys, nil
+}
+
+// verify checks the token's signature and its exp, nbf, iss and aud
+// claims, and returns its claims.
+func (v *jwtVerifier) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *jwtVerifier) verifySignature(alg, kid, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "HS256":
+		if len(v.secret) == 0 {
+			return errTokenAlgorithm
+		}
+
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errTokenSignature
+		}
+
+		return nil
+	case "RS256", "ES256":
+		keys := v.keys.Load()
+		if keys == nil {
+			return errTokenAlgorithm
+		}
+
+		for _, k := range *keys {
+			if kid != "" && k.kid != kid {
+				continue
+			}
+
+			switch key := k.key.(type) {
+			case *rsa.PublicKey:
+				if alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+					return nil
+				}
+			case *ecdsa.PublicKey:
+				// JWS carries ES256 signatures as the 32-byte r and s concatenated.
+				if alg == "ES256" && len(signature) == 64 &&
+					ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+					return nil
+				}
+			}
+		}
+
+		return errTokenSignature
+	default:
+		return errTokenAlgorithm
+	}
+}
+
+func (v *jwtVerifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: exp claim missing", errTokenMalformed)
+	}
+	if now.After(unixSeconds(exp).Add(jwtLeeway)) {
+		return errTokenExpired
+	}
+
+	if raw, ok := claims["nbf"]; ok {
+		nbf, ok := raw.(float64)
+		if !ok {
+			return fmt.Errorf("%w: nbf claim invalid", errTokenMalformed)
+		}
+		if now.Add(jwtLeeway).Before(unixSeconds(nbf)) {
+			return errTokenNotYetValid
+		}
+	}
+
+	if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+		return errTokenIssuer
+	}
+
+	if !audienceContains(claims["aud"], v.cfg.Audience) {
+		return errTokenAudience
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errTokenSubjectMissing
+	}
+
+	return nil
+}
+
+// audienceContains reports whether an aud claim, a string or an array of
+// strings, includes audience.
+func audienceContains(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, entry := range aud {
+			if entry == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errTokenMalformed
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		return errTokenMalformed
+	}
+
+	return nil
+}
+
+func unixSeconds(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// authenticate attaches the principal named by the caller's bearer token
+// to ctx. A caller without a token is let through only if it already has a
+// principal from a verified client certificate.
+func (v *jwtVerifier) authenticate(ctx context.Context) (context.Context, error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		if _, ok := PrincipalFromContext(ctx); ok {
+			return ctx, nil
+		}
+
+		return nil, status.Error(codes.Unauthenticated, "bearer token required")
+	}
+
+	claims, err := v.verify(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid bearer token: %v", err)
+	}
+
+	principal := Principal{Name: claims["sub"].(string), Claims: claims}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// bearerToken returns the token from the caller's authorization metadata.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, value := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(value, " ")
+		if ok && strings.EqualFold(scheme, "bearer") && token != "" {
+			return token, true
+		}
+	}
+
+	return "", false
+}
+
+func (v *jwtVerifier) unaryInterceptor(ctx context.Context, req any, _ *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (any, error) {
+	ctx, err := v.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (v *jwtVerifier) streamInterceptor(srv any, ss gogrpc.ServerStream, _ *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) error {
+	ctx, err := v.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
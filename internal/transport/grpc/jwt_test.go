@@ -0,0 +1,409 @@
+package grpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testIssuer   = "https://auth.aero-arc"
+	testAudience = "aero-arc-registry"
+	testSecret   = "shared-secret"
+)
+
+func TestJWTVerifierUnary(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+
+	verifier := newTestVerifier(t, registry.JWTConfig{
+		Secret:   testSecret,
+		JWKSPath: writeJWKS(t, t.TempDir(), jwkFor("rsa-1", &rsaKey.PublicKey), jwkFor("ec-1", &ecKey.PublicKey)),
+	})
+
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub": "relay-1",
+			"iss": testIssuer,
+			"aud": testAudience,
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := valid()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		principal     string
+		want          codes.Code
+	}{
+		{
+			name:          "hs256",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), valid()),
+			want:          codes.OK,
+		},
+		{
+			name:          "rs256",
+			authorization: "Bearer " + signToken(t, "RS256", "rsa-1", rsaKey, valid()),
+			want:          codes.OK,
+		},
+		{
+			name:          "es256",
+			authorization: "Bearer " + signToken(t, "ES256", "ec-1", ecKey, valid()),
+			want:          codes.OK,
+		},
+		{
+			name:          "es256 without kid",
+			authorization: "bearer " + signToken(t, "ES256", "", ecKey, valid()),
+			want:          codes.OK,
+		},
+		{
+			name:          "audience list",
+			authorization: "Bearer " + signToken(t, "ES256", "ec-1", ecKey, with("aud", []string{"other", testAudience})),
+			want:          codes.OK,
+		},
+		{
+			name:          "hs256 wrong secret",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte("wrong"), valid()),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "unknown key",
+			authorization: "Bearer " + signToken(t, "ES256", "ec-1", otherKey, valid()),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "algorithm none",
+			authorization: "Bearer " + signToken(t, "none", "", nil, valid()),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "expired",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("exp", now.Add(-time.Hour).Unix())),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "missing exp",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("exp", nil)),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "not yet valid",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("nbf", now.Add(time.Hour).Unix())),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "wrong issuer",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("iss", "https://other")),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "wrong audience",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("aud", "other")),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "missing subject",
+			authorization: "Bearer " + signToken(t, "HS256", "", []byte(testSecret), with("sub", nil)),
+			want:          codes.Unauthenticated,
+		},
+		{
+			name:          "malformed",
+			authorization: "Bearer not-a-token",
+			want:          codes.Unauthenticated,
+		},
+		{
+			name: "missing token",
+			want: codes.Unauthenticated,
+		},
+		{
+			name:      "client certificate without token",
+			principal: "spiffe://aero-arc/relay/relay-1",
+			want:      codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if test.principal != "" {
+				ctx = principalContext(t, test.principal)
+			}
+			if test.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.authorization))
+			}
+
+			var got Principal
+			handler := func(ctx context.Context, _ any) (any, error) {
+				got, _ = PrincipalFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := verifier.unaryInterceptor(ctx, nil, &gogrpc.UnaryServerInfo{FullMethod: "/test"}, handler)
+			if status.Code(err) != test.want {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+			if test.want != codes.OK {
+				return
+			}
+
+			wantName := "relay-1"
+			if test.principal != "" {
+				wantName = test.principal
+			}
+			if got.Name != wantName {
+				t.Fatalf("expected principal %q, got %q", wantName, got.Name)
+			}
+			if test.authorization != "" && got.Claims["iss"] != testIssuer {
+				t.Fatalf("expected claims attached, got %v", got.Claims)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierStream(t *testing.T) {
+	t.Parallel()
+
+	verifier := newTestVerifier(t, registry.JWTConfig{Secret: testSecret})
+
+	token := signToken(t, "HS256", "", []byte(testSecret), map[string]any{
+		"sub": "control-plane",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+	var got Principal
+	handler := func(_ any, ss gogrpc.ServerStream) error {
+		got, _ = PrincipalFromContext(ss.Context())
+		return nil
+	}
+
+	info := &gogrpc.StreamServerInfo{FullMethod: "/test"}
+	if err := verifier.streamInterceptor(nil, &fakeStream{ctx: ctx}, info, handler); err != nil {
+		t.Fatalf("stream with token: %v", err)
+	}
+	if got.Name != "control-plane" {
+		t.Fatalf("expected principal %q, got %q", "control-plane", got.Name)
+	}
+
+	err := verifier.streamInterceptor(nil, &fakeStream{ctx: context.Background()}, info, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestJWTVerifierReloadsJWKS(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+
+	dir := t.TempDir()
+	verifier := newTestVerifier(t, registry.JWTConfig{
+		JWKSPath:           writeJWKS(t, dir, jwkFor("old", &oldKey.PublicKey)),
+		JWKSReloadInterval: 10 * time.Millisecond,
+	})
+
+	claims := map[string]any{
+		"sub": "relay-1",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	token := signToken(t, "ES256", "new", newKey, claims)
+
+	if _, err := verifier.verify(token); err == nil {
+		t.Fatalf("expected token signed by an unknown key to be rejected")
+	}
+
+	writeJWKS(t, dir, jwkFor("new", &newKey.PublicKey))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := verifier.verify(token)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected rotated key to be loaded, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A broken key set keeps the previous keys in use.
+	if err := os.WriteFile(filepath.Join(dir, "jwks.json"), []byte("{"), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := verifier.verify(token); err != nil {
+		t.Fatalf("expected previous keys after a failed reload, got %v", err)
+	}
+}
+
+func TestNewJWTVerifierInvalidJWKS(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	if _, err := newJWTVerifier(registry.JWTConfig{JWKSPath: path}); err == nil {
+		t.Fatalf("expected error for jwks without signing keys")
+	}
+}
+
+func newTestVerifier(t *testing.T, cfg registry.JWTConfig) *jwtVerifier {
+	t.Helper()
+
+	cfg.Enabled = true
+	cfg.Issuer = testIssuer
+	cfg.Audience = testAudience
+
+	verifier, err := newJWTVerifier(cfg)
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	t.Cleanup(verifier.close)
+
+	return verifier
+}
+
+// writeJWKS writes keys as dir/jwks.json and returns its path.
+func writeJWKS(t *testing.T, dir string, keys ...map[string]string) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	path := filepath.Join(dir, "jwks.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	return path
+}
+
+func jwkFor(kid string, key crypto.PublicKey) map[string]string {
+	enc := base64.RawURLEncoding
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   enc.EncodeToString(key.N.Bytes()),
+			"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"crv": "P-256",
+			"x":   enc.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   enc.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	default:
+		panic("unsupported key type")
+	}
+}
+
+func signToken(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal token: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
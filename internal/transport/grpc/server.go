@@ -14,16 +14,47 @@ import (
 type Server struct {
 	registryv1.UnimplementedAeroRegistryServer
 	registry   *registry.Registry
+	jwt        *jwtVerifier
 	grpcServer *gogrpc.Server
 }
 
 var _ registryv1.AeroRegistryServer = (*Server)(nil)
 
-func New(reg *registry.Registry, opts ...gogrpc.ServerOption) (*Server, error) {
+// New builds the gRPC server. Handlers can identify callers that presented
+// a verified client certificate or bearer token through
+// PrincipalFromContext. When cfg.JWT is enabled, callers with neither are
+// rejected as unauthenticated, and when cfg.Auth is enabled, calls its
+// policy does not grant are rejected before reaching a handler.
+func New(reg *registry.Registry, cfg registry.GRPCConfig, opts ...gogrpc.ServerOption) (*Server, error) {
 	s := &Server{
 		registry: reg,
 	}
 
+	unary := []gogrpc.UnaryServerInterceptor{principalUnaryInterceptor}
+	stream := []gogrpc.StreamServerInterceptor{principalStreamInterceptor}
+
+	if cfg.JWT.Enabled {
+		verifier, err := newJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+
+		s.jwt = verifier
+		unary = append(unary, verifier.unaryInterceptor)
+		stream = append(stream, verifier.streamInterceptor)
+	}
+
+	if cfg.Auth.Enabled {
+		authz := newAuthorizer(reg, cfg.Auth)
+		unary = append(unary, authz.unaryInterceptor)
+		stream = append(stream, authz.streamInterceptor)
+	}
+
+	opts = append([]gogrpc.ServerOption{
+		gogrpc.ChainUnaryInterceptor(unary...),
+		gogrpc.ChainStreamInterceptor(stream...),
+	}, opts...)
+
 	s.grpcServer = gogrpc.NewServer(opts...)
 	registryv1.RegisterAeroRegistryServer(s.grpcServer, s)
 
@@ -36,4 +67,8 @@ func (s *Server) Serve(lis net.Listener) error {
 
 func (s *Server) GracefulStop() {
 	s.grpcServer.GracefulStop()
+
+	if s.jwt != nil {
+		s.jwt.close()
+	}
 }
@@ -0,0 +1,234 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
+	registryv1 "github.com/aero-arc/aero-arc-protos/gen/go/aeroarc/registry/v1"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1024 * 1024
+
+func TestRelayAndAgentLifecycle(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	_, err := client.RegisterRelay(ctx, &registryv1.RegisterRelayRequest{
+		Relay: &registryv1.Relay{
+			RelayId:             "relay-1",
+			Address:             "10.0.0.1",
+			GrpcPort:            50052,
+			LastHeartbeatUnixMs: now.UnixMilli(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("register relay: %v", err)
+	}
+
+	// The registry stamps heartbeats itself, ignoring a client clock that
+	// runs ahead.
+	hb := now.Add(time.Hour)
+	if _, err := client.HeartbeatRelay(ctx, &registryv1.HeartbeatRelayRequest{
+		RelayId:         "relay-1",
+		TimestampUnixMs: hb.UnixMilli(),
+	}); err != nil {
+		t.Fatalf("heartbeat relay: %v", err)
+	}
+
+	listResp, err := client.ListRelays(ctx, &registryv1.ListRelaysRequest{})
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(listResp.GetRelays()) != 1 {
+		t.Fatalf("expected 1 relay, got %d", len(listResp.GetRelays()))
+	}
+	relay := listResp.GetRelays()[0]
+	if relay.GetRelayId() != "relay-1" || relay.GetAddress() != "10.0.0.1" || relay.GetGrpcPort() != 50052 {
+		t.Fatalf("unexpected relay: %v", relay)
+	}
+	if got := relay.GetLastHeartbeatUnixMs(); got < now.UnixMilli() || got > time.Now().UnixMilli() {
+		t.Fatalf("expected last heartbeat stamped by the registry after %d, got %d", now.UnixMilli(), got)
+	}
+
+	if _, err := client.RegisterAgent(ctx, &registryv1.RegisterAgentRequest{
+		Agent:   &registryv1.Agent{AgentId: "agent-1", LastHeartbeatUnixMs: now.UnixMilli()},
+		RelayId: "relay-1",
+	}); err != nil {
+		t.Fatalf("register agent: %v", err)
+	}
+
+	if _, err := client.HeartbeatAgent(ctx, &registryv1.HeartbeatAgentRequest{
+		AgentId:         "agent-1",
+		TimestampUnixMs: hb.UnixMilli(),
+	}); err != nil {
+		t.Fatalf("heartbeat agent: %v", err)
+	}
+
+	placementResp, err := client.GetAgentPlacement(ctx, &registryv1.GetAgentPlacementRequest{AgentId: "agent-1"})
+	if err != nil {
+		t.Fatalf("get placement: %v", err)
+	}
+	placement := placementResp.GetPlacement()
+	if placement.GetAgentId() != "agent-1" || placement.GetRelayId() != "relay-1" {
+		t.Fatalf("unexpected placement: %v", placement)
+	}
+	if got := placement.GetLastUpdatedUnixMs(); got < now.UnixMilli() || got > time.Now().UnixMilli() {
+		t.Fatalf("expected last updated stamped by the registry after %d, got %d", now.UnixMilli(), got)
+	}
+}
+
+func TestListRelaysEmpty(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.ListRelays(context.Background(), &registryv1.ListRelaysRequest{})
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	if len(resp.GetRelays()) != 0 {
+		t.Fatalf("expected no relays, got %d", len(resp.GetRelays()))
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "register relay missing relay",
+			call: func() error {
+				_, err := client.RegisterRelay(ctx, &registryv1.RegisterRelayRequest{})
+				return err
+			},
+		},
+		{
+			name: "register relay missing id",
+			call: func() error {
+				_, err := client.RegisterRelay(ctx, &registryv1.RegisterRelayRequest{
+					Relay: &registryv1.Relay{Address: "10.0.0.1"},
+				})
+				return err
+			},
+		},
+		{
+			name: "register relay invalid port",
+			call: func() error {
+				_, err := client.RegisterRelay(ctx, &registryv1.RegisterRelayRequest{
+					Relay: &registryv1.Relay{RelayId: "relay-1", GrpcPort: 70000},
+				})
+				return err
+			},
+		},
+		{
+			name: "heartbeat relay missing id",
+			call: func() error {
+				_, err := client.HeartbeatRelay(ctx, &registryv1.HeartbeatRelayRequest{})
+				return err
+			},
+		},
+		{
+			name: "register agent missing agent",
+			call: func() error {
+				_, err := client.RegisterAgent(ctx, &registryv1.RegisterAgentRequest{RelayId: "relay-1"})
+				return err
+			},
+		},
+		{
+			name: "register agent missing agent id",
+			call: func() error {
+				_, err := client.RegisterAgent(ctx, &registryv1.RegisterAgentRequest{
+					Agent:   &registryv1.Agent{},
+					RelayId: "relay-1",
+				})
+				return err
+			},
+		},
+		{
+			name: "register agent missing relay id",
+			call: func() error {
+				_, err := client.RegisterAgent(ctx, &registryv1.RegisterAgentRequest{
+					Agent: &registryv1.Agent{AgentId: "agent-1"},
+				})
+				return err
+			},
+		},
+		{
+			name: "heartbeat agent missing id",
+			call: func() error {
+				_, err := client.HeartbeatAgent(ctx, &registryv1.HeartbeatAgentRequest{})
+				return err
+			},
+		},
+		{
+			name: "get placement missing id",
+			call: func() error {
+				_, err := client.GetAgentPlacement(ctx, &registryv1.GetAgentPlacementRequest{})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func newTestClient(t *testing.T) registryv1.AeroRegistryClient {
+	t.Helper()
+
+	cfg := &registry.Config{
+		Backend: registry.BackendConfig{Type: registry.MemoryRegistryBackend},
+		GRPC:    registry.GRPCConfig{ListenAddress: "bufconn", ListenPort: 50051},
+		TTL:     registry.TTLConfig{Relay: time.Minute, Agent: time.Minute},
+	}
+	backend, err := memory.New(&registry.MemoryConfig{})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	reg, err := registry.New(cfg, backend)
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	server, err := New(reg, cfg.GRPC)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	lis := bufconn.Listen(bufSize)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.GracefulStop)
+
+	conn, err := gogrpc.NewClient("passthrough:///bufconn",
+		gogrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return registryv1.NewAeroRegistryClient(conn)
+}
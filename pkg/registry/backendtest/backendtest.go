@@ -0,0 +1,834 @@
+// Package backendtest provides a conformance suite for driver.Backend
+// implementations.
+//
+// Every backend, including out-of-tree ones, is expected to pass the suite
+// from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.Run(t, func(t *testing.T) driver.Backend {
+//			return newBackendForTest(t)
+//		})
+//	}
+//
+// The suite only relies on the driver.Backend contract. It never assumes
+// an ordering for list results, and it exercises TTL semantics through
+// registry.Registry so that liveness behaves identically on every backend.
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+)
+
+// Factory returns a new, empty backend for a single test case. The suite
+// closes the backend when the test case finishes.
+type Factory func(t *testing.T) driver.Backend
+
+type testCase struct {
+	name string
+	run  func(t *testing.T, b driver.Backend)
+}
+
+// Run executes the full conformance suite against backends created by
+// newBackend.
+func Run(t *testing.T, newBackend Factory) {
+	t.Helper()
+
+	groups := []struct {
+		name  string
+		cases []testCase
+	}{
+		{name: "CRUD", cases: crudCases},
+		{name: "Errors", cases: errorCases},
+		{name: "Ordering", cases: orderingCases},
+		{name: "Context", cases: contextCases},
+		{name: "Concurrency", cases: concurrencyCases},
+		{name: "TTL", cases: ttlCases},
+		{name: "Watch", cases: watchCases},
+	}
+
+	for _, group := range groups {
+		t.Run(group.name, func(t *testing.T) {
+			for _, tc := range group.cases {
+				t.Run(tc.name, func(t *testing.T) {
+					b := newBackend(t)
+					t.Cleanup(func() {
+						_ = b.Close(context.Background())
+					})
+					tc.run(t, b)
+				})
+			}
+		})
+	}
+}
+
+var crudCases = []testCase{
+	{
+		name: "register relay round trips all fields",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			want := driver.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: now}
+			mustRegisterRelay(t, b, want)
+
+			got := mustGetRelay(t, b, want.ID)
+			assertRelay(t, got, want)
+		},
+	},
+	{
+		name: "register relay defaults last seen",
+		run: func(t *testing.T, b driver.Backend) {
+			before := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1"})
+
+			got := mustGetRelay(t, b, "relay-1")
+			if got.LastSeen.Before(before.Add(-time.Second)) {
+				t.Fatalf("expected last seen to default to now, got %v", got.LastSeen)
+			}
+		},
+	},
+	{
+		name: "re-register relay replaces record",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 1, LastSeen: now})
+
+			want := driver.Relay{ID: "relay-1", Address: "10.0.0.2", GRPCPort: 2, LastSeen: now.Add(time.Second)}
+			mustRegisterRelay(t, b, want)
+
+			relays := mustListRelays(t, b)
+			if len(relays) != 1 {
+				t.Fatalf("expected 1 relay, got %d", len(relays))
+			}
+			assertRelay(t, relays[0], want)
+		},
+	},
+	{
+		name: "heartbeat relay only refreshes last seen",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			relay := driver.Relay{ID: "relay-1", Address: "10.0.0.1", GRPCPort: 50051, LastSeen: now}
+			mustRegisterRelay(t, b, relay)
+
+			hb := now.Add(5 * time.Second)
+			if err := b.HeartbeatRelay(context.Background(), relay.ID, hb); err != nil {
+				t.Fatalf("heartbeat relay: %v", err)
+			}
+
+			relay.LastSeen = hb
+			assertRelay(t, mustGetRelay(t, b, relay.ID), relay)
+		},
+	},
+	{
+		name: "heartbeat relay defaults timestamp",
+		run: func(t *testing.T, b driver.Backend) {
+			old := time.Now().Add(-time.Hour)
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: old})
+
+			if err := b.HeartbeatRelay(context.Background(), "relay-1", time.Time{}); err != nil {
+				t.Fatalf("heartbeat relay: %v", err)
+			}
+
+			got := mustGetRelay(t, b, "relay-1")
+			if !got.LastSeen.After(old) {
+				t.Fatalf("expected zero heartbeat to default to now, got %v", got.LastSeen)
+			}
+		},
+	},
+	{
+		name: "list relays returns every relay",
+		run: func(t *testing.T, b driver.Backend) {
+			for _, id := range []string{"relay-c", "relay-a", "relay-b"} {
+				mustRegisterRelay(t, b, driver.Relay{ID: id, LastSeen: time.Now()})
+			}
+
+			assertRelayIDs(t, mustListRelays(t, b), "relay-a", "relay-b", "relay-c")
+		},
+	},
+	{
+		name: "list on empty backend",
+		run: func(t *testing.T, b driver.Backend) {
+			if relays := mustListRelays(t, b); len(relays) != 0 {
+				t.Fatalf("expected no relays, got %#v", relays)
+			}
+			if placements := mustListPlacements(t, b); len(placements) != 0 {
+				t.Fatalf("expected no placements, got %#v", placements)
+			}
+		},
+	},
+	{
+		name: "remove relay",
+		run: func(t *testing.T, b driver.Backend) {
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-2", LastSeen: time.Now()})
+
+			if err := b.RemoveRelay(context.Background(), "relay-1"); err != nil {
+				t.Fatalf("remove relay: %v", err)
+			}
+
+			assertRelayIDs(t, mustListRelays(t, b), "relay-2")
+		},
+	},
+	{
+		name: "register agent creates placement",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1")
+
+			assertPlacement(t, *mustGetPlacement(t, b, "agent-1"), driver.AgentPlacement{
+				AgentID:   "agent-1",
+				RelayID:   "relay-1",
+				UpdatedAt: now,
+			})
+		},
+	},
+	{
+		name: "register agent defaults heartbeat",
+		run: func(t *testing.T, b driver.Backend) {
+			before := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1"})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+
+			got := mustGetPlacement(t, b, "agent-1")
+			if got.UpdatedAt.Before(before.Add(-time.Second)) {
+				t.Fatalf("expected updated at to default to now, got %v", got.UpdatedAt)
+			}
+		},
+	},
+	{
+		name: "re-register agent moves placement",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-2", LastSeen: now})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1")
+
+			moved := now.Add(time.Second)
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: moved}, "relay-2")
+
+			assertPlacement(t, *mustGetPlacement(t, b, "agent-1"), driver.AgentPlacement{
+				AgentID:   "agent-1",
+				RelayID:   "relay-2",
+				UpdatedAt: moved,
+			})
+			if placements := mustListPlacements(t, b); len(placements) != 1 {
+				t.Fatalf("expected 1 placement, got %#v", placements)
+			}
+		},
+	},
+	{
+		name: "heartbeat agent refreshes placement",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1")
+
+			hb := now.Add(5 * time.Second)
+			if err := b.HeartbeatAgent(context.Background(), "agent-1", hb); err != nil {
+				t.Fatalf("heartbeat agent: %v", err)
+			}
+
+			assertPlacement(t, *mustGetPlacement(t, b, "agent-1"), driver.AgentPlacement{
+				AgentID:   "agent-1",
+				RelayID:   "relay-1",
+				UpdatedAt: hb,
+			})
+		},
+	},
+	{
+		name: "list placements returns every placement",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-2", LastSeen: now})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-2", LastHeartbeat: now}, "relay-2")
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: now}, "relay-1")
+
+			placements := mustListPlacements(t, b)
+			slices.SortFunc(placements, func(a, b driver.AgentPlacement) int {
+				return strings.Compare(a.AgentID, b.AgentID)
+			})
+			if len(placements) != 2 {
+				t.Fatalf("expected 2 placements, got %#v", placements)
+			}
+			assertPlacement(t, placements[0], driver.AgentPlacement{AgentID: "agent-1", RelayID: "relay-1", UpdatedAt: now})
+			assertPlacement(t, placements[1], driver.AgentPlacement{AgentID: "agent-2", RelayID: "relay-2", UpdatedAt: now})
+		},
+	},
+	{
+		name: "remove agent",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-2"}, "relay-1")
+
+			if err := b.RemoveAgent(ctx, "agent-1"); err != nil {
+				t.Fatalf("remove agent: %v", err)
+			}
+
+			if _, err := b.GetAgentPlacement(ctx, "agent-1"); !errors.Is(err, driver.ErrAgentNotRegistered) {
+				t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+			}
+			if err := b.HeartbeatAgent(ctx, "agent-1", time.Now()); !errors.Is(err, driver.ErrAgentNotRegistered) {
+				t.Fatalf("expected ErrAgentNotRegistered, got %v", err)
+			}
+			placements := mustListPlacements(t, b)
+			if len(placements) != 1 || placements[0].AgentID != "agent-2" {
+				t.Fatalf("expected only agent-2 to remain, got %#v", placements)
+			}
+		},
+	},
+	{
+		name: "remove relay does not cascade",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+
+			if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+				t.Fatalf("remove relay: %v", err)
+			}
+
+			// Backends are persistence-only; cascading is the registry's job.
+			if _, err := b.GetAgentPlacement(ctx, "agent-1"); err != nil {
+				t.Fatalf("expected placement to remain persisted, got %v", err)
+			}
+		},
+	},
+	{
+		name: "returned placement is a copy",
+		run: func(t *testing.T, b driver.Backend) {
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+
+			placement := mustGetPlacement(t, b, "agent-1")
+			placement.RelayID = "mutated"
+
+			if got := mustGetPlacement(t, b, "agent-1"); got.RelayID != "relay-1" {
+				t.Fatalf("expected stored placement to be unaffected, got %q", got.RelayID)
+			}
+		},
+	},
+}
+
+var errorCases = []testCase{
+	{
+		name: "empty ids",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			now := time.Now()
+
+			assertErr(t, b.RegisterRelay(ctx, driver.Relay{}), driver.ErrRelayIDEmpty)
+			assertErr(t, b.HeartbeatRelay(ctx, "", now), driver.ErrRelayIDEmpty)
+			assertErr(t, b.RemoveRelay(ctx, ""), driver.ErrRelayIDEmpty)
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{}, "relay-1"), driver.ErrAgentIDEmpty)
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{ID: "agent-1"}, ""), driver.ErrRelayIDEmpty)
+			assertErr(t, b.HeartbeatAgent(ctx, "", now), driver.ErrAgentIDEmpty)
+			assertErr(t, b.RemoveAgent(ctx, ""), driver.ErrAgentIDEmpty)
+
+			_, err := b.GetAgentPlacement(ctx, "")
+			assertErr(t, err, driver.ErrAgentIDEmpty)
+		},
+	},
+	{
+		name: "not registered",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			now := time.Now()
+
+			assertErr(t, b.HeartbeatRelay(ctx, "missing", now), driver.ErrRelayNotRegistered)
+			assertErr(t, b.RemoveRelay(ctx, "missing"), driver.ErrRelayNotRegistered)
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{ID: "agent-1"}, "missing"), driver.ErrRelayNotRegistered)
+			assertErr(t, b.HeartbeatAgent(ctx, "missing", now), driver.ErrAgentNotRegistered)
+			assertErr(t, b.RemoveAgent(ctx, "missing"), driver.ErrAgentNotRegistered)
+
+			_, err := b.GetAgentPlacement(ctx, "missing")
+			assertErr(t, err, driver.ErrAgentNotRegistered)
+		},
+	},
+	{
+		name: "failed register agent leaves no state",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{ID: "agent-1"}, "missing"), driver.ErrRelayNotRegistered)
+
+			_, err := b.GetAgentPlacement(ctx, "agent-1")
+			assertErr(t, err, driver.ErrAgentNotRegistered)
+			assertErr(t, b.HeartbeatAgent(ctx, "agent-1", time.Now()), driver.ErrAgentNotRegistered)
+		},
+	},
+}
+
+var orderingCases = []testCase{
+	{
+		name: "heartbeat before register",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+
+			assertErr(t, b.HeartbeatRelay(ctx, "relay-1", time.Now()), driver.ErrRelayNotRegistered)
+			if relays := mustListRelays(t, b); len(relays) != 0 {
+				t.Fatalf("heartbeat must not create a relay, got %#v", relays)
+			}
+		},
+	},
+	{
+		name: "register agent after relay removed",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+				t.Fatalf("remove relay: %v", err)
+			}
+
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{ID: "agent-1"}, "relay-1"), driver.ErrRelayNotRegistered)
+			assertErr(t, b.HeartbeatRelay(ctx, "relay-1", time.Now()), driver.ErrRelayNotRegistered)
+		},
+	},
+	{
+		name: "re-register after remove",
+		run: func(t *testing.T, b driver.Backend) {
+			ctx := context.Background()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+			if err := b.RemoveAgent(ctx, "agent-1"); err != nil {
+				t.Fatalf("remove agent: %v", err)
+			}
+			if err := b.RemoveRelay(ctx, "relay-1"); err != nil {
+				t.Fatalf("remove relay: %v", err)
+			}
+
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+			if got := mustGetPlacement(t, b, "agent-1"); got.RelayID != "relay-1" {
+				t.Fatalf("expected placement on relay-1, got %q", got.RelayID)
+			}
+		},
+	},
+	{
+		name: "heartbeat refreshes a record past its ttl",
+		run: func(t *testing.T, b driver.Backend) {
+			// The record is older than any backend's TTL but has not been
+			// reaped, so a late heartbeat still finds it.
+			now := time.Now()
+			stale := now.Add(-time.Hour)
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-2", LastSeen: stale})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1", LastHeartbeat: stale}, "relay-1")
+
+			if err := b.HeartbeatRelay(context.Background(), "relay-2", now); err != nil {
+				t.Fatalf("heartbeat stale relay: %v", err)
+			}
+			if err := b.HeartbeatAgent(context.Background(), "agent-1", now); err != nil {
+				t.Fatalf("heartbeat stale agent: %v", err)
+			}
+			if got := mustGetRelay(t, b, "relay-2"); !got.LastSeen.Equal(now) {
+				t.Fatalf("expected last seen %v, got %v", now, got.LastSeen)
+			}
+			if got := mustGetPlacement(t, b, "agent-1"); !got.UpdatedAt.Equal(now) {
+				t.Fatalf("expected placement updated at %v, got %v", now, got.UpdatedAt)
+			}
+		},
+	},
+	{
+		name: "older heartbeat is stored as given",
+		run: func(t *testing.T, b driver.Backend) {
+			now := time.Now()
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: now})
+
+			// Backends store what they are given; rejecting clock skew is
+			// not part of the contract.
+			older := now.Add(-time.Second)
+			if err := b.HeartbeatRelay(context.Background(), "relay-1", older); err != nil {
+				t.Fatalf("heartbeat relay: %v", err)
+			}
+			if got := mustGetRelay(t, b, "relay-1"); !got.LastSeen.Equal(older) {
+				t.Fatalf("expected last seen %v, got %v", older, got.LastSeen)
+			}
+		},
+	},
+}
+
+var contextCases = []testCase{
+	{
+		name: "canceled context",
+		run: func(t *testing.T, b driver.Backend) {
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+			mustRegisterAgent(t, b, driver.Agent{ID: "agent-1"}, "relay-1")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			now := time.Now()
+
+			assertErr(t, b.RegisterRelay(ctx, driver.Relay{ID: "relay-2"}), context.Canceled)
+			assertErr(t, b.HeartbeatRelay(ctx, "relay-1", now), context.Canceled)
+			assertErr(t, b.RemoveRelay(ctx, "relay-1"), context.Canceled)
+			assertErr(t, b.RegisterAgent(ctx, driver.Agent{ID: "agent-2"}, "relay-1"), context.Canceled)
+			assertErr(t, b.HeartbeatAgent(ctx, "agent-1", now), context.Canceled)
+			assertErr(t, b.RemoveAgent(ctx, "agent-1"), context.Canceled)
+
+			_, err := b.ListRelays(ctx)
+			assertErr(t, err, context.Canceled)
+			_, err = b.GetAgentPlacement(ctx, "agent-1")
+			assertErr(t, err, context.Canceled)
+			_, err = b.ListPlacements(ctx)
+			assertErr(t, err, context.Canceled)
+
+			// Nothing was written by the canceled calls.
+			assertRelayIDs(t, mustListRelays(t, b), "relay-1")
+			if placements := mustListPlacements(t, b); len(placements) != 1 {
+				t.Fatalf("expected 1 placement, got %#v", placements)
+			}
+		},
+	},
+}
+
+var concurrencyCases = []testCase{
+	{
+		name: "parallel registrations and heartbeats",
+		run: func(t *testing.T, b driver.Backend) {
+			const (
+				relays         = 4
+				agentsPerRelay = 8
+				heartbeats     = 10
+			)
+			ctx := context.Background()
+
+			for i := 0; i < relays; i++ {
+				mustRegisterRelay(t, b, driver.Relay{ID: fmt.Sprintf("relay-%d", i), LastSeen: time.Now()})
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, relays*agentsPerRelay)
+			for i := 0; i < relays*agentsPerRelay; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					relayID := fmt.Sprintf("relay-%d", i%relays)
+					agentID := fmt.Sprintf("agent-%d", i)
+					if err := b.RegisterAgent(ctx, driver.Agent{ID: agentID}, relayID); err != nil {
+						errs <- fmt.Errorf("register %s: %w", agentID, err)
+						return
+					}
+					for j := 0; j < heartbeats; j++ {
+						if err := b.HeartbeatAgent(ctx, agentID, time.Now()); err != nil {
+							errs <- fmt.Errorf("heartbeat %s: %w", agentID, err)
+							return
+						}
+						if err := b.HeartbeatRelay(ctx, relayID, time.Now()); err != nil {
+							errs <- fmt.Errorf("heartbeat %s: %w", relayID, err)
+							return
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			if got := mustListRelays(t, b); len(got) != relays {
+				t.Fatalf("expected %d relays, got %d", relays, len(got))
+			}
+			placements := mustListPlacements(t, b)
+			if len(placements) != relays*agentsPerRelay {
+				t.Fatalf("expected %d placements, got %d", relays*agentsPerRelay, len(placements))
+			}
+			for _, placement := range placements {
+				var n int
+				if _, err := fmt.Sscanf(placement.AgentID, "agent-%d", &n); err != nil {
+					t.Fatalf("unexpected agent id %q", placement.AgentID)
+				}
+				if want := fmt.Sprintf("relay-%d", n%relays); placement.RelayID != want {
+					t.Fatalf("expected %s on %s, got %s", placement.AgentID, want, placement.RelayID)
+				}
+			}
+		},
+	},
+}
+
+// watchCases require every backend to state whether it can stream its
+// changes, so a missing watch is an explicit ErrWatchUnsupported rather
+// than silence.
+var watchCases = []testCase{
+	{
+		name: "watch streams writes or reports unsupported",
+		run: func(t *testing.T, b driver.Backend) {
+			watcher, ok := b.(driver.Watcher)
+			if !ok {
+				t.Fatalf("expected %T to implement driver.Watcher", b)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			events, err := watcher.Watch(ctx)
+			if errors.Is(err, driver.ErrWatchUnsupported) {
+				return
+			}
+			if err != nil {
+				t.Fatalf("watch: %v", err)
+			}
+
+			mustRegisterRelay(t, b, driver.Relay{ID: "relay-1", LastSeen: time.Now()})
+
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					t.Fatalf("expected relay event, channel closed")
+				}
+				if ev.Relay.ID != "relay-1" {
+					t.Fatalf("expected event for relay-1, got %+v", ev)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for relay event")
+			}
+
+			cancel()
+			deadline := time.After(5 * time.Second)
+			for {
+				select {
+				case _, ok := <-events:
+					if !ok {
+						return
+					}
+				case <-deadline:
+					t.Fatalf("expected watch channel to close after cancel")
+				}
+			}
+		},
+	},
+}
+
+var ttlCases = []testCase{
+	{
+		name: "expired relays are hidden",
+		run: func(t *testing.T, b driver.Backend) {
+			clock := NewClock()
+			reg := newRegistry(t, b, clock)
+			ctx := context.Background()
+
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "stale", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			clock.Advance(ttl / 2)
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "fresh", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+
+			clock.Advance(ttl/2 + time.Millisecond)
+			relays, err := reg.ListRelays(ctx)
+			if err != nil {
+				t.Fatalf("list relays: %v", err)
+			}
+			assertRelayIDs(t, relays, "fresh")
+		},
+	},
+	{
+		name: "heartbeat keeps relay live",
+		run: func(t *testing.T, b driver.Backend) {
+			clock := NewClock()
+			reg := newRegistry(t, b, clock)
+			ctx := context.Background()
+
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				clock.Advance(ttl - time.Millisecond)
+				if err := reg.HeartbeatRelay(ctx, "relay-1"); err != nil {
+					t.Fatalf("heartbeat relay: %v", err)
+				}
+			}
+
+			relays, err := reg.ListRelays(ctx)
+			if err != nil {
+				t.Fatalf("list relays: %v", err)
+			}
+			assertRelayIDs(t, relays, "relay-1")
+		},
+	},
+	{
+		name: "expired placement is not found",
+		run: func(t *testing.T, b driver.Backend) {
+			clock := NewClock()
+			reg := newRegistry(t, b, clock)
+			ctx := context.Background()
+
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "relay-1", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			if err := reg.RegisterAgent(ctx, driver.Agent{ID: "agent-1", LastHeartbeat: clock.Now()}, "relay-1"); err != nil {
+				t.Fatalf("register agent: %v", err)
+			}
+
+			clock.Advance(ttl)
+			if _, err := reg.GetAgentPlacement(ctx, "agent-1"); err != nil {
+				t.Fatalf("expected placement live at ttl boundary, got %v", err)
+			}
+
+			clock.Advance(time.Millisecond)
+			_, err := reg.GetAgentPlacement(ctx, "agent-1")
+			assertErr(t, err, driver.ErrAgentNotRegistered)
+		},
+	},
+	{
+		name: "sweep evicts and cascades",
+		run: func(t *testing.T, b driver.Backend) {
+			clock := NewClock()
+			reg := newRegistry(t, b, clock)
+			ctx := context.Background()
+
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "dead", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			if err := reg.RegisterAgent(ctx, driver.Agent{ID: "orphan", LastHeartbeat: clock.Now()}, "dead"); err != nil {
+				t.Fatalf("register agent: %v", err)
+			}
+
+			clock.Advance(ttl + time.Millisecond)
+			if err := reg.RegisterRelay(ctx, driver.Relay{ID: "alive", LastSeen: clock.Now()}); err != nil {
+				t.Fatalf("register relay: %v", err)
+			}
+			if err := reg.RegisterAgent(ctx, driver.Agent{ID: "kept", LastHeartbeat: clock.Now()}, "alive"); err != nil {
+				t.Fatalf("register agent: %v", err)
+			}
+
+			if err := reg.Sweep(ctx); err != nil {
+				t.Fatalf("sweep: %v", err)
+			}
+
+			assertRelayIDs(t, mustListRelays(t, b), "alive")
+			placements := mustListPlacements(t, b)
+			if len(placements) != 1 || placements[0].AgentID != "kept" {
+				t.Fatalf("expected only the live agent to remain, got %#v", placements)
+			}
+		},
+	},
+}
+
+// ttl is the relay and agent TTL used by the TTL cases.
+const ttl = 30 * time.Second
+
+func newRegistry(t *testing.T, b driver.Backend, clock *Clock) *registry.Registry {
+	t.Helper()
+
+	// The backend under test is handed to registry.New directly, so the
+	// config carries no backend section.
+	cfg := &driver.Config{TTL: driver.TTLConfig{Relay: ttl, Agent: ttl}}
+	cfg.GRPC.ListenAddress = "127.0.0.1"
+	cfg.GRPC.ListenPort = 50051
+	reg, err := registry.New(cfg, b, registry.WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	return reg
+}
+
+func mustRegisterRelay(t *testing.T, b driver.Backend, relay driver.Relay) {
+	t.Helper()
+
+	if err := b.RegisterRelay(context.Background(), relay); err != nil {
+		t.Fatalf("register relay %s: %v", relay.ID, err)
+	}
+}
+
+func mustRegisterAgent(t *testing.T, b driver.Backend, agent driver.Agent, relayID string) {
+	t.Helper()
+
+	if err := b.RegisterAgent(context.Background(), agent, relayID); err != nil {
+		t.Fatalf("register agent %s: %v", agent.ID, err)
+	}
+}
+
+func mustListRelays(t *testing.T, b driver.Backend) []driver.Relay {
+	t.Helper()
+
+	relays, err := b.ListRelays(context.Background())
+	if err != nil {
+		t.Fatalf("list relays: %v", err)
+	}
+	return relays
+}
+
+func mustGetRelay(t *testing.T, b driver.Backend, relayID string) driver.Relay {
+	t.Helper()
+
+	for _, relay := range mustListRelays(t, b) {
+		if relay.ID == relayID {
+			return relay
+		}
+	}
+	t.Fatalf("relay %s not listed", relayID)
+	return driver.Relay{}
+}
+
+func mustListPlacements(t *testing.T, b driver.Backend) []driver.AgentPlacement {
+	t.Helper()
+
+	placements, err := b.ListPlacements(context.Background())
+	if err != nil {
+		t.Fatalf("list placements: %v", err)
+	}
+	return placements
+}
+
+func mustGetPlacement(t *testing.T, b driver.Backend, agentID string) *driver.AgentPlacement {
+	t.Helper()
+
+	placement, err := b.GetAgentPlacement(context.Background(), agentID)
+	if err != nil {
+		t.Fatalf("get placement %s: %v", agentID, err)
+	}
+	return placement
+}
+
+func assertErr(t *testing.T, got, want error) {
+	t.Helper()
+
+	if !errors.Is(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func assertRelay(t *testing.T, got, want driver.Relay) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Address != want.Address || got.GRPCPort != want.GRPCPort || !got.LastSeen.Equal(want.LastSeen) {
+		t.Fatalf("expected relay %#v, got %#v", want, got)
+	}
+}
+
+func assertPlacement(t *testing.T, got driver.AgentPlacement, want driver.AgentPlacement) {
+	t.Helper()
+
+	if got.AgentID != want.AgentID || got.RelayID != want.RelayID || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Fatalf("expected placement %#v, got %#v", want, got)
+	}
+}
+
+func assertRelayIDs(t *testing.T, relays []driver.Relay, want ...string) {
+	t.Helper()
+
+	got := make([]string, 0, len(relays))
+	for _, relay := range relays {
+		got = append(got, relay.ID)
+	}
+	slices.Sort(got)
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected relays %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,35 @@
+package backendtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a manually advanced clock for tests that control when records
+// expire. Its Now method can be handed to a backend or fake service in place
+// of time.Now. It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock set to the current time.
+func NewClock() *Clock {
+	return &Clock{now: time.Now()}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
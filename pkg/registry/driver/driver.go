@@ -0,0 +1,176 @@
+// Package driver is the registry of backend implementations selectable by
+// name. Backend packages register a Driver from an init function, so linking
+// a backend into the binary, even with a blank import, makes it available
+// to the registry command. Backends maintained outside this module register
+// the same way.
+//
+// Drivers construct and validate backends. The registry command declares
+// the flags of the backends in this module itself; a backend maintained
+// outside it may declare its own through Driver.Flags and Driver.Configure,
+// and can also be configured through the backend.options section of the
+// config file.
+package driver
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/urfave/cli/v3"
+)
+
+// Aliases of the registry types a driver works with, so backends outside
+// this module can implement one.
+type (
+	Name           = registry.RegistryBackend
+	Config         = registry.Config
+	BackendConfig  = registry.BackendConfig
+	Backend        = registry.Backend
+	Relay          = registry.Relay
+	Agent          = registry.Agent
+	AgentPlacement = registry.AgentPlacement
+	TTLConfig      = registry.TTLConfig
+
+	// A backend may implement the optional interfaces below; the registry
+	// checks for them with a type assertion.
+	Watcher   = registry.Watcher
+	TTLSetter = registry.TTLSetter
+	Event     = registry.Event
+	EventType = registry.EventType
+)
+
+const (
+	EventRelayAdded       = registry.EventRelayAdded
+	EventRelayHeartbeat   = registry.EventRelayHeartbeat
+	EventRelayExpired     = registry.EventRelayExpired
+	EventRelayRemoved     = registry.EventRelayRemoved
+	EventPlacementChanged = registry.EventPlacementChanged
+	EventPlacementRemoved = registry.EventPlacementRemoved
+	EventPlacementExpired = registry.EventPlacementExpired
+)
+
+// Errors a backend returns, and the registry tests for with errors.Is.
+var (
+	// ErrUnsupportedBackend is returned for a name no driver is registered
+	// under.
+	ErrUnsupportedBackend = registry.ErrUnsupportedBackend
+
+	ErrRelayNotRegistered = registry.ErrRelayNotRegistered
+	ErrAgentNotRegistered = registry.ErrAgentNotRegistered
+	ErrRelayIDEmpty       = registry.ErrRelayIDEmpty
+	ErrAgentIDEmpty       = registry.ErrAgentIDEmpty
+	ErrCapacityExceeded   = registry.ErrCapacityExceeded
+	ErrWatchUnsupported   = registry.ErrWatchUnsupported
+	ErrTTLRestartRequired = registry.ErrTTLRestartRequired
+)
+
+// Driver describes a backend implementation that can be selected by name.
+type Driver struct {
+	// Name is the value selecting the backend, e.g. through --backend.
+	Name Name
+
+	// Options returns a pointer to a new value of the type stored in
+	// BackendConfig.Options. It is only needed by backends outside this
+	// module, and lets a config file carry their options.
+	Options func() any
+
+	// Flags are added to the registry command whether or not the backend
+	// is selected. Flag names should carry the backend name as a prefix.
+	// Optional, and ignored for the backends in this module, whose flags
+	// the command declares itself.
+	Flags []cli.Flag
+
+	// Configure copies the backend's flags into cfg, allocating
+	// cfg.Options if it is nil. Optional.
+	Configure func(flags FlagValues, cfg *BackendConfig) error
+
+	// Validate checks the backend's section of cfg.
+	Validate func(cfg *BackendConfig) error
+
+	// New constructs the backend from a validated configuration.
+	New func(cfg *Config) (Backend, error)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[Name]Driver)
+)
+
+// Register makes a backend available by d.Name. It panics if the name is
+// empty or already registered, or if Validate or New is nil, since those
+// are programming errors caught at startup.
+func Register(d Driver) {
+	if d.Name == "" {
+		panic("driver: Register with empty name")
+	}
+	if d.Validate == nil || d.New == nil {
+		panic(fmt.Sprintf("driver: Register %q is missing Validate or New", d.Name))
+	}
+
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, ok := drivers[d.Name]; ok {
+		panic(fmt.Sprintf("driver: Register called twice for %q", d.Name))
+	}
+	drivers[d.Name] = d
+}
+
+// Lookup returns the driver registered under name.
+func Lookup(name Name) (Driver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	d, ok := drivers[name]
+	return d, ok
+}
+
+// Names returns the names of the registered backends in sorted order.
+func Names() []Name {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]Name, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Parse returns the backend name for s, failing with ErrUnsupportedBackend
+// when no driver is registered under it.
+func Parse(s string) (Name, error) {
+	if _, ok := Lookup(Name(s)); ok {
+		return Name(s), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedBackend, s)
+}
+
+// Validate checks cfg in full: the backend section with the driver of
+// cfg.Backend.Type, then the rest with Config.Validate.
+func Validate(cfg *Config) error {
+	d, ok := Lookup(cfg.Backend.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedBackend, cfg.Backend.Type)
+	}
+
+	if err := d.Validate(&cfg.Backend); err != nil {
+		return fmt.Errorf("%s config invalid: %w", cfg.Backend.Type, err)
+	}
+
+	return cfg.Validate()
+}
+
+// New constructs the backend selected by cfg.Backend.Type.
+func New(cfg *Config) (Backend, error) {
+	d, ok := Lookup(cfg.Backend.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedBackend, cfg.Backend.Type)
+	}
+
+	return d.New(cfg)
+}
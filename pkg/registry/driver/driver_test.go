@@ -0,0 +1,389 @@
+package driver_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/internal/registry"
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+
+	// Link the built-in backends so their drivers are registered for the
+	// tests in this package.
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/consul"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/etcd"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/file"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/memory"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/postgres"
+	_ "github.com/Aero-Arc/aero-arc-registry/internal/registry/backend/redis"
+)
+
+type customOptions struct {
+	Capacity int `yaml:"capacity" toml:"capacity"`
+}
+
+var errCapacityInvalid = errors.New("capacity must be > 0")
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	const name driver.Name = "driver-test"
+
+	driver.Register(driver.Driver{
+		Name: name,
+		Options: func() any {
+			return &customOptions{}
+		},
+		Validate: func(cfg *driver.BackendConfig) error {
+			opts, ok := cfg.Options.(*customOptions)
+			if !ok || opts.Capacity <= 0 {
+				return errCapacityInvalid
+			}
+			return nil
+		},
+		New: func(cfg *driver.Config) (driver.Backend, error) {
+			return nil, registry.ErrNotImplemented
+		},
+	})
+
+	if got, err := driver.Parse(string(name)); err != nil || got != name {
+		t.Fatalf("expected %q, got %q (%v)", name, got, err)
+	}
+
+	if !slices.Contains(driver.Names(), name) {
+		t.Fatalf("expected %q in %v", name, driver.Names())
+	}
+
+	cfg := &driver.Config{
+		Backend: driver.BackendConfig{Type: name, Options: &customOptions{}},
+		GRPC:    registry.GRPCConfig{ListenPort: 50051},
+		TTL:     registry.TTLConfig{Relay: 1, Agent: 1},
+	}
+	if err := driver.Validate(cfg); !errors.Is(err, errCapacityInvalid) {
+		t.Fatalf("expected errCapacityInvalid, got %v", err)
+	}
+
+	cfg.Backend.Options = &customOptions{Capacity: 1}
+	if err := driver.Validate(cfg); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	cfg.GRPC.ListenPort = 0
+	if err := driver.Validate(cfg); !errors.Is(err, registry.ErrGRPCPortInvalid) {
+		t.Fatalf("expected the rest of the config to be validated, got %v", err)
+	}
+
+	if _, err := driver.New(cfg); !errors.Is(err, registry.ErrNotImplemented) {
+		t.Fatalf("expected driver constructor to be used, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    driver.Name
+		wantErr error
+	}{
+		{
+			name:  "redis backend",
+			input: "redis",
+			want:  registry.RedisRegistryBackend,
+		},
+		{
+			name:  "etcd backend",
+			input: "etcd",
+			want:  registry.EtcdRegistryBackend,
+		},
+		{
+			name:  "consul backend",
+			input: "consul",
+			want:  registry.ConsulRegistryBackend,
+		},
+		{
+			name:  "memory backend",
+			input: "memory",
+			want:  registry.MemoryRegistryBackend,
+		},
+		{
+			name:  "file backend",
+			input: "file",
+			want:  registry.FileRegistryBackend,
+		},
+		{
+			name:  "postgres backend",
+			input: "postgres",
+			want:  registry.PostgresRegistryBackend,
+		},
+		{
+			name:    "unsupported backend",
+			input:   "unknown",
+			want:    "",
+			wantErr: driver.ErrUnsupportedBackend,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := driver.Parse(test.input)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if got != test.want {
+				t.Fatalf("expected backend %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	validGRPC := registry.GRPCConfig{
+		ListenAddress: "127.0.0.1",
+		ListenPort:    50051,
+	}
+	validTTL := registry.TTLConfig{
+		Relay: 5 * time.Second,
+		Agent: 10 * time.Second,
+	}
+	validRedis := &registry.RedisConfig{
+		Address:  "localhost",
+		Port:     6379,
+		Username: "user",
+		Password: "pass",
+		DB:       0,
+	}
+
+	tests := []struct {
+		name    string
+		config  registry.Config
+		wantErr error
+	}{
+		{
+			name: "unsupported backend",
+			config: registry.Config{
+				Backend: registry.BackendConfig{Type: "unknown"},
+				GRPC:    validGRPC,
+				TTL:     validTTL,
+			},
+			wantErr: driver.ErrUnsupportedBackend,
+		},
+		{
+			name: "memory backend with invalid capacity",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type:   registry.MemoryRegistryBackend,
+					Memory: &registry.MemoryConfig{MaxRelays: -1},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrMemoryMaxRelaysInvalid,
+		},
+		{
+			name: "redis backend with valid redis config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type:  registry.RedisRegistryBackend,
+					Redis: validRedis,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "redis backend with nil redis config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type:  registry.RedisRegistryBackend,
+					Redis: nil,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrRedisConfigNil,
+		},
+		{
+			name: "etcd backend with valid etcd config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.EtcdRegistryBackend,
+					Etcd: &registry.EtcdConfig{Endpoints: []string{"localhost:2379"}},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "etcd backend with nil etcd config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.EtcdRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrEtcdConfigNil,
+		},
+		{
+			name: "consul backend with valid consul config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type:   registry.ConsulRegistryBackend,
+					Consul: &registry.ConsulConfig{Address: "localhost:8500"},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "consul backend with nil consul config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.ConsulRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrConsulConfigNil,
+		},
+		{
+			name: "file backend with valid file config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.FileRegistryBackend,
+					File: &registry.FileConfig{Path: "/var/lib/aero-arc/registry.db"},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "file backend without a path",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.FileRegistryBackend,
+					File: &registry.FileConfig{},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrFilePathEmpty,
+		},
+		{
+			name: "file backend with negative compact interval",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.FileRegistryBackend,
+					File: &registry.FileConfig{Path: "registry.db", CompactInterval: -time.Second},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrFileCompactIntervalInvalid,
+		},
+		{
+			name: "postgres backend with valid postgres config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type:     registry.PostgresRegistryBackend,
+					Postgres: &registry.PostgresConfig{Address: "localhost:5432", User: "registry"},
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "postgres backend with nil postgres config",
+			config: registry.Config{
+				Backend: registry.BackendConfig{
+					Type: registry.PostgresRegistryBackend,
+				},
+				GRPC: validGRPC,
+				TTL:  validTTL,
+			},
+			wantErr: registry.ErrPostgresConfigNil,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := driver.Validate(&test.config)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigFileBackendOptions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "registry.toml")
+	contents := "[backend]\ntype = \"options-test\"\n\n[backend.options]\ncapacity = 3\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+
+	file, err := registry.ReadConfigFile(path)
+	if err != nil {
+		t.Fatalf("read config file: %v", err)
+	}
+
+	var cfg driver.Config
+	if err := file.Apply(&cfg); !errors.Is(err, registry.ErrConfigFileInvalid) {
+		t.Fatalf("expected options without an options value to be rejected, got %v", err)
+	}
+
+	cfg.Backend.Options = &customOptions{}
+	if err := file.Apply(&cfg); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	opts, ok := cfg.Backend.Options.(*customOptions)
+	if !ok || opts.Capacity != 3 {
+		t.Fatalf("expected decoded options, got %#v", cfg.Backend.Options)
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate registration to panic")
+		}
+	}()
+
+	driver.Register(driver.Driver{
+		Name:     registry.MemoryRegistryBackend,
+		Validate: func(*driver.BackendConfig) error { return nil },
+		New:      func(*driver.Config) (driver.Backend, error) { return nil, nil },
+	})
+}
+
+func TestNewUnsupported(t *testing.T) {
+	t.Parallel()
+
+	cfg := &driver.Config{Backend: driver.BackendConfig{Type: "unknown"}}
+	if _, err := driver.New(cfg); !errors.Is(err, driver.ErrUnsupportedBackend) {
+		t.Fatalf("expected ErrUnsupportedBackend, got %v", err)
+	}
+}
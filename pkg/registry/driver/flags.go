@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// FlagValues copies parsed flag values into configuration fields. The
+// registry command passes one to Driver.Configure twice: first from
+// AllFlags, before the config file is applied, then from SetFlags, so
+// flags given explicitly take precedence over the file.
+type FlagValues struct {
+	cmd     *cli.Command
+	setOnly bool
+}
+
+// AllFlags returns FlagValues that copy every flag, falling back to flag
+// defaults for those the user did not set.
+func AllFlags(cmd *cli.Command) FlagValues {
+	return FlagValues{cmd: cmd}
+}
+
+// SetFlags returns FlagValues that copy only flags the user set, on the
+// command line or through the environment, leaving other fields untouched.
+func SetFlags(cmd *cli.Command) FlagValues {
+	return FlagValues{cmd: cmd, setOnly: true}
+}
+
+// Command returns the command the flags were parsed by.
+func (v FlagValues) Command() *cli.Command {
+	return v.cmd
+}
+
+// Copies reports whether the flag name is copied into configuration.
+func (v FlagValues) Copies(name string) bool {
+	return !v.setOnly || v.cmd.IsSet(name)
+}
+
+func (v FlagValues) String(name string, dst *string) {
+	if v.Copies(name) {
+		*dst = v.cmd.String(name)
+	}
+}
+
+func (v FlagValues) Int(name string, dst *int) {
+	if v.Copies(name) {
+		*dst = v.cmd.Int(name)
+	}
+}
+
+func (v FlagValues) Bool(name string, dst *bool) {
+	if v.Copies(name) {
+		*dst = v.cmd.Bool(name)
+	}
+}
+
+func (v FlagValues) Duration(name string, dst *time.Duration) {
+	if v.Copies(name) {
+		*dst = v.cmd.Duration(name)
+	}
+}
+
+func (v FlagValues) StringSlice(name string, dst *[]string) {
+	if v.Copies(name) {
+		*dst = v.cmd.StringSlice(name)
+	}
+}
@@ -0,0 +1,65 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Aero-Arc/aero-arc-registry/pkg/registry/driver"
+	"github.com/urfave/cli/v3"
+)
+
+type flagOptions struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func TestFlagValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values func(cmd *cli.Command) driver.FlagValues
+		want   flagOptions
+	}{
+		{
+			name:   "all flags",
+			values: driver.AllFlags,
+			want:   flagOptions{Addr: "from-flag", Timeout: 5 * time.Second},
+		},
+		{
+			name:   "set flags",
+			values: driver.SetFlags,
+			want:   flagOptions{Addr: "from-flag", Timeout: time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := flagOptions{Addr: "from-file", Timeout: time.Minute}
+			cmd := &cli.Command{
+				Name: "registry",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "custom-addr"},
+					&cli.DurationFlag{Name: "custom-timeout", Value: 5 * time.Second},
+				},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					values := test.values(cmd)
+					values.String("custom-addr", &got.Addr)
+					values.Duration("custom-timeout", &got.Timeout)
+					return nil
+				},
+			}
+
+			if err := cmd.Run(context.Background(), []string{"registry", "--custom-addr", "from-flag"}); err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if got != test.want {
+				t.Fatalf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}